@@ -14,7 +14,8 @@ var (
 func main() {
 	// Set version information for CLI
 	// This will be set during build time via ldflags
+	cli.SetVersionInfo(version, buildDate)
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
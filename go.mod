@@ -3,11 +3,15 @@ module github.com/mosaan/mdatlas
 go 1.22.2
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/spf13/cobra v1.9.1
 	github.com/yuin/goldmark v1.7.12
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
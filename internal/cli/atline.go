@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	atLine       int
+	atLineFormat string
+)
+
+// atLineCmd represents the at-line command
+var atLineCmd = &cobra.Command{
+	Use:   "at-line [file|-]",
+	Short: "Print the section containing a line of a Markdown file",
+	Long: `Print the innermost section whose line range contains the line given with
+--line, e.g. the section under an editor's cursor, with the titles of its
+ancestors as a breadcrumb. A line before the first heading is reported with
+in_preamble set, and the preamble's section ID if it has text.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if atLineFormat != "json" && atLineFormat != "yaml" {
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, atLineFormat)
+		}
+
+		content, absPath, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+
+		located, err := core.NewStructureManagerWithParser(nil, parser).BuildSectionAtLine(absPath, structure, atLine)
+		if err != nil {
+			return err
+		}
+		return writeOutput(func(w io.Writer) error {
+			return encodeOutput(w, located, atLineFormat)
+		})
+	},
+}
+
+func init() {
+	atLineCmd.Flags().IntVar(&atLine, "line", 0, "Line number to locate (1-based)")
+	atLineCmd.Flags().StringVar(&atLineFormat, "format", "json", "Output format (json, yaml)")
+	atLineCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+	atLineCmd.MarkFlagRequired("line")
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	depthFormat string
+)
+
+// depthCmd represents the depth command
+var depthCmd = &cobra.Command{
+	Use:   "depth [file|-]",
+	Short: "Print the deepest heading level of a Markdown file",
+	Long: `Print the deepest heading level of a Markdown file and its number of
+sections per level as JSON or YAML, without the structure itself.
+max_level is 0 for a file without headings.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if depthFormat != "json" && depthFormat != "yaml" {
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, depthFormat)
+		}
+
+		content, absPath, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+
+		depth := core.NewStructureManagerWithParser(nil, parser).BuildOutlineDepth(absPath, structure)
+		return writeOutput(func(w io.Writer) error {
+			return encodeOutput(w, depth, depthFormat)
+		})
+	},
+}
+
+func init() {
+	depthCmd.Flags().StringVar(&depthFormat, "format", "json", "Output format (json, yaml)")
+	depthCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/mosaan/mdatlas/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare the heading structure of two Markdown files",
+	Long: `Compare the heading structure of two Markdown files and print the
+difference as JSON, keyed by heading path. "added" and "removed" list
+sections found in only one file, and "changed" lists sections that moved
+to another path, changed heading level, or whose own text (excluding
+subsections) changed length significantly.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		oldStructure, err := parseFile(parser, args[0])
+		if err != nil {
+			return err
+		}
+
+		newStructure, err := parseFile(parser, args[1])
+		if err != nil {
+			return err
+		}
+
+		return encodeOutput(stdout(), core.DiffStructures(oldStructure, newStructure), "json")
+	},
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output")
+}
+
+// parseFile parses the structure of a file argument, reporting it as given
+func parseFile(parser *core.Parser, filePath string) (*types.DocumentStructure, error) {
+	content, _, err := readInput([]string{filePath})
+	if err != nil {
+		return nil, err
+	}
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse structure of %s: %w", filePath, err)
+	}
+
+	structure.FilePath = filePath
+	return structure, nil
+}
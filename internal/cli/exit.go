@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/mosaan/mdatlas/internal/core"
+)
+
+// Exit codes returned by ExitCode
+const (
+	ExitOK           = 0
+	ExitError        = 1 // Any other failure, including usage errors
+	ExitValidation   = 2 // The input was read but failed a check, e.g. lint issues
+	ExitAccessDenied = 3 // A file could not be read because access was refused
+	ExitNotFound     = 4 // The file or section asked for does not exist
+	ExitUnsupported  = 5 // The output format asked for is not offered
+)
+
+// ValidationError reports that a command ran to completion but the document
+// failed its check, as opposed to the command itself failing
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ExitCode returns the process exit status for an error returned by Execute
+func ExitCode(err error) int {
+	var validationErr *ValidationError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &validationErr):
+		return ExitValidation
+	case isAccessDenied(err):
+		return ExitAccessDenied
+	case errors.Is(err, core.ErrFileNotFound), errors.Is(err, core.ErrSectionNotFound):
+		return ExitNotFound
+	case errors.Is(err, core.ErrUnsupportedFormat):
+		return ExitUnsupported
+	default:
+		return ExitError
+	}
+}
+
+// isAccessDenied reports whether err was caused by the operating system or
+// access control refusing a file
+func isAccessDenied(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, core.ErrAccessDenied)
+}
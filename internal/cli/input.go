@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// stdinPath is the file argument and display path used for standard input
+const (
+	stdinArg  = "-"
+	stdinPath = "<stdin>"
+)
+
+// fileOrStdinArg accepts exactly one file argument, or none when Markdown is
+// piped through standard input
+func fileOrStdinArg(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 || (len(args) == 0 && stdinIsPiped()) {
+		return nil
+	}
+	return fmt.Errorf("accepts 1 arg(s), received %d", len(args))
+}
+
+// stdinIsPiped reports whether standard input is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice == 0
+}
+
+// isStdinInput reports whether the command should read from standard input
+func isStdinInput(args []string) bool {
+	return len(args) == 0 || args[0] == stdinArg
+}
+
+// resolveFilePath resolves a file argument relative to the base directory.
+// Unless --no-sandbox is set, the path is checked as the MCP server checks
+// it: it must lie within the base directory, have an allowed extension and be
+// no larger than --max-file-size.
+func resolveFilePath(filePath string) (string, error) {
+	if !noSandbox {
+		accessControl, err := newAccessControl()
+		if err != nil {
+			return "", err
+		}
+		return accessControl.ValidatePath(filePath)
+	}
+
+	absPath := filePath
+	if !filepath.IsAbs(filePath) {
+		absPath = filepath.Join(baseDir, filePath)
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", core.ErrFileNotFound, filePath)
+	}
+	return absPath, nil
+}
+
+// readInput reads Markdown content from the file argument or standard input,
+// without a UTF-8 byte order mark and, with --normalize-eol, with its line
+// endings normalized to LF. With --follow-includes its include directives
+// are expanded. It returns the content and the path to report in output.
+func readInput(args []string) ([]byte, string, error) {
+	content, displayPath, _, err := readExpandedInput(args)
+	return content, displayPath, err
+}
+
+// readExpandedInput is readInput, also returning the expanded document with
+// --follow-includes, or nil without it
+func readExpandedInput(args []string) ([]byte, string, *core.ExpandedDocument, error) {
+	if isStdinInput(args) {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		content, err = decodeInput(content)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return expandIncludes(content, stdinPath, "")
+	}
+
+	absPath, err := resolveFilePath(args[0])
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// Read file content
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	content, err = decodeInput(content)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return expandIncludes(content, absPath, absPath)
+}
+
+// expandIncludes applies --follow-includes to content, read from filePath
+// ("" for standard input, whose includes resolve against the base
+// directory). Included files must pass the checks of the base directory,
+// or with --no-sandbox lie within the including document's directory.
+func expandIncludes(content []byte, displayPath, filePath string) ([]byte, string, *core.ExpandedDocument, error) {
+	if !followIncludes {
+		return content, displayPath, nil, nil
+	}
+
+	var accessControl *core.AccessControl
+	var err error
+	if noSandbox && filePath != "" {
+		accessControl, err = core.NewAccessControl(filepath.Dir(filePath))
+	} else {
+		accessControl, err = newAccessControl()
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	expanded, err := core.NewIncludeResolver(accessControl, 0).Expand(filePath, content)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to expand includes: %w", err)
+	}
+	return expanded.Content, displayPath, expanded, nil
+}
+
+// decodeInput removes a UTF-8 byte order mark from content and applies
+// --normalize-eol, as the parser does
+func decodeInput(content []byte) ([]byte, error) {
+	content, err := core.DecodeContent(content)
+	if err != nil || !normalizeEOL {
+		return content, err
+	}
+	return core.NormalizeLineEndings(content), nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	startLine int
+	endLine   int
+)
+
+// linesCmd represents the lines command
+var linesCmd = &cobra.Command{
+	Use:   "lines [file|-]",
+	Short: "Print a range of lines from a Markdown file",
+	Long: `Print a range of lines from a Markdown file, independent of its sections.
+The output starts with a header comment reporting the actual range returned,
+since an end line past the end of the file is clamped to the last line.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if startLine < 1 {
+			return fmt.Errorf("--start must be 1 or more, got %d", startLine)
+		}
+		if endLine < 0 {
+			return fmt.Errorf("--end must be 0 or more, got %d", endLine)
+		}
+		if endLine > 0 && startLine > endLine {
+			return fmt.Errorf("invalid line range: --start %d is after --end %d", startLine, endLine)
+		}
+
+		content, _, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		lineRange, err := core.SliceLines(content, startLine, endLine)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdout(), lineRange.Header())
+		fmt.Fprintln(stdout(), strings.Join(lineRange.Lines, "\n"))
+		return nil
+	},
+}
+
+func init() {
+	linesCmd.Flags().IntVar(&startLine, "start", 1, "First line to print (1-based)")
+	linesCmd.Flags().IntVar(&endLine, "end", 0, "Last line to print, inclusive (0 for end of file)")
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFormat string
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [file|-]",
+	Short: "Report problems in the heading structure of a Markdown file",
+	Long: `Report all problems in the heading structure of a Markdown file: headings
+that skip a level below their parent (H1 then H3), empty headings, and sibling
+headings with the same title. Plain output prints one issue per line as
+"<file>:<line>: <kind>: <message>". The command exits with status 2 when any
+issue is found.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, displayPath, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+
+		issues := core.LintSections(structure.Structure)
+
+		switch lintFormat {
+		case "json":
+			if err := encodeOutput(stdout(), map[string]interface{}{
+				"file_path": displayPath,
+				"issues":    issues,
+				"count":     len(issues),
+			}, "json"); err != nil {
+				return err
+			}
+		case "plain":
+			for _, issue := range issues {
+				fmt.Fprintf(stdout(), "%s:%d: %s: %s\n", displayPath, issue.Line, issue.Kind, issue.Message)
+			}
+		default:
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, lintFormat)
+		}
+
+		if len(issues) > 0 {
+			// Issues are the command's result, not a usage error
+			cmd.SilenceUsage = true
+			return &ValidationError{Message: fmt.Sprintf("%d issue(s) found", len(issues))}
+		}
+		return nil
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", "plain", "Output format (plain, json)")
+	lintCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+// encodeOutput writes v to w as JSON, honoring --pretty, or as YAML
+func encodeOutput(w io.Writer, v interface{}, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		if pretty {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(v)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, format)
+	}
+}
+
+// stdout returns the writer for normal command output, which discards it
+// with --quiet
+func stdout() io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// writeOutput passes write the destination selected by --output: standard
+// output when it is unset or "-", and otherwise the named file
+func writeOutput(write func(w io.Writer) error) error {
+	if outputPath == "" || outputPath == "-" {
+		return write(stdout())
+	}
+	return writeFileAtomic(outputPath, write)
+}
+
+// outputWriter returns the destination selected by --output for commands
+// that write their output more than once, such as structure --watch. Each
+// Write to an output file replaces its content.
+func outputWriter() io.Writer {
+	if outputPath == "" || outputPath == "-" {
+		return stdout()
+	}
+	return replacingFile(outputPath)
+}
+
+// replacingFile is a writer that replaces the content of the named file with
+// each Write
+type replacingFile string
+
+func (f replacingFile) Write(p []byte) (int, error) {
+	err := writeFileAtomic(string(f), func(w io.Writer) error {
+		_, err := w.Write(p)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFileAtomic writes to a temporary file next to filePath and renames it
+// over filePath once write succeeds, so a failed write leaves an existing
+// file untouched instead of truncated
+func writeFileAtomic(filePath string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Keep the permissions of a file being replaced
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(filePath); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
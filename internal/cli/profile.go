@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var (
+	cpuProfile string
+	memProfile string
+
+	// stopCPUProfile stops the CPU profile started by startProfiling, if any
+	stopCPUProfile func() error
+)
+
+// startProfiling starts writing a CPU profile to --cpuprofile, if it is set
+func startProfiling() error {
+	if cpuProfile == "" {
+		return nil
+	}
+
+	file, err := os.Create(cpuProfile)
+	if err != nil {
+		return fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+
+	stopCPUProfile = func() error {
+		pprof.StopCPUProfile()
+		return file.Close()
+	}
+	return nil
+}
+
+// stopProfiling finishes the CPU profile and writes a heap profile to
+// --memprofile, if they are set
+func stopProfiling() error {
+	if stopCPUProfile != nil {
+		err := stopCPUProfile()
+		stopCPUProfile = nil
+		if err != nil {
+			return fmt.Errorf("failed to write CPU profile: %w", err)
+		}
+	}
+
+	if memProfile == "" {
+		return nil
+	}
+
+	file, err := os.Create(memProfile)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %w", err)
+	}
+	defer file.Close()
+
+	// Collect garbage so the profile shows the memory still in use
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+	return nil
+}
@@ -3,16 +3,46 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
 
+	"github.com/mosaan/mdatlas/internal/core"
 	"github.com/mosaan/mdatlas/internal/mcp"
 	"github.com/spf13/cobra"
 )
 
 var (
-	baseDir   string
-	mcpServer bool
-	version   string = "dev"
-	buildDate string = "unknown"
+	baseDir           string
+	mcpServer         bool
+	noSandbox         bool
+	idStyle           string
+	idPrefix          string
+	allowedExts       []string
+	maxFileSize       string
+	excludes          []string
+	gitignore         bool
+	mcpFraming        string
+	mcpMaxMessageSize string
+	requestTimeout    time.Duration
+	logLevel          string
+	cacheDir          string
+	noCache           bool
+	cacheSize         int
+	cacheTTL          time.Duration
+	concurrency       int
+	fastStructure     bool
+	ignoreLevels      []int
+	normalizeEOL      bool
+	followIncludes    bool
+	outputPath        string
+	quiet             bool
+	version           string = "dev"
+	buildDate         string = "unknown"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -24,6 +54,9 @@ allowing AI models to selectively retrieve specific sections without loading ent
 
 By default, mdatlas runs as an MCP server using STDIO for communication.
 Use the subcommands for CLI-based operations.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return startProfiling()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if mcpServer {
 			return runMCPServer(baseDir)
@@ -34,30 +67,269 @@ Use the subcommands for CLI-based operations.`,
 	},
 }
 
+// SetVersionInfo sets the version and build date reported by the version
+// command and the MCP server. main passes the values set by ldflags, so both
+// always describe the same binary.
+func SetVersionInfo(v, date string) {
+	version = v
+	buildDate = date
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	if profileErr := stopProfiling(); err == nil {
+		err = profileErr
+	}
+	return err
 }
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&baseDir, "base-dir", ".", "Base directory for file access")
+	rootCmd.PersistentFlags().StringVar(&baseDir, "base-dir", ".", "Base directory for file access; CLI commands, like the MCP server, only read files within it")
+	rootCmd.PersistentFlags().BoolVar(&noSandbox, "no-sandbox", false, "Let CLI commands read any file, ignoring the base directory bounds, --allowed-exts and --max-file-size (relative paths still resolve against --base-dir)")
 	rootCmd.PersistentFlags().BoolVar(&mcpServer, "mcp-server", false, "Run as MCP server (STDIO mode)")
+	rootCmd.PersistentFlags().StringVar(&idStyle, "id-style", core.IDStyleHash, "Section ID style (hash, slug)")
+	rootCmd.PersistentFlags().StringVar(&idPrefix, "id-prefix", core.DefaultIDPrefix, "Prefix of hash section IDs (letters, digits, '_' and '-'); the preamble stays section_preamble")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedExts, "allowed-exts", core.DefaultAllowedExts, "Comma-separated file extensions the MCP server and CLI commands may read")
+	rootCmd.PersistentFlags().StringVar(&maxFileSize, "max-file-size", "50MB", "Largest file the MCP server and CLI commands may read, in bytes or with a KB, MB or GB suffix")
+	rootCmd.PersistentFlags().StringArrayVar(&excludes, "exclude", nil, "Glob of paths relative to the base directory to leave out of resource listings, e.g. node_modules or **/build (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&gitignore, "gitignore", false, "Also leave out paths ignored by the base directory's .gitignore")
+	rootCmd.PersistentFlags().StringVar(&mcpFraming, "mcp-framing", mcp.FramingStream, "MCP message framing (stream, ndjson, header)")
+	rootCmd.PersistentFlags().StringVar(&mcpMaxMessageSize, "mcp-max-message-size", "1MB", "Largest MCP request accepted with ndjson or header framing, in bytes or with a KB, MB or GB suffix")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 0, "Answer MCP requests that run longer than this, e.g. 30s, with a timeout error (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "MCP server log level written to stderr (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory for a structure cache shared between runs (used by structure and the MCP server)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Disable structure and content caching so every request rereads and reparses the document")
+	rootCmd.PersistentFlags().IntVar(&cacheSize, "cache-size", core.DefaultCacheSize, "Number of parsed structures the MCP server keeps in memory")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", core.DefaultCacheTTL, "How long the MCP server keeps an unused parsed structure in memory, e.g. 10m")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", core.DefaultConcurrency, "Number of files the MCP server parses at once for get_directory_structure")
+	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Write the output of structure, section, sections, stats, depth, toc and schema to this file instead of stdout (\"-\" for stdout)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress normal output on stdout and report the result through the exit status alone")
+	rootCmd.PersistentFlags().BoolVar(&fastStructure, "fast-structure", false, "Scan files of 4MB or more line by line for ATX headings instead of fully parsing them; titles keep inline markup")
+	rootCmd.PersistentFlags().IntSliceVar(&ignoreLevels, "ignore-levels", nil, "Comma-separated heading levels that do not start sections, e.g. 5,6; their lines stay in the preceding section")
+	rootCmd.PersistentFlags().BoolVar(&followIncludes, "follow-includes", false, fmt.Sprintf("Inline files referenced by {%% include \"file.md\" %%} or ![[file.md]] lines before parsing, up to %d levels deep; sections from included files get a source_file (CLI commands only, not --mcp-server)", core.DefaultMaxIncludeDepth))
+	rootCmd.PersistentFlags().BoolVar(&normalizeEOL, "normalize-eol", true, "Convert CRLF and lone CR line endings to LF before parsing, so lines are counted the same in every file; character counts then refer to the normalized content")
+
+	// Profiling flags for investigating performance, left out of the help
+	rootCmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "Write a pprof CPU profile of the command to this file")
+	rootCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "Write a pprof heap profile to this file after the command")
+	rootCmd.PersistentFlags().MarkHidden("cpuprofile")
+	rootCmd.PersistentFlags().MarkHidden("memprofile")
 
 	// Add subcommands
 	rootCmd.AddCommand(structureCmd)
 	rootCmd.AddCommand(sectionCmd)
+	rootCmd.AddCommand(sectionsCmd)
+	rootCmd.AddCommand(tocCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(depthCmd)
+	rootCmd.AddCommand(atLineCmd)
+	rootCmd.AddCommand(linesCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(schemaCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// newParser creates a parser configured from the global flags
+func newParser() (*core.Parser, error) {
+	switch idStyle {
+	case core.IDStyleHash, core.IDStyleSlug:
+	default:
+		return nil, fmt.Errorf("unsupported id style: %s", idStyle)
+	}
+
+	if err := validateIgnoreLevels(); err != nil {
+		return nil, err
+	}
+	if err := validateIDPrefix(); err != nil {
+		return nil, err
+	}
+
+	return core.NewParserWithOptions(core.ParserOptions{
+		IDStyle:            idStyle,
+		IDPrefix:           idPrefix,
+		ExcludeFrontMatter: excludeFrontMatter,
+		WordCountMode:      wordCountMode,
+		WarnSkippedLevels:  warnSkippedLevels,
+		FastStructure:      fastStructure,
+		IgnoreLevels:       ignoreLevels,
+		NoCounts:           noCounts,
+		KeepLineEndings:    !normalizeEOL,
+	}), nil
+}
+
+// validateIDPrefix checks that --id-prefix keeps section IDs usable in
+// resource URIs and as anchors
+func validateIDPrefix() error {
+	if idPrefix == "" {
+		return fmt.Errorf("invalid id prefix: must not be empty")
+	}
+	for _, r := range idPrefix {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return fmt.Errorf("invalid id prefix %q: may only hold letters, digits, '_' and '-'", idPrefix)
+		}
+	}
+	return nil
+}
+
+// validateIgnoreLevels checks that every --ignore-levels value is a heading
+// level
+func validateIgnoreLevels() error {
+	for _, level := range ignoreLevels {
+		if level < 1 || level > 6 {
+			return fmt.Errorf("invalid ignore level %d: must be from 1 to 6", level)
+		}
+	}
+	return nil
+}
+
+// parseFileSize parses a size given in bytes or with a B, KB, MB or GB suffix
+// (powers of 1024, case-insensitive), e.g. "1048576" or "100MB"
+func parseFileSize(value string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file size %q: use bytes or a KB, MB or GB suffix, e.g. 100MB", value)
+	}
+
+	bytes := int64(number * float64(multiplier))
+	if bytes <= 0 {
+		return 0, fmt.Errorf("invalid file size %q: must be positive", value)
+	}
+
+	return bytes, nil
+}
+
+// newDiskCache creates the disk cache selected by --cache-dir, or nil when
+// there is none or --no-cache is set
+func newDiskCache() (*core.DiskCache, error) {
+	if cacheDir == "" || noCache {
+		return nil, nil
+	}
+	return core.NewDiskCache(cacheDir)
+}
+
+// newAccessControl creates the access control configured by --base-dir,
+// --allowed-exts and --max-file-size
+func newAccessControl() (*core.AccessControl, error) {
+	fileSizeLimit, err := parseFileSize(maxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	accessControl, err := core.NewAccessControl(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	config := accessControl.GetConfig()
+	config.AllowedExts = allowedExts
+	config.MaxFileSize = fileSizeLimit
+	if err := accessControl.UpdateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid access configuration: %w", err)
+	}
+	return accessControl, nil
+}
+
 // runMCPServer starts the MCP server
 func runMCPServer(baseDir string) error {
-	server, err := mcp.NewServer(baseDir)
+	fileSizeLimit, err := parseFileSize(maxFileSize)
+	if err != nil {
+		return err
+	}
+
+	if !mcp.IsValidFraming(mcpFraming) {
+		return fmt.Errorf("unsupported MCP framing: %s", mcpFraming)
+	}
+
+	maxMessageSize, err := parseFileSize(mcpMaxMessageSize)
+	if err != nil {
+		return err
+	}
+
+	if _, err := mcp.ParseLogLevel(logLevel); err != nil {
+		return err
+	}
+
+	if cacheSize <= 0 {
+		return fmt.Errorf("cache size must be positive, got %d", cacheSize)
+	}
+	if cacheTTL <= 0 {
+		return fmt.Errorf("cache TTL must be positive, got %v", cacheTTL)
+	}
+	if concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", concurrency)
+	}
+
+	if err := validateIgnoreLevels(); err != nil {
+		return err
+	}
+	if err := validateIDPrefix(); err != nil {
+		return err
+	}
+	// Tools and resources parse files as stored, so includes would be
+	// silently left unexpanded
+	if followIncludes {
+		return fmt.Errorf("--follow-includes applies to CLI commands only and cannot be used with --mcp-server")
+	}
+
+	server, err := mcp.NewServerWithOptions(baseDir, mcp.ServerOptions{
+		Framing:         mcpFraming,
+		MaxMessageSize:  int(maxMessageSize),
+		LogLevel:        logLevel,
+		CacheDir:        cacheDir,
+		NoCache:         noCache,
+		CacheSize:       cacheSize,
+		CacheTTL:        cacheTTL,
+		FastStructure:   fastStructure,
+		IgnoreLevels:    ignoreLevels,
+		IDPrefix:        idPrefix,
+		KeepLineEndings: !normalizeEOL,
+		Concurrency:     concurrency,
+		RequestTimeout:  requestTimeout,
+		Version:         version,
+		BuildDate:       buildDate,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create MCP server: %w", err)
 	}
 
-	return server.Run(context.Background())
+	config := server.AccessConfig()
+	config.AllowedExts = allowedExts
+	config.MaxFileSize = fileSizeLimit
+	config.ExcludePatterns = excludes
+	config.UseGitignore = gitignore
+	if err := server.UpdateAccessConfig(config); err != nil {
+		return fmt.Errorf("invalid access configuration: %w", err)
+	}
+
+	// Cancel on SIGINT and SIGTERM so the request being handled is answered
+	// in full before the server exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return server.Run(ctx)
 }
 
 // versionCmd represents the version command
@@ -65,7 +337,7 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version information",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("mdatlas version %s\n", version)
-		fmt.Printf("Build date: %s\n", buildDate)
+		fmt.Fprintf(stdout(), "mdatlas version %s\n", version)
+		fmt.Fprintf(stdout(), "Build date: %s\n", buildDate)
 	},
 }
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/mosaan/mdatlas/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// outputSchemas lists, by name, the output types schemaCmd describes and
+// the title of each schema
+var outputSchemas = map[string]struct {
+	value interface{}
+	title string
+}{
+	"structure": {types.DocumentStructure{}, "mdatlas structure output"},
+	"section":   {types.SectionContent{}, "mdatlas section output"},
+	"stats":     {core.DocumentStats{}, "mdatlas stats output"},
+}
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema structure|section|stats",
+	Short: "Print the JSON Schema of an output format",
+	Long: `Print the JSON Schema (draft 2020-12) of the JSON written by the structure,
+section or stats command, for validating output or generating client code.
+The schema is generated from the Go types the output is encoded from, so it
+always describes the running binary's output.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"structure", "section", "stats"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, ok := outputSchemas[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown schema: %s (expected structure, section or stats)", args[0])
+		}
+
+		return writeOutput(func(w io.Writer) error {
+			return encodeOutput(w, core.JSONSchema(output.value, output.title), "json")
+		})
+	},
+}
+
+func init() {
+	schemaCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output")
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	query         string
+	caseSensitive bool
+	searchBody    bool
+	searchFormat  string
+	searchMode    string
+	highlight     string
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <file>",
+	Short: "Search for sections matching a query in a Markdown file",
+	Long: `Search the section titles of a Markdown file for a query string.
+Use --search-body to search section body text instead of titles, and
+--mode regex to match the query as a regular expression such as "^Chapter \d+".
+Plain output prints one match per line as "<level> <title> (#<id> line <n>)",
+followed by a snippet of the matched line for body searches. Use --highlight
+to surround the matches in snippets with a delimiter such as "**". JSON
+output includes the number of matches in each section as match_count.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath := args[0]
+
+		if query == "" {
+			return fmt.Errorf("query is required (use --query flag)")
+		}
+		if !core.IsValidSearchMode(searchMode) {
+			return fmt.Errorf("unsupported search mode: %s", searchMode)
+		}
+
+		// Resolve path relative to base directory
+		absPath, err := resolveFilePath(filePath)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		// Search sections
+		structureManager := core.NewStructureManagerWithParser(nil, parser)
+		var matches []core.SearchMatch
+		if searchBody {
+			matches, err = structureManager.SearchSectionBodiesWithHighlight(absPath, query, searchMode, caseSensitive, highlight)
+		} else {
+			matches, err = structureManager.SearchSectionsWithMode(absPath, query, searchMode, caseSensitive)
+		}
+		if err != nil {
+			return fmt.Errorf("search failed: %w", err)
+		}
+
+		switch searchFormat {
+		case "json":
+			encoder := json.NewEncoder(stdout())
+			if pretty {
+				encoder.SetIndent("", "  ")
+			}
+			return encoder.Encode(map[string]interface{}{
+				"file_path": absPath,
+				"query":     query,
+				"results":   matches,
+				"count":     len(matches),
+			})
+		case "plain":
+			for _, match := range matches {
+				fmt.Fprintf(stdout(), "%d %s (#%s line %d)", match.Level, match.Title, match.ID, match.MatchLine)
+				if match.Snippet != "" {
+					fmt.Fprintf(stdout(), ": %s", match.Snippet)
+				}
+				fmt.Fprintln(stdout())
+			}
+			return nil
+		default:
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, searchFormat)
+		}
+	},
+}
+
+func init() {
+	searchCmd.Flags().StringVar(&query, "query", "", "Text to search for (required)")
+	searchCmd.Flags().BoolVar(&caseSensitive, "case-sensitive", false, "Match the query case sensitively")
+	searchCmd.Flags().BoolVar(&searchBody, "search-body", false, "Search section body text instead of titles")
+	searchCmd.Flags().StringVar(&searchMode, "mode", core.SearchModeSubstring, "Query matching mode (substring, regex)")
+	searchCmd.Flags().StringVar(&highlight, "highlight", "", "Delimiter placed around matches in body search snippets")
+	searchCmd.Flags().StringVar(&searchFormat, "format", "plain", "Output format (json, plain)")
+	searchCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+
+	// Mark query as required
+	searchCmd.MarkFlagRequired("query")
+}
@@ -1,90 +1,179 @@
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"strings"
 
 	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/mosaan/mdatlas/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var (
 	sectionID       string
+	sectionPath     string
 	includeChildren bool
 	format          string
+	contextLines    int
+	includePreamble bool
+	withMeta        bool
+	tokenizer       string
 )
 
 // sectionCmd represents the section command
 var sectionCmd = &cobra.Command{
-	Use:   "section <file>",
+	Use:   "section [file|-]",
 	Short: "Extract content from a specific section of a Markdown file",
 	Long: `Extract and display the content of a specific section from a Markdown file.
-Use the section ID obtained from the structure command to retrieve the content.`,
-	Args: cobra.ExactArgs(1),
+Use the section ID obtained from the structure command to retrieve the content,
+or --section-path with a slash-separated heading path such as
+"Introduction/Getting Started/Installation" (matched case-insensitively).
+Use --context to add lines of the surrounding document before and after the
+section; the JSON and YAML formats report the returned start_line and end_line.
+Use --include-preamble to start the document's first section at the text
+before its heading; it has no effect on other sections.
+Use --with-meta to precede markdown and html output with an HTML comment, and
+plain output with "# " lines, giving the section's id, title, level, lines and
+breadcrumb; the JSON and YAML formats always include them.
+The JSON and YAML formats report an approximate token_estimate of the content,
+counted with the heuristic selected by --tokenizer: chars (one token per four
+bytes) or words (words, punctuation and symbols, closer for code).
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		filePath := args[0]
-
-		if sectionID == "" {
-			return fmt.Errorf("section ID is required (use --section-id flag)")
+		if sectionID == "" && sectionPath == "" {
+			return fmt.Errorf("section ID or path is required (use --section-id or --section-path flag)")
 		}
-
-		// Resolve path relative to base directory
-		var absPath string
-		if filepath.IsAbs(filePath) {
-			absPath = filePath
-		} else {
-			absPath = filepath.Join(baseDir, filePath)
+		if contextLines < 0 {
+			return fmt.Errorf("context must not be negative, got %d", contextLines)
 		}
-
-		// Check if file exists
-		if _, err := os.Stat(absPath); os.IsNotExist(err) {
-			return fmt.Errorf("file does not exist: %s", filePath)
+		if !core.IsValidTokenizer(tokenizer) {
+			return fmt.Errorf("unsupported tokenizer: %s (use %s or %s)", tokenizer, core.TokenizerChars, core.TokenizerWords)
 		}
 
-		// Read file content
-		content, err := os.ReadFile(absPath)
+		content, _, err := readInput(args)
 		if err != nil {
-			return fmt.Errorf("failed to read file: %w", err)
+			return err
 		}
 
 		// Get section content
-		parser := core.NewParser()
-		sectionContent, err := parser.GetSectionContent(content, sectionID, includeChildren)
+		parser, err := newParser()
 		if err != nil {
-			return fmt.Errorf("failed to get section content: %w", err)
+			return err
 		}
 
-		// Set the requested format
-		sectionContent.Format = format
+		if sectionPath != "" {
+			sectionContent, err := parser.GetSectionContentByPath(content, sectionPath, includeChildren)
+			if err != nil {
+				return fmt.Errorf("failed to get section content: %w", err)
+			}
+			if err := prependPreamble(parser, content, sectionContent); err != nil {
+				return err
+			}
+			parser.ExpandSectionContent(content, sectionContent, contextLines)
+			return writeSectionContent(parser, sectionContent)
+		}
 
-		// Output based on format
-		switch format {
-		case "json":
-			encoder := json.NewEncoder(os.Stdout)
-			if pretty {
-				encoder.SetIndent("", "  ")
+		// The parser that found the section, whose IDs it is identified by
+		sectionParser := parser
+		sectionContent, err := parser.GetSectionContent(content, sectionID, includeChildren)
+		if err != nil && idStyle == core.IDStyleHash {
+			// Accept slug IDs without requiring --id-style slug
+			slugParser := core.NewParserWithOptions(core.ParserOptions{IDStyle: core.IDStyleSlug})
+			if slugContent, slugErr := slugParser.GetSectionContent(content, sectionID, includeChildren); slugErr == nil {
+				sectionContent, err = slugContent, nil
+				sectionParser = slugParser
 			}
-			return encoder.Encode(sectionContent)
-		case "plain":
-			fmt.Print(sectionContent.Content)
-			return nil
-		case "markdown":
-			fmt.Print(sectionContent.Content)
-			return nil
-		default:
-			return fmt.Errorf("unsupported format: %s", format)
 		}
+		if err != nil {
+			return fmt.Errorf("failed to get section content: %w", err)
+		}
+
+		if err := prependPreamble(sectionParser, content, sectionContent); err != nil {
+			return err
+		}
+		parser.ExpandSectionContent(content, sectionContent, contextLines)
+		return writeSectionContent(parser, sectionContent)
 	},
 }
 
+// prependPreamble applies --include-preamble, starting the content of the
+// document's first section at its preamble
+func prependPreamble(parser *core.Parser, content []byte, sectionContent *types.SectionContent) error {
+	if !includePreamble {
+		return nil
+	}
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse structure: %w", err)
+	}
+
+	parser.PrependPreamble(content, structure, sectionContent)
+	return nil
+}
+
+// writeSectionContent prints section content in the requested format,
+// rendering it as plain text or HTML for the plain and html formats
+func writeSectionContent(parser *core.Parser, sectionContent *types.SectionContent) error {
+	// Set the requested format
+	if err := parser.FormatSectionContent(sectionContent, format); err != nil {
+		return err
+	}
+	sectionContent.TokenEstimate = core.EstimateTokens(sectionContent.Content, tokenizer)
+
+	// Output based on format
+	switch format {
+	case "json", "yaml":
+		return writeOutput(func(w io.Writer) error {
+			return encodeOutput(w, sectionContent, format)
+		})
+	case "plain", "markdown", "html":
+		return writeOutput(func(w io.Writer) error {
+			if withMeta {
+				if _, err := io.WriteString(w, formatSectionMeta(sectionContent, format)); err != nil {
+					return err
+				}
+			}
+			_, err := io.WriteString(w, sectionContent.Content)
+			return err
+		})
+	default:
+		return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, format)
+	}
+}
+
+// formatSectionMeta renders the header written by --with-meta: an HTML
+// comment for markdown and html, which renderers hide, and "# " lines
+// followed by a blank line for plain text
+func formatSectionMeta(sectionContent *types.SectionContent, format string) string {
+	fields := []string{
+		"id: " + sectionContent.ID,
+		"title: " + sectionContent.Title,
+		fmt.Sprintf("level: %d", sectionContent.Level),
+		fmt.Sprintf("lines: %d-%d", sectionContent.StartLine, sectionContent.EndLine),
+		"breadcrumb: " + strings.Join(sectionContent.Breadcrumb, " > "),
+	}
+
+	if format == "plain" {
+		return "# " + strings.Join(fields, "\n# ") + "\n\n"
+	}
+	return "<!--\n" + strings.Join(fields, "\n") + "\n-->\n"
+}
+
 func init() {
-	sectionCmd.Flags().StringVar(&sectionID, "section-id", "", "Section ID to retrieve (required)")
+	sectionCmd.Flags().StringVar(&sectionID, "section-id", "", "Section ID to retrieve")
+	sectionCmd.Flags().StringVar(&sectionPath, "section-path", "", "Slash-separated heading path of the section to retrieve")
 	sectionCmd.Flags().BoolVar(&includeChildren, "include-children", false, "Include child sections in the output")
-	sectionCmd.Flags().StringVar(&format, "format", "markdown", "Output format (json, markdown, plain)")
+	sectionCmd.Flags().IntVar(&contextLines, "context", 0, "Number of lines of surrounding context to include before and after the section")
+	sectionCmd.Flags().BoolVar(&includePreamble, "include-preamble", false, "Start the document's first section at the text before its heading, if any")
+	sectionCmd.Flags().BoolVar(&withMeta, "with-meta", false, "Precede markdown, html and plain output with a header giving the section's id, title, level, lines and breadcrumb")
+	sectionCmd.Flags().StringVar(&tokenizer, "tokenizer", core.TokenizerChars, "Heuristic for the approximate token estimate (chars, words)")
+	sectionCmd.Flags().StringVar(&format, "format", "markdown", "Output format (json, yaml, markdown, plain, html)")
 	sectionCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
 
-	// Mark section-id as required
-	sectionCmd.MarkFlagRequired("section-id")
+	// Exactly one way of identifying the section is required
+	sectionCmd.MarkFlagsOneRequired("section-id", "section-path")
+	sectionCmd.MarkFlagsMutuallyExclusive("section-id", "section-path")
 }
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sectionsLevel  int
+	sectionsFormat string
+)
+
+// sectionsCmd represents the sections command
+var sectionsCmd = &cobra.Command{
+	Use:   "sections [file|-] --level N",
+	Short: "List the sections at a heading level of a Markdown file",
+	Long: `List every section at a heading level of a Markdown file in document order,
+e.g. --level 2 for the chapters of a document with a single title heading.
+Plain output prints one section per line as "<level> <title> (#<id> line <n>)";
+use --format json for the section metadata, without subsections but with a
+child_count of each section's direct subsections.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sectionsLevel < 1 || sectionsLevel > 6 {
+			return fmt.Errorf("level must be from 1 to 6, got %d", sectionsLevel)
+		}
+
+		content, _, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+
+		structureManager := core.NewStructureManagerWithParser(nil, parser)
+		sections := structureManager.BuildSectionsByLevel(structure, sectionsLevel)
+
+		switch sectionsFormat {
+		case "json":
+			return writeOutput(func(w io.Writer) error {
+				encoder := json.NewEncoder(w)
+				if pretty {
+					encoder.SetIndent("", "  ")
+				}
+				return encoder.Encode(sections)
+			})
+		case "plain":
+			return writeOutput(func(w io.Writer) error {
+				for _, section := range sections {
+					if _, err := fmt.Fprintf(w, "%d %s (#%s line %d)\n", section.Level, section.Title, section.ID, section.StartLine); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		default:
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, sectionsFormat)
+		}
+	},
+}
+
+func init() {
+	sectionsCmd.Flags().IntVar(&sectionsLevel, "level", 0, "Heading level of the sections to list, from 1 to 6 (required)")
+	sectionsCmd.Flags().StringVar(&sectionsFormat, "format", "plain", "Output format (json, plain)")
+	sectionsCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+
+	sectionsCmd.MarkFlagRequired("level")
+}
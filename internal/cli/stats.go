@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	wordCountMode string
+	statsFormat   string
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats [file|-]",
+	Short: "Print statistics about a Markdown file",
+	Long: `Print statistics about a Markdown file as JSON or YAML, including character, line
+and word counts, sections per heading level, and counts of code blocks,
+links, images and tables.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !core.IsValidWordCountMode(wordCountMode) {
+			return fmt.Errorf("unsupported word count mode: %s", wordCountMode)
+		}
+		if statsFormat != "json" && statsFormat != "yaml" {
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, statsFormat)
+		}
+
+		content, absPath, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+		structure.FilePath = absPath
+		if stat, err := os.Stat(absPath); err == nil {
+			structure.LastModified = stat.ModTime()
+		}
+
+		structureManager := core.NewStructureManagerWithParser(nil, parser)
+		stats, err := structureManager.BuildDocumentStats(absPath, structure, content)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(func(w io.Writer) error {
+			return encodeOutput(w, stats, statsFormat)
+		})
+	},
+}
+
+func init() {
+	statsCmd.Flags().StringVar(&wordCountMode, "word-count-mode", core.WordCountWhitespace, "Word count mode (whitespace, cjk)")
+	statsCmd.Flags().StringVar(&statsFormat, "format", "json", "Output format (json, yaml)")
+	statsCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+}
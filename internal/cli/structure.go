@@ -1,10 +1,15 @@
 package cli
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/mosaan/mdatlas/internal/core"
 	"github.com/mosaan/mdatlas/pkg/types"
@@ -12,68 +17,228 @@ import (
 )
 
 var (
-	maxDepth int
-	pretty   bool
+	maxDepth           int
+	minLevel           int
+	pretty             bool
+	excludeFrontMatter bool
+	watch              bool
+	structureFormat    string
+	warnSkippedLevels  bool
+	flatStructure      bool
+	noCounts           bool
 )
 
 // structureCmd represents the structure command
 var structureCmd = &cobra.Command{
-	Use:   "structure <file>",
+	Use:   "structure [file|-]",
 	Short: "Extract structure information from Markdown file",
 	Long: `Extract and display the hierarchical structure of a Markdown file.
 This command analyzes the heading structure and provides metadata about
-each section including character counts, line numbers, and nesting levels.`,
-	Args: cobra.ExactArgs(1),
+each section including character counts, line numbers, and nesting levels.
+Use "-" or pipe content without a file argument to read from stdin.
+Use --format yaml for YAML output, --format outline for an indented bullet list of the headings, or
+--format headings for the headings alone as nested ATX headings.
+Use --min-level to start the tree at a heading level, e.g. 2 to leave out a
+document's H1 title: sections above it are dropped and their subsections
+become roots. With --max-depth it selects a window of levels.
+Use --flat with the json and yaml formats to list the sections in document
+order, each with the parent_id of its parent section, instead of nesting them.
+Use --no-counts when only the heading tree is needed: the character, line and
+word counts of the sections are left at zero, which is faster on large files.
+With --watch, the structure is printed again whenever the file changes; each
+JSON output is a complete document ending in a newline.`,
+	Args: fileOrStdinArg,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		filePath := args[0]
-
-		// Resolve path relative to base directory
-		var absPath string
-		if filepath.IsAbs(filePath) {
-			absPath = filePath
-		} else {
-			absPath = filepath.Join(baseDir, filePath)
-		}
-
-		// Check if file exists
-		if _, err := os.Stat(absPath); os.IsNotExist(err) {
-			return fmt.Errorf("file does not exist: %s", filePath)
-		}
-
-		// Read file content
-		content, err := os.ReadFile(absPath)
+		parser, err := newParser()
 		if err != nil {
-			return fmt.Errorf("failed to read file: %w", err)
+			return err
 		}
 
-		// Parse structure
-		parser := core.NewParser()
-		structure, err := parser.ParseStructure(content)
-		if err != nil {
-			return fmt.Errorf("failed to parse structure: %w", err)
+		// Keep the last structure parsed, so that while watching, edits
+		// that leave the headings alone are reparsed incrementally
+		var previous *types.DocumentStructure
+		render := func() ([]byte, error) {
+			output, structure, err := renderStructure(parser, args, previous)
+			if err != nil {
+				return nil, err
+			}
+			previous = structure
+			return output, nil
 		}
 
-		// Set file path in structure
-		structure.FilePath = absPath
-
-		// Filter by max depth if specified
-		if maxDepth > 0 {
-			structure.Structure = filterByDepth(structure.Structure, maxDepth)
+		if watch {
+			if isStdinInput(args) {
+				return fmt.Errorf("--watch requires a file argument")
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			filePath, err := resolveFilePath(args[0])
+			if err != nil {
+				return err
+			}
+			return watchFile(ctx, filePath, outputWriter(), render)
 		}
 
-		// Output JSON
-		encoder := json.NewEncoder(os.Stdout)
-		if pretty {
-			encoder.SetIndent("", "  ")
+		output, err := render()
+		if err != nil {
+			return err
 		}
-
-		return encoder.Encode(structure)
+		return writeOutput(func(w io.Writer) error {
+			_, err := w.Write(output)
+			return err
+		})
 	},
 }
 
 func init() {
 	structureCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Maximum heading depth to include (0 for all)")
+	structureCmd.Flags().IntVar(&minLevel, "min-level", 0, "Minimum heading level to include; sections above it are dropped and their subsections promoted to roots (0 for all)")
 	structureCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output")
+	structureCmd.Flags().BoolVar(&excludeFrontMatter, "exclude-front-matter", false, "Exclude YAML front matter lines from total counts")
+	structureCmd.Flags().BoolVar(&noCounts, "no-counts", false, "Leave the character, line and word counts of the sections at zero to extract the heading tree faster")
+	structureCmd.Flags().BoolVar(&warnSkippedLevels, "warn-skipped-levels", false, "Report headings that skip a level below their parent in a warnings array")
+	structureCmd.Flags().StringVar(&structureFormat, "format", "json", "Output format (json, yaml, outline, headings)")
+	structureCmd.Flags().BoolVar(&flatStructure, "flat", false, "List the sections as a flat, document ordered array with a parent_id on each (json and yaml formats)")
+	structureCmd.Flags().BoolVar(&watch, "watch", false, "Keep running and print the structure again whenever the file changes")
+}
+
+// renderStructure parses the input and renders its structure in the
+// requested format. previous, if not nil, is the structure returned for an
+// earlier version of the input, which is reparsed incrementally (see
+// core.Parser.ReparseStructure). It returns the output and the structure
+// parsed, before any filtering.
+func renderStructure(parser *core.Parser, args []string, previous *types.DocumentStructure) ([]byte, *types.DocumentStructure, error) {
+	switch structureFormat {
+	case "json", "yaml", "outline", "headings":
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, structureFormat)
+	}
+	if flatStructure && structureFormat != "json" && structureFormat != "yaml" {
+		return nil, nil, fmt.Errorf("--flat requires the json or yaml format")
+	}
+	if minLevel < 0 || minLevel > 6 {
+		return nil, nil, fmt.Errorf("--min-level must be from 0 to 6, got %d", minLevel)
+	}
+	if minLevel > 0 && maxDepth > 0 && minLevel > maxDepth {
+		return nil, nil, fmt.Errorf("--min-level %d is deeper than --max-depth %d", minLevel, maxDepth)
+	}
+
+	var parsed *types.DocumentStructure
+	var err error
+	// The structure manager reads the file as stored, without its includes
+	useManager := ((cacheDir != "" && !noCache) || fastStructure) && !followIncludes
+	if useManager && !isStdinInput(args) {
+		parsed, err = managedStructure(parser, args[0])
+	} else {
+		parsed, err = parseInput(parser, args, previous)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Filter by max depth and min level if specified, on a copy that leaves
+	// the parsed structure whole
+	structure := *parsed
+	if maxDepth > 0 {
+		structure.Structure = filterByDepth(structure.Structure, maxDepth)
+	}
+	structure.Structure = core.FilterByMinLevel(structure.Structure, minLevel)
+
+	switch structureFormat {
+	case "outline":
+		return []byte(formatOutline(structure.Structure)), parsed, nil
+	case "headings":
+		return []byte(core.BuildSkeleton(structure.Structure, 0)), parsed, nil
+	}
+
+	if flatStructure {
+		structure.Structure = parser.FlattenStructure(structure.Structure)
+	}
+
+	var output bytes.Buffer
+	if err := encodeOutput(&output, &structure, structureFormat); err != nil {
+		return nil, nil, err
+	}
+
+	return output.Bytes(), parsed, nil
+}
+
+// parseInput parses the structure of the file argument or standard input,
+// reparsing an earlier version's structure previous if it is not nil
+func parseInput(parser *core.Parser, args []string, previous *types.DocumentStructure) (*types.DocumentStructure, error) {
+	content, absPath, expanded, err := readExpandedInput(args)
+	if err != nil {
+		return nil, err
+	}
+
+	structure, err := parser.ReparseStructure(previous, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse structure: %w", err)
+	}
+	if expanded != nil {
+		expanded.SetSourceFiles(structure)
+	}
+
+	// Set file path in structure
+	structure.FilePath = absPath
+	return structure, nil
+}
+
+// managedStructure returns the structure of filePath through a structure
+// manager, which consults the --cache-dir cache if one is set and scans large
+// files with --fast-structure
+func managedStructure(parser *core.Parser, filePath string) (*types.DocumentStructure, error) {
+	diskCache, err := newDiskCache()
+	if err != nil {
+		return nil, err
+	}
+
+	// Key the cache by absolute path so it is shared between working directories
+	displayPath, err := resolveFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(displayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	structureManager := core.NewStructureManagerWithParser(nil, parser)
+	structureManager.SetDiskCache(diskCache)
+
+	structure, err := structureManager.GetDocumentStructure(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the path as given, like an uncached run
+	structure.FilePath = displayPath
+	return structure, nil
+}
+
+// formatOutline renders the section hierarchy as a bullet list indented two
+// spaces per level relative to the shallowest heading
+func formatOutline(sections []types.Section) string {
+	// Children are always deeper than their parent, so the shallowest
+	// heading is among the top-level sections
+	minLevel := 0
+	for _, section := range sections {
+		if minLevel == 0 || section.Level < minLevel {
+			minLevel = section.Level
+		}
+	}
+
+	var builder strings.Builder
+	var walk func(sections []types.Section)
+	walk = func(sections []types.Section) {
+		for _, section := range sections {
+			fmt.Fprintf(&builder, "%s- %s\n", strings.Repeat("  ", section.Level-minLevel), section.Title)
+			walk(section.Children)
+		}
+	}
+	walk(sections)
+
+	return builder.String()
 }
 
 // filterByDepth filters sections by maximum depth
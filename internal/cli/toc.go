@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tocFormat string
+	tocInsert bool
+)
+
+// tocCmd represents the toc command
+var tocCmd = &cobra.Command{
+	Use:   "toc [file|-]",
+	Short: "Print the table of contents of a Markdown file",
+	Long: `Print a table of contents for a Markdown file.
+By default the TOC is printed as an indented outline using two spaces per
+heading level. Use --format json to emit the TOC entries as JSON.
+With --insert the document itself is printed with a Markdown TOC of anchor
+links placed after its <!-- toc --> marker and closed by <!-- tocstop -->.
+A TOC already between the markers is replaced, so the command can be rerun
+on its own output.
+Use "-" or pipe content without a file argument to read from stdin.`,
+	Args: fileOrStdinArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, _, err := readInput(args)
+		if err != nil {
+			return err
+		}
+
+		parser, err := newParser()
+		if err != nil {
+			return err
+		}
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse structure: %w", err)
+		}
+
+		// Generate table of contents
+		structureManager := core.NewStructureManagerWithParser(nil, parser)
+		toc := structureManager.BuildTableOfContents(structure, maxDepth)
+
+		if tocInsert {
+			result, err := core.InsertToc(content, toc)
+			if err != nil {
+				return fmt.Errorf("failed to insert table of contents: %w", err)
+			}
+			return writeOutput(func(w io.Writer) error {
+				_, err := w.Write(result)
+				return err
+			})
+		}
+
+		switch tocFormat {
+		case "json":
+			return writeOutput(func(w io.Writer) error {
+				encoder := json.NewEncoder(w)
+				if pretty {
+					encoder.SetIndent("", "  ")
+				}
+				return encoder.Encode(toc)
+			})
+		case "text":
+			return writeOutput(func(w io.Writer) error {
+				_, err := io.WriteString(w, formatTocText(toc))
+				return err
+			})
+		default:
+			return fmt.Errorf("%w: %s", core.ErrUnsupportedFormat, tocFormat)
+		}
+	},
+}
+
+func init() {
+	tocCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Maximum heading depth to include (0 for all)")
+	tocCmd.Flags().StringVar(&tocFormat, "format", "text", "Output format (text, json)")
+	tocCmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output (only for json format)")
+	tocCmd.Flags().BoolVar(&tocInsert, "insert", false, "Print the document with a Markdown TOC inserted at its <!-- toc --> marker")
+}
+
+// formatTocText renders TOC entries as an indented outline, indenting two
+// spaces per level relative to the shallowest heading in the TOC
+func formatTocText(toc []core.TocEntry) string {
+	minLevel := 0
+	for _, entry := range toc {
+		if minLevel == 0 || entry.Level < minLevel {
+			minLevel = entry.Level
+		}
+	}
+
+	var builder strings.Builder
+	for _, entry := range toc {
+		builder.WriteString(strings.Repeat("  ", entry.Level-minLevel))
+		builder.WriteString(entry.Title)
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait after the last change before rendering
+// again, since editors often write a file more than once per save
+const watchDebounce = 100 * time.Millisecond
+
+// watchFile writes the output of render to out, then renders again whenever
+// filePath changes until ctx is cancelled. Output identical to the previous
+// one is not repeated. A deleted file is reported on stderr and picked up
+// again once it is recreated.
+func watchFile(ctx context.Context, filePath string, out io.Writer, render func() ([]byte, error)) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the parent directory so that deletes, recreates and editors
+	// that save by renaming a temporary file are all seen
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(absPath), err)
+	}
+
+	// Render only once watching, so a change right after the first output
+	// is not missed
+	last, err := render()
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(last); err != nil {
+		return err
+	}
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	removed := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != absPath {
+				continue
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			debounce.Reset(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Watch error: %v\n", err)
+
+		case <-debounce.C:
+			if _, err := os.Stat(absPath); os.IsNotExist(err) {
+				if !removed {
+					fmt.Fprintf(os.Stderr, "%s was removed, waiting for it to be recreated\n", filePath)
+					removed = true
+				}
+				continue
+			}
+			removed = false
+
+			output, err := render()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				continue
+			}
+			if bytes.Equal(output, last) {
+				continue
+			}
+			last = output
+			if _, err := out.Write(output); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -1,12 +1,13 @@
 package core
 
 import (
-	"crypto/md5"
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/mosaan/mdatlas/pkg/types"
 )
 
@@ -16,61 +17,108 @@ type Cache struct {
 	structures map[string]*CacheEntry
 	maxSize    int
 	ttl        time.Duration
+	hits       atomic.Int64
+	misses     atomic.Int64
+	done       chan struct{} // Closed by Close to stop cleanupExpired
+	closeOnce  sync.Once
 }
 
 // CacheEntry represents a cached document structure
 type CacheEntry struct {
-	Structure    *types.DocumentStructure
-	LastAccessed time.Time
-	FileModTime  time.Time
-	FileHash     string
+	Structure    *types.DocumentStructure `json:"structure"`
+	LastAccessed time.Time                `json:"last_accessed"`
+	FileModTime  time.Time                `json:"file_mod_time"`
+	FileSize     int64                    `json:"file_size"`
+	FileHash     string                   `json:"file_hash"`
 }
 
+// Defaults used by NewCache for a non-positive size or TTL
+const (
+	DefaultCacheSize = 100
+	DefaultCacheTTL  = 30 * time.Minute
+)
+
 // NewCache creates a new cache instance
 func NewCache(maxSize int, ttl time.Duration) *Cache {
 	if maxSize <= 0 {
-		maxSize = 100 // Default max size
+		maxSize = DefaultCacheSize
 	}
 
 	if ttl <= 0 {
-		ttl = 30 * time.Minute // Default TTL
+		ttl = DefaultCacheTTL
 	}
 
 	cache := &Cache{
 		structures: make(map[string]*CacheEntry),
 		maxSize:    maxSize,
 		ttl:        ttl,
+		done:       make(chan struct{}),
 	}
 
-	// Start cleanup goroutine
+	// Start cleanup goroutine; Close stops it
 	go cache.cleanupExpired()
 
 	return cache
 }
 
+// Close stops the goroutine removing expired entries. The cache stays usable,
+// but expired entries are no longer removed until they are looked up or
+// evicted. Close may be called more than once.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
 // GetStructure retrieves a cached document structure
 func (c *Cache) GetStructure(filePath string) (*types.DocumentStructure, bool) {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-
 	entry, exists := c.structures[filePath]
+	var lastAccessed time.Time
+	if exists {
+		lastAccessed = entry.LastAccessed
+	}
+	c.mu.RUnlock()
+
 	if !exists {
+		c.misses.Add(1)
 		return nil, false
 	}
 
 	// Check if entry is expired
-	if time.Since(entry.LastAccessed) > c.ttl {
+	if time.Since(lastAccessed) > c.ttl {
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	// Check if file has been modified
-	if !c.isFileUnchanged(filePath, entry) {
+	// Check if file has been modified. Only LastAccessed changes after an
+	// entry is created, so this needs no lock and doesn't block other lookups.
+	if !isFileUnchanged(filePath, entry) {
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	// Update access time
+	// Update access time under the write lock, as concurrent lookups, Stats
+	// and evictLRU read it
+	c.mu.Lock()
 	entry.LastAccessed = time.Now()
+	c.mu.Unlock()
+
+	c.hits.Add(1)
+	return entry.Structure, true
+}
+
+// PreviousStructure returns the structure cached for filePath even if the
+// file changed or the entry expired since, for ReparseStructure to build on.
+// It does not count as a hit or a miss.
+func (c *Cache) PreviousStructure(filePath string) (*types.DocumentStructure, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
+	entry, exists := c.structures[filePath]
+	if !exists {
+		return nil, false
+	}
 	return entry.Structure, true
 }
 
@@ -79,32 +127,56 @@ func (c *Cache) SetStructure(filePath string, structure *types.DocumentStructure
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Get file information
-	stat, err := os.Stat(filePath)
+	// Create cache entry from the file's current state
+	entry, err := NewCacheEntry(filePath, structure)
 	if err != nil {
-		return // Skip caching if we can't stat the file
+		return // Skip caching if we can't stat or hash the file
 	}
 
-	// Calculate file hash
-	hash, err := c.calculateFileHash(filePath)
-	if err != nil {
-		return // Skip caching if we can't hash the file
-	}
+	c.setEntry(filePath, entry)
+}
 
+// setEntry stores an entry, evicting the least recently used one if the
+// cache is full. The caller must hold c.mu.
+func (c *Cache) setEntry(filePath string, entry *CacheEntry) {
 	// Check if we need to evict entries
-	if len(c.structures) >= c.maxSize {
+	if _, exists := c.structures[filePath]; !exists && len(c.structures) >= c.maxSize {
 		c.evictLRU()
 	}
 
-	// Create cache entry
-	entry := &CacheEntry{
+	c.structures[filePath] = entry
+}
+
+// SetEntry caches an existing entry, e.g. one loaded from a DiskCache, keeping
+// its recorded modification time and hash
+func (c *Cache) SetEntry(filePath string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.LastAccessed = time.Now()
+	c.setEntry(filePath, entry)
+}
+
+// NewCacheEntry creates a cache entry for structure that records the current
+// modification time, size and hash of filePath
+func NewCacheEntry(filePath string, structure *types.DocumentStructure) (*CacheEntry, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := calculateFileHash(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheEntry{
 		Structure:    structure,
 		LastAccessed: time.Now(),
 		FileModTime:  stat.ModTime(),
+		FileSize:     stat.Size(),
 		FileHash:     hash,
-	}
-
-	c.structures[filePath] = entry
+	}, nil
 }
 
 // InvalidateStructure removes a cached structure
@@ -115,12 +187,14 @@ func (c *Cache) InvalidateStructure(filePath string) {
 	delete(c.structures, filePath)
 }
 
-// Clear removes all cached structures
+// Clear removes all cached structures and resets the hit and miss counters
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.structures = make(map[string]*CacheEntry)
+	c.hits.Store(0)
+	c.misses.Store(0)
 }
 
 // Size returns the current number of cached structures
@@ -140,6 +214,13 @@ func (c *Cache) Stats() CacheStats {
 		Size:    len(c.structures),
 		MaxSize: c.maxSize,
 		TTL:     c.ttl,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+
+	// Calculate the share of lookups served from the cache
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
 	}
 
 	// Calculate oldest and newest entries
@@ -159,20 +240,23 @@ func (c *Cache) Stats() CacheStats {
 	return stats
 }
 
-// isFileUnchanged checks if a file has been modified since caching
-func (c *Cache) isFileUnchanged(filePath string, entry *CacheEntry) bool {
+// isFileUnchanged reports whether filePath still has the modification time,
+// size and hash recorded in entry
+func isFileUnchanged(filePath string, entry *CacheEntry) bool {
 	stat, err := os.Stat(filePath)
 	if err != nil {
 		return false
 	}
 
-	// Check modification time
-	if !stat.ModTime().Equal(entry.FileModTime) {
+	// Check size and nanosecond modification time first, which rejects most
+	// changes without reading the file
+	if stat.Size() != entry.FileSize || stat.ModTime().UnixNano() != entry.FileModTime.UnixNano() {
 		return false
 	}
 
-	// Check file hash for additional verification
-	hash, err := c.calculateFileHash(filePath)
+	// Filesystems with coarse timestamps can keep the same mtime across a
+	// rapid edit of the same length, so confirm with the content hash
+	hash, err := calculateFileHash(filePath)
 	if err != nil {
 		return false
 	}
@@ -180,15 +264,25 @@ func (c *Cache) isFileUnchanged(filePath string, entry *CacheEntry) bool {
 	return hash == entry.FileHash
 }
 
-// calculateFileHash calculates MD5 hash of a file
-func (c *Cache) calculateFileHash(filePath string) (string, error) {
+// calculateFileHash calculates the hash of a file's content
+func calculateFileHash(filePath string) (string, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return "", err
 	}
 
-	hash := md5.Sum(content)
-	return fmt.Sprintf("%x", hash), nil
+	return hashContent(content), nil
+}
+
+// hashContent calculates the xxHash64 of file content. The hash only detects
+// changes, so a fast non-cryptographic hash is enough.
+func hashContent(content []byte) string {
+	return formatHash(xxhash.Sum64(content))
+}
+
+// formatHash formats an xxHash64 sum as stored in cache entries
+func formatHash(sum uint64) string {
+	return fmt.Sprintf("%016x", sum)
 }
 
 // evictLRU evicts the least recently used entry
@@ -212,12 +306,18 @@ func (c *Cache) evictLRU() {
 	}
 }
 
-// cleanupExpired removes expired entries periodically
+// cleanupExpired removes expired entries periodically until Close is called
 func (c *Cache) cleanupExpired() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
 		c.mu.Lock()
 
 		now := time.Now()
@@ -238,6 +338,9 @@ type CacheStats struct {
 	TTL         time.Duration `json:"ttl"`
 	OldestEntry time.Time     `json:"oldest_entry"`
 	NewestEntry time.Time     `json:"newest_entry"`
+	Hits        int64         `json:"hits"`
+	Misses      int64         `json:"misses"`
+	HitRatio    float64       `json:"hit_ratio"`
 }
 
 // RefreshStructure forces a refresh of a cached structure
@@ -281,13 +384,20 @@ func (c *Cache) GetCachedFiles() []string {
 	return files
 }
 
-// WarmUpCache pre-loads structures for specified files
+// WarmUpCache pre-loads structures for specified files, parsing up to
+// DefaultConcurrency of them at once
 func (c *Cache) WarmUpCache(filePaths []string, parser *Parser) error {
-	for _, filePath := range filePaths {
-		if err := c.RefreshStructure(filePath, parser); err != nil {
-			return fmt.Errorf("failed to warm up cache for %s: %w", filePath, err)
-		}
-	}
+	return c.WarmUpCacheWithConcurrency(filePaths, parser, 0)
+}
 
-	return nil
+// WarmUpCacheWithConcurrency is WarmUpCache parsing up to concurrency files
+// at once (DefaultConcurrency if concurrency is 0 or less). On failure, the
+// error is that of the first failing path.
+func (c *Cache) WarmUpCacheWithConcurrency(filePaths []string, parser *Parser, concurrency int) error {
+	return forEachIndex(len(filePaths), concurrency, func(i int) error {
+		if err := c.RefreshStructure(filePaths[i], parser); err != nil {
+			return fmt.Errorf("failed to warm up cache for %s: %w", filePaths[i], err)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheHitsAndMisses(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# Title\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	cache := NewCache(10, time.Minute)
+	sm := NewStructureManager(cache)
+
+	// First lookup misses and populates the cache; the next two hit
+	for i := 0; i < 3; i++ {
+		if _, err := sm.GetDocumentStructure(filePath); err != nil {
+			t.Fatalf("GetDocumentStructure failed: %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.HitRatio < 0.66 || stats.HitRatio > 0.67 {
+		t.Errorf("Expected hit ratio of 2/3, got %f", stats.HitRatio)
+	}
+
+	// A changed file is a miss
+	if err := os.WriteFile(filePath, []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if _, exists := cache.GetStructure(filePath); exists {
+		t.Error("Expected stale entry to miss")
+	}
+	if stats := cache.Stats(); stats.Misses != 2 {
+		t.Errorf("Expected 2 misses, got %d", stats.Misses)
+	}
+
+	cache.Clear()
+	stats = cache.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.HitRatio != 0 {
+		t.Errorf("Expected counters to reset on Clear, got %+v", stats)
+	}
+}
+
+func TestCacheDetectsSameSecondModification(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# Title A\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	// Simulate a filesystem with one-second timestamps
+	modTime := time.Now().Truncate(time.Second)
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+
+	cache := NewCache(10, time.Minute)
+	sm := NewStructureManager(cache)
+	if _, err := sm.GetDocumentStructure(filePath); err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	// Same length and same mtime, different content
+	if err := os.WriteFile(filePath, []byte("# Title B\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+
+	if _, exists := cache.GetStructure(filePath); exists {
+		t.Fatal("Expected entry to be stale after a same-second, same-length edit")
+	}
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	if title := structure.Structure[0].Title; title != "Title B" {
+		t.Errorf("Expected Title B, got %q", title)
+	}
+
+	// A change in size is detected even when the mtime is unchanged
+	if err := os.WriteFile(filePath, []byte("# Longer Title\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	if _, exists := cache.GetStructure(filePath); exists {
+		t.Error("Expected entry to be stale after a size change")
+	}
+}
+
+func TestCacheCloseStopsCleanup(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		cache := NewCache(10, time.Minute)
+		cache.Close()
+		cache.Close() // Closing twice is harmless
+	}
+
+	// The cleanup goroutines exit asynchronously after Close
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected closed caches to leave no goroutines, went from %d to %d", before, after)
+	}
+}
+
+func TestCacheConcurrentLookups(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# Title\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	cache := NewCache(10, time.Minute)
+	defer cache.Close()
+	sm := NewStructureManager(cache)
+	if _, err := sm.GetDocumentStructure(filePath); err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	// Run with -race: lookups update the entry's access time while other
+	// lookups and Stats read it
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, exists := cache.GetStructure(filePath); !exists {
+					t.Error("Expected cached structure to hit")
+					return
+				}
+				cache.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits != 160 {
+		t.Errorf("Expected 160 hits, got %d", stats.Hits)
+	}
+}
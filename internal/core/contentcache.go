@@ -0,0 +1,208 @@
+package core
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultContentCacheBytes is the default total size of a ContentCache
+const DefaultContentCacheBytes int64 = 64 * 1024 * 1024 // 64MB
+
+// racyWindow is how long after a file's modification time an edit may keep
+// the same timestamp on filesystems with coarse timestamps
+const racyWindow = 2 * time.Second
+
+// ContentCache caches the raw content of files, bounded by the total number
+// of bytes held rather than the number of files. The least recently used
+// files are evicted first, and files larger than the whole cache are never
+// cached. Content returned by the cache is shared and must not be modified.
+type ContentCache struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element // file path -> element holding a *contentEntry
+	lru      *list.List               // Most recently used at the front
+	bytes    int64
+	maxBytes int64
+	hits     int64
+	misses   int64
+}
+
+// contentEntry is a cached file with the state it was read in
+type contentEntry struct {
+	filePath string
+	content  []byte
+	modTime  time.Time
+	size     int64
+	hash     string
+	readAt   time.Time
+}
+
+// ContentCacheStats represents content cache statistics
+type ContentCacheStats struct {
+	Entries  int     `json:"entries"`
+	Bytes    int64   `json:"bytes"`
+	MaxBytes int64   `json:"max_bytes"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// NewContentCache creates a content cache holding up to maxBytes bytes
+func NewContentCache(maxBytes int64) *ContentCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultContentCacheBytes
+	}
+
+	return &ContentCache{
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// ReadFile returns the content of filePath, from the cache if the file is
+// unchanged since it was cached and from disk otherwise
+func (cc *ContentCache) ReadFile(filePath string) ([]byte, error) {
+	cc.mu.Lock()
+	if element, exists := cc.entries[filePath]; exists {
+		entry := element.Value.(*contentEntry)
+		if isContentUnchanged(entry) {
+			cc.lru.MoveToFront(element)
+			cc.hits++
+			cc.mu.Unlock()
+			return entry.content, nil
+		}
+		cc.remove(element)
+	}
+	cc.misses++
+	cc.mu.Unlock()
+
+	// Stat before reading, so an edit made while reading leaves an older
+	// modification time in the entry and is detected on the next lookup
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	readAt := time.Now()
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	cc.set(&contentEntry{
+		filePath: filePath,
+		content:  content,
+		modTime:  stat.ModTime(),
+		size:     stat.Size(),
+		hash:     hashContent(content),
+		readAt:   readAt,
+	})
+
+	return content, nil
+}
+
+// set stores an entry, evicting the least recently used entries until the
+// cache fits in maxBytes
+func (cc *ContentCache) set(entry *contentEntry) {
+	size := int64(len(entry.content))
+	if size > cc.maxBytes {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if element, exists := cc.entries[entry.filePath]; exists {
+		cc.remove(element)
+	}
+
+	for cc.bytes+size > cc.maxBytes {
+		cc.remove(cc.lru.Back())
+	}
+
+	cc.entries[entry.filePath] = cc.lru.PushFront(entry)
+	cc.bytes += size
+}
+
+// remove drops a cached entry. The caller must hold cc.mu.
+func (cc *ContentCache) remove(element *list.Element) {
+	entry := cc.lru.Remove(element).(*contentEntry)
+	delete(cc.entries, entry.filePath)
+	cc.bytes -= int64(len(entry.content))
+}
+
+// Invalidate removes the cached content of filePath
+func (cc *ContentCache) Invalidate(filePath string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if element, exists := cc.entries[filePath]; exists {
+		cc.remove(element)
+	}
+}
+
+// Clear removes all cached content and resets the hit and miss counters
+func (cc *ContentCache) Clear() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.entries = make(map[string]*list.Element)
+	cc.lru.Init()
+	cc.bytes = 0
+	cc.hits = 0
+	cc.misses = 0
+}
+
+// Stats returns content cache statistics
+func (cc *ContentCache) Stats() ContentCacheStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	stats := ContentCacheStats{
+		Entries:  len(cc.entries),
+		Bytes:    cc.bytes,
+		MaxBytes: cc.maxBytes,
+		Hits:     cc.hits,
+		Misses:   cc.misses,
+	}
+
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+
+	return stats
+}
+
+// isContentUnchanged reports whether the file of entry still has the
+// recorded size and modification time. Like isFileUnchanged it falls back to
+// the content hash, but only for files modified shortly before they were
+// read: any later edit of an older file moves its modification time, so the
+// file is not read again on every hit. The caller must hold the cache's lock.
+func isContentUnchanged(entry *contentEntry) bool {
+	stat, err := os.Stat(entry.filePath)
+	if err != nil {
+		return false
+	}
+
+	if stat.Size() != entry.size || stat.ModTime().UnixNano() != entry.modTime.UnixNano() {
+		return false
+	}
+
+	if entry.readAt.Sub(entry.modTime) > racyWindow {
+		return true
+	}
+
+	checkedAt := time.Now()
+	hash, err := calculateFileHash(entry.filePath)
+	if err != nil || hash != entry.hash {
+		return false
+	}
+
+	// The content is confirmed as of checkedAt, so once the file is old
+	// enough later hits need only the stat
+	entry.readAt = checkedAt
+	return true
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentCacheHitsAndInvalidation(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# First\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cc := NewContentCache(1024)
+
+	for i := 0; i < 2; i++ {
+		content, err := cc.ReadFile(filePath)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(content) != "# First\n" {
+			t.Errorf("Unexpected content %q", content)
+		}
+	}
+
+	stats := cc.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.Bytes != int64(len("# First\n")) {
+		t.Errorf("Expected one hit, one miss and one cached file, got %+v", stats)
+	}
+
+	// Rewrite with the same length and modification time, as a rapid edit
+	// on a filesystem with coarse timestamps would
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("# Other\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Chtimes(filePath, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("Failed to reset modification time: %v", err)
+	}
+
+	content, err := cc.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(content) != "# Other\n" {
+		t.Errorf("Expected the rewritten content, got %q", content)
+	}
+}
+
+func TestContentCacheEvictsByBytes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) string {
+		filePath := filepath.Join(dir, name)
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = 'x'
+		}
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		// Age the file so hits are validated by stat alone
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(filePath, old, old); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+		return filePath
+	}
+
+	small := write("small.md", 40)
+	medium := write("medium.md", 50)
+	large := write("large.md", 60)
+	huge := write("huge.md", 200)
+
+	cc := NewContentCache(100)
+	for _, filePath := range []string{small, medium, small, large, huge} {
+		if _, err := cc.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+	}
+
+	// Caching large.md evicted medium.md, the least recently used file, and
+	// huge.md is larger than the whole cache
+	stats := cc.Stats()
+	if stats.Entries != 2 || stats.Bytes != 100 {
+		t.Errorf("Expected small.md and large.md (100 bytes) to be cached, got %+v", stats)
+	}
+
+	if _, err := cc.ReadFile(small); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if _, err := cc.ReadFile(medium); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if stats := cc.Stats(); stats.Hits != 2 || stats.Misses != 5 {
+		t.Errorf("Expected hits for small.md only, got %+v", stats)
+	}
+}
+
+func TestStructureManagerUsesContentCache(t *testing.T) {
+	filePath := writeLargeDocument(t, 5)
+	cc := NewContentCache(0)
+
+	sm := NewStructureManager(NewCache(10, time.Minute))
+	sm.SetContentCache(cc)
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	for _, child := range structure.Structure[0].Children {
+		if _, err := sm.GetSectionContent(filePath, child.ID, false); err != nil {
+			t.Fatalf("GetSectionContent failed: %v", err)
+		}
+	}
+
+	// Parsing read the file once; every section lookup was a hit
+	if stats := cc.Stats(); stats.Misses != 1 || stats.Hits != int64(len(structure.Structure[0].Children)) {
+		t.Errorf("Expected one miss and a hit per section, got %+v", stats)
+	}
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// significantChangeRatio is the relative change in a section's own length
+// above which the section is reported as resized
+const significantChangeRatio = 0.2
+
+// minSignificantChange is the smallest change in characters reported as a
+// resize, so that small sections do not flag every edit
+const minSignificantChange = 20
+
+// Kinds of section changes reported in StructureDiff.Changed
+const (
+	ChangeMoved   = "moved"   // The section's heading path changed
+	ChangeLevel   = "level"   // The heading level changed
+	ChangeResized = "resized" // The section's own text grew or shrank significantly
+)
+
+// StructureDiff is the structural difference between two documents, keyed
+// by heading path
+type StructureDiff struct {
+	OldFile string          `json:"old_file"`
+	NewFile string          `json:"new_file"`
+	Added   []DiffSection   `json:"added"`
+	Removed []DiffSection   `json:"removed"`
+	Changed []SectionChange `json:"changed"`
+}
+
+// DiffSection identifies a section that exists in only one of the documents
+type DiffSection struct {
+	Path      string `json:"path"`
+	Level     int    `json:"level"`
+	ID        string `json:"id"`
+	CharCount int    `json:"char_count"`
+}
+
+// SectionChange describes a section present in both documents that moved,
+// changed level or changed length significantly
+type SectionChange struct {
+	Path         string   `json:"path"`
+	OldPath      string   `json:"old_path,omitempty"`
+	Changes      []string `json:"changes"`
+	OldLevel     int      `json:"old_level"`
+	NewLevel     int      `json:"new_level"`
+	OldCharCount int      `json:"old_char_count"`
+	NewCharCount int      `json:"new_char_count"`
+}
+
+// diffEntry is a section flattened with its heading path
+type diffEntry struct {
+	path     string
+	section  types.Section
+	ownChars int // Characters of the section excluding its subsections
+	matched  bool
+}
+
+// DiffStructures returns the structural difference between two documents.
+// Sections are matched by heading path first; sections left over on both
+// sides with the same unique title are reported as moved.
+func DiffStructures(oldStructure, newStructure *types.DocumentStructure) *StructureDiff {
+	diff := &StructureDiff{
+		OldFile: oldStructure.FilePath,
+		NewFile: newStructure.FilePath,
+		Added:   []DiffSection{},
+		Removed: []DiffSection{},
+		Changed: []SectionChange{},
+	}
+
+	oldEntries := flattenForDiff(oldStructure.Structure)
+	newEntries := flattenForDiff(newStructure.Structure)
+
+	oldByPath := make(map[string]*diffEntry, len(oldEntries))
+	for _, entry := range oldEntries {
+		oldByPath[entry.path] = entry
+	}
+
+	// Pair sections with the same path
+	pairs := make(map[*diffEntry]*diffEntry)
+	for _, newEntry := range newEntries {
+		if oldEntry, exists := oldByPath[newEntry.path]; exists {
+			oldEntry.matched, newEntry.matched = true, true
+			pairs[newEntry] = oldEntry
+		}
+	}
+
+	// Pair the remaining sections whose title is unique among them on both sides
+	oldByTitle := unmatchedByTitle(oldEntries)
+	newByTitle := unmatchedByTitle(newEntries)
+	for title, newCandidates := range newByTitle {
+		oldCandidates := oldByTitle[title]
+		if len(newCandidates) == 1 && len(oldCandidates) == 1 {
+			oldCandidates[0].matched, newCandidates[0].matched = true, true
+			pairs[newCandidates[0]] = oldCandidates[0]
+		}
+	}
+
+	for _, newEntry := range newEntries {
+		oldEntry, paired := pairs[newEntry]
+		if !paired {
+			diff.Added = append(diff.Added, newDiffSection(newEntry))
+			continue
+		}
+		if change, changed := compareSections(oldEntry, newEntry); changed {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+
+	for _, oldEntry := range oldEntries {
+		if !oldEntry.matched {
+			diff.Removed = append(diff.Removed, newDiffSection(oldEntry))
+		}
+	}
+
+	return diff
+}
+
+// DiffDocuments parses two documents and returns their structural difference
+func (sm *StructureManager) DiffDocuments(oldPath, newPath string) (*StructureDiff, error) {
+	oldStructure, err := sm.GetDocumentStructure(oldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	newStructure, err := sm.GetDocumentStructure(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffStructures(oldStructure, newStructure), nil
+}
+
+// compareSections reports how a section changed between the documents
+func compareSections(oldEntry, newEntry *diffEntry) (SectionChange, bool) {
+	change := SectionChange{
+		Path:         newEntry.path,
+		Changes:      []string{},
+		OldLevel:     oldEntry.section.Level,
+		NewLevel:     newEntry.section.Level,
+		OldCharCount: oldEntry.ownChars,
+		NewCharCount: newEntry.ownChars,
+	}
+
+	if oldEntry.path != newEntry.path {
+		change.OldPath = oldEntry.path
+		change.Changes = append(change.Changes, ChangeMoved)
+	}
+	if oldEntry.section.Level != newEntry.section.Level {
+		change.Changes = append(change.Changes, ChangeLevel)
+	}
+	if isSignificantChange(oldEntry.ownChars, newEntry.ownChars) {
+		change.Changes = append(change.Changes, ChangeResized)
+	}
+
+	return change, len(change.Changes) > 0
+}
+
+// isSignificantChange reports whether a length changed by more than
+// significantChangeRatio and at least minSignificantChange characters
+func isSignificantChange(oldChars, newChars int) bool {
+	delta := newChars - oldChars
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta < minSignificantChange {
+		return false
+	}
+	if oldChars == 0 {
+		return true
+	}
+	return float64(delta)/float64(oldChars) > significantChangeRatio
+}
+
+// flattenForDiff lists sections in document order with their heading paths.
+// Repeated paths get a " [n]" suffix so every entry has a unique key.
+func flattenForDiff(sections []types.Section) []*diffEntry {
+	var entries []*diffEntry
+	seen := make(map[string]int)
+
+	var walk func(sections []types.Section, parentPath string)
+	walk = func(sections []types.Section, parentPath string) {
+		for _, section := range sections {
+			path := section.Title
+			if parentPath != "" {
+				path = parentPath + "/" + section.Title
+			}
+
+			seen[path]++
+			key := path
+			if seen[path] > 1 {
+				key = fmt.Sprintf("%s [%d]", path, seen[path])
+			}
+
+			ownChars := section.CharCount
+			for _, child := range section.Children {
+				ownChars -= child.CharCount
+			}
+
+			entries = append(entries, &diffEntry{path: key, section: section, ownChars: ownChars})
+			walk(section.Children, path)
+		}
+	}
+	walk(sections, "")
+
+	return entries
+}
+
+// unmatchedByTitle groups the unmatched entries by case-insensitive title
+func unmatchedByTitle(entries []*diffEntry) map[string][]*diffEntry {
+	byTitle := make(map[string][]*diffEntry)
+	for _, entry := range entries {
+		if !entry.matched {
+			title := strings.ToLower(entry.section.Title)
+			byTitle[title] = append(byTitle[title], entry)
+		}
+	}
+	return byTitle
+}
+
+// newDiffSection describes an added or removed section
+func newDiffSection(entry *diffEntry) DiffSection {
+	return DiffSection{
+		Path:      entry.path,
+		Level:     entry.section.Level,
+		ID:        entry.section.ID,
+		CharCount: entry.section.CharCount,
+	}
+}
+
+// SectionHashChanges compares the content hashes a client recorded for the
+// sections of a document with their current hashes, so that only the
+// sections whose text changed need to be fetched again
+type SectionHashChanges struct {
+	FilePath  string          `json:"file_path"`
+	Changed   []HashedSection `json:"changed"`
+	Added     []HashedSection `json:"added"`
+	Removed   []string        `json:"removed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// HashedSection is a section reported by CompareSectionHashes, with its
+// current content hash and, for a changed section, the hash recorded before
+type HashedSection struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Level       int    `json:"level"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	ContentHash string `json:"content_hash"`
+	PriorHash   string `json:"prior_hash,omitempty"`
+}
+
+// CompareSectionHashes compares priorHashes, the content hash a client
+// recorded for each section ID, with the sections of structure. Sections
+// whose hash differs are Changed and sections missing from priorHashes are
+// Added, both in document order; IDs of priorHashes no longer in the
+// document are Removed, sorted. As a section's hash covers only its own
+// text (see types.Section), editing a subsection changes that subsection
+// alone.
+func CompareSectionHashes(structure *types.DocumentStructure, priorHashes map[string]string) *SectionHashChanges {
+	changes := &SectionHashChanges{
+		FilePath: structure.FilePath,
+		Changed:  []HashedSection{},
+		Added:    []HashedSection{},
+		Removed:  []string{},
+	}
+
+	current := make(map[string]bool)
+	var walk func(sections []types.Section)
+	walk = func(sections []types.Section) {
+		for _, section := range sections {
+			current[section.ID] = true
+
+			hashed := HashedSection{
+				ID:          section.ID,
+				Title:       section.Title,
+				Level:       section.Level,
+				StartLine:   section.StartLine,
+				EndLine:     section.EndLine,
+				ContentHash: section.ContentHash,
+			}
+			switch prior, known := priorHashes[section.ID]; {
+			case !known:
+				changes.Added = append(changes.Added, hashed)
+			case prior != section.ContentHash:
+				hashed.PriorHash = prior
+				changes.Changed = append(changes.Changed, hashed)
+			default:
+				changes.Unchanged++
+			}
+
+			walk(section.Children)
+		}
+	}
+	walk(structure.Structure)
+
+	for id := range priorHashes {
+		if !current[id] {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+	sort.Strings(changes.Removed)
+
+	return changes
+}
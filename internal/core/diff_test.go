@@ -0,0 +1,163 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffStructures(t *testing.T) {
+	parser := NewParser()
+
+	oldContent := `# Guide
+
+## Install
+
+Install the tool.
+
+## Configure
+
+Short.
+
+### Options
+
+## Legacy
+
+Old notes.
+`
+	newContent := `# Guide
+
+## Install
+
+Install the tool.
+
+### Options
+
+## Configure
+
+Short, but now with a much longer explanation of every setting.
+
+## Usage
+
+Run it.
+`
+
+	oldStructure, err := parser.ParseStructure([]byte(oldContent))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	newStructure, err := parser.ParseStructure([]byte(newContent))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	diff := DiffStructures(oldStructure, newStructure)
+
+	if len(diff.Added) != 1 || diff.Added[0].Path != "Guide/Usage" {
+		t.Errorf("Expected Guide/Usage to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "Guide/Legacy" {
+		t.Errorf("Expected Guide/Legacy to be removed, got %+v", diff.Removed)
+	}
+
+	changes := make(map[string]SectionChange)
+	for _, change := range diff.Changed {
+		changes[change.Path] = change
+	}
+	if len(changes) != 2 {
+		t.Errorf("Expected 2 changed sections, got %+v", diff.Changed)
+	}
+
+	// Options moved from Configure to Install
+	moved, exists := changes["Guide/Install/Options"]
+	if !exists || moved.OldPath != "Guide/Configure/Options" || strings.Join(moved.Changes, ",") != ChangeMoved {
+		t.Errorf("Expected Options to be moved from Guide/Configure/Options, got %+v", moved)
+	}
+
+	// Configure's own text grew; its lost subsection does not count
+	resized, exists := changes["Guide/Configure"]
+	if !exists || strings.Join(resized.Changes, ",") != ChangeResized {
+		t.Errorf("Expected Configure to be resized, got %+v", resized)
+	}
+	if exists && resized.NewCharCount <= resized.OldCharCount {
+		t.Errorf("Expected Configure to grow, got %d -> %d", resized.OldCharCount, resized.NewCharCount)
+	}
+}
+
+func TestDiffStructuresLevelChange(t *testing.T) {
+	parser := NewParser()
+
+	oldStructure, err := parser.ParseStructure([]byte("# Title\n\n## Notes\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	newStructure, err := parser.ParseStructure([]byte("# Title\n\n### Notes\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	diff := DiffStructures(oldStructure, newStructure)
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Expected 1 changed section, got %+v", diff.Changed)
+	}
+
+	change := diff.Changed[0]
+	if change.Path != "Title/Notes" || change.OldLevel != 2 || change.NewLevel != 3 || strings.Join(change.Changes, ",") != ChangeLevel {
+		t.Errorf("Expected a level change of Title/Notes from 2 to 3, got %+v", change)
+	}
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("Expected no added or removed sections, got %+v and %+v", diff.Added, diff.Removed)
+	}
+}
+
+func TestCompareSectionHashes(t *testing.T) {
+	parser := NewParser()
+
+	oldContent := "# Guide\n\nIntro.\n\n## Install\n\nRun make.\n\n## Configure\n\nEdit the file.\n\n## Legacy\n\nOld notes.\n"
+	newContent := "# Guide\n\nIntro.\n\n## Install\n\nRun make install.\n\n## Configure\n\nEdit the file.\n\n## Usage\n\nRun it.\n"
+
+	oldStructure, err := parser.ParseStructure([]byte(oldContent))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	newStructure, err := parser.ParseStructure([]byte(newContent))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	priorHashes := make(map[string]string)
+	ids := make(map[string]string)
+	for _, section := range parser.FlattenStructure(oldStructure.Structure) {
+		priorHashes[section.ID] = section.ContentHash
+		ids[section.Title] = section.ID
+	}
+
+	// Only Install was edited; its parent Guide keeps its hash
+	changes := CompareSectionHashes(newStructure, priorHashes)
+	if len(changes.Changed) != 1 || changes.Changed[0].Title != "Install" {
+		t.Fatalf("Expected only Install changed, got %+v", changes.Changed)
+	}
+	changed := changes.Changed[0]
+	if changed.ID != ids["Install"] || changed.PriorHash != priorHashes[ids["Install"]] || changed.ContentHash == changed.PriorHash {
+		t.Errorf("Expected Install with its prior and new hashes, got %+v", changed)
+	}
+
+	if len(changes.Added) != 1 || changes.Added[0].Title != "Usage" || changes.Added[0].ContentHash == "" {
+		t.Errorf("Expected Usage added with its hash, got %+v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != ids["Legacy"] {
+		t.Errorf("Expected Legacy removed, got %v", changes.Removed)
+	}
+	if changes.Unchanged != 2 {
+		t.Errorf("Expected Guide and Configure unchanged, got %d", changes.Unchanged)
+	}
+
+	// Comparing with the current hashes reports nothing
+	current := make(map[string]string)
+	for _, section := range parser.FlattenStructure(newStructure.Structure) {
+		current[section.ID] = section.ContentHash
+	}
+	changes = CompareSectionHashes(newStructure, current)
+	if len(changes.Changed) != 0 || len(changes.Added) != 0 || len(changes.Removed) != 0 || changes.Unchanged != 4 {
+		t.Errorf("Expected no changes against the current hashes, got %+v", changes)
+	}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// diskCacheVersion is bumped whenever the on-disk format or the parser output
+// changes, so entries written by older versions are ignored
+const diskCacheVersion = 14
+
+// DiskCache persists parsed document structures across processes. Entries are
+// validated against the file's modification time and hash before use, and
+// are written atomically so concurrent processes never see partial files.
+type DiskCache struct {
+	dir string
+}
+
+// diskCacheFile is the serialized form of a disk cache entry
+type diskCacheFile struct {
+	Version  int        `json:"version"`
+	FilePath string     `json:"file_path"`
+	Options  string     `json:"options"`
+	Entry    CacheEntry `json:"entry"`
+}
+
+// NewDiskCache creates a disk cache in dir, creating the directory if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cache directory: %w", err)
+	}
+
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &DiskCache{dir: absDir}, nil
+}
+
+// Dir returns the cache directory
+func (dc *DiskCache) Dir() string {
+	return dc.dir
+}
+
+// GetStructure loads the cached entry for filePath parsed with options. It
+// reports false if there is no entry or the file changed since it was cached.
+func (dc *DiskCache) GetStructure(filePath string, options ParserOptions) (*CacheEntry, bool) {
+	data, err := os.ReadFile(dc.entryPath(filePath, options))
+	if err != nil {
+		return nil, false
+	}
+
+	var file diskCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, false
+	}
+
+	if file.Version != diskCacheVersion || file.FilePath != filePath || file.Options != optionsKey(options) {
+		return nil, false
+	}
+
+	if file.Entry.Structure == nil || !isFileUnchanged(filePath, &file.Entry) {
+		return nil, false
+	}
+
+	return &file.Entry, true
+}
+
+// SetStructure stores the structure for filePath parsed with options. Errors
+// are returned so callers can report them, but a failed write only means the
+// next process parses the file again.
+func (dc *DiskCache) SetStructure(filePath string, options ParserOptions, entry *CacheEntry) error {
+	data, err := json.Marshal(diskCacheFile{
+		Version:  diskCacheVersion,
+		FilePath: filePath,
+		Options:  optionsKey(options),
+		Entry:    *entry,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temporary file and rename it into place so readers see
+	// either the old entry or the new one, never a partial write
+	tmp, err := os.CreateTemp(dc.dir, ".entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dc.entryPath(filePath, options)); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// entryPath returns the cache file for filePath parsed with options
+func (dc *DiskCache) entryPath(filePath string, options ParserOptions) string {
+	sum := sha256.Sum256([]byte(filePath + "\x00" + optionsKey(options)))
+	return filepath.Join(dc.dir, fmt.Sprintf("%x.json", sum[:16]))
+}
+
+// optionsKey identifies the parser options an entry was produced with, since
+// they change section IDs and counts
+func optionsKey(options ParserOptions) string {
+	return fmt.Sprintf("%+v", options)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupportedEncoding is returned for documents that are not UTF-8, as
+// recognized by a UTF-16 or UTF-32 byte order mark
+var ErrUnsupportedEncoding = errors.New("unsupported encoding")
+
+// utf8BOM is the UTF-8 encoding of U+FEFF, which some editors write at the
+// start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// byteOrderMarks are the byte order marks of the encodings mdatlas does not
+// read. UTF-32 LE comes before UTF-16 LE, whose mark it starts with.
+var byteOrderMarks = []struct {
+	encoding string
+	mark     []byte
+}{
+	{"UTF-32BE", []byte{0x00, 0x00, 0xFE, 0xFF}},
+	{"UTF-32LE", []byte{0xFF, 0xFE, 0x00, 0x00}},
+	{"UTF-16BE", []byte{0xFE, 0xFF}},
+	{"UTF-16LE", []byte{0xFF, 0xFE}},
+}
+
+// DecodeContent returns content without a leading UTF-8 byte order mark, so
+// it neither leaks into the first line nor counts towards its size. Content
+// starting with a UTF-16 or UTF-32 byte order mark is rejected with
+// ErrUnsupportedEncoding rather than parsed as garbled UTF-8.
+func DecodeContent(content []byte) ([]byte, error) {
+	if bytes.HasPrefix(content, utf8BOM) {
+		return content[len(utf8BOM):], nil
+	}
+
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(content, bom.mark) {
+			return nil, fmt.Errorf("%w: %s (convert the document to UTF-8)", ErrUnsupportedEncoding, bom.encoding)
+		}
+	}
+
+	return content, nil
+}
+
+// NormalizeLineEndings returns content with Windows (CRLF) and old Mac (lone
+// CR) line endings converted to LF, so lines are counted and sliced the same
+// way whichever convention a document was written with. Content without a
+// carriage return is returned as is.
+func NormalizeLineEndings(content []byte) []byte {
+	if bytes.IndexByte(content, '\r') < 0 {
+		return content
+	}
+
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(normalized, []byte("\r"), []byte("\n"))
+}
+
+// lineEndingReader normalizes line endings like NormalizeLineEndings while
+// content is streamed through it
+type lineEndingReader struct {
+	r      io.Reader
+	skipLF bool // Whether the last byte read was a CR, already turned into LF
+}
+
+// Read implements io.Reader, rewriting p in place: each CR becomes LF and an
+// LF right after a CR is dropped, so the output is never longer than the input
+func (l *lineEndingReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	written := 0
+	for _, b := range p[:n] {
+		if b == '\n' && l.skipLF {
+			l.skipLF = false
+			continue
+		}
+		l.skipLF = b == '\r'
+		if b == '\r' {
+			b = '\n'
+		}
+		p[written] = b
+		written++
+	}
+	return written, err
+}
+
+// decode returns content as the parser reads it: without a UTF-8 byte order
+// mark, as by DecodeContent, and with normalized line endings unless
+// ParserOptions.KeepLineEndings is set
+func (p *Parser) decode(content []byte) ([]byte, error) {
+	content, err := DecodeContent(content)
+	if err != nil || p.options.KeepLineEndings {
+		return content, err
+	}
+	return NormalizeLineEndings(content), nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter describes a YAML front matter block at the top of a document
+type frontMatter struct {
+	Data  map[string]interface{}
+	End   int // Byte offset just past the closing delimiter line
+	Lines int // Number of lines occupied by the block, including delimiters
+}
+
+// detectFrontMatter detects a '---' delimited YAML front matter block at the
+// very beginning of content. It returns nil if the document has no front
+// matter or the block is never closed. A block whose YAML fails to parse is
+// still reported so that its lines are not treated as Markdown, but Data is nil.
+func detectFrontMatter(content []byte) *frontMatter {
+	firstEnd := lineEnd(content, 0)
+	if !isFrontMatterDelimiter(content[:firstEnd], false) {
+		return nil
+	}
+
+	for offset := firstEnd; offset < len(content); {
+		next := lineEnd(content, offset)
+		if isFrontMatterDelimiter(content[offset:next], true) {
+			fm := &frontMatter{
+				End:   next,
+				Lines: bytes.Count(content[:next], []byte("\n")),
+			}
+			if next == len(content) && !bytes.HasSuffix(content, []byte("\n")) {
+				fm.Lines++
+			}
+
+			var data map[string]interface{}
+			if err := yaml.Unmarshal(content[firstEnd:offset], &data); err == nil {
+				fm.Data = data
+			}
+			return fm
+		}
+		offset = next
+	}
+
+	return nil
+}
+
+// isFrontMatterDelimiter reports whether a line is a front matter delimiter.
+// The closing delimiter may also be written as '...'.
+func isFrontMatterDelimiter(line []byte, closing bool) bool {
+	line = bytes.TrimRight(line, " \t\r\n")
+	if bytes.Equal(line, []byte("---")) {
+		return true
+	}
+	return closing && bytes.Equal(line, []byte("..."))
+}
+
+// maskFrontMatter returns a copy of content with the front matter block
+// replaced by empty lines, so the Markdown parser ignores it while line
+// numbers of the remaining content are preserved
+func maskFrontMatter(content []byte, fm *frontMatter) []byte {
+	masked := make([]byte, 0, len(content)-fm.End+fm.Lines)
+	masked = append(masked, bytes.Repeat([]byte("\n"), bytes.Count(content[:fm.End], []byte("\n")))...)
+	return append(masked, content[fm.End:]...)
+}
+
+// FrontMatterOrder returns the indexes of paths ordered by the value of key
+// in the front matter of their documents, as static site generators order
+// pages by a weight or order key; structures[i] is the structure of
+// paths[i]. Documents setting key come first, by ascending value, numbers
+// before other values, which compare as strings. Documents without key
+// follow, and documents with equal values are ordered by path.
+func FrontMatterOrder(paths []string, structures []*types.DocumentStructure, key string) []int {
+	type sortKey struct {
+		present bool
+		numeric bool
+		number  float64
+		text    string
+	}
+
+	keys := make([]sortKey, len(paths))
+	for i, structure := range structures {
+		value, ok := structure.FrontMatter[key]
+		if !ok || value == nil {
+			continue
+		}
+		keys[i].present = true
+		switch v := value.(type) {
+		case int:
+			keys[i].numeric, keys[i].number = true, float64(v)
+		case int64:
+			keys[i].numeric, keys[i].number = true, float64(v)
+		case float64:
+			keys[i].numeric, keys[i].number = true, v
+		default:
+			keys[i].text = fmt.Sprint(v)
+		}
+	}
+
+	order := make([]int, len(paths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		switch {
+		case ka.present != kb.present:
+			return ka.present
+		case ka.numeric != kb.numeric:
+			return ka.numeric
+		case ka.numeric && ka.number != kb.number:
+			return ka.number < kb.number
+		case !ka.numeric && ka.text != kb.text:
+			return ka.text < kb.text
+		}
+		return paths[order[a]] < paths[order[b]]
+	})
+
+	return order
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+func TestFrontMatterOrder(t *testing.T) {
+	parser := NewParser()
+	parse := func(content string) *types.DocumentStructure {
+		structure, err := parser.ParseStructure([]byte(content))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+		return structure
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		key      string
+		expected []string
+	}{
+		{
+			name: "Weights differ from alphabetical order",
+			files: map[string]string{
+				"alpha.md": "---\nweight: 30\n---\n# Alpha\n",
+				"beta.md":  "---\nweight: 10\n---\n# Beta\n",
+				"gamma.md": "---\nweight: 20\n---\n# Gamma\n",
+			},
+			key:      "weight",
+			expected: []string{"beta.md", "gamma.md", "alpha.md"},
+		},
+		{
+			name: "Files without the key last in path order",
+			files: map[string]string{
+				"alpha.md": "# Alpha\n",
+				"beta.md":  "---\ntitle: Beta\n---\n# Beta\n",
+				"gamma.md": "---\norder: 1.5\n---\n# Gamma\n",
+			},
+			key:      "order",
+			expected: []string{"gamma.md", "alpha.md", "beta.md"},
+		},
+		{
+			name: "Equal weights in path order, numbers before strings",
+			files: map[string]string{
+				"alpha.md": "---\nweight: first\n---\n# Alpha\n",
+				"beta.md":  "---\nweight: 2\n---\n# Beta\n",
+				"gamma.md": "---\nweight: 2\n---\n# Gamma\n",
+			},
+			key:      "weight",
+			expected: []string{"beta.md", "gamma.md", "alpha.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Pass the files in reverse path order, so the result does not
+			// depend on the input order
+			paths := []string{"gamma.md", "beta.md", "alpha.md"}
+			structures := make([]*types.DocumentStructure, len(paths))
+			for i, path := range paths {
+				structures[i] = parse(tt.files[path])
+			}
+
+			var ordered []string
+			for _, i := range FrontMatterOrder(paths, structures, tt.key) {
+				ordered = append(ordered, paths[i])
+			}
+			if !reflect.DeepEqual(ordered, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ordered)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IgnoreFileName is the ignore file, in .gitignore syntax, whose patterns
+// ListAllowedFiles applies relative to the base directory when it exists
+const IgnoreFileName = ".mdatlasignore"
+
+// ignoreRule is a single exclude glob or .gitignore pattern, split into
+// slash-separated segments. A "**" segment matches zero or more segments.
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreMatcher decides whether a path relative to the base directory is
+// excluded. Rules are evaluated in order and the last matching rule wins, so
+// a negated .gitignore pattern can re-include a file.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// validateExcludePattern checks that an exclude glob is well formed
+func validateExcludePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("exclude pattern must not be empty")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// splitGlob validates a glob and splits it into slash-separated segments for
+// matchSegments
+func splitGlob(pattern string) ([]string, error) {
+	segments := strings.Split(strings.Trim(filepath.ToSlash(pattern), "/"), "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return segments, nil
+}
+
+// newIgnoreMatcher builds a matcher from the base directory's .gitignore
+// (when useGitignore is set), then the rules of its ignore file, then the
+// exclude globs. A negated ignore file pattern can re-include a path the
+// .gitignore excludes, but exclude globs are matched against the whole
+// relative path and always take precedence.
+func newIgnoreMatcher(baseDir string, excludePatterns []string, useGitignore bool, fileRules []ignoreRule) (*ignoreMatcher, error) {
+	matcher := &ignoreMatcher{}
+
+	if useGitignore {
+		rules, err := readIgnoreRules(filepath.Join(baseDir, ".gitignore"))
+		if err != nil {
+			return nil, err
+		}
+		matcher.rules = append(matcher.rules, rules...)
+	}
+	matcher.rules = append(matcher.rules, fileRules...)
+
+	for _, pattern := range excludePatterns {
+		pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+		matcher.rules = append(matcher.rules, ignoreRule{segments: strings.Split(pattern, "/")})
+	}
+
+	return matcher, nil
+}
+
+// readIgnoreRules parses a file of .gitignore patterns. A missing file has
+// no rules and is not an error.
+func readIgnoreRules(ignorePath string) ([]ignoreRule, error) {
+	file, err := os.Open(ignorePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignorePath, err)
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// ignoreFile keeps the parsed rules of an ignore file, so that listings
+// parse it once and again only after its size or modification time changes
+type ignoreFile struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	modTime time.Time
+	size    int64
+	rules   []ignoreRule
+}
+
+// newIgnoreFile returns the not yet parsed ignore file at ignorePath
+func newIgnoreFile(ignorePath string) *ignoreFile {
+	return &ignoreFile{path: ignorePath}
+}
+
+// Rules returns the rules of the ignore file, parsing it if it changed
+// since the last call, or none if it does not exist
+func (f *ignoreFile) Rules() ([]ignoreRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		f.loaded, f.rules = false, nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if f.loaded && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.rules, nil
+	}
+
+	rules, err := readIgnoreRules(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.loaded, f.modTime, f.size, f.rules = true, info.ModTime(), info.Size(), rules
+	return rules, nil
+}
+
+// parseGitignoreLine converts a .gitignore line into a rule. Blank lines and
+// comments yield false.
+func parseGitignoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A pattern without a slash matches at any depth; otherwise it is
+	// relative to the directory containing the .gitignore
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// Match reports whether relPath (slash or OS separated, relative to the base
+// directory) is excluded
+func (m *ignoreMatcher) Match(relPath string, isDir bool) bool {
+	if len(m.rules) == 0 {
+		return false
+	}
+
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	excluded := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			excluded = !rule.negate
+		}
+	}
+
+	return excluded
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more whole segments and other segments use path.Match
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
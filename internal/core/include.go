@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// DefaultMaxIncludeDepth is how deeply includes may nest when an
+// IncludeResolver is created without a limit
+const DefaultMaxIncludeDepth = 8
+
+// Errors returned by IncludeResolver besides those of AccessControl, which
+// refuses includes outside the base directory, without an allowed extension,
+// missing or too large
+var (
+	ErrIncludeCycle = errors.New("include cycle")
+	ErrIncludeDepth = errors.New("includes nested too deeply")
+)
+
+// includePatterns match a line consisting of nothing but an include
+// directive, in Liquid ({% include "other.md" %}) or Obsidian (![[other.md]])
+// syntax, capturing the included path
+var includePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*\{%-?\s*include\s+["']([^"']+)["']\s*-?%\}\s*$`),
+	regexp.MustCompile(`^\s*!\[\[([^\]|#]+)\]\]\s*$`),
+}
+
+// IncludeResolver inlines the files referenced by include directives, so a
+// document is parsed as it reads once its transclusions are expanded
+type IncludeResolver struct {
+	accessControl *AccessControl
+	maxDepth      int
+}
+
+// NewIncludeResolver creates an IncludeResolver that reads included files
+// only if accessControl allows them, and fails on includes nested more than
+// maxDepth levels deep (DefaultMaxIncludeDepth if maxDepth is 0 or less)
+func NewIncludeResolver(accessControl *AccessControl, maxDepth int) *IncludeResolver {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxIncludeDepth
+	}
+	return &IncludeResolver{accessControl: accessControl, maxDepth: maxDepth}
+}
+
+// ExpandedDocument is a document with its includes inlined. Every line of
+// Content ends with a newline.
+type ExpandedDocument struct {
+	Content []byte
+
+	// sources holds, for each line of Content, the file it was included
+	// from relative to the base directory, or "" for the expanding document
+	sources []string
+}
+
+// Expand replaces each line of content, the content of filePath, holding
+// nothing but an include directive with the lines of the included file,
+// whose own includes are expanded in turn. Included paths are resolved
+// against the directory of the including file, or the base directory for an
+// empty filePath, and read through ValidatePath; directives inside fenced
+// code blocks are left as written. Content must already be decoded, as by
+// DecodeContent; included files are decoded and their line endings
+// normalized.
+func (r *IncludeResolver) Expand(filePath string, content []byte) (*ExpandedDocument, error) {
+	dir := r.accessControl.config.BaseDir
+	var stack []string
+	if filePath != "" {
+		absPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path: %w", err)
+		}
+		dir = filepath.Dir(absPath)
+		stack = append(stack, absPath)
+	}
+
+	doc := &ExpandedDocument{}
+	var buf bytes.Buffer
+	if err := r.expand(&buf, doc, content, dir, "", stack); err != nil {
+		return nil, err
+	}
+	doc.Content = buf.Bytes()
+
+	return doc, nil
+}
+
+// expand writes the lines of content, read from source in dir, to buf,
+// expanding its includes. stack lists the files being expanded, outermost
+// first, to detect cycles.
+func (r *IncludeResolver) expand(buf *bytes.Buffer, doc *ExpandedDocument, content []byte, dir, source string, stack []string) error {
+	writeLine := func(line string) {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		doc.sources = append(doc.sources, source)
+	}
+
+	var fenceChar byte
+	var fenceLength int
+	lines := strings.Split(string(content), "\n")[:countLines(content)]
+	for i, line := range lines {
+		if fenceLength > 0 {
+			if isClosingFence(line, fenceChar, fenceLength) {
+				fenceLength = 0
+			}
+			writeLine(line)
+			continue
+		}
+		if char, length := openingFence(line); length > 0 {
+			fenceChar, fenceLength = char, length
+			writeLine(line)
+			continue
+		}
+
+		target, ok := includeTarget(line)
+		if !ok {
+			writeLine(line)
+			continue
+		}
+
+		where := fmt.Sprintf("include %q on line %d", target, i+1)
+		if source != "" {
+			where += " of " + source
+		}
+		if len(stack) > r.maxDepth {
+			return fmt.Errorf("%w: %s is more than %d levels deep", ErrIncludeDepth, where, r.maxDepth)
+		}
+
+		path := target
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		validPath, err := r.accessControl.ValidatePath(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		for _, including := range stack {
+			if including == validPath {
+				return fmt.Errorf("%w: %s includes a file that includes it", ErrIncludeCycle, where)
+			}
+		}
+
+		included, err := os.ReadFile(validPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if included, err = DecodeContent(included); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+
+		relPath, err := filepath.Rel(r.accessControl.config.BaseDir, validPath)
+		if err != nil {
+			relPath = validPath
+		}
+		if err := r.expand(buf, doc, NormalizeLineEndings(included), filepath.Dir(validPath), relPath, append(stack, validPath)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includeTarget returns the path of the file included by line, if the line
+// is an include directive
+func includeTarget(line string) (string, bool) {
+	for _, pattern := range includePatterns {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			return strings.TrimSpace(match[1]), true
+		}
+	}
+	return "", false
+}
+
+// SetSourceFiles sets the SourceFile of the sections of structure, parsed
+// from the expanded content, whose heading comes from an included file
+func (d *ExpandedDocument) SetSourceFiles(structure *types.DocumentStructure) {
+	var walk func(sections []types.Section)
+	walk = func(sections []types.Section) {
+		for i := range sections {
+			if line := sections[i].StartLine; line >= 1 && line <= len(d.sources) {
+				sections[i].SourceFile = d.sources[line-1]
+			}
+			walk(sections[i].Children)
+		}
+	}
+	walk(structure.Structure)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// expandFile expands the includes of name, a file written with files into a
+// new base directory
+func expandFile(t *testing.T, files map[string]string, name string) (*ExpandedDocument, error) {
+	t.Helper()
+
+	baseDir := t.TempDir()
+	for file, content := range files {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+	path := filepath.Join(baseDir, filepath.FromSlash(name))
+	return NewIncludeResolver(ac, 0).Expand(path, []byte(files[name]))
+}
+
+func TestIncludeResolverExpand(t *testing.T) {
+	doc, err := expandFile(t, map[string]string{
+		"book.md":         "# Book\n\n{% include \"chapters/one.md\" %}\n\n```\n![[chapters/two.md]]\n```\n",
+		"chapters/one.md": "## One\n\nFirst chapter.\n\n![[two.md]]\n",
+		"chapters/two.md": "## Two\n\nSecond chapter.\n",
+	}, "book.md")
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	want := "# Book\n\n## One\n\nFirst chapter.\n\n## Two\n\nSecond chapter.\n\n```\n![[chapters/two.md]]\n```\n"
+	if string(doc.Content) != want {
+		t.Fatalf("Expected expanded content %q, got %q", want, doc.Content)
+	}
+
+	structure, err := NewParser().ParseStructure(doc.Content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	doc.SetSourceFiles(structure)
+
+	book := structure.Structure[0]
+	if book.SourceFile != "" || len(book.Children) != 2 {
+		t.Fatalf("Expected the book from the root file with two chapters, got %+v", book)
+	}
+	one, two := book.Children[0], book.Children[1]
+	if filepath.ToSlash(one.SourceFile) != "chapters/one.md" || one.StartLine != 3 {
+		t.Errorf("Expected One from chapters/one.md at line 3, got %q at line %d", one.SourceFile, one.StartLine)
+	}
+	if filepath.ToSlash(two.SourceFile) != "chapters/two.md" || two.StartLine != 7 {
+		t.Errorf("Expected Two from chapters/two.md at line 7, got %q at line %d", two.SourceFile, two.StartLine)
+	}
+}
+
+func TestIncludeResolverErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr error
+	}{
+		{
+			name:    "missing include",
+			files:   map[string]string{"doc.md": "# Doc\n\n![[missing.md]]\n"},
+			wantErr: ErrFileNotFound,
+		},
+		{
+			name:    "include outside base directory",
+			files:   map[string]string{"doc.md": "{% include \"../secret.md\" %}\n"},
+			wantErr: ErrOutsideBaseDir,
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"doc.md": "# Doc\n\n![[a.md]]\n",
+				"a.md":   "## A\n\n![[b.md]]\n",
+				"b.md":   "## B\n\n![[a.md]]\n",
+			},
+			wantErr: ErrIncludeCycle,
+		},
+		{
+			name:    "self include",
+			files:   map[string]string{"doc.md": "# Doc\n\n![[doc.md]]\n"},
+			wantErr: ErrIncludeCycle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expandFile(t, tt.files, "doc.md")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIncludeResolverMaxDepth(t *testing.T) {
+	files := map[string]string{"doc.md": "![[1.md]]\n"}
+	for i := 1; i <= DefaultMaxIncludeDepth+1; i++ {
+		files[strconv.Itoa(i)+".md"] = "![[" + strconv.Itoa(i+1) + ".md]]\n"
+	}
+	files[strconv.Itoa(DefaultMaxIncludeDepth+2)+".md"] = "# Deep\n"
+
+	if _, err := expandFile(t, files, "doc.md"); !errors.Is(err, ErrIncludeDepth) {
+		t.Errorf("Expected ErrIncludeDepth, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// ReparseStructure returns the structure of content, an edited version of
+// the document whose structure, parsed with the same options, is previous.
+// The heading lines of content are found by the line scanner of
+// ScanStructure and compared by hash with the raw titles of previous. When
+// the edit left the headings as they were, in the same order, only the section
+// boundaries and counts are recomputed from the new line positions, without
+// building the Markdown AST, and the sections keep the titles and IDs of
+// previous. Otherwise content is parsed in full, as it is when previous is nil
+// or the document has lines the line scanner could misread: Setext headings,
+// block quotes, HTML blocks, indented code and headings in list items.
+func (p *Parser) ReparseStructure(previous *types.DocumentStructure, content []byte) (*types.DocumentStructure, error) {
+	return p.ReparseStructureContext(context.Background(), previous, content)
+}
+
+// ReparseStructureContext is ReparseStructure, abandoned with ctx's error
+// once ctx is done
+func (p *Parser) ReparseStructureContext(ctx context.Context, previous *types.DocumentStructure, content []byte) (*types.DocumentStructure, error) {
+	structure, ok, err := p.reparseIncrementally(ctx, previous, content)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return structure, nil
+	}
+	return p.ParseStructureContext(ctx, content)
+}
+
+// reparseIncrementally rescans content for the headings of previous,
+// reporting false if it cannot be reparsed that way
+func (p *Parser) reparseIncrementally(ctx context.Context, previous *types.DocumentStructure, content []byte) (*types.DocumentStructure, bool, error) {
+	if previous == nil {
+		return nil, false, nil
+	}
+
+	// Leave encoding errors to the full parse
+	content, err := p.decode(content)
+	if err != nil {
+		return nil, false, nil
+	}
+	if hasScannerBlindSpots(content) {
+		return nil, false, nil
+	}
+
+	sections := p.flattenSections(previous.Structure)
+	reuse := &headingReuse{
+		hashes: make([]uint64, len(sections)),
+		titles: make([]string, len(sections)),
+	}
+	for i, section := range sections {
+		// Setext headings and headings in block quotes or lists have raw
+		// titles the line scanner cannot match
+		if level, _, ok := parseATXHeading(section.RawTitle); !ok || level != section.Level || strings.Contains(section.RawTitle, "\n") {
+			return nil, false, nil
+		}
+		reuse.hashes[i] = xxhash.Sum64String(section.RawTitle)
+		reuse.titles[i] = section.Title
+	}
+
+	structure, err := p.scanStructure(ctx, bytes.NewReader(content), reuse)
+	if err != nil {
+		return nil, false, err
+	}
+	if reuse.mismatch {
+		return nil, false, nil
+	}
+	return structure, true, nil
+}
+
+// hasScannerBlindSpots reports whether content has lines outside code fences
+// and front matter that the Markdown parser may read differently from the
+// line scanner, so that a scan could miss headings or count code as prose
+func hasScannerBlindSpots(content []byte) bool {
+	offset := 0
+	if fm := detectFrontMatter(content); fm != nil {
+		offset = fm.End
+	}
+
+	var fenceChar byte
+	fenceLen := 0
+	previousBlank := true
+	for offset < len(content) {
+		end := lineEnd(content, offset)
+		line := strings.TrimRight(string(content[offset:end]), "\r\n")
+		offset = end
+
+		if fenceLen > 0 {
+			if isClosingFence(line, fenceChar, fenceLen) {
+				fenceLen = 0
+			}
+			previousBlank = false
+			continue
+		}
+		if char, length := openingFence(line); length > 0 {
+			fenceChar, fenceLen = char, length
+			previousBlank = false
+			continue
+		}
+
+		if isScannerBlindSpot(line, previousBlank) {
+			return true
+		}
+		previousBlank = strings.TrimSpace(line) == ""
+	}
+
+	return false
+}
+
+// isScannerBlindSpot reports whether a line outside code fences may start a
+// construct the line scanner does not follow: a Setext underline, block
+// quote, HTML block, indented code block or list item holding a heading
+func isScannerBlindSpot(line string, previousBlank bool) bool {
+	rest := strings.TrimLeft(line, " ")
+	if rest == "" || strings.TrimSpace(rest) == "" {
+		return false
+	}
+
+	// Indented code starts after a blank line; an indented heading may sit
+	// in a list item
+	if indent := len(line) - len(rest); indent > 3 || rest[0] == '\t' {
+		return previousBlank || strings.HasPrefix(strings.TrimSpace(rest), "#")
+	}
+
+	switch rest[0] {
+	case '>', '<':
+		return true
+	case '=', '-':
+		if run := strings.TrimRight(rest, " \t"); strings.Trim(run, string(rest[0])) == "" {
+			return true
+		}
+	}
+
+	if item, ok := trimListMarker(rest); ok && strings.HasPrefix(strings.TrimLeft(item, " \t"), "#") {
+		return true
+	}
+
+	return false
+}
+
+// trimListMarker returns a line without its leading list item marker, such
+// as "- ", "* ", "+ ", "1. " or "1) ", reporting false if it has none
+func trimListMarker(line string) (string, bool) {
+	if len(line) >= 2 && strings.ContainsRune("-*+", rune(line[0])) && (line[1] == ' ' || line[1] == '\t') {
+		return line[2:], true
+	}
+
+	digits := 0
+	for digits < len(line) && digits < 9 && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits+1 < len(line) && (line[digits] == '.' || line[digits] == ')') && (line[digits+1] == ' ' || line[digits+1] == '\t') {
+		return line[digits+2:], true
+	}
+
+	return "", false
+}
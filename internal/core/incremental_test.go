@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReparseStructureMatchesParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      string
+		after       string
+		options     ParserOptions
+		incremental bool
+	}{
+		{
+			name:        "body edit",
+			before:      "# Guide\n\nIntro text.\n\n## Install\n\nRun the installer.\n\n## Usage\n\nRun it.\n",
+			after:       "# Guide\n\nIntro text, now longer.\n\nAnother paragraph.\n\n## Install\n\nRun the installer.\n\n## Usage\n\nRun it.\n",
+			incremental: true,
+		},
+		{
+			name:        "inline markup in titles",
+			before:      "# Using `mdatlas` **fast**\n\nText.\n\n## [Links](https://example.com)\n\nMore.\n",
+			after:       "# Using `mdatlas` **fast**\n\nEdited text.\n\n## [Links](https://example.com)\n\nMore.\n",
+			incremental: true,
+		},
+		{
+			name:        "front matter, preamble and code fences",
+			before:      "---\ntitle: Guide\n---\nIntro.\n\n# Guide\n\n```sh\n# not a heading\n```\n",
+			after:       "---\ntitle: Guide\n---\nA longer intro.\n\n# Guide\n\n```sh\n# still not a heading\necho\n```\n",
+			incremental: true,
+		},
+		{
+			name:        "repeated titles with slug IDs",
+			before:      "# API\n\n## Examples\n\nOne.\n\n## Examples\n\nTwo.\n",
+			after:       "# API\n\n## Examples\n\nOne, edited.\n\n## Examples\n\nTwo.\n",
+			options:     ParserOptions{IDStyle: IDStyleSlug},
+			incremental: true,
+		},
+		{
+			name:        "ignored levels",
+			before:      "# Guide\n\n##### Note\n\nText.\n",
+			after:       "# Guide\n\n##### Note\n\nMore text.\n",
+			options:     ParserOptions{IgnoreLevels: []int{5}},
+			incremental: true,
+		},
+		{
+			name:   "changed heading",
+			before: "# Guide\n\n## Install\n\nText.\n",
+			after:  "# Guide\n\n## Installation\n\nText.\n",
+		},
+		{
+			name:   "added heading",
+			before: "# Guide\n\nText.\n",
+			after:  "# Guide\n\nText.\n\n## Usage\n",
+		},
+		{
+			name:   "removed heading",
+			before: "# Guide\n\n## Install\n\nText.\n",
+			after:  "# Guide\n\nText.\n",
+		},
+		{
+			name:   "heading hidden in a new code fence",
+			before: "# Guide\n\n## Install\n",
+			after:  "# Guide\n\n```\n## Install\n```\n",
+		},
+		{
+			name:   "new Setext heading",
+			before: "# Guide\n\nText.\n",
+			after:  "# Guide\n\nUsage\n-----\n",
+		},
+		{
+			name:   "heading in a list item",
+			before: "# Guide\n\nText.\n",
+			after:  "# Guide\n\n- # Item\n",
+		},
+		{
+			name:   "indented code",
+			before: "# Guide\n\nText.\n",
+			after:  "# Guide\n\n    code words here\n",
+		},
+		{
+			name:   "block quote",
+			before: "# Guide\n\nText.\n",
+			after:  "# Guide\n\n> # Quoted\n",
+		},
+		{
+			name:   "Setext heading before",
+			before: "Guide\n=====\n\nText.\n",
+			after:  "Guide\n=====\n\nMore text.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParserWithOptions(tt.options)
+
+			previous, err := parser.ParseStructure([]byte(tt.before))
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+			expected, err := parser.ParseStructure([]byte(tt.after))
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+
+			_, incremental, err := parser.reparseIncrementally(context.Background(), previous, []byte(tt.after))
+			if err != nil {
+				t.Fatalf("reparseIncrementally failed: %v", err)
+			}
+			if incremental != tt.incremental {
+				t.Errorf("Expected incremental reparse %v, got %v", tt.incremental, incremental)
+			}
+
+			reparsed, err := parser.ReparseStructure(previous, []byte(tt.after))
+			if err != nil {
+				t.Fatalf("ReparseStructure failed: %v", err)
+			}
+			expected.LastModified, reparsed.LastModified = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(expected, reparsed) {
+				t.Errorf("Reparsed structure differs from the parsed one\nparsed:   %+v\nreparsed: %+v", expected, reparsed)
+			}
+		})
+	}
+}
+
+func TestStructureManagerReparsesChangedFile(t *testing.T) {
+	filePath := writeLargeDocument(t, 10)
+	sm := NewStructureManager(NewCache(10, time.Minute))
+	if _, err := sm.GetDocumentStructure(filePath); err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read document: %v", err)
+	}
+	edited := strings.Replace(string(content), "Lorem ipsum", "Lorem ipsum, edited,", 5)
+	if err := os.WriteFile(filePath, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	expected, err := NewStructureManager(nil).GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	if !reflect.DeepEqual(expected, structure) {
+		t.Error("Expected the reparsed structure of the edited file to match a fresh parse")
+	}
+}
+
+// BenchmarkReparseStructure compares a full parse of an edited large
+// document with an incremental reparse based on the structure before the edit
+func BenchmarkReparseStructure(b *testing.B) {
+	content, err := os.ReadFile(writeLargeDocument(b, 2000))
+	if err != nil {
+		b.Fatalf("Failed to read document: %v", err)
+	}
+	edited := []byte(strings.Replace(string(content), "Lorem ipsum", "Lorem ipsum, edited,", 1))
+
+	parser := NewParser()
+	previous, err := parser.ParseStructure(content)
+	if err != nil {
+		b.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	b.Run("full", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.ParseStructure(edited); err != nil {
+				b.Fatalf("ParseStructure failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.ReparseStructure(previous, edited); err != nil {
+				b.Fatalf("ReparseStructure failed: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// Kinds of problems reported by LintStructure
+const (
+	IssueSkippedLevel   = "skipped_level"   // A heading is more than one level below its parent
+	IssueEmptyHeading   = "empty_heading"   // A heading has no text
+	IssueDuplicateTitle = "duplicate_title" // Sibling headings share a title, so their path is ambiguous
+)
+
+// LintStructure reports every structural problem in the document, unlike
+// ValidateStructure which stops at the first one
+func (sm *StructureManager) LintStructure(filePath string) ([]types.StructureIssue, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return LintSections(structure.Structure), nil
+}
+
+// LintSections reports structural problems in a section hierarchy, ordered
+// by line
+func LintSections(sections []types.Section) []types.StructureIssue {
+	issues := findSkippedLevels(sections)
+	lintSiblings(sections, &issues)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+
+	return issues
+}
+
+// findSkippedLevels reports sections more than one level below their parent.
+// Top-level sections may start at any level.
+func findSkippedLevels(sections []types.Section) []types.StructureIssue {
+	issues := []types.StructureIssue{}
+
+	var walk func(sections []types.Section, parentLevel int)
+	walk = func(sections []types.Section, parentLevel int) {
+		for _, section := range sections {
+			if parentLevel > 0 && section.Level > parentLevel+1 {
+				issues = append(issues, types.StructureIssue{
+					SectionID: section.ID,
+					Kind:      IssueSkippedLevel,
+					Message:   fmt.Sprintf("H%d heading %q follows an H%d parent, skipping a level", section.Level, section.Title, parentLevel),
+					Line:      section.StartLine,
+				})
+			}
+			walk(section.Children, section.Level)
+		}
+	}
+	walk(sections, 0)
+
+	return issues
+}
+
+// lintSiblings checks for empty headings and duplicate titles among sections
+// sharing a parent, and recurses into their children
+func lintSiblings(sections []types.Section, issues *[]types.StructureIssue) {
+	firstLines := make(map[string]int)
+
+	for _, section := range sections {
+		if section.Title == "" {
+			*issues = append(*issues, types.StructureIssue{
+				SectionID: section.ID,
+				Kind:      IssueEmptyHeading,
+				Message:   fmt.Sprintf("H%d heading has no text", section.Level),
+				Line:      section.StartLine,
+			})
+		} else {
+			// Titles are compared like heading paths, case-insensitively
+			key := strings.ToLower(section.Title)
+			if firstLine, exists := firstLines[key]; exists {
+				*issues = append(*issues, types.StructureIssue{
+					SectionID: section.ID,
+					Kind:      IssueDuplicateTitle,
+					Message:   fmt.Sprintf("heading %q duplicates the sibling heading on line %d", section.Title, firstLine),
+					Line:      section.StartLine,
+				})
+			} else {
+				firstLines[key] = section.StartLine
+			}
+		}
+
+		lintSiblings(section.Children, issues)
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestLintSections(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		name    string
+		content string
+		kinds   []string
+		lines   []int
+	}{
+		{
+			name:    "clean document",
+			content: "# Title\n\n## First\n\n### Nested\n\n## Second\n",
+		},
+		{
+			name:    "skipped level",
+			content: "# Title\n\n### Too Deep\n\n## Fine\n\n#### Also Too Deep\n",
+			kinds:   []string{IssueSkippedLevel, IssueSkippedLevel},
+			lines:   []int{3, 7},
+		},
+		{
+			name:    "top-level sections may start below H1",
+			content: "## Intro\n\n### Details\n",
+		},
+		{
+			name:    "empty heading",
+			content: "# Title\n\n##\n\nBody\n",
+			kinds:   []string{IssueEmptyHeading},
+			lines:   []int{3},
+		},
+		{
+			name:    "duplicate sibling titles",
+			content: "# Title\n\n## Notes\n\n## notes\n\n## Other\n\n### Notes\n",
+			kinds:   []string{IssueDuplicateTitle},
+			lines:   []int{5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structure, err := parser.ParseStructure([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+
+			issues := LintSections(structure.Structure)
+			if len(issues) != len(tt.kinds) {
+				t.Fatalf("Expected %d issues, got %+v", len(tt.kinds), issues)
+			}
+			for i, issue := range issues {
+				if issue.Kind != tt.kinds[i] || issue.Line != tt.lines[i] {
+					t.Errorf("Expected %s on line %d, got %s on line %d", tt.kinds[i], tt.lines[i], issue.Kind, issue.Line)
+				}
+				if issue.SectionID == "" || issue.Message == "" {
+					t.Errorf("Expected section ID and message, got %+v", issue)
+				}
+			}
+		})
+	}
+}
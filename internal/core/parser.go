@@ -2,141 +2,736 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mosaan/mdatlas/pkg/types"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/text"
 )
 
+// Section ID styles supported by the parser
+const (
+	IDStyleHash = "hash"
+	IDStyleSlug = "slug"
+)
+
+// DefaultIDPrefix starts hash section IDs unless ParserOptions.IDPrefix sets
+// another prefix
+const DefaultIDPrefix = "section_"
+
+// PreambleSectionID is the ID of the synthetic section holding the text
+// before the first heading. Hash IDs are hex, so they never collide with it,
+// and it is reserved in slug de-duplication.
+const PreambleSectionID = "section_preamble"
+
+// ParserOptions configures how the parser extracts structure
+type ParserOptions struct {
+	// IDStyle selects how section IDs are generated: IDStyleHash (default)
+	// or IDStyleSlug for GitHub-style anchor slugs
+	IDStyle string
+
+	// IDPrefix starts hash section IDs in place of DefaultIDPrefix, which
+	// is used when it is empty. Slug IDs take no prefix, and the preamble
+	// keeps PreambleSectionID.
+	IDPrefix string
+
+	// ExcludeFrontMatter excludes the lines of a YAML front matter block
+	// from the document's total_chars and total_lines
+	ExcludeFrontMatter bool
+
+	// WordCountMode selects how words are counted: WordCountWhitespace
+	// (default) or WordCountCJK
+	WordCountMode string
+
+	// WarnSkippedLevels records headings more than one level below their
+	// parent (e.g. H2 followed by H4) in the structure's warnings
+	WarnSkippedLevels bool
+
+	// FastStructure makes StructureManager stream files of at least
+	// FastStructureMinSize bytes through ScanStructure instead of reading
+	// them whole and building the Markdown AST
+	FastStructure bool
+
+	// IgnoreLevels lists heading levels that do not start sections, e.g.
+	// []int{5, 6}. Their headings are left in the body of the preceding
+	// section, or the preamble, as plain text lines.
+	IgnoreLevels []int
+
+	// KeepLineEndings leaves CRLF and lone CR line endings as written
+	// instead of converting them to LF before parsing. With normalized line
+	// endings, the default, character counts and byte offsets refer to the
+	// normalized content, so a CRLF file reports one byte less per line than
+	// its size on disk; kept, a CR-only file is a single line.
+	KeepLineEndings bool
+
+	// NoCounts leaves the character, rune, line and word counts of the
+	// sections and the preamble, and the document's word count, at zero,
+	// which saves splitting the content into lines to count them. Line and
+	// byte ranges are still taken from the heading positions, and the
+	// document's other totals are kept.
+	NoCounts bool
+}
+
 // Parser handles Markdown parsing and structure extraction
 type Parser struct {
-	md goldmark.Markdown
+	md      goldmark.Markdown
+	options ParserOptions
 }
 
 // NewParser creates a new Parser instance
 func NewParser() *Parser {
+	return NewParserWithOptions(ParserOptions{})
+}
+
+// NewParserWithOptions creates a new Parser instance with the given options
+func NewParserWithOptions(options ParserOptions) *Parser {
+	if options.IDStyle == "" {
+		options.IDStyle = IDStyleHash
+	}
+
+	if options.IDPrefix == "" {
+		options.IDPrefix = DefaultIDPrefix
+	}
+
+	if options.WordCountMode == "" {
+		options.WordCountMode = WordCountWhitespace
+	}
+
+	options.IgnoreLevels = normalizeLevels(options.IgnoreLevels)
+
 	return &Parser{
 		md: goldmark.New(
 			goldmark.WithExtensions(
-			// Add necessary extensions here
+				// GitHub Flavored Markdown: tables and task lists feed the
+				// element statistics, and strikethrough markup is dropped
+				// from heading titles like other inline markup
+				extension.GFM,
 			),
 		),
+		options: options,
+	}
+}
+
+// Options returns the options the parser was created with
+func (p *Parser) Options() ParserOptions {
+	return p.options
+}
+
+// normalizeLevels returns a sorted copy of levels without repeats, so equal
+// sets of levels give equal cache keys, or nil for an empty set
+func normalizeLevels(levels []int) []int {
+	if len(levels) == 0 {
+		return nil
 	}
+
+	normalized := slices.Clone(levels)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// ignoresLevel reports whether headings at level are ignored through
+// ParserOptions.IgnoreLevels
+func (p *Parser) ignoresLevel(level int) bool {
+	return slices.Contains(p.options.IgnoreLevels, level)
 }
 
 // ParseStructure parses the content and extracts document structure
 func (p *Parser) ParseStructure(content []byte) (*types.DocumentStructure, error) {
-	doc := p.md.Parser().Parse(text.NewReader(content))
+	return p.ParseStructureContext(context.Background(), content)
+}
+
+// ParseStructureContext is ParseStructure, abandoned with ctx's error once
+// ctx is done. The Markdown parser itself cannot be interrupted, so ctx is
+// checked before and after building the AST and while walking it.
+func (p *Parser) ParseStructureContext(ctx context.Context, content []byte) (*types.DocumentStructure, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Byte offsets and counts are relative to the content after a UTF-8
+	// byte order mark and line ending normalization, as returned by decode
+	content, err := p.decode(content)
+	if err != nil {
+		return nil, err
+	}
 
 	structure := &types.DocumentStructure{
 		TotalChars:   len(content),
-		TotalLines:   bytes.Count(content, []byte("\n")) + 1,
+		TotalRunes:   utf8.RuneCount(content),
+		TotalLines:   countLines(content),
 		Structure:    []types.Section{},
 		LastModified: time.Now(),
 	}
 
+	// Hide front matter from the Markdown parser so its delimiters are not
+	// mistaken for thematic breaks or Setext underlines. Masking keeps only
+	// its newlines, so byte offsets in the masked content are shifted.
+	byteShift := 0
+	bodyStart := 0 // Offset of the first line after front matter in the masked content
+	if fm := detectFrontMatter(content); fm != nil {
+		bodyStart = bytes.Count(content[:fm.End], []byte("\n"))
+		byteShift = fm.End - bodyStart
+		structure.FrontMatter = fm.Data
+		if p.options.ExcludeFrontMatter {
+			structure.TotalChars -= fm.End
+			structure.TotalRunes -= utf8.RuneCount(content[:fm.End])
+			structure.TotalLines -= fm.Lines
+		}
+		content = maskFrontMatter(content, fm)
+	}
+
+	doc := p.md.Parser().Parse(text.NewReader(content))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Extract sections from AST
-	sections := p.extractSections(doc, content)
+	sections, nonProse, err := p.extractSections(ctx, doc, content)
+	if err != nil {
+		return nil, err
+	}
 
 	// Calculate proper section boundaries
-	sections = p.calculateSectionBoundaries(sections, content)
+	sections = p.calculateSectionBoundaries(sections, content, nonProse)
 
-	structure.Structure = p.buildHierarchy(sections)
+	// Text before the first heading becomes the preamble section
+	structure.Preamble = p.extractPreamble(content, bodyStart, sections, nonProse)
+	if structure.Preamble != nil {
+		structure.Preamble.StartByte += byteShift
+		structure.Preamble.EndByte += byteShift
+	}
+
+	for i := range sections {
+		sections[i].StartByte += byteShift
+		sections[i].EndByte += byteShift
+	}
 
+	// Count over all lines, since TotalLines leaves out excluded front matter
+	if !p.options.NoCounts {
+		lines := strings.Split(string(content), "\n")
+		structure.WordCount = p.countWordsInLines(lines, 1, len(lines), nonProse)
+	}
+
+	p.assembleStructure(structure, sections)
 	return structure, nil
 }
 
-// extractSections walks through the AST and extracts section information
-func (p *Parser) extractSections(doc ast.Node, content []byte) []types.Section {
+// assembleStructure links the flat, document ordered sections and the
+// preamble in reading order, nests the sections into structure and records
+// the duplicate titles and requested warnings
+func (p *Parser) assembleStructure(structure *types.DocumentStructure, sections []types.Section) {
+	if structure.Preamble != nil && len(sections) > 0 {
+		structure.Preamble.NextID = sections[0].ID
+		sections[0].PrevID = structure.Preamble.ID
+	}
+
+	// The line scanner counts as it reads, so clear what it counted
+	if p.options.NoCounts {
+		clearCounts(structure.Preamble)
+		for i := range sections {
+			clearCounts(&sections[i])
+		}
+		structure.WordCount = 0
+	}
+
+	// Link each section to its neighbors in reading order before nesting
+	linkNeighbors(sections)
+	structure.DuplicateTitles = findDuplicateTitles(sections)
+
+	structure.Structure = p.buildHierarchy(sections)
+
+	if p.options.WarnSkippedLevels {
+		if warnings := findSkippedLevels(structure.Structure); len(warnings) > 0 {
+			structure.Warnings = warnings
+		}
+	}
+}
+
+// findDuplicateTitles returns the titles, compared case-insensitively, of
+// more than one section in a flat, document ordered list, as first written
+// and in the order they first appear. Empty headings are left to the linter.
+func findDuplicateTitles(sections []types.Section) []string {
+	var duplicates []string
+	firstTitles := make(map[string]string)
+	reported := make(map[string]bool)
+
+	for _, section := range sections {
+		if section.Title == "" {
+			continue
+		}
+		key := strings.ToLower(section.Title)
+		firstTitle, exists := firstTitles[key]
+		if !exists {
+			firstTitles[key] = section.Title
+		} else if !reported[key] {
+			duplicates = append(duplicates, firstTitle)
+			reported[key] = true
+		}
+	}
+
+	return duplicates
+}
+
+// linkNeighbors sets PrevID and NextID on each section of a flat, document
+// ordered list, so the chain follows reading order across heading levels
+func linkNeighbors(sections []types.Section) {
+	for i := range sections {
+		if i > 0 {
+			sections[i].PrevID = sections[i-1].ID
+		}
+		if i < len(sections)-1 {
+			sections[i].NextID = sections[i+1].ID
+		}
+	}
+}
+
+// extractState carries per-document state while sections are extracted.
+// Headings are visited in document order, so line numbers are counted
+// incrementally from the previous heading instead of from the start.
+type extractState struct {
+	content    []byte
+	searchFrom int            // Byte offset just past the previous heading
+	usedIDs    map[string]int // IDs already assigned, for de-duplication
+	nonProse   map[int]bool   // Heading and code block lines excluded from word counts
+	lineOffset int            // Byte offset at which lineNumber was counted
+	lineNumber int
+}
+
+// markLines marks the lines covering the byte range [start, stop) as non-prose
+func (st *extractState) markLines(start, stop int) {
+	first := st.lineAt(start)
+	last := first + bytes.Count(st.content[start:stop], []byte("\n"))
+	if stop > start && st.content[stop-1] == '\n' {
+		last--
+	}
+	for line := first; line <= last; line++ {
+		st.nonProse[line] = true
+	}
+}
+
+// lineAt returns the 1-based line number of a byte offset
+func (st *extractState) lineAt(offset int) int {
+	if offset < st.lineOffset {
+		st.lineOffset, st.lineNumber = 0, 1
+	}
+	st.lineNumber += bytes.Count(st.content[st.lineOffset:offset], []byte("\n"))
+	st.lineOffset = offset
+	return st.lineNumber
+}
+
+// extractSections walks through the AST and extracts section information.
+// It also returns the set of lines that hold heading markup or code, which
+// are excluded from word counts. The walk stops with ctx's error once ctx is
+// done.
+func (p *Parser) extractSections(ctx context.Context, doc ast.Node, content []byte) ([]types.Section, map[int]bool, error) {
 	var sections []types.Section
+	state := &extractState{
+		content:    content,
+		usedIDs:    map[string]int{PreambleSectionID: 1},
+		nonProse:   make(map[int]bool),
+		lineNumber: 1,
+	}
 
 	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
-		if entering && node.Kind() == ast.KindHeading {
-			section := p.extractSection(node, content)
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node.Kind() {
+		case ast.KindHeading:
+			if err := ctx.Err(); err != nil {
+				return ast.WalkStop, err
+			}
+			if heading := node.(*ast.Heading); p.ignoresLevel(heading.Level) {
+				// Keep the lines in the preceding section's body, but move
+				// past them for the next empty heading's lookup
+				_, state.searchFrom = p.headingRange(heading, state.content, state.searchFrom)
+				return ast.WalkSkipChildren, nil
+			}
+			section := p.extractSection(node, state)
 			sections = append(sections, section)
+		case ast.KindFencedCodeBlock, ast.KindCodeBlock:
+			p.markCodeBlock(node, state)
 		}
 		return ast.WalkContinue, nil
 	})
-
 	if err != nil {
-		// Handle error gracefully
-		return sections
+		return nil, nil, err
 	}
 
-	return sections
+	return sections, state.nonProse, nil
+}
+
+// markCodeBlock marks the lines of a code block, including the opening and
+// closing fences of fenced blocks, as non-prose
+func (p *Parser) markCodeBlock(node ast.Node, state *extractState) {
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return
+	}
+
+	start := lineStart(state.content, lines.At(0).Start)
+	stop := lines.At(lines.Len() - 1).Stop
+
+	if node.Kind() == ast.KindFencedCodeBlock {
+		// Extend over the opening fence line
+		if start > 0 {
+			start = lineStart(state.content, start-1)
+		}
+
+		// Extend over the closing fence line, which is absent for blocks
+		// left open at the end of the document
+		if state.content[stop-1] != '\n' {
+			stop = lineEnd(state.content, stop)
+		}
+		closing := bytes.TrimLeft(state.content[stop:lineEnd(state.content, stop)], " ")
+		if bytes.HasPrefix(closing, []byte("```")) || bytes.HasPrefix(closing, []byte("~~~")) {
+			stop = lineEnd(state.content, stop)
+		}
+	}
+
+	state.markLines(start, stop)
+}
+
+// extractPreamble returns the synthetic level 0 section holding the text
+// between bodyStart and the first heading, or nil if there is only whitespace
+func (p *Parser) extractPreamble(content []byte, bodyStart int, sections []types.Section, nonProse map[int]bool) *types.Section {
+	startLine := bytes.Count(content[:bodyStart], []byte("\n")) + 1
+	endLine := countLines(content)
+	endByte := len(content)
+	if len(sections) > 0 {
+		endLine = sections[0].StartLine - 1
+		endByte = sections[0].StartByte - 1
+	}
+
+	if endByte <= bodyStart || len(bytes.TrimSpace(content[bodyStart:endByte])) == 0 {
+		return nil
+	}
+
+	preamble := &types.Section{
+		ID:        PreambleSectionID,
+		Level:     0,
+		Title:     "Preamble",
+		StartLine: startLine,
+		EndLine:   endLine,
+		StartByte: bodyStart,
+		EndByte:   endByte,
+		Children:  []types.Section{},
+	}
+	if !p.options.NoCounts {
+		lines := strings.Split(string(content), "\n")
+		preamble.CharCount = p.calculateCharCount(lines, startLine, endLine)
+		preamble.RuneCount = p.calculateRuneCount(lines, startLine, endLine)
+		preamble.LineCount = endLine - startLine + 1
+		preamble.WordCount = p.countWordsInLines(lines, startLine, endLine, nonProse)
+	}
+	return preamble
+}
+
+// clearCounts zeroes the counts of section, if there is one, for
+// ParserOptions.NoCounts
+func clearCounts(section *types.Section) {
+	if section == nil {
+		return
+	}
+	section.CharCount = 0
+	section.RuneCount = 0
+	section.LineCount = 0
+	section.WordCount = 0
 }
 
 // calculateSectionBoundaries calculates the proper end lines for each section
-func (p *Parser) calculateSectionBoundaries(sections []types.Section, content []byte) []types.Section {
-	lines := strings.Split(string(content), "\n")
-	totalLines := len(lines)
+func (p *Parser) calculateSectionBoundaries(sections []types.Section, content []byte, nonProse map[int]bool) []types.Section {
+	var lines []string
+	if !p.options.NoCounts {
+		lines = strings.Split(string(content), "\n")
+	}
+	totalLines := countLines(content)
 
 	for i := range sections {
 		// Find the end line by looking for the next section at the same or higher level
 		endLine := totalLines
+		endByte := len(content)
 
 		for j := i + 1; j < len(sections); j++ {
 			if sections[j].Level <= sections[i].Level {
 				endLine = sections[j].StartLine - 1
+				// Stop before the newline ending the section's last line,
+				// matching the lines joined by GetSectionContent
+				endByte = sections[j].StartByte - 1
 				break
 			}
 		}
 
 		sections[i].EndLine = endLine
+		sections[i].EndByte = endByte
+
+		// Hash the section's own lines, up to the next heading at any level,
+		// so editing a subsection leaves its parent's hash alone
+		ownEnd := len(content)
+		if i+1 < len(sections) {
+			ownEnd = sections[i+1].StartByte
+		}
+		sections[i].ContentHash = hashContent(content[sections[i].StartByte:ownEnd])
+
+		if p.options.NoCounts {
+			sections[i].LineCount = 0
+			continue
+		}
 		sections[i].LineCount = endLine - sections[i].StartLine + 1
-		sections[i].CharCount = p.calculateCharCount(nil, content, sections[i].StartLine, endLine)
+		sections[i].CharCount = p.calculateCharCount(lines, sections[i].StartLine, endLine)
+		sections[i].RuneCount = p.calculateRuneCount(lines, sections[i].StartLine, endLine)
+		sections[i].WordCount = p.countWordsInLines(lines, sections[i].StartLine, endLine, nonProse)
 	}
 
 	return sections
 }
 
 // extractSection extracts section information from a heading node
-func (p *Parser) extractSection(node ast.Node, content []byte) types.Section {
+func (p *Parser) extractSection(node ast.Node, state *extractState) types.Section {
 	heading := node.(*ast.Heading)
 
-	title := p.extractHeadingText(heading, content)
-	startLine := p.getLineNumber(node, content)
+	title := p.extractHeadingText(heading, state.content)
+	start, stop := p.headingRange(heading, state.content, state.searchFrom)
+	startLine := state.lineAt(start)
+	state.searchFrom = stop
+	state.markLines(start, stop)
+
+	rawStop := stop
+	if p.isSetextHeading(heading, state.content) {
+		// Leave out the underline, the last line of the range
+		rawStop = lineStart(state.content, stop-1)
+	}
 
 	return types.Section{
-		ID:        p.generateSectionID(heading, title),
+		ID:        p.generateSectionID(heading.Level, title, state.usedIDs),
 		Level:     heading.Level,
 		Title:     title,
+		RawTitle:  strings.TrimRight(string(state.content[start:rawStop]), "\r\n"),
 		StartLine: startLine,
 		EndLine:   startLine, // Will be calculated later in calculateSectionBoundaries
-		CharCount: 0,         // Will be calculated later in calculateSectionBoundaries
-		LineCount: 1,         // Will be calculated later in calculateSectionBoundaries
+		StartByte: start,
+		CharCount: 0, // Will be calculated later in calculateSectionBoundaries
+		LineCount: 1, // Will be calculated later in calculateSectionBoundaries
 		Children:  []types.Section{},
 	}
 }
 
+// headingRange returns the byte range of the source lines occupied by a heading.
+// For ATX headings this is the single '#'-prefixed line. For Setext headings the
+// range starts at the first text line and ends after the '===' or '---' underline,
+// so the section starts at the text rather than the underline.
+func (p *Parser) headingRange(heading *ast.Heading, content []byte, searchFrom int) (int, int) {
+	lines := heading.Lines()
+	if lines.Len() == 0 {
+		// Empty ATX headings such as "#" carry no segments, so locate the
+		// next heading marker after the previous heading instead.
+		start := p.findATXHeadingLine(content, searchFrom)
+		return start, lineEnd(content, start)
+	}
+
+	last := lines.At(lines.Len() - 1)
+	start := lineStart(content, lines.At(0).Start)
+	stop := lineEnd(content, last.Start)
+	if last.Stop > last.Start {
+		stop = lineEnd(content, last.Stop-1)
+	}
+
+	if p.isSetextHeading(heading, content) {
+		// Include the underline that follows the last text line
+		stop = lineEnd(content, stop)
+	}
+
+	return start, stop
+}
+
+// isSetextHeading reports whether a heading uses the underline (Setext) syntax
+func (p *Parser) isSetextHeading(heading *ast.Heading, content []byte) bool {
+	if heading.Lines().Len() == 0 {
+		return false
+	}
+
+	segment := heading.Lines().At(0)
+	prefix := bytes.TrimLeft(content[lineStart(content, segment.Start):segment.Start], " \t")
+	return len(prefix) == 0 || prefix[0] != '#'
+}
+
+// findATXHeadingLine returns the offset of the first line at or after
+// searchFrom that starts with a '#' heading marker
+func (p *Parser) findATXHeadingLine(content []byte, searchFrom int) int {
+	for offset := lineStart(content, searchFrom); offset < len(content); offset = lineEnd(content, offset) {
+		line := bytes.TrimLeft(content[offset:], " ")
+		if len(line) > 0 && line[0] == '#' {
+			return offset
+		}
+	}
+	return lineStart(content, searchFrom)
+}
+
+// lineStart returns the offset of the beginning of the line containing offset
+func lineStart(content []byte, offset int) int {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// countLines returns the number of lines in content, the convention behind
+// TotalLines, section line ranges and SliceLines: every newline ends a line,
+// and text after the last newline is a final line without one. A trailing
+// newline does not start another line, so "a\n" and "a" both have one line
+// and empty content has none.
+func countLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+// lineEnd returns the offset just past the newline ending the line containing offset
+func lineEnd(content []byte, offset int) int {
+	if offset >= len(content) {
+		return len(content)
+	}
+	if idx := bytes.IndexByte(content[offset:], '\n'); idx >= 0 {
+		return offset + idx + 1
+	}
+	return len(content)
+}
+
 // extractHeadingText extracts the text content from a heading node
 func (p *Parser) extractHeadingText(heading *ast.Heading, content []byte) string {
 	var text strings.Builder
 
-	for child := heading.FirstChild(); child != nil; child = child.NextSibling() {
-		if textNode, ok := child.(*ast.Text); ok {
-			text.Write(textNode.Value(content))
+	// Walk all inline descendants so code spans, emphasis and link labels
+	// contribute their visible text while the markup itself is dropped
+	_ = ast.Walk(heading, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
 		}
-	}
+
+		switch n := node.(type) {
+		case *ast.Text:
+			text.Write(n.Value(content))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				text.WriteByte(' ')
+			}
+		case *ast.String:
+			text.Write(n.Value)
+		case *ast.AutoLink:
+			text.Write(n.Label(content))
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
 
 	return strings.TrimSpace(text.String())
 }
 
-// generateSectionID generates a unique ID for a section
-func (p *Parser) generateSectionID(heading *ast.Heading, title string) string {
-	// Create a hash-based ID for uniqueness
-	hash := sha256.Sum256([]byte(title + strconv.Itoa(heading.Level)))
-	return fmt.Sprintf("section_%x", hash[:8])
+// generateSectionID generates a unique ID for a section. IDs depend only on
+// the options and on the titles and levels of the section and the sections
+// before it, so the same content always gets the same IDs.
+func (p *Parser) generateSectionID(level int, title string, usedIDs map[string]int) string {
+	if p.options.IDStyle == IDStyleSlug {
+		return uniqueSlug(Slugify(title), usedIDs)
+	}
+
+	// Create a hash-based ID for uniqueness. The first section with a title
+	// and level keeps the hash of both alone; later ones, such as repeated
+	// "Examples" sections under different parents, also hash their
+	// occurrence so every section gets its own ID. Every ID handed out is
+	// recorded, and the occurrence is incremented past IDs already taken, as
+	// the hash of another title, such as "A1#" at level 1, may equal the
+	// hash of a repeated title and occurrence.
+	key := title + strconv.Itoa(level)
+	id := p.hashSectionID(key)
+
+	occurrence := usedIDs[id]
+	usedIDs[id] = occurrence + 1
+	if occurrence == 0 {
+		return id
+	}
+
+	candidate := p.hashSectionID(key + "#" + strconv.Itoa(occurrence))
+	for usedIDs[candidate] > 0 {
+		occurrence++
+		candidate = p.hashSectionID(key + "#" + strconv.Itoa(occurrence))
+	}
+	usedIDs[id] = occurrence + 1
+	usedIDs[candidate] = 1
+	return candidate
+}
+
+// hashSectionID returns the hash-style section ID for key
+func (p *Parser) hashSectionID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s%x", p.options.IDPrefix, hash[:8])
+}
+
+// Slugify converts a heading title into a GitHub-style anchor slug: the title
+// is lowercased, punctuation is stripped and runs of whitespace become hyphens
+func Slugify(title string) string {
+	var slug strings.Builder
+	pendingHyphen := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			if pendingHyphen {
+				slug.WriteByte('-')
+				pendingHyphen = false
+			}
+			slug.WriteRune(r)
+		case unicode.IsSpace(r):
+			pendingHyphen = slug.Len() > 0
+		}
+	}
+
+	return slug.String()
+}
+
+// uniqueSlug appends -1, -2, ... to slugs that were already used in the document
+func uniqueSlug(slug string, usedIDs map[string]int) string {
+	if slug == "" {
+		slug = "section"
+	}
+
+	count, exists := usedIDs[slug]
+	usedIDs[slug] = count + 1
+	if !exists {
+		return slug
+	}
+
+	candidate := fmt.Sprintf("%s-%d", slug, count)
+	for usedIDs[candidate] > 0 {
+		count++
+		candidate = fmt.Sprintf("%s-%d", slug, count)
+	}
+	usedIDs[slug] = count + 1
+	usedIDs[candidate] = 1
+	return candidate
 }
 
 // getLineNumber calculates the line number of a node in the content
 func (p *Parser) getLineNumber(node ast.Node, content []byte) int {
+	if node.Lines().Len() == 0 {
+		return 1
+	}
+
 	segment := node.Lines().At(0)
 	if segment.IsEmpty() {
 		return 1
@@ -161,21 +756,42 @@ func (p *Parser) calculateEndLine(node ast.Node, content []byte) int {
 }
 
 // calculateCharCount calculates the character count for a section
-func (p *Parser) calculateCharCount(node ast.Node, content []byte, startLine, endLine int) int {
+func (p *Parser) calculateCharCount(lines []string, startLine, endLine int) int {
 	// Simple implementation - can be enhanced for more accurate counting
-	lines := strings.Split(string(content), "\n")
 	if startLine > len(lines) || endLine > len(lines) || startLine < 1 {
 		return 0
 	}
 
 	var charCount int
 	for i := startLine - 1; i < endLine && i < len(lines); i++ {
-		charCount += len(lines[i]) + 1 // +1 for newline
+		charCount += len(lines[i])
+		// Count the newline only if one follows; the last line has none
+		if i < len(lines)-1 {
+			charCount++
+		}
 	}
 
 	return charCount
 }
 
+// calculateRuneCount calculates the number of UTF-8 characters in a range of
+// lines, counting newlines like calculateCharCount
+func (p *Parser) calculateRuneCount(lines []string, startLine, endLine int) int {
+	if startLine > len(lines) || endLine > len(lines) || startLine < 1 {
+		return 0
+	}
+
+	var runeCount int
+	for i := startLine - 1; i < endLine && i < len(lines); i++ {
+		runeCount += utf8.RuneCountInString(lines[i])
+		if i < len(lines)-1 {
+			runeCount++
+		}
+	}
+
+	return runeCount
+}
+
 // buildHierarchy builds a hierarchical structure from flat sections
 func (p *Parser) buildHierarchy(sections []types.Section) []types.Section {
 	if len(sections) == 0 {
@@ -214,43 +830,235 @@ func (p *Parser) buildHierarchy(sections []types.Section) []types.Section {
 
 // GetSectionContent retrieves the content of a specific section
 func (p *Parser) GetSectionContent(content []byte, sectionID string, includeChildren bool) (*types.SectionContent, error) {
+	content, err := p.decode(content)
+	if err != nil {
+		return nil, err
+	}
+
 	structure, err := p.ParseStructure(content)
 	if err != nil {
 		return nil, err
 	}
 
+	return p.ExtractSectionContent(content, structure, sectionID, includeChildren)
+}
+
+// GetSectionContentByPath retrieves the content of a section identified by
+// its heading path
+func (p *Parser) GetSectionContentByPath(content []byte, sectionPath string, includeChildren bool) (*types.SectionContent, error) {
+	content, err := p.decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	structure, err := p.ParseStructure(content)
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := p.FindSectionByPath(structure.Structure, sectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.sliceSectionContent(content, structure.Structure, section, includeChildren), nil
+}
+
+// ExtractSectionContent slices the content of a section using the line
+// boundaries of an already parsed structure, avoiding a reparse of content.
+// A UTF-8 byte order mark must already be removed, as by DecodeContent.
+func (p *Parser) ExtractSectionContent(content []byte, structure *types.DocumentStructure, sectionID string, includeChildren bool) (*types.SectionContent, error) {
 	section := p.findSection(structure.Structure, sectionID)
+	if section == nil && sectionID == PreambleSectionID {
+		section = structure.Preamble
+	}
 	if section == nil {
-		return nil, fmt.Errorf("section not found: %s", sectionID)
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, sectionID)
+	}
+
+	return p.sliceSectionContent(content, structure.Structure, section, includeChildren), nil
+}
+
+// SectionRange is the content of the document from the start of one section
+// up to the start of a later one
+type SectionRange struct {
+	FromSectionID string `json:"from_section_id" yaml:"from_section_id"`
+	ToSectionID   string `json:"to_section_id" yaml:"to_section_id"`
+	Content       string `json:"content" yaml:"content"`
+	StartLine     int    `json:"start_line" yaml:"start_line"`
+	EndLine       int    `json:"end_line" yaml:"end_line"`
+}
+
+// ErrSectionNotFound is returned, wrapped with the section ID or path, when a
+// document has no section matching a lookup
+var ErrSectionNotFound = errors.New("section not found")
+
+// ErrInvalidSectionRange is returned for a section range whose first section
+// does not come before its last
+var ErrInvalidSectionRange = errors.New("invalid section range")
+
+// ExtractSectionRange slices the lines of content from the heading of the
+// section fromID up to, but not including, the heading of the section toID,
+// using an already parsed structure. Both sections are looked up in document
+// order, preamble first, and fromID must come before toID. A UTF-8 byte order
+// mark must already be removed, as by DecodeContent.
+func (p *Parser) ExtractSectionRange(content []byte, structure *types.DocumentStructure, fromID, toID string) (*SectionRange, error) {
+	sections := p.flattenSections(structure.Structure)
+	if structure.Preamble != nil {
+		sections = append([]types.Section{*structure.Preamble}, sections...)
 	}
 
+	from, to := -1, -1
+	for i, section := range sections {
+		if section.ID == fromID {
+			from = i
+		}
+		if section.ID == toID {
+			to = i
+		}
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, fromID)
+	}
+	if to < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, toID)
+	}
+	if from >= to {
+		return nil, fmt.Errorf("%w: section %s does not precede section %s in the document", ErrInvalidSectionRange, fromID, toID)
+	}
+
+	startLine, endLine := sections[from].StartLine, sections[to].StartLine-1
+	lines := strings.Split(string(content), "\n")
+	return &SectionRange{
+		FromSectionID: fromID,
+		ToSectionID:   toID,
+		Content:       strings.Join(lines[startLine-1:endLine], "\n"),
+		StartLine:     startLine,
+		EndLine:       endLine,
+	}, nil
+}
+
+// sliceSectionContent slices the content of a located section by its line
+// range and records where the section sits in the hierarchy of sections
+func (p *Parser) sliceSectionContent(content []byte, sections []types.Section, section *types.Section, includeChildren bool) *types.SectionContent {
 	sectionContent := &types.SectionContent{
 		ID:              section.ID,
 		Title:           section.Title,
+		Level:           section.Level,
 		Format:          "markdown",
 		IncludeChildren: includeChildren,
+		Breadcrumb:      []string{},
+	}
+
+	// Record the ancestor titles from the root down to the parent
+	ancestors, _ := p.findAncestors(sections, section.ID)
+	for _, ancestor := range ancestors {
+		sectionContent.Breadcrumb = append(sectionContent.Breadcrumb, ancestor.Title)
+	}
+	if len(ancestors) > 0 {
+		sectionContent.ParentID = ancestors[len(ancestors)-1].ID
 	}
 
 	// Extract content based on line numbers
 	lines := strings.Split(string(content), "\n")
 	if section.StartLine > 0 && section.StartLine <= len(lines) {
+		// The section's EndLine already includes all children
 		endLine := section.EndLine
 		if !includeChildren {
-			// Find the next section at the same or higher level (stop before children)
-			flatSections := p.flattenSections(structure.Structure)
-			endLine = p.findSectionEnd(flatSections, section)
+			endLine = p.findSectionEnd(section)
 		}
-		// If includeChildren is true, use the section's EndLine which includes all children
 
 		if endLine > len(lines) {
 			endLine = len(lines)
 		}
 
-		contentLines := lines[section.StartLine-1 : endLine]
-		sectionContent.Content = strings.Join(contentLines, "\n")
+		sliceLines(sectionContent, lines, section.StartLine, endLine)
 	}
 
-	return sectionContent, nil
+	return sectionContent
+}
+
+// ExpandSectionContent widens sectionContent, sliced from content, by
+// contextLines lines before its first and after its last line, clamped to
+// the bounds of content, and updates its line range to the one returned
+func (p *Parser) ExpandSectionContent(content []byte, sectionContent *types.SectionContent, contextLines int) {
+	if contextLines <= 0 || sectionContent.StartLine == 0 {
+		return
+	}
+
+	lines := strings.Split(string(content), "\n")
+	startLine := sectionContent.StartLine - contextLines
+	if startLine < 1 {
+		startLine = 1
+	}
+	endLine := sectionContent.EndLine + contextLines
+	if totalLines := countLines(content); endLine > totalLines {
+		endLine = totalLines
+	}
+
+	sliceLines(sectionContent, lines, startLine, endLine)
+}
+
+// PrependPreamble widens sectionContent, sliced from content, to start at the
+// first line of the document's preamble when it is the content of the first
+// section, the one the preamble leads to. It leaves other sections, and
+// documents without a preamble, unchanged.
+func (p *Parser) PrependPreamble(content []byte, structure *types.DocumentStructure, sectionContent *types.SectionContent) {
+	preamble := structure.Preamble
+	if preamble == nil || preamble.NextID != sectionContent.ID || sectionContent.StartLine == 0 {
+		return
+	}
+
+	lines := strings.Split(string(content), "\n")
+	sliceLines(sectionContent, lines, min(preamble.StartLine, sectionContent.StartLine), sectionContent.EndLine)
+}
+
+// TruncateSectionContent cuts the content of sectionContent to at most
+// maxChars bytes, counted like CharCount, stepping back to the start of a
+// rune so no UTF-8 sequence is split. With atParagraph the cut moves further
+// back to the end of the last paragraph that fits, if any. A cut content is
+// marked Truncated, OriginalCharCount keeps its length before the cut, and
+// EndLine becomes the last line it reaches. A maxChars of 0 or less, or one
+// the content fits in, leaves sectionContent unchanged.
+func (p *Parser) TruncateSectionContent(sectionContent *types.SectionContent, maxChars int, atParagraph bool) {
+	content := sectionContent.Content
+	if maxChars <= 0 || len(content) <= maxChars {
+		return
+	}
+
+	cut := maxChars
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	if atParagraph {
+		// Keep the newline ending the paragraph's last line
+		if end := strings.LastIndex(content[:cut], "\n\n"); end >= 0 {
+			cut = end + 1
+		}
+	}
+
+	sectionContent.Content = content[:cut]
+	sectionContent.Truncated = true
+	sectionContent.OriginalCharCount = len(content)
+	if lines := countLines([]byte(sectionContent.Content)); lines > 0 {
+		sectionContent.EndLine = sectionContent.StartLine + lines - 1
+	}
+}
+
+// sliceLines sets the content of sectionContent to the lines from startLine
+// to endLine, both 1-based and inclusive, split from the content by newline.
+// The lines are joined without the newline ending endLine, except at the end
+// of content, so a range of every line returns content unchanged.
+func sliceLines(sectionContent *types.SectionContent, lines []string, startLine, endLine int) {
+	stop := endLine
+	if stop == len(lines)-1 && lines[stop] == "" {
+		// Keep the trailing newline, after which the split leaves an empty
+		// string that is not a line of its own
+		stop++
+	}
+	sectionContent.Content = strings.Join(lines[startLine-1:stop], "\n")
+	sectionContent.StartLine = startLine
+	sectionContent.EndLine = endLine
 }
 
 // findSection recursively finds a section by ID
@@ -266,6 +1074,78 @@ func (p *Parser) findSection(sections []types.Section, sectionID string) *types.
 	return nil
 }
 
+// findAncestors returns the ancestors of the section with the given ID,
+// ordered from the top-level section down to its parent. It reports false if
+// the section does not exist.
+func (p *Parser) findAncestors(sections []types.Section, sectionID string) ([]*types.Section, bool) {
+	for i := range sections {
+		if sections[i].ID == sectionID {
+			return nil, true
+		}
+		if ancestors, found := p.findAncestors(sections[i].Children, sectionID); found {
+			return append([]*types.Section{&sections[i]}, ancestors...), true
+		}
+	}
+	return nil, false
+}
+
+// FindSectionByPath finds a section by a '/'-separated heading path such as
+// "Introduction/Getting Started/Installation". Titles are matched case
+// insensitively one level of the hierarchy at a time.
+func (p *Parser) FindSectionByPath(sections []types.Section, sectionPath string) (*types.Section, error) {
+	var parts []string
+	for _, part := range strings.Split(sectionPath, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty section path: %q", sectionPath)
+	}
+
+	var current *types.Section
+	candidates := sections
+	for i, part := range parts {
+		var matches []*types.Section
+		for j := range candidates {
+			if strings.EqualFold(candidates[j].Title, part) {
+				matches = append(matches, &candidates[j])
+			}
+		}
+
+		matched := strings.Join(parts[:i+1], "/")
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("%w: path %s (available: %s)", ErrSectionNotFound, matched, p.listTitles(candidates))
+		case 1:
+			current = matches[0]
+			candidates = current.Children
+		default:
+			ids := make([]string, 0, len(matches))
+			for _, match := range matches {
+				ids = append(ids, match.ID)
+			}
+			return nil, fmt.Errorf("ambiguous section path: %s matches %d sections (%s)", matched, len(matches), strings.Join(ids, ", "))
+		}
+	}
+
+	found := *current
+	return &found, nil
+}
+
+// listTitles returns the quoted titles of sections for error messages
+func (p *Parser) listTitles(sections []types.Section) string {
+	if len(sections) == 0 {
+		return "none"
+	}
+
+	titles := make([]string, 0, len(sections))
+	for _, section := range sections {
+		titles = append(titles, strconv.Quote(section.Title))
+	}
+	return strings.Join(titles, ", ")
+}
+
 // flattenSections flattens a hierarchical section structure into a linear list
 func (p *Parser) flattenSections(sections []types.Section) []types.Section {
 	var flat []types.Section
@@ -278,30 +1158,37 @@ func (p *Parser) flattenSections(sections []types.Section) []types.Section {
 	return flat
 }
 
-// findSectionEnd finds the end line of a section (excluding children)
-func (p *Parser) findSectionEnd(sections []types.Section, target *types.Section) int {
-	// Find the next child section of the target section
-	found := false
-	for _, section := range sections {
-		if found && section.Level > target.Level {
-			// This is a child section, find where it starts
-			return section.StartLine - 1
-		}
-		if section.ID == target.ID {
-			found = true
+// FlattenStructure lists a section hierarchy as a flat, document ordered
+// list in which each section links to its parent through ParentID instead of
+// holding its subsections, so Children is empty. Top-level sections have no
+// ParentID. The list is empty rather than nil when there are no sections.
+func (p *Parser) FlattenStructure(sections []types.Section) []types.Section {
+	flat := p.flattenSections(sections)
+
+	parentIDs := make(map[string]string)
+	for _, section := range flat {
+		for _, child := range section.Children {
+			parentIDs[child.ID] = section.ID
 		}
 	}
 
-	// If no children found, find the next section at the same or higher level
-	found = false
-	for _, section := range sections {
-		if found && section.Level <= target.Level {
-			return section.StartLine - 1
-		}
-		if section.ID == target.ID {
-			found = true
-		}
+	for i := range flat {
+		flat[i].ParentID = parentIDs[flat[i].ID]
+		flat[i].Children = []types.Section{}
+	}
+
+	if flat == nil {
+		return []types.Section{}
+	}
+	return flat
+}
+
+// findSectionEnd finds the end line of a section (excluding children)
+func (p *Parser) findSectionEnd(section *types.Section) int {
+	// Stop right before the first child section, if any
+	if len(section.Children) > 0 {
+		return section.Children[0].StartLine - 1
 	}
 
-	return target.EndLine
+	return section.EndLine
 }
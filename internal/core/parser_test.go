@@ -1,9 +1,14 @@
 package core
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/mosaan/mdatlas/pkg/types"
 )
@@ -93,6 +98,57 @@ func TestParseEmptyContent(t *testing.T) {
 	}
 }
 
+func TestLineCountsAgree(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   int
+	}{
+		{"final newline", "# Title\n\nText.\n", 3},
+		{"no final newline", "# Title\n\nText.", 3},
+		{"trailing blank line", "# Title\n\nText.\n\n", 4},
+		{"empty", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser()
+			structure, err := parser.ParseStructure([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+			if structure.TotalLines != tt.lines {
+				t.Errorf("Expected %d total lines, got %d", tt.lines, structure.TotalLines)
+			}
+
+			lineRange, err := SliceLines([]byte(tt.content), 1, 0)
+			if err != nil {
+				t.Fatalf("SliceLines failed: %v", err)
+			}
+			if lineRange.TotalLines != tt.lines || len(lineRange.Lines) != tt.lines || lineRange.EndLine != tt.lines {
+				t.Errorf("Expected SliceLines to return all %d lines, got %+v", tt.lines, lineRange)
+			}
+
+			if tt.lines == 0 {
+				return
+			}
+
+			// The only section covers every line, and its content is the file
+			section := structure.Structure[0]
+			if section.EndLine != tt.lines || section.LineCount != tt.lines {
+				t.Errorf("Expected the section to end on line %d with %d lines, got %d and %d", tt.lines, tt.lines, section.EndLine, section.LineCount)
+			}
+			sectionContent, err := parser.ExtractSectionContent([]byte(tt.content), structure, section.ID, true)
+			if err != nil {
+				t.Fatalf("ExtractSectionContent failed: %v", err)
+			}
+			if sectionContent.Content != tt.content {
+				t.Errorf("Expected the whole file as content, got %q", sectionContent.Content)
+			}
+		})
+	}
+}
+
 func TestParseNoHeadings(t *testing.T) {
 	parser := NewParser()
 
@@ -214,6 +270,171 @@ func TestParseFixtureFiles(t *testing.T) {
 	}
 }
 
+func TestParseLineEndings(t *testing.T) {
+	read := func(name string) []byte {
+		content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		return content
+	}
+
+	parser := NewParser()
+	want, err := parser.ParseStructure(read("eol_lf.md"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if want.TotalLines != 11 {
+		t.Fatalf("Expected 11 lines in eol_lf.md, got %d", want.TotalLines)
+	}
+	second := want.Structure[0].Children[1]
+
+	for _, name := range []string{"eol_crlf.md", "eol_cr.md"} {
+		t.Run(name, func(t *testing.T) {
+			content := read(name)
+			structure, err := parser.ParseStructure(content)
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+			if structure.TotalLines != want.TotalLines || structure.TotalChars != want.TotalChars {
+				t.Errorf("Expected %d lines and %d chars, got %d lines and %d chars", want.TotalLines, want.TotalChars, structure.TotalLines, structure.TotalChars)
+			}
+
+			got := structure.Structure[0].Children[1]
+			if got.ID != second.ID || got.StartLine != second.StartLine || got.EndLine != second.EndLine {
+				t.Errorf("Expected section %s at lines %d-%d, got %s at lines %d-%d", second.ID, second.StartLine, second.EndLine, got.ID, got.StartLine, got.EndLine)
+			}
+
+			sectionContent, err := parser.GetSectionContent(content, got.ID, false)
+			if err != nil {
+				t.Fatalf("GetSectionContent failed: %v", err)
+			}
+			if sectionContent.Content != "## Second\n\nSecond body.\n" {
+				t.Errorf("Expected the normalized section content, got %q", sectionContent.Content)
+			}
+		})
+	}
+
+	// Kept line endings leave a CR-only document as a single line
+	keep := NewParserWithOptions(ParserOptions{KeepLineEndings: true})
+	structure, err := keep.ParseStructure(read("eol_cr.md"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if structure.TotalLines != 1 {
+		t.Errorf("Expected 1 line with kept line endings, got %d", structure.TotalLines)
+	}
+}
+
+func TestParseByteOrderMark(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", "bom.md"))
+	if err != nil {
+		t.Fatalf("Failed to read bom.md: %v", err)
+	}
+	if !bytes.HasPrefix(content, []byte("\ufeff")) {
+		t.Fatal("Expected bom.md to start with a UTF-8 byte order mark")
+	}
+
+	parser := NewParser()
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	first := structure.Structure[0]
+	if first.Title != "BOM Document" || first.StartByte != 0 {
+		t.Errorf("Expected the title %q at byte 0, got %q at byte %d", "BOM Document", first.Title, first.StartByte)
+	}
+	if structure.TotalChars != len(content)-3 {
+		t.Errorf("Expected %d total chars without the mark, got %d", len(content)-3, structure.TotalChars)
+	}
+
+	sectionContent, err := parser.GetSectionContent(content, first.ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+	if !strings.HasPrefix(sectionContent.Content, "# BOM Document\n") {
+		t.Errorf("Expected the section content to start with its heading, got %q", sectionContent.Content)
+	}
+
+	// UTF-16 is reported instead of parsed as garbled UTF-8
+	for _, bom := range [][]byte{{0xFF, 0xFE}, {0xFE, 0xFF}} {
+		utf16 := append(bom, "#\x00 \x00T\x00\n\x00"...)
+		if _, err := parser.ParseStructure(utf16); !errors.Is(err, ErrUnsupportedEncoding) {
+			t.Errorf("Expected ErrUnsupportedEncoding for byte order mark % x, got %v", bom, err)
+		}
+	}
+}
+
+func TestParseUnicodeRuneCounts(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", "japanese.md"))
+	if err != nil {
+		t.Fatalf("Failed to read japanese.md: %v", err)
+	}
+
+	structure, err := NewParser().ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed on japanese.md: %v", err)
+	}
+
+	// total_chars stays a byte count; Japanese characters take three bytes
+	if structure.TotalChars != len(content) {
+		t.Errorf("Expected total_chars to be %d bytes, got %d", len(content), structure.TotalChars)
+	}
+	if structure.TotalRunes >= structure.TotalChars {
+		t.Errorf("Expected fewer runes than bytes, got %d runes and %d bytes", structure.TotalRunes, structure.TotalChars)
+	}
+
+	var check func(sections []types.Section)
+	check = func(sections []types.Section) {
+		for _, section := range sections {
+			if section.RuneCount >= section.CharCount {
+				t.Errorf("%s: expected fewer runes than bytes, got %d runes and %d bytes", section.Title, section.RuneCount, section.CharCount)
+			}
+			check(section.Children)
+		}
+	}
+	check(structure.Structure)
+
+	// The top-level section covers the whole file
+	if root := structure.Structure[0]; root.RuneCount != structure.TotalRunes {
+		t.Errorf("Expected top-level rune count %d, got %d", structure.TotalRunes, root.RuneCount)
+	}
+}
+
+func TestParseGFMFixture(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", "gfm.md"))
+	if err != nil {
+		t.Fatalf("Failed to read gfm.md: %v", err)
+	}
+
+	parser := NewParser()
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed on gfm.md: %v", err)
+	}
+
+	if len(structure.Structure) != 1 || len(structure.Structure[0].Children) != 2 {
+		t.Fatalf("Expected one top-level section with two subsections, got %+v", structure.Structure)
+	}
+
+	// Strikethrough markup is dropped from the title like emphasis
+	if title := structure.Structure[0].Children[0].Title; title != "Beta Stable Features" {
+		t.Errorf("Expected title %q, got %q", "Beta Stable Features", title)
+	}
+
+	stats, err := parser.ParseStats(content)
+	if err != nil {
+		t.Fatalf("ParseStats failed on gfm.md: %v", err)
+	}
+
+	// The bare URL is autolinked
+	expected := ElementStats{LinkCount: 1, TableCount: 1, TaskCount: 3, CompletedTasks: 2}
+	if *stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *stats)
+	}
+}
+
 func checkNesting(sections []types.Section, hasDeepNesting *bool, level int) {
 	if level > 3 {
 		*hasDeepNesting = true
@@ -252,3 +473,1270 @@ func TestGenerateSectionID(t *testing.T) {
 		}
 	}
 }
+
+func TestSectionIDsStableAcrossParses(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", "complex.md"))
+	if err != nil {
+		t.Fatalf("Failed to read complex.md: %v", err)
+	}
+
+	sectionIDs := func(parser *Parser) []string {
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+		var ids []string
+		for _, section := range parser.flattenSections(structure.Structure) {
+			ids = append(ids, section.ID)
+		}
+		return ids
+	}
+
+	// Separate parsers share no state, as separate runs would not
+	first, second := sectionIDs(NewParser()), sectionIDs(NewParser())
+	if len(first) == 0 || !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected identical IDs from two parses of the same content\nfirst:  %v\nsecond: %v", first, second)
+	}
+
+	prefixed := sectionIDs(NewParserWithOptions(ParserOptions{IDPrefix: "doc-"}))
+	for i, id := range prefixed {
+		if id != "doc-"+strings.TrimPrefix(first[i], DefaultIDPrefix) {
+			t.Errorf("Expected the default ID %s with the prefix replaced, got %s", first[i], id)
+		}
+	}
+}
+
+func TestParseSetextHeadings(t *testing.T) {
+	parser := NewParser()
+
+	content, err := os.ReadFile(filepath.Join("..", "..", "tests", "fixtures", "setext.md"))
+	if err != nil {
+		t.Fatalf("Failed to read setext.md: %v", err)
+	}
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	expected := []struct {
+		title     string
+		level     int
+		startLine int
+		endLine   int
+	}{
+		{"Setext Title", 1, 1, 23},
+		{"Setext Section", 2, 6, 14},
+		{"ATX Subsection", 3, 11, 14},
+		{"ATX Section", 2, 15, 18},
+		{"Multi-line Setext Section", 2, 19, 23},
+	}
+
+	flat := parser.flattenSections(structure.Structure)
+	if len(flat) != len(expected) {
+		t.Fatalf("Expected %d sections, got %d", len(expected), len(flat))
+	}
+
+	for i, want := range expected {
+		got := flat[i]
+		if got.Title != want.title {
+			t.Errorf("Section %d: expected title %q, got %q", i, want.title, got.Title)
+		}
+		if got.Level != want.level {
+			t.Errorf("Section %d: expected level %d, got %d", i, want.level, got.Level)
+		}
+		if got.StartLine != want.startLine || got.EndLine != want.endLine {
+			t.Errorf("Section %q: expected lines %d-%d, got %d-%d",
+				got.Title, want.startLine, want.endLine, got.StartLine, got.EndLine)
+		}
+	}
+}
+
+func TestParseEmptyATXHeading(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("Intro\n\n#\n\nBody\n\n## Next\n")
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	flat := parser.flattenSections(structure.Structure)
+	if len(flat) != 2 {
+		t.Fatalf("Expected 2 sections, got %d", len(flat))
+	}
+
+	if flat[0].StartLine != 3 {
+		t.Errorf("Expected empty heading on line 3, got %d", flat[0].StartLine)
+	}
+
+	if flat[1].StartLine != 7 {
+		t.Errorf("Expected 'Next' on line 7, got %d", flat[1].StartLine)
+	}
+}
+
+func TestExtractHeadingTextInlineNodes(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		heading  string
+		expected string
+	}{
+		{"## Using the `parse()` function", "Using the parse() function"},
+		{"## See [docs](https://example.com/docs)", "See docs"},
+		{"## *Emphasis* and **strong** text", "Emphasis and strong text"},
+		{"## Mixed `code` in [*linked* label](url)", "Mixed code in linked label"},
+		{"## Visit <https://example.com>", "Visit https://example.com"},
+	}
+
+	for _, tt := range tests {
+		structure, err := parser.ParseStructure([]byte(tt.heading + "\n"))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if len(structure.Structure) != 1 {
+			t.Fatalf("Expected 1 section for %q, got %d", tt.heading, len(structure.Structure))
+		}
+
+		if got := structure.Structure[0].Title; got != tt.expected {
+			t.Errorf("Heading %q: expected title %q, got %q", tt.heading, tt.expected, got)
+		}
+	}
+}
+
+func TestParseRawTitle(t *testing.T) {
+	content := "# **Bold** _title_ ##\r\n\nText.\n\n## Section {#custom-id}\n\nSetext *Title*\n--------------\n"
+	parser := NewParser()
+	structure, err := parser.ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	sections := parser.flattenSections(structure.Structure)
+	expected := []struct {
+		title    string
+		rawTitle string
+	}{
+		{"Bold title", "# **Bold** _title_ ##"},
+		{"Section {#custom-id}", "## Section {#custom-id}"},
+		{"Setext Title", "Setext *Title*"},
+	}
+	if len(sections) != len(expected) {
+		t.Fatalf("Expected %d sections, got %d", len(expected), len(sections))
+	}
+	for i, exp := range expected {
+		if sections[i].Title != exp.title || sections[i].RawTitle != exp.rawTitle {
+			t.Errorf("Section %d: expected title %q and raw title %q, got %q and %q", i, exp.title, exp.rawTitle, sections[i].Title, sections[i].RawTitle)
+		}
+	}
+}
+
+func TestParseDuplicateTitles(t *testing.T) {
+	content := "# Guide\n\n## Overview\n\n## Install\n\n### overview\n\n## Usage\n\n### Options\n\n### Options\n\n### Options\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// Duplicates are found across the whole document, not only among siblings
+	if !reflect.DeepEqual(structure.DuplicateTitles, []string{"Overview", "Options"}) {
+		t.Errorf("Expected duplicate titles [Overview Options], got %v", structure.DuplicateTitles)
+	}
+
+	structure, err = NewParser().ParseStructure([]byte("# Guide\n\n## Install\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if structure.DuplicateTitles != nil {
+		t.Errorf("Expected no duplicate titles, got %v", structure.DuplicateTitles)
+	}
+}
+
+func TestParseIgnoreLevels(t *testing.T) {
+	content := "# Guide\n\n### Details\n\nText.\n\n##### Note\n\nMore text.\n\n## Next\n"
+
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	details := structure.Structure[0].Children[0]
+	if len(details.Children) != 1 || details.Children[0].StartLine != 7 {
+		t.Fatalf("Expected Details to have the H5 section on line 7, got %+v", details)
+	}
+
+	// Repeated and unordered levels are normalized
+	parser := NewParserWithOptions(ParserOptions{IgnoreLevels: []int{6, 5, 5}})
+	if levels := parser.Options().IgnoreLevels; !reflect.DeepEqual(levels, []int{5, 6}) {
+		t.Errorf("Expected normalized levels [5 6], got %v", levels)
+	}
+
+	structure, err = parser.ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// The H5 heading and its text fold into the H3 section
+	details = structure.Structure[0].Children[0]
+	if details.Title != "Details" || details.StartLine != 3 || details.EndLine != 10 || len(details.Children) != 0 {
+		t.Errorf("Expected Details to span lines 3-10 without children, got %+v", details)
+	}
+	if next := structure.Structure[0].Children[1]; next.Title != "Next" || next.StartLine != 11 || next.PrevID != details.ID {
+		t.Errorf("Expected Next to follow Details on line 11, got %+v", next)
+	}
+}
+
+func TestParseNoCounts(t *testing.T) {
+	content := []byte("Intro text.\n\n# Guide\n\nSome words here.\n\n## Install\n\nRun it.\n")
+
+	counted, err := NewParser().ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	structure, err := NewParserWithOptions(ParserOptions{NoCounts: true}).ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	for i, section := range []types.Section{*structure.Preamble, structure.Structure[0], structure.Structure[0].Children[0]} {
+		if section.CharCount != 0 || section.RuneCount != 0 || section.LineCount != 0 || section.WordCount != 0 {
+			t.Errorf("Expected zero counts for %q, got %+v", section.Title, section)
+		}
+
+		// Line and byte ranges match the counted structure
+		expected := []types.Section{*counted.Preamble, counted.Structure[0], counted.Structure[0].Children[0]}[i]
+		if section.StartLine != expected.StartLine || section.EndLine != expected.EndLine || section.StartByte != expected.StartByte || section.EndByte != expected.EndByte {
+			t.Errorf("Expected %q to span lines %d-%d, bytes %d-%d, got lines %d-%d, bytes %d-%d", section.Title,
+				expected.StartLine, expected.EndLine, expected.StartByte, expected.EndByte,
+				section.StartLine, section.EndLine, section.StartByte, section.EndByte)
+		}
+	}
+
+	if structure.WordCount != 0 || structure.TotalLines != counted.TotalLines || structure.TotalChars != counted.TotalChars {
+		t.Errorf("Expected no word count and the same totals, got %d words, %d lines, %d chars", structure.WordCount, structure.TotalLines, structure.TotalChars)
+	}
+
+	// The line scanner leaves the counts at zero too
+	scanned, err := NewParserWithOptions(ParserOptions{NoCounts: true}).ScanStructure(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("ScanStructure failed: %v", err)
+	}
+	if section := scanned.Structure[0]; section.CharCount != 0 || section.LineCount != 0 || scanned.Preamble.WordCount != 0 {
+		t.Errorf("Expected zero counts from the scanner, got %+v", section)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{"Using the API", "using-the-api"},
+		{"What's new?", "whats-new"},
+		{"  Multiple   spaces  ", "multiple-spaces"},
+		{"snake_case and kebab-case", "snake_case-and-kebab-case"},
+		{"日本語 タイトル", "日本語-タイトル"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.expected {
+			t.Errorf("Slugify(%q): expected %q, got %q", tt.title, tt.expected, got)
+		}
+	}
+}
+
+func TestGenerateSlugSectionIDs(t *testing.T) {
+	parser := NewParserWithOptions(ParserOptions{IDStyle: IDStyleSlug})
+
+	content := []byte(`# Using the API
+
+## Setup
+
+## Setup
+
+## Setup
+
+## Setup 1
+
+## !!!`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	expected := []string{"using-the-api", "setup", "setup-1", "setup-2", "setup-1-1", "section"}
+	flat := parser.flattenSections(structure.Structure)
+	if len(flat) != len(expected) {
+		t.Fatalf("Expected %d sections, got %d", len(expected), len(flat))
+	}
+
+	for i, want := range expected {
+		if flat[i].ID != want {
+			t.Errorf("Section %d: expected ID %q, got %q", i, want, flat[i].ID)
+		}
+	}
+
+	sectionContent, err := parser.GetSectionContent(content, "setup-1", false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed for slug ID: %v", err)
+	}
+
+	if sectionContent.Title != "Setup" {
+		t.Errorf("Expected title 'Setup', got '%s'", sectionContent.Title)
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	t.Run("no front matter", func(t *testing.T) {
+		structure, err := NewParser().ParseStructure([]byte("# Title\n\nBody\n"))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if structure.FrontMatter != nil {
+			t.Errorf("Expected no front matter, got %v", structure.FrontMatter)
+		}
+	})
+
+	t.Run("front matter followed by H1", func(t *testing.T) {
+		content := []byte("---\ntitle: Guide\ntags:\n  - docs\n---\n# Guide\n\nBody\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if structure.FrontMatter["title"] != "Guide" {
+			t.Errorf("Expected front matter title 'Guide', got %v", structure.FrontMatter["title"])
+		}
+
+		if len(structure.Structure) != 1 {
+			t.Fatalf("Expected 1 section, got %d", len(structure.Structure))
+		}
+
+		if section := structure.Structure[0]; section.Title != "Guide" || section.StartLine != 6 {
+			t.Errorf("Expected 'Guide' on line 6, got %q on line %d", section.Title, section.StartLine)
+		}
+
+		if structure.TotalLines != 8 {
+			t.Errorf("Expected 8 total lines, got %d", structure.TotalLines)
+		}
+	})
+
+	t.Run("exclude front matter", func(t *testing.T) {
+		content := []byte("---\ntitle: Guide\n---\n# Guide\n")
+		parser := NewParserWithOptions(ParserOptions{ExcludeFrontMatter: true})
+
+		structure, err := parser.ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if structure.TotalLines != 1 {
+			t.Errorf("Expected 1 total line, got %d", structure.TotalLines)
+		}
+
+		if structure.TotalChars != len("# Guide\n") {
+			t.Errorf("Expected %d total chars, got %d", len("# Guide\n"), structure.TotalChars)
+		}
+
+		if structure.Structure[0].StartLine != 4 {
+			t.Errorf("Expected section to keep its file line 4, got %d", structure.Structure[0].StartLine)
+		}
+	})
+
+	t.Run("malformed front matter", func(t *testing.T) {
+		content := []byte("---\ntitle: [unclosed\n---\n# Title\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if structure.FrontMatter != nil {
+			t.Errorf("Expected no front matter for malformed YAML, got %v", structure.FrontMatter)
+		}
+
+		if len(structure.Structure) != 1 || structure.Structure[0].Title != "Title" {
+			t.Errorf("Expected only the 'Title' section, got %+v", structure.Structure)
+		}
+	})
+
+	t.Run("unclosed front matter", func(t *testing.T) {
+		content := []byte("---\ntitle: Guide\n\n# Title\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if structure.FrontMatter != nil {
+			t.Errorf("Expected no front matter when block is not closed, got %v", structure.FrontMatter)
+		}
+	})
+}
+
+func TestGetSectionContentStopsAtSibling(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte(`# Title
+
+## First
+
+First body
+
+## Second
+
+Second body
+
+### Nested
+
+Nested body`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	first := structure.Structure[0].Children[0]
+	sectionContent, err := parser.GetSectionContent(content, first.ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+
+	if expected := "## First\n\nFirst body\n"; sectionContent.Content != expected {
+		t.Errorf("Expected content %q, got %q", expected, sectionContent.Content)
+	}
+}
+
+func TestGetSectionContentBreadcrumb(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte(`# Guide
+
+## Install
+
+### Linux
+
+#### Packages
+
+Package body
+
+## Usage`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	linux := structure.Structure[0].Children[0].Children[0]
+	packages := linux.Children[0]
+	sectionContent, err := parser.GetSectionContent(content, packages.ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+
+	expected := []string{"Guide", "Install", "Linux"}
+	if strings.Join(sectionContent.Breadcrumb, "/") != strings.Join(expected, "/") {
+		t.Errorf("Expected breadcrumb %v, got %v", expected, sectionContent.Breadcrumb)
+	}
+	if sectionContent.ParentID != linux.ID {
+		t.Errorf("Expected parent ID %s, got %s", linux.ID, sectionContent.ParentID)
+	}
+
+	// Top-level sections have an empty breadcrumb and no parent
+	root, err := parser.GetSectionContent(content, structure.Structure[0].ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+	if len(root.Breadcrumb) != 0 || root.ParentID != "" {
+		t.Errorf("Expected no ancestors for top-level section, got %v and %q", root.Breadcrumb, root.ParentID)
+	}
+}
+
+func TestSectionNeighbors(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte(`# Guide
+
+## Install
+
+### Linux
+
+#### Packages
+
+## Usage
+
+# Appendix`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// Flatten in document order
+	var flat []types.Section
+	var walk func(sections []types.Section)
+	walk = func(sections []types.Section) {
+		for _, section := range sections {
+			flat = append(flat, section)
+			walk(section.Children)
+		}
+	}
+	walk(structure.Structure)
+
+	titles := []string{"Guide", "Install", "Linux", "Packages", "Usage", "Appendix"}
+	if len(flat) != len(titles) {
+		t.Fatalf("Expected %d sections, got %d", len(titles), len(flat))
+	}
+
+	// The chain follows reading order, up and down heading levels
+	for i, section := range flat {
+		if section.Title != titles[i] {
+			t.Fatalf("Expected section %d to be %q, got %q", i, titles[i], section.Title)
+		}
+
+		expectedPrev, expectedNext := "", ""
+		if i > 0 {
+			expectedPrev = flat[i-1].ID
+		}
+		if i < len(flat)-1 {
+			expectedNext = flat[i+1].ID
+		}
+		if section.PrevID != expectedPrev {
+			t.Errorf("%s: expected prev %q, got %q", section.Title, expectedPrev, section.PrevID)
+		}
+		if section.NextID != expectedNext {
+			t.Errorf("%s: expected next %q, got %q", section.Title, expectedNext, section.NextID)
+		}
+	}
+}
+
+func TestSectionByteOffsets(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "nested sections",
+			content: "# Guide\n\nIntro\n\n## Install\n\nSteps\n\n### Linux\n\n## Usage\n\nRun it\n",
+		},
+		{
+			name:    "no trailing newline",
+			content: "# One\n\nFirst\n\n# Two\n\nSecond",
+		},
+		{
+			name:    "front matter and setext headings",
+			content: "---\ntitle: Test\n---\n\nTitle\n=====\n\nBody\n\nSub\n---\n\nMore\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.content)
+			structure, err := parser.ParseStructure(content)
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+
+			var check func(sections []types.Section)
+			check = func(sections []types.Section) {
+				for _, section := range sections {
+					sectionContent, err := parser.ExtractSectionContent(content, structure, section.ID, true)
+					if err != nil {
+						t.Fatalf("ExtractSectionContent failed: %v", err)
+					}
+
+					// Slicing the original bytes reproduces the section content
+					sliced := string(content[section.StartByte:section.EndByte])
+					if sliced != sectionContent.Content {
+						t.Errorf("%s: bytes [%d:%d] = %q, expected %q", section.Title, section.StartByte, section.EndByte, sliced, sectionContent.Content)
+					}
+					check(section.Children)
+				}
+			}
+			check(structure.Structure)
+		})
+	}
+}
+
+func TestCharCountsAddUpToTotal(t *testing.T) {
+	parser := NewParser()
+
+	for _, content := range []string{
+		"Preamble\n\n# One\n\nFirst\n\n## Nested\n\n# Two\n\nSecond\n",
+		"Preamble\n\n# One\n\nFirst\n\n## Nested\n\n# Two\n\nSecond",
+	} {
+		structure, err := parser.ParseStructure([]byte(content))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		// Top-level sections do not overlap, so with the preamble before the
+		// first heading they cover the whole document
+		sum := structure.Structure[0].StartByte
+		for _, section := range structure.Structure {
+			sum += section.CharCount
+		}
+		if sum != structure.TotalChars {
+			t.Errorf("Expected char counts to add up to %d, got %d for %q", structure.TotalChars, sum, content)
+		}
+	}
+}
+
+func TestParsePreamble(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte(`First paragraph of the preamble.
+
+Second paragraph.
+
+## Details
+
+Body`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	preamble := structure.Preamble
+	if preamble == nil {
+		t.Fatal("Expected a preamble section")
+	}
+	if preamble.ID != PreambleSectionID || preamble.Level != 0 {
+		t.Errorf("Expected level 0 section %s, got level %d section %s", PreambleSectionID, preamble.Level, preamble.ID)
+	}
+	if preamble.StartLine != 1 || preamble.EndLine != 4 || preamble.LineCount != 4 {
+		t.Errorf("Expected lines 1-4, got %d-%d (%d lines)", preamble.StartLine, preamble.EndLine, preamble.LineCount)
+	}
+	if preamble.WordCount != 7 {
+		t.Errorf("Expected 7 words, got %d", preamble.WordCount)
+	}
+
+	// The preamble and the sections cover the whole document
+	if total := preamble.CharCount + structure.Structure[0].CharCount; total != structure.TotalChars {
+		t.Errorf("Expected char counts to add up to %d, got %d", structure.TotalChars, total)
+	}
+	if preamble.NextID != structure.Structure[0].ID || structure.Structure[0].PrevID != preamble.ID {
+		t.Error("Expected the preamble to be linked to the first section")
+	}
+
+	sectionContent, err := parser.GetSectionContent(content, PreambleSectionID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+	expected := "First paragraph of the preamble.\n\nSecond paragraph.\n"
+	if sectionContent.Content != expected {
+		t.Errorf("Expected preamble content %q, got %q", expected, sectionContent.Content)
+	}
+	if string(content[preamble.StartByte:preamble.EndByte]) != expected {
+		t.Errorf("Expected byte range to match the preamble, got %q", content[preamble.StartByte:preamble.EndByte])
+	}
+
+	// Front matter and blank lines alone are not a preamble
+	structure, err = parser.ParseStructure([]byte("---\ntitle: Test\n---\n\n# Title\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if structure.Preamble != nil {
+		t.Errorf("Expected no preamble, got %+v", structure.Preamble)
+	}
+}
+
+func TestWarnSkippedLevels(t *testing.T) {
+	content := []byte("# Title\n\n## Section\n\n#### Too Deep\n\n## Other\n")
+
+	// Off by default so existing output is unchanged
+	structure, err := NewParser().ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if structure.Warnings != nil {
+		t.Errorf("Expected no warnings by default, got %+v", structure.Warnings)
+	}
+
+	parser := NewParserWithOptions(ParserOptions{WarnSkippedLevels: true})
+	structure, err = parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	if len(structure.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %+v", structure.Warnings)
+	}
+
+	warning := structure.Warnings[0]
+	deep := structure.Structure[0].Children[0].Children[0]
+	if warning.Kind != IssueSkippedLevel || warning.SectionID != deep.ID || warning.Line != 5 {
+		t.Errorf("Expected skipped level for %s on line 5, got %+v", deep.ID, warning)
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	t.Run("english prose", func(t *testing.T) {
+		content := []byte("# Title Words\n\nOne two three.\n\n## Child\n\nFour five\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		title := structure.Structure[0]
+		if title.WordCount != 5 {
+			t.Errorf("Expected 5 words including children, got %d", title.WordCount)
+		}
+
+		if child := title.Children[0]; child.WordCount != 2 {
+			t.Errorf("Expected 2 words in child, got %d", child.WordCount)
+		}
+
+		if structure.WordCount != 5 {
+			t.Errorf("Expected 5 words in document, got %d", structure.WordCount)
+		}
+	})
+
+	t.Run("code blocks are excluded", func(t *testing.T) {
+		content := []byte("# Code\n\nBefore the block.\n\n```go\nfunc main() { fmt.Println(\"x\") }\n```\n\n    indented code here\n\nAfter.\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if got := structure.Structure[0].WordCount; got != 4 {
+			t.Errorf("Expected 4 prose words, got %d", got)
+		}
+	})
+
+	t.Run("setext underline is excluded", func(t *testing.T) {
+		content := []byte("Title\n=====\n\nJust three words\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if got := structure.Structure[0].WordCount; got != 3 {
+			t.Errorf("Expected 3 words, got %d", got)
+		}
+	})
+
+	t.Run("unicode", func(t *testing.T) {
+		content := []byte("# 日本語\n\n日本語の文章です。 Hello world\n")
+
+		structure, err := NewParser().ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		if got := structure.Structure[0].WordCount; got != 3 {
+			t.Errorf("Expected 3 whitespace tokens, got %d", got)
+		}
+
+		cjkParser := NewParserWithOptions(ParserOptions{WordCountMode: WordCountCJK})
+		structure, err = cjkParser.ParseStructure(content)
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+
+		// 日本語の文章です are 8 CJK characters; the full stop is not a word
+		if got := structure.Structure[0].WordCount; got != 10 {
+			t.Errorf("Expected 10 words in cjk mode, got %d", got)
+		}
+	})
+}
+
+func TestParseStats(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("# Stats\n\n" +
+		"See [inline](https://example.com), [reference][ref] and <https://example.org>.\n\n" +
+		"![diagram](diagram.png)\n\n" +
+		"```go\nfmt.Println(\"fenced\")\n```\n\n" +
+		"    indented code\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"[ref]: https://example.com/ref\n")
+
+	stats, err := parser.ParseStats(content)
+	if err != nil {
+		t.Fatalf("ParseStats failed: %v", err)
+	}
+
+	expected := ElementStats{CodeBlockCount: 2, LinkCount: 3, ImageCount: 1, TableCount: 1}
+	if *stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *stats)
+	}
+}
+
+func TestRenderPlainText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "heading markers",
+			content:  "# Guide ##\n\n## Install\n",
+			expected: "Guide\n\nInstall\n",
+		},
+		{
+			name:     "nested emphasis and code spans",
+			content:  "Use ***very*** **bold `mdatlas` _flags_** and ~~old~~ text.\n",
+			expected: "Use very bold mdatlas flags and old text.\n",
+		},
+		{
+			name:     "links and images keep their labels",
+			content:  "See [the **docs**](https://example.com), ![a diagram](d.png) and <https://example.org>.\n",
+			expected: "See the docs, a diagram and https://example.org.\n",
+		},
+		{
+			name:     "code blocks keep their code",
+			content:  "Run:\n\n```sh\necho \"**hi**\"\n```\n\n    indented\n",
+			expected: "Run:\n\necho \"**hi**\"\n\nindented\n",
+		},
+		{
+			name:     "lists and block quotes",
+			content:  "- [x] one\n- two\n  1. nested\n\n> quoted *text*\n",
+			expected: "one\ntwo\nnested\n\nquoted text\n",
+		},
+		{
+			name:     "tables, HTML and breaks",
+			content:  "| a | b |\n|---|---|\n| `1` | **2** |\n\n---\n\n<div>block</div>\n",
+			expected: "a\tb\n1\t2\n",
+		},
+		{
+			name:     "front matter",
+			content:  "---\ntitle: Guide\n---\nText.\n",
+			expected: "Text.\n",
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plain := parser.RenderPlainText([]byte(tt.content)); plain != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, plain)
+			}
+		})
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	parser := NewParser()
+
+	sectionContent := &types.SectionContent{
+		Content: "## Install\n\n```sh\nmake build\n```\n\n<script>alert(1)</script>\n",
+	}
+	if err := parser.FormatSectionContent(sectionContent, "html"); err != nil {
+		t.Fatalf("FormatSectionContent failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"<h2>Install</h2>\n",
+		"<pre><code class=\"language-sh\">make build\n</code></pre>\n",
+		"<!-- raw HTML omitted -->",
+	} {
+		if !strings.Contains(sectionContent.Content, expected) {
+			t.Errorf("Expected the HTML to contain %q, got %q", expected, sectionContent.Content)
+		}
+	}
+	if strings.Contains(sectionContent.Content, "<script>") {
+		t.Errorf("Expected raw HTML to be omitted, got %q", sectionContent.Content)
+	}
+	if sectionContent.Format != "html" || sectionContent.MimeType != "text/html" {
+		t.Errorf("Expected format html with MIME type text/html, got %q and %q", sectionContent.Format, sectionContent.MimeType)
+	}
+}
+
+func TestFindSectionByPath(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte(`# Guide
+
+## Getting Started
+
+### Installation
+
+Install steps
+
+## Usage
+
+### Options
+
+### Options`)
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	section, err := parser.FindSectionByPath(structure.Structure, "guide/GETTING STARTED/ installation ")
+	if err != nil {
+		t.Fatalf("FindSectionByPath failed: %v", err)
+	}
+
+	if section.Title != "Installation" || section.StartLine != 5 {
+		t.Errorf("Expected Installation on line 5, got %q on line %d", section.Title, section.StartLine)
+	}
+
+	_, err = parser.FindSectionByPath(structure.Structure, "Guide/Usage/Options")
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguity error, got %v", err)
+	}
+
+	_, err = parser.FindSectionByPath(structure.Structure, "Guide/Missing")
+	if !errors.Is(err, ErrSectionNotFound) || !strings.Contains(err.Error(), `"Getting Started", "Usage"`) {
+		t.Errorf("Expected not-found error listing available children, got %v", err)
+	}
+
+	if _, err := parser.ExtractSectionContent(content, structure, "section_missing", false); !errors.Is(err, ErrSectionNotFound) {
+		t.Errorf("Expected ErrSectionNotFound for an unknown ID, got %v", err)
+	}
+
+	sectionContent, err := parser.GetSectionContentByPath(content, "Guide/Getting Started/Installation", false)
+	if err != nil {
+		t.Fatalf("GetSectionContentByPath failed: %v", err)
+	}
+
+	if sectionContent.Content != "### Installation\n\nInstall steps\n" {
+		t.Errorf("Unexpected section content: %q", sectionContent.Content)
+	}
+}
+
+func TestExpandSectionContent(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("# Guide\n\nIntro.\n\n## Install\n\nInstall steps\n\n## Usage\n\nRun it.\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	installID := structure.Structure[0].Children[0].ID
+	usageID := structure.Structure[0].Children[1].ID
+
+	sectionContent, err := parser.ExtractSectionContent(content, structure, installID, false)
+	if err != nil {
+		t.Fatalf("ExtractSectionContent failed: %v", err)
+	}
+	if sectionContent.StartLine != 5 || sectionContent.EndLine != 8 {
+		t.Errorf("Expected lines 5-8 without context, got %d-%d", sectionContent.StartLine, sectionContent.EndLine)
+	}
+
+	parser.ExpandSectionContent(content, sectionContent, 2)
+	if sectionContent.StartLine != 3 || sectionContent.EndLine != 10 {
+		t.Errorf("Expected lines 3-10 with context, got %d-%d", sectionContent.StartLine, sectionContent.EndLine)
+	}
+	if sectionContent.Content != "Intro.\n\n## Install\n\nInstall steps\n\n## Usage\n" {
+		t.Errorf("Unexpected content with context: %q", sectionContent.Content)
+	}
+
+	// Context past the start and end of the file is clamped
+	sectionContent, err = parser.ExtractSectionContent(content, structure, usageID, false)
+	if err != nil {
+		t.Fatalf("ExtractSectionContent failed: %v", err)
+	}
+	parser.ExpandSectionContent(content, sectionContent, 100)
+	if sectionContent.StartLine != 1 || sectionContent.EndLine != 11 || sectionContent.Content != string(content) {
+		t.Errorf("Expected the whole file as lines 1-11, got %d-%d: %q", sectionContent.StartLine, sectionContent.EndLine, sectionContent.Content)
+	}
+}
+
+func TestTruncateSectionContent(t *testing.T) {
+	parser := NewParser()
+	content := "## 概要\n\n日本語の段落です。\n\nSecond paragraph.\n"
+
+	tests := []struct {
+		name        string
+		maxChars    int
+		atParagraph bool
+		expected    string
+		endLine     int
+		truncated   bool
+	}{
+		{name: "fits", maxChars: len(content), expected: content, endLine: 5},
+		{name: "no limit", maxChars: 0, expected: content, endLine: 5},
+		// Byte 21 falls inside "の", whose 3 bytes start at byte 20
+		{name: "rune boundary", maxChars: 21, expected: "## 概要\n\n日本語", endLine: 3, truncated: true},
+		{name: "paragraph boundary", maxChars: 40, atParagraph: true, expected: "## 概要\n\n日本語の段落です。\n", endLine: 3, truncated: true},
+		{name: "no paragraph fits", maxChars: 5, atParagraph: true, expected: "## ", endLine: 1, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sectionContent := &types.SectionContent{Content: content, StartLine: 1, EndLine: 5}
+			parser.TruncateSectionContent(sectionContent, tt.maxChars, tt.atParagraph)
+
+			if sectionContent.Content != tt.expected {
+				t.Errorf("Expected content %q, got %q", tt.expected, sectionContent.Content)
+			}
+			if !utf8.ValidString(sectionContent.Content) {
+				t.Errorf("Expected valid UTF-8, got %q", sectionContent.Content)
+			}
+			if sectionContent.EndLine != tt.endLine {
+				t.Errorf("Expected end line %d, got %d", tt.endLine, sectionContent.EndLine)
+			}
+			if sectionContent.Truncated != tt.truncated {
+				t.Errorf("Expected truncated %v, got %v", tt.truncated, sectionContent.Truncated)
+			}
+			if tt.truncated && sectionContent.OriginalCharCount != len(content) {
+				t.Errorf("Expected original char count %d, got %d", len(content), sectionContent.OriginalCharCount)
+			}
+		})
+	}
+}
+
+func TestExtractSectionRange(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("Some intro.\n\n# Guide\n\n## Install\n\nSteps.\n\n### Linux\n\nPackages.\n\n## Usage\n\nRun it.\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	guide := structure.Structure[0]
+	installID, linuxID, usageID := guide.Children[0].ID, guide.Children[0].Children[0].ID, guide.Children[1].ID
+
+	sectionRange, err := parser.ExtractSectionRange(content, structure, installID, usageID)
+	if err != nil {
+		t.Fatalf("ExtractSectionRange failed: %v", err)
+	}
+	if sectionRange.StartLine != 5 || sectionRange.EndLine != 12 || sectionRange.Content != "## Install\n\nSteps.\n\n### Linux\n\nPackages.\n" {
+		t.Errorf("Expected lines 5-12 up to Usage, got %d-%d: %q", sectionRange.StartLine, sectionRange.EndLine, sectionRange.Content)
+	}
+
+	// The range may start at the preamble and end inside a section
+	sectionRange, err = parser.ExtractSectionRange(content, structure, PreambleSectionID, linuxID)
+	if err != nil {
+		t.Fatalf("ExtractSectionRange failed: %v", err)
+	}
+	if sectionRange.StartLine != 1 || sectionRange.EndLine != 8 {
+		t.Errorf("Expected lines 1-8 up to Linux, got %d-%d", sectionRange.StartLine, sectionRange.EndLine)
+	}
+
+	for _, ids := range [][2]string{{usageID, installID}, {installID, installID}, {installID, "missing"}} {
+		if _, err := parser.ExtractSectionRange(content, structure, ids[0], ids[1]); err == nil {
+			t.Errorf("Expected an error for the range from %s to %s", ids[0], ids[1])
+		}
+	}
+}
+
+func TestPrependPreamble(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("Some intro.\n\n# Guide\n\nText.\n\n## Install\n\nSteps.\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	sectionContent, err := parser.ExtractSectionContent(content, structure, structure.Structure[0].ID, false)
+	if err != nil {
+		t.Fatalf("ExtractSectionContent failed: %v", err)
+	}
+	parser.PrependPreamble(content, structure, sectionContent)
+	if sectionContent.StartLine != 1 || sectionContent.EndLine != 6 || sectionContent.Content != "Some intro.\n\n# Guide\n\nText.\n" {
+		t.Errorf("Expected the preamble before the first section as lines 1-6, got %d-%d: %q", sectionContent.StartLine, sectionContent.EndLine, sectionContent.Content)
+	}
+
+	// Later sections are left as they are
+	installID := structure.Structure[0].Children[0].ID
+	sectionContent, err = parser.ExtractSectionContent(content, structure, installID, false)
+	if err != nil {
+		t.Fatalf("ExtractSectionContent failed: %v", err)
+	}
+	parser.PrependPreamble(content, structure, sectionContent)
+	if sectionContent.StartLine != 7 {
+		t.Errorf("Expected Install to keep starting on line 7, got %d", sectionContent.StartLine)
+	}
+
+	// Without a preamble the first section is unchanged
+	content = []byte("# Guide\n\nText.\n")
+	structure, err = parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	sectionContent, err = parser.ExtractSectionContent(content, structure, structure.Structure[0].ID, false)
+	if err != nil {
+		t.Fatalf("ExtractSectionContent failed: %v", err)
+	}
+	parser.PrependPreamble(content, structure, sectionContent)
+	if sectionContent.StartLine != 1 || sectionContent.Content != string(content) {
+		t.Errorf("Expected the first section alone, got line %d: %q", sectionContent.StartLine, sectionContent.Content)
+	}
+}
+
+func TestRepeatedTitlesGetDistinctIDs(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("# API\n\n## Read\n\n### Examples\n\nread example\n\n" +
+		"## Write\n\n### Examples\n\nwrite example\n\n" +
+		"## Delete\n\n### Examples\n\ndelete example\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// The first occurrence keeps the ID of its title and level alone
+	first := structure.Structure[0].Children[0].Children[0].ID
+	if expected := parser.generateSectionID(3, "Examples", map[string]int{}); first != expected {
+		t.Errorf("Expected the first Examples section to keep ID %s, got %s", expected, first)
+	}
+
+	seen := map[string]bool{}
+	for i, parent := range []string{"read", "write", "delete"} {
+		examples := structure.Structure[0].Children[i].Children[0]
+		if seen[examples.ID] {
+			t.Errorf("Expected a distinct ID for the Examples section under %s, got %s again", parent, examples.ID)
+		}
+		seen[examples.ID] = true
+
+		sectionContent, err := parser.ExtractSectionContent(content, structure, examples.ID, false)
+		if err != nil {
+			t.Fatalf("ExtractSectionContent failed: %v", err)
+		}
+		if expected := "### Examples\n\n" + parent + " example\n"; !strings.HasPrefix(sectionContent.Content, expected) {
+			t.Errorf("Expected %s to resolve to the %s examples, got %q", examples.ID, parent, sectionContent.Content)
+		}
+	}
+}
+
+func TestSuffixedIDsDoNotCollideWithOtherTitles(t *testing.T) {
+	parser := NewParser()
+
+	// "A1#" at level 1 hashes the same key as the second "A" at level 1
+	for _, content := range []string{"# A\n\n# A1#\n\n# A\n", "# A\n\n# A\n\n# A1#\n"} {
+		structure, err := parser.ParseStructure([]byte(content))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+		if len(structure.Structure) != 3 {
+			t.Fatalf("Expected 3 sections for %q, got %d", content, len(structure.Structure))
+		}
+
+		seen := map[string]string{}
+		for _, section := range structure.Structure {
+			if title, ok := seen[section.ID]; ok {
+				t.Errorf("Expected distinct IDs for %q, %s and %s share %s", content, title, section.Title, section.ID)
+			}
+			seen[section.ID] = section.Title
+		}
+	}
+}
+
+func TestFlattenStructureDocumentOrder(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("# B\n\n## Z\n\n### Deep\n\n## A\n\n# A\n\n#### Skipped\n\n## Z\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	flat := parser.FlattenStructure(structure.Structure)
+	var titles []string
+	for i, section := range flat {
+		titles = append(titles, section.Title)
+		if i > 0 && section.StartLine <= flat[i-1].StartLine {
+			t.Errorf("Expected strictly increasing start lines, got %d after %d", section.StartLine, flat[i-1].StartLine)
+		}
+	}
+	if strings.Join(titles, ", ") != "B, Z, Deep, A, A, Skipped, Z" {
+		t.Errorf("Expected the headings in document order, got %v", titles)
+	}
+}
+
+func TestFlattenStructure(t *testing.T) {
+	parser := NewParser()
+
+	content := []byte("# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n\n# Appendix\n")
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	var count func(sections []types.Section) int
+	count = func(sections []types.Section) int {
+		total := len(sections)
+		for _, section := range sections {
+			total += count(section.Children)
+		}
+		return total
+	}
+
+	flat := parser.FlattenStructure(structure.Structure)
+	if len(flat) != count(structure.Structure) {
+		t.Fatalf("Expected %d flat sections, got %d", count(structure.Structure), len(flat))
+	}
+
+	guide := structure.Structure[0]
+	install := guide.Children[0]
+	expected := []struct {
+		title    string
+		parentID string
+	}{
+		{"Guide", ""},
+		{"Install", guide.ID},
+		{"Linux", install.ID},
+		{"macOS", install.ID},
+		{"Usage", guide.ID},
+		{"Appendix", ""},
+	}
+	for i, want := range expected {
+		if flat[i].Title != want.title || flat[i].ParentID != want.parentID {
+			t.Errorf("Expected section %d to be %q under %q, got %q under %q", i, want.title, want.parentID, flat[i].Title, flat[i].ParentID)
+		}
+		if len(flat[i].Children) != 0 {
+			t.Errorf("Expected %q to have no children in the flat list, got %d", flat[i].Title, len(flat[i].Children))
+		}
+	}
+
+	// The nested structure is left as it is
+	if len(structure.Structure[0].Children) != 2 || structure.Structure[0].ParentID != "" {
+		t.Errorf("Expected the nested structure to be unchanged, got %+v", structure.Structure[0])
+	}
+
+	if flat := parser.FlattenStructure(nil); flat == nil || len(flat) != 0 {
+		t.Errorf("Expected an empty, non-nil list without sections, got %#v", flat)
+	}
+}
+
+func TestSectionContentHash(t *testing.T) {
+	parser := NewParser()
+	hashes := func(content string) map[string]string {
+		structure, err := parser.ParseStructure([]byte(content))
+		if err != nil {
+			t.Fatalf("ParseStructure failed: %v", err)
+		}
+		result := map[string]string{}
+		for _, section := range parser.FlattenStructure(structure.Structure) {
+			if section.ContentHash == "" {
+				t.Errorf("Expected a content hash for %q", section.Title)
+			}
+			result[section.Title] = section.ContentHash
+		}
+		return result
+	}
+
+	before := hashes("# Guide\n\nIntro.\n\n## Install\n\nRun make.\n\n## Usage\n\nRun mdatlas.\n")
+	after := hashes("# Guide\n\nIntro.\n\n## Install\n\nRun make install.\n\n## Usage\n\nRun mdatlas.\n")
+
+	if before["Install"] == after["Install"] {
+		t.Error("Expected the edited section's hash to change")
+	}
+	for _, title := range []string{"Guide", "Usage"} {
+		if before[title] != after[title] {
+			t.Errorf("Expected the hash of %q to stay %s, got %s", title, before[title], after[title])
+		}
+	}
+
+	// Sections with the same text hash the same wherever they are
+	moved := hashes("# Guide\n\nIntro.\n\n## Usage\n\nRun mdatlas.\n")
+	if moved["Usage"] != before["Usage"] {
+		t.Errorf("Expected the hash of an unchanged section to stay %s, got %s", before["Usage"], moved["Usage"])
+	}
+}
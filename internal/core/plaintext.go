@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// RenderPlainText renders Markdown content as plain text by walking its AST.
+// Headings lose their leading '#', emphasis, strikethrough and code span
+// markers are dropped, links and images keep only their label or alt text,
+// and list and block quote markers are removed, leaving one list item per
+// line. Code blocks keep their code without the fences, table cells are
+// separated by tabs, and HTML, thematic breaks, task checkboxes and front
+// matter are dropped. Blocks are separated by a blank line.
+func (p *Parser) RenderPlainText(content []byte) string {
+	if fm := detectFrontMatter(content); fm != nil {
+		content = maskFrontMatter(content, fm)
+	}
+
+	doc := p.md.Parser().Parse(text.NewReader(content))
+
+	blocks := renderPlainBlocks(doc, content)
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderPlainBlocks renders the block children of node, one string per block
+func renderPlainBlocks(node ast.Node, content []byte) []string {
+	var blocks []string
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if block := renderPlainBlock(child, content); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// renderPlainBlock renders a single block node
+func renderPlainBlock(node ast.Node, content []byte) string {
+	switch n := node.(type) {
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var code bytes.Buffer
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			code.Write(segment.Value(content))
+		}
+		return strings.TrimRight(code.String(), "\n")
+	case *ast.HTMLBlock, *ast.ThematicBreak:
+		return ""
+	case *ast.List, *ast.ListItem:
+		// Nested blocks are kept on consecutive lines, as in a tight list
+		return strings.Join(renderPlainBlocks(n, content), "\n")
+	case *extast.Table:
+		var rows []string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, renderPlainInline(cell, content))
+			}
+			rows = append(rows, strings.Join(cells, "\t"))
+		}
+		return strings.Join(rows, "\n")
+	default:
+		// Block quotes hold blocks like a document; headings, paragraphs
+		// and the text blocks of tight list items hold inline text
+		if n.HasChildren() && n.FirstChild().Type() == ast.TypeBlock {
+			return strings.Join(renderPlainBlocks(n, content), "\n\n")
+		}
+		return renderPlainInline(n, content)
+	}
+}
+
+// renderPlainInline renders the visible text of the inline children of node
+func renderPlainInline(node ast.Node, content []byte) string {
+	var plain strings.Builder
+
+	_ = ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := child.(type) {
+		case *ast.Text:
+			plain.Write(n.Value(content))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				plain.WriteByte('\n')
+			}
+		case *ast.String:
+			plain.Write(n.Value)
+		case *ast.AutoLink:
+			plain.Write(n.Label(content))
+		case *ast.RawHTML, *extast.TaskCheckBox:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(plain.String())
+}
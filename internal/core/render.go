@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// ErrUnsupportedFormat is returned, wrapped with the format name, for an
+// output format that is not one of those offered
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// RenderHTML renders Markdown content, with GitHub Flavored Markdown, to an
+// HTML fragment. Raw HTML in the content is not passed through: goldmark
+// replaces it with an "<!-- raw HTML omitted -->" comment, so the fragment
+// is safe to preview. Front matter is dropped.
+func (p *Parser) RenderHTML(content []byte) (string, error) {
+	if fm := detectFrontMatter(content); fm != nil {
+		content = maskFrontMatter(content, fm)
+	}
+
+	var html bytes.Buffer
+	if err := p.md.Convert(content, &html); err != nil {
+		return "", fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return html.String(), nil
+}
+
+// FormatSectionContent sets the format of sectionContent, rendering its
+// Markdown content as plain text for "plain" and as HTML for "html", and sets
+// the MIME type of the content. Any other format, such as "json", keeps the
+// Markdown as written. The token estimate is taken, with TokenizerChars, from
+// the content in its final format.
+func (p *Parser) FormatSectionContent(sectionContent *types.SectionContent, format string) error {
+	sectionContent.Format = format
+
+	switch format {
+	case "plain":
+		sectionContent.Content = p.RenderPlainText([]byte(sectionContent.Content))
+		sectionContent.MimeType = "text/plain"
+	case "html":
+		html, err := p.RenderHTML([]byte(sectionContent.Content))
+		if err != nil {
+			return err
+		}
+		sectionContent.Content = html
+		sectionContent.MimeType = "text/html"
+	default:
+		sectionContent.MimeType = "text/markdown"
+	}
+
+	sectionContent.TokenEstimate = EstimateTokens(sectionContent.Content, TokenizerChars)
+	return nil
+}
@@ -0,0 +1,373 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// FastStructureMinSize is the file size from which StructureManager streams
+// a file through ScanStructure when ParserOptions.FastStructure is set
+var FastStructureMinSize int64 = 4 * 1024 * 1024 // 4MB
+
+// scanCheckLines is how many lines ScanStructureContext reads between
+// checks of its context
+const scanCheckLines = 4096
+
+// structureScan carries per-document state while ScanStructure reads lines
+type structureScan struct {
+	parser    *Parser
+	offset    int // Byte offset of the next line
+	line      int // Number of the line being scanned
+	runes     int
+	words     int
+	sections  []types.Section
+	open      []int // Indexes of the sections still open, outermost first
+	preamble  *types.Section
+	prose     bool // Whether the preamble has non-whitespace text
+	usedIDs   map[string]int
+	fenceChar byte
+	fenceLen  int // Length of the open code fence, 0 outside code blocks
+	reuse     *headingReuse
+	digest    *xxhash.Digest // Hash of the last section's own lines so far
+}
+
+// headingReuse lists the headings a scan for ReparseStructure must find
+// again, in document order, and the titles to give them
+type headingReuse struct {
+	hashes   []uint64 // Hashes of the raw heading lines
+	titles   []string
+	mismatch bool // Whether the scan found other headings
+}
+
+// ScanStructure extracts the structure of a document read line by line from
+// r, without loading it into memory or building the Markdown AST. Section
+// boundaries, counts and byte offsets match ParseStructure, but only ATX
+// headings outside fenced code blocks are recognized (Setext headings and
+// headings nested in block quotes or lists are not), and titles keep their
+// inline markup, e.g. "**bold**" and "`code`" stay as written.
+func (p *Parser) ScanStructure(r io.Reader) (*types.DocumentStructure, error) {
+	return p.ScanStructureContext(context.Background(), r)
+}
+
+// ScanStructureContext is ScanStructure, abandoned with ctx's error once ctx
+// is done
+func (p *Parser) ScanStructureContext(ctx context.Context, r io.Reader) (*types.DocumentStructure, error) {
+	return p.scanStructure(ctx, r, nil)
+}
+
+// scanStructure implements ScanStructureContext. With reuse, the headings
+// found are checked against and titled after reuse.
+func (p *Parser) scanStructure(ctx context.Context, r io.Reader, reuse *headingReuse) (*types.DocumentStructure, error) {
+	if !p.options.KeepLineEndings {
+		r = &lineEndingReader{r: r}
+	}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	structure := &types.DocumentStructure{
+		Structure:    []types.Section{},
+		LastModified: time.Now(),
+	}
+	scan := &structureScan{
+		parser:  p,
+		usedIDs: map[string]int{PreambleSectionID: 1},
+		reuse:   reuse,
+	}
+
+	eof := false
+	lines := 0
+	readLine := func() (string, error) {
+		if lines%scanCheckLines == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		lines++
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			eof, err = true, nil
+		}
+		return line, err
+	}
+
+	// Buffer the lines of a possible front matter block, which is only
+	// known to be one once its closing delimiter is found
+	first, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := DecodeContent([]byte(first))
+	if err != nil {
+		return nil, err
+	}
+	first = string(decoded)
+	pending := []string{first}
+	if isFrontMatterDelimiter([]byte(first), false) {
+		for !eof {
+			line, err := readLine()
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, line)
+			if isFrontMatterDelimiter([]byte(line), true) {
+				break
+			}
+		}
+
+		block := strings.Join(pending, "")
+		if fm := detectFrontMatter([]byte(block)); fm != nil {
+			structure.FrontMatter = fm.Data
+			scan.skip(block, fm.Lines)
+			if p.options.ExcludeFrontMatter {
+				structure.TotalChars -= fm.End
+				structure.TotalRunes -= utf8.RuneCountInString(block)
+				structure.TotalLines -= fm.Lines
+			}
+			pending = nil
+		}
+	}
+
+	for _, line := range pending {
+		scan.addLine(line)
+	}
+	for !eof {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		scan.addLine(line)
+	}
+
+	sections := scan.finish()
+	if reuse != nil && len(sections) != len(reuse.hashes) {
+		reuse.mismatch = true
+	}
+
+	structure.TotalChars += scan.offset
+	structure.TotalRunes += scan.runes
+	structure.TotalLines += scan.line
+	structure.WordCount = scan.words
+	structure.Preamble = scan.preamble
+
+	p.assembleStructure(structure, sections)
+	return structure, nil
+}
+
+// skip accounts for the lines of a front matter block, which belong to no
+// section
+func (s *structureScan) skip(block string, lines int) {
+	s.offset += len(block)
+	s.runes += utf8.RuneCountInString(block)
+	s.line += lines
+}
+
+// addLine scans one line, including its trailing newline if it has one. The
+// empty read at the end of content, after its last newline or of empty
+// content, is no line (see countLines).
+func (s *structureScan) addLine(raw string) {
+	if raw == "" {
+		return
+	}
+	s.line++
+	start := s.offset
+	s.offset += len(raw)
+	runes := utf8.RuneCountInString(raw)
+	s.runes += runes
+	text := strings.TrimRight(raw, "\r\n")
+
+	prose := false
+	if s.fenceLen > 0 {
+		if isClosingFence(text, s.fenceChar, s.fenceLen) {
+			s.fenceLen = 0
+		}
+	} else if char, length := openingFence(text); length > 0 {
+		s.fenceChar, s.fenceLen = char, length
+	} else if level, title, ok := parseATXHeading(text); ok && !s.parser.ignoresLevel(level) {
+		s.openSection(level, title, text, start)
+	} else {
+		prose = true
+	}
+
+	words := 0
+	if prose {
+		words = countWords(text, s.parser.options.WordCountMode)
+		s.words += words
+	}
+
+	// Lines before the first heading make up the preamble
+	if len(s.sections) == 0 {
+		if s.preamble == nil {
+			s.preamble = &types.Section{
+				ID:        PreambleSectionID,
+				Title:     "Preamble",
+				StartLine: s.line,
+				StartByte: start,
+				Children:  []types.Section{},
+			}
+		}
+		addLineCounts(s.preamble, s.line, len(raw), runes, words)
+		s.prose = s.prose || strings.TrimSpace(text) != ""
+		return
+	}
+
+	for _, index := range s.open {
+		addLineCounts(&s.sections[index], s.line, len(raw), runes, words)
+	}
+	s.digest.WriteString(raw)
+}
+
+// openSection starts a section at a heading line, ending the open sections
+// at the same or a deeper level and, for the first heading, the preamble
+func (s *structureScan) openSection(level int, title, rawTitle string, start int) {
+	if len(s.sections) == 0 {
+		s.closePreamble(start - 1)
+	}
+
+	if s.reuse != nil {
+		index := len(s.sections)
+		if index < len(s.reuse.hashes) && xxhash.Sum64String(rawTitle) == s.reuse.hashes[index] {
+			title = s.reuse.titles[index]
+		} else {
+			s.reuse.mismatch = true
+		}
+	}
+
+	s.closeContentHash()
+
+	for len(s.open) > 0 && s.sections[s.open[len(s.open)-1]].Level >= level {
+		// Stop before the newline ending the section's last line, as in
+		// calculateSectionBoundaries
+		s.sections[s.open[len(s.open)-1]].EndByte = start - 1
+		s.open = s.open[:len(s.open)-1]
+	}
+
+	s.sections = append(s.sections, types.Section{
+		ID:        s.parser.generateSectionID(level, title, s.usedIDs),
+		Level:     level,
+		Title:     title,
+		RawTitle:  rawTitle,
+		StartLine: s.line,
+		StartByte: start,
+		Children:  []types.Section{},
+	})
+	s.open = append(s.open, len(s.sections)-1)
+}
+
+// closeContentHash sets the content hash of the last section, whose own
+// lines end at the next heading, as in calculateSectionBoundaries
+func (s *structureScan) closeContentHash() {
+	if len(s.sections) == 0 {
+		s.digest = xxhash.New()
+		return
+	}
+	s.sections[len(s.sections)-1].ContentHash = formatHash(s.digest.Sum64())
+	s.digest.Reset()
+}
+
+// closePreamble ends the preamble at endByte, dropping it if it holds only
+// whitespace
+func (s *structureScan) closePreamble(endByte int) {
+	if s.preamble == nil {
+		return
+	}
+	if !s.prose {
+		s.preamble = nil
+		return
+	}
+	s.preamble.EndByte = endByte
+}
+
+// finish ends the sections still open at the end of the document and
+// returns all sections in document order
+func (s *structureScan) finish() []types.Section {
+	if len(s.sections) == 0 {
+		s.closePreamble(s.offset)
+	}
+	if len(s.sections) > 0 {
+		s.closeContentHash()
+	}
+	for _, index := range s.open {
+		s.sections[index].EndByte = s.offset
+	}
+	return s.sections
+}
+
+// addLineCounts adds a line to the counts of a section
+func addLineCounts(section *types.Section, line, chars, runes, words int) {
+	section.EndLine = line
+	section.LineCount++
+	section.CharCount += chars
+	section.RuneCount += runes
+	section.WordCount += words
+}
+
+// parseATXHeading parses an ATX heading line such as "## Title ##",
+// returning its level and title with the closing sequence removed
+func parseATXHeading(line string) (int, string, bool) {
+	rest := strings.TrimLeft(line, " ")
+	if len(line)-len(rest) > 3 {
+		return 0, "", false
+	}
+
+	level := 0
+	for level < len(rest) && rest[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+
+	rest = rest[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false
+	}
+
+	// The closing sequence must be separated from the title by whitespace,
+	// so "# C#" keeps its trailing '#'
+	title := strings.TrimSpace(rest)
+	if trimmed := strings.TrimRight(title, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") || strings.HasSuffix(trimmed, "\t") {
+		title = strings.TrimSpace(trimmed)
+	}
+
+	return level, title, true
+}
+
+// openingFence returns the fence character and length of a line opening a
+// fenced code block, or a zero length if the line opens none
+func openingFence(line string) (byte, int) {
+	rest := strings.TrimLeft(line, " ")
+	if len(line)-len(rest) > 3 || len(rest) < 3 || (rest[0] != '`' && rest[0] != '~') {
+		return 0, 0
+	}
+
+	length := 0
+	for length < len(rest) && rest[length] == rest[0] {
+		length++
+	}
+	if length < 3 || (rest[0] == '`' && strings.Contains(rest[length:], "`")) {
+		return 0, 0
+	}
+
+	return rest[0], length
+}
+
+// isClosingFence reports whether a line closes a code block opened by a
+// fence of length characters
+func isClosingFence(line string, char byte, length int) bool {
+	rest := strings.TrimLeft(line, " ")
+	if len(line)-len(rest) > 3 {
+		return false
+	}
+
+	count := 0
+	for count < len(rest) && rest[count] == char {
+		count++
+	}
+
+	return count >= length && strings.TrimSpace(rest[count:]) == ""
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanStructureMatchesParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		options ParserOptions
+	}{
+		{
+			name:    "nested sections",
+			content: "# Guide\n\nIntro text.\n\n## Install\n\nRun the installer.\n\n### Linux\n\nUse the package.\n\n## Usage\n\nRun it.\n",
+		},
+		{
+			name:    "no trailing newline",
+			content: "# Title\n\nBody text without a final newline",
+		},
+		{
+			name:    "preamble and front matter",
+			content: "---\ntitle: Guide\n---\nSome intro before the first heading.\n\n# Guide ##\n\nText.\n",
+		},
+		{
+			name:    "excluded front matter",
+			content: "---\ntitle: Guide\n---\n# Guide\n\nText.\n",
+			options: ParserOptions{ExcludeFrontMatter: true},
+		},
+		{
+			name:    "code fences hide headings",
+			content: "# Code\n\n```sh\n# not a heading\n```\n\n~~~~\n## still code\n~~~\n~~~~\n\n## After C#\n\nDone.\n",
+		},
+		{
+			name:    "unclosed code fence",
+			content: "# Code\n\n```\n# not a heading\n",
+		},
+		{
+			name:    "slug ids and skipped levels",
+			content: "# Guide\n\n### Details\n\n## Empty\n\n#\n\n## Empty\n",
+			options: ParserOptions{IDStyle: IDStyleSlug, WarnSkippedLevels: true},
+		},
+		{
+			name:    "ignored levels",
+			content: "##### Label\n\n# Guide\n\n### Details\n\n##### Note\n\nText.\n\n## Next\n",
+			options: ParserOptions{IgnoreLevels: []int{5}},
+		},
+		{
+			name:    "whitespace preamble",
+			content: "\n\n# Title\n",
+		},
+		{
+			name:    "unclosed front matter",
+			content: "---\ntitle: Guide\n# Guide\n\nText.\n",
+		},
+		{
+			name:    "CRLF line endings",
+			content: "# Guide\r\n\r\nText.\r\n\r\n## Next ##\r\n",
+		},
+		{
+			name:    "CR line endings",
+			content: "# Guide\r\rText.\r\r## Next ##\r",
+		},
+		{
+			name:    "byte order mark",
+			content: "\ufeff# Guide\n\nText.\n",
+		},
+		{
+			name:    "no headings",
+			content: "Just a paragraph.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParserWithOptions(tt.options)
+
+			parsed, err := parser.ParseStructure([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseStructure failed: %v", err)
+			}
+			scanned, err := parser.ScanStructure(bytes.NewReader([]byte(tt.content)))
+			if err != nil {
+				t.Fatalf("ScanStructure failed: %v", err)
+			}
+
+			parsed.LastModified, scanned.LastModified = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(parsed, scanned) {
+				t.Errorf("Scanned structure differs from the parsed one\nparsed:  %+v\nscanned: %+v", parsed, scanned)
+			}
+		})
+	}
+}
+
+func TestScanStructureKeepsInlineMarkup(t *testing.T) {
+	structure, err := NewParser().ScanStructure(bytes.NewReader([]byte("# Using `mdatlas` **fast**\n")))
+	if err != nil {
+		t.Fatalf("ScanStructure failed: %v", err)
+	}
+
+	if title := structure.Structure[0].Title; title != "Using `mdatlas` **fast**" {
+		t.Errorf("Expected inline markup to be kept, got %q", title)
+	}
+}
+
+func TestStructureManagerFastStructure(t *testing.T) {
+	filePath := writeLargeDocument(t, 10)
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read document: %v", err)
+	}
+	if err := os.WriteFile(filePath, append([]byte("# *Fast*\n\n"), content...), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	defer func(size int64) { FastStructureMinSize = size }(FastStructureMinSize)
+
+	// Below the threshold the file is parsed and emphasis is stripped
+	FastStructureMinSize = int64(len(content)) * 2
+	sm := NewStructureManagerWithParser(nil, NewParserWithOptions(ParserOptions{FastStructure: true}))
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	if title := structure.Structure[0].Title; title != "Fast" {
+		t.Errorf("Expected parsed title %q, got %q", "Fast", title)
+	}
+
+	// From the threshold on the file is scanned and the markup is kept
+	FastStructureMinSize = 1
+	structure, err = sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	if title := structure.Structure[0].Title; title != "*Fast*" {
+		t.Errorf("Expected scanned title %q, got %q", "*Fast*", title)
+	}
+	if len(structure.Structure) != 2 || len(structure.Structure[1].Children) != 10 {
+		t.Errorf("Expected the large document's sections after the first heading, got %d top-level sections", len(structure.Structure))
+	}
+}
+
+// slowReader delays every read, standing in for a file too large to scan in
+// time
+type slowReader struct {
+	r     *strings.Reader
+	delay time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	if len(p) > 512 {
+		p = p[:512]
+	}
+	return s.r.Read(p)
+}
+
+func TestScanStructureContextDeadline(t *testing.T) {
+	content := strings.Repeat("# Heading\n\nText.\n", 10000)
+	reader := &slowReader{r: strings.NewReader(content), delay: time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewParser().ScanStructureContext(ctx, reader)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the scan to stop soon after the deadline, took %v", elapsed)
+	}
+}
+
+// BenchmarkParseStructureLarge measures reading a large document whole and
+// parsing it into the Markdown AST
+func BenchmarkParseStructureLarge(b *testing.B) {
+	filePath := writeLargeDocument(b, 2000)
+	sm := NewStructureManager(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sm.GetDocumentStructure(filePath); err != nil {
+			b.Fatalf("GetDocumentStructure failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkParseStructureLargeNoCounts measures the same as
+// BenchmarkParseStructureLarge without counting the lines of each section
+func BenchmarkParseStructureLargeNoCounts(b *testing.B) {
+	filePath := writeLargeDocument(b, 2000)
+	sm := NewStructureManagerWithParser(nil, NewParserWithOptions(ParserOptions{NoCounts: true}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sm.GetDocumentStructure(filePath); err != nil {
+			b.Fatalf("GetDocumentStructure failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkScanStructureLarge measures streaming the same document through
+// the line scanner used by FastStructure
+func BenchmarkScanStructureLarge(b *testing.B) {
+	filePath := writeLargeDocument(b, 2000)
+	sm := NewStructureManagerWithParser(nil, NewParserWithOptions(ParserOptions{FastStructure: true}))
+
+	defer func(size int64) { FastStructureMinSize = size }(FastStructureMinSize)
+	FastStructureMinSize = 0
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sm.GetDocumentStructure(filePath); err != nil {
+			b.Fatalf("GetDocumentStructure failed: %v", err)
+		}
+	}
+}
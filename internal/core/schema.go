@@ -0,0 +1,128 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDialect is the JSON Schema draft JSONSchema writes schemas for
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchema returns a JSON Schema describing the JSON encoding of v, built
+// by reflection from its struct fields and their json tags so that it follows
+// the types as they change. Fields without omitempty are required, embedded
+// structs without a tag contribute their fields as encoding/json promotes
+// them, and the named structs v refers to are described once under $defs.
+// Objects admit no properties beyond their fields.
+func JSONSchema(v interface{}, title string) map[string]interface{} {
+	g := &schemaGenerator{defs: map[string]interface{}{}}
+	schema := g.structSchema(reflect.TypeOf(v))
+	schema["$schema"] = JSONSchemaDialect
+	schema["title"] = title
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema
+}
+
+// schemaGenerator collects the definitions of the named structs met while
+// describing a type
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// typeSchema describes the JSON encoding of a value of type t
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return map[string]interface{}{"anyOf": []interface{}{g.typeSchema(t.Elem()), map[string]interface{}{"type": "null"}}}
+	case t.Kind() == reflect.Struct:
+		return g.ref(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = g.typeSchema(t.Elem())
+		}
+		// encoding/json writes integer map keys as decimal strings
+		if t.Key().Kind() != reflect.String {
+			schema["propertyNames"] = map[string]interface{}{"pattern": "^-?[0-9]+$"}
+		}
+		return schema
+	default:
+		// Interfaces may hold any value
+		return map[string]interface{}{}
+	}
+}
+
+// ref returns a reference to the definition of a named struct, adding the
+// definition on first use
+func (g *schemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	if _, ok := g.defs[t.Name()]; !ok {
+		// Reserve the name first, as the struct may refer to itself
+		g.defs[t.Name()] = nil
+		g.defs[t.Name()] = g.structSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+}
+
+// structSchema describes a struct as an object with a property per field
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	g.addFields(t, properties, &required)
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// addFields adds the properties encoding/json writes for the fields of a
+// struct, in field order
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.typeSchema(field.Type)
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema(types.DocumentStructure{}, "structure")
+
+	if schema["$schema"] != JSONSchemaDialect || schema["title"] != "structure" {
+		t.Errorf("Expected the dialect and title to be set, got %v and %v", schema["$schema"], schema["title"])
+	}
+
+	required := schema["required"].([]string)
+	if !reflect.DeepEqual(required, []string{"file_path", "total_chars", "total_runes", "total_lines", "word_count", "structure", "last_modified"}) {
+		t.Errorf("Expected the fields without omitempty to be required, got %v", required)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	if format := properties["last_modified"].(map[string]interface{})["format"]; format != "date-time" {
+		t.Errorf("Expected last_modified to be a date-time, got %v", format)
+	}
+	if items := properties["structure"].(map[string]interface{})["items"]; !reflect.DeepEqual(items, map[string]interface{}{"$ref": "#/$defs/Section"}) {
+		t.Errorf("Expected structure items to refer to Section, got %v", items)
+	}
+
+	// Section refers to itself through its children
+	section := schema["$defs"].(map[string]interface{})["Section"].(map[string]interface{})
+	children := section["properties"].(map[string]interface{})["children"].(map[string]interface{})
+	if !reflect.DeepEqual(children["items"], map[string]interface{}{"$ref": "#/$defs/Section"}) {
+		t.Errorf("Expected children items to refer to Section, got %v", children["items"])
+	}
+}
+
+func TestJSONSchemaPromotesEmbeddedFields(t *testing.T) {
+	schema := JSONSchema(DocumentStats{}, "stats")
+
+	properties := schema["properties"].(map[string]interface{})
+	if _, ok := properties["code_block_count"]; !ok {
+		t.Error("Expected the fields of ElementStats to be properties of the stats")
+	}
+	if _, ok := properties["ElementStats"]; ok {
+		t.Error("Expected no property for the embedded ElementStats itself")
+	}
+
+	levelCounts := properties["level_counts"].(map[string]interface{})
+	if _, ok := levelCounts["propertyNames"]; !ok {
+		t.Error("Expected level_counts to restrict its keys to integers")
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,9 +11,44 @@ import (
 	"github.com/mosaan/mdatlas/pkg/types"
 )
 
+// Default access restrictions used by NewAccessControl
+var (
+	DefaultAllowedExts       = []string{".md", ".markdown", ".txt"}
+	DefaultMaxFileSize int64 = 50 * 1024 * 1024 // 50MB
+)
+
+// ErrAccessDenied matches, with errors.Is, every error of AccessControl
+// refusing to read a file that exists: ErrOutsideBaseDir,
+// ErrExtensionNotAllowed and ErrFileTooLarge
+var ErrAccessDenied = errors.New("access denied")
+
+// Errors returned by AccessControl when it refuses a path. They are wrapped
+// with the offending path, so callers test for them with errors.Is.
+var (
+	ErrOutsideBaseDir      error = &accessDeniedError{"path outside base directory"}
+	ErrExtensionNotAllowed error = &accessDeniedError{"file extension not allowed"}
+	ErrFileNotFound              = errors.New("file does not exist")
+	ErrFileTooLarge        error = &accessDeniedError{"file too large"}
+)
+
+// accessDeniedError is a reason for refusing a file that is also
+// ErrAccessDenied
+type accessDeniedError struct {
+	message string
+}
+
+func (e *accessDeniedError) Error() string {
+	return e.message
+}
+
+func (e *accessDeniedError) Is(target error) bool {
+	return target == ErrAccessDenied
+}
+
 // AccessControl manages file access restrictions and security
 type AccessControl struct {
-	config *types.AccessConfig
+	config     *types.AccessConfig
+	ignoreFile *ignoreFile // IgnoreFileName in the base directory
 }
 
 // NewAccessControl creates a new AccessControl instance
@@ -30,11 +66,14 @@ func NewAccessControl(baseDir string) (*AccessControl, error) {
 
 	config := &types.AccessConfig{
 		BaseDir:     absBaseDir,
-		AllowedExts: []string{".md", ".markdown", ".txt"},
-		MaxFileSize: 50 * 1024 * 1024, // 50MB
+		AllowedExts: append([]string(nil), DefaultAllowedExts...),
+		MaxFileSize: DefaultMaxFileSize,
 	}
 
-	return &AccessControl{config: config}, nil
+	return &AccessControl{
+		config:     config,
+		ignoreFile: newIgnoreFile(filepath.Join(absBaseDir, IgnoreFileName)),
+	}, nil
 }
 
 // IsAllowed checks if access to a file path is allowed
@@ -70,40 +109,87 @@ func (ac *AccessControl) IsAllowed(filePath string) bool {
 
 // ValidatePath validates and normalizes a file path
 func (ac *AccessControl) ValidatePath(filePath string) (string, error) {
-	// Resolve relative to base directory
-	var absPath string
-	if filepath.IsAbs(filePath) {
-		absPath = filePath
-	} else {
-		absPath = filepath.Join(ac.config.BaseDir, filePath)
-	}
-
-	// Clean the path to remove any path traversal attempts
-	cleanPath := filepath.Clean(absPath)
+	cleanPath := ac.resolvePath(filePath)
 
 	// Check if path is within base directory
 	if !ac.isWithinBaseDir(cleanPath) {
-		return "", fmt.Errorf("path outside base directory: %s", filePath)
+		return "", fmt.Errorf("%w: %s", ErrOutsideBaseDir, filePath)
 	}
 
 	// Check file extension
 	if !ac.isAllowedExtension(cleanPath) {
-		return "", fmt.Errorf("file extension not allowed: %s", filepath.Ext(cleanPath))
+		return "", fmt.Errorf("%w: %s", ErrExtensionNotAllowed, filepath.Ext(cleanPath))
 	}
 
 	// Check if file exists
 	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file does not exist: %s", filePath)
+		return "", fmt.Errorf("%w: %s", ErrFileNotFound, filePath)
 	}
 
 	// Check file size
 	if !ac.isAllowedSize(cleanPath) {
-		return "", fmt.Errorf("file too large: %s", filePath)
+		return "", fmt.Errorf("%w: %s", ErrFileTooLarge, filePath)
 	}
 
 	return cleanPath, nil
 }
 
+// resolvePath resolves filePath against the base directory and cleans it to
+// remove any path traversal attempts
+func (ac *AccessControl) resolvePath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filepath.Clean(filePath)
+	}
+	return filepath.Clean(filepath.Join(ac.config.BaseDir, filePath))
+}
+
+// Reasons reported by CheckPath for a refused path
+const (
+	PathReasonOutsideBase  = "outside_base"
+	PathReasonBadExtension = "bad_extension"
+	PathReasonNotFound     = "not_found"
+	PathReasonTooLarge     = "too_large"
+)
+
+// PathCheck is the outcome of checking a path with CheckPath
+type PathCheck struct {
+	Allowed      bool   `json:"allowed"`
+	RelativePath string `json:"relative_path"`
+	Reason       string `json:"reason,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// CheckPath runs ValidatePath on filePath without reading the file and
+// reports whether it is accessible and, if not, why. RelativePath is the
+// path resolved against the base directory, reported even for a refused
+// path, so for a path outside the base directory it starts with "..".
+func (ac *AccessControl) CheckPath(filePath string) *PathCheck {
+	check := &PathCheck{RelativePath: ac.resolvePath(filePath)}
+	if relPath, err := filepath.Rel(ac.config.BaseDir, check.RelativePath); err == nil {
+		check.RelativePath = relPath
+	}
+
+	_, err := ac.ValidatePath(filePath)
+	if err == nil {
+		check.Allowed = true
+		return check
+	}
+
+	check.Message = err.Error()
+	switch {
+	case errors.Is(err, ErrOutsideBaseDir):
+		check.Reason = PathReasonOutsideBase
+	case errors.Is(err, ErrExtensionNotAllowed):
+		check.Reason = PathReasonBadExtension
+	case errors.Is(err, ErrFileNotFound):
+		check.Reason = PathReasonNotFound
+	case errors.Is(err, ErrFileTooLarge):
+		check.Reason = PathReasonTooLarge
+	}
+
+	return check
+}
+
 // isWithinBaseDir checks if a path is within the base directory
 func (ac *AccessControl) isWithinBaseDir(absPath string) bool {
 	// Ensure both paths end with separator for proper comparison
@@ -181,37 +267,76 @@ func (ac *AccessControl) UpdateConfig(config types.AccessConfig) error {
 		return fmt.Errorf("at least one allowed extension must be specified")
 	}
 
+	// File extensions are compared in lower case
+	allowedExts := make([]string, 0, len(config.AllowedExts))
+	for _, ext := range config.AllowedExts {
+		if len(ext) < 2 || !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("invalid extension %q: extensions must start with a dot, e.g. .md", ext)
+		}
+		allowedExts = append(allowedExts, strings.ToLower(ext))
+	}
+
+	// Validate exclude patterns
+	for _, pattern := range config.ExcludePatterns {
+		if err := validateExcludePattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	if absBaseDir != ac.config.BaseDir {
+		ac.ignoreFile = newIgnoreFile(filepath.Join(absBaseDir, IgnoreFileName))
+	}
+
 	// Update configuration
 	ac.config = &types.AccessConfig{
-		BaseDir:     absBaseDir,
-		AllowedExts: config.AllowedExts,
-		MaxFileSize: config.MaxFileSize,
+		BaseDir:         absBaseDir,
+		AllowedExts:     allowedExts,
+		MaxFileSize:     config.MaxFileSize,
+		ExcludePatterns: config.ExcludePatterns,
+		UseGitignore:    config.UseGitignore,
 	}
 
 	return nil
 }
 
-// ListAllowedFiles lists all files within the base directory that are allowed
+// ListAllowedFiles lists all files within the base directory that are allowed.
+// Paths matching an exclude pattern, a pattern of the base directory's
+// IgnoreFileName or, when enabled, its .gitignore are skipped even if they
+// have an allowed extension. The ignore file is parsed again only once it
+// changes.
 func (ac *AccessControl) ListAllowedFiles() ([]string, error) {
 	var allowedFiles []string
 
-	err := filepath.Walk(ac.config.BaseDir, func(path string, info os.FileInfo, err error) error {
+	fileRules, err := ac.ignoreFile.Rules()
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := newIgnoreMatcher(ac.config.BaseDir, ac.config.ExcludePatterns, ac.config.UseGitignore, fileRules)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(ac.config.BaseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Convert to relative path from base directory
+		relPath, err := filepath.Rel(ac.config.BaseDir, path)
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip directories, pruning excluded ones
 		if info.IsDir() {
+			if relPath != "." && ignore.Match(relPath, true) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
 		// Check if file is allowed
-		if ac.IsAllowed(path) {
-			// Convert to relative path from base directory
-			relPath, err := filepath.Rel(ac.config.BaseDir, path)
-			if err != nil {
-				return err
-			}
+		if !ignore.Match(relPath, false) && ac.IsAllowed(path) {
 			allowedFiles = append(allowedFiles, relPath)
 		}
 
@@ -221,6 +346,86 @@ func (ac *AccessControl) ListAllowedFiles() ([]string, error) {
 	return allowedFiles, err
 }
 
+// ListAllowedFilesIn lists the allowed files under dir, a directory relative
+// to the base directory ("" or "." for the base directory itself). The
+// returned paths are relative to the base directory, as in ListAllowedFiles.
+func (ac *AccessControl) ListAllowedFilesIn(dir string) ([]string, error) {
+	absDir := dir
+	if !filepath.IsAbs(dir) {
+		absDir = filepath.Join(ac.config.BaseDir, dir)
+	}
+	absDir = filepath.Clean(absDir)
+
+	// isWithinBaseDir checks the parent of absDir, which is outside the
+	// base directory when absDir is the base directory itself
+	if absDir != ac.config.BaseDir && !ac.isWithinBaseDir(absDir) {
+		return nil, fmt.Errorf("%w: %s", ErrOutsideBaseDir, dir)
+	}
+
+	stat, err := os.Stat(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("directory does not exist: %s", dir)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	files, err := ac.ListAllowedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	relDir, err := filepath.Rel(ac.config.BaseDir, absDir)
+	if err != nil || relDir == "." {
+		return files, err
+	}
+
+	var inDir []string
+	prefix := relDir + string(filepath.Separator)
+	for _, file := range files {
+		if strings.HasPrefix(file, prefix) {
+			inDir = append(inDir, file)
+		}
+	}
+
+	return inDir, nil
+}
+
+// ListFileInfos returns information about the allowed files whose path
+// relative to the base directory matches pattern, a glob in the same syntax
+// as exclude patterns ("**" matches any number of directories). An empty
+// pattern matches every allowed file.
+func (ac *AccessControl) ListFileInfos(pattern string) ([]*FileInfo, error) {
+	var segments []string
+	if pattern != "" {
+		var err error
+		if segments, err = splitGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := ac.ListAllowedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*FileInfo, 0, len(files))
+	for _, file := range files {
+		if segments != nil && !matchSegments(segments, strings.Split(filepath.ToSlash(file), "/")) {
+			continue
+		}
+
+		// A file removed since the directory walk is simply left out
+		info, err := ac.GetFileInfo(file)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
 // GetFileInfo returns information about a file if access is allowed
 func (ac *AccessControl) GetFileInfo(filePath string) (*FileInfo, error) {
 	validPath, err := ac.ValidatePath(filePath)
@@ -261,7 +466,9 @@ type FileInfo struct {
 
 // SecureFileReader provides secure file reading with access control
 type SecureFileReader struct {
-	accessControl *AccessControl
+	accessControl   *AccessControl
+	contentCache    *ContentCache
+	keepLineEndings bool
 }
 
 // NewSecureFileReader creates a new secure file reader
@@ -271,6 +478,19 @@ func NewSecureFileReader(accessControl *AccessControl) *SecureFileReader {
 	}
 }
 
+// SetContentCache makes the reader serve files from contentCache. Content
+// returned by ReadFile is then shared and must not be modified.
+func (sfr *SecureFileReader) SetContentCache(contentCache *ContentCache) {
+	sfr.contentCache = contentCache
+}
+
+// SetKeepLineEndings makes ReadLineRange split lines at LF only, leaving CRLF
+// and lone CR line endings as written, to match a parser created with
+// ParserOptions.KeepLineEndings
+func (sfr *SecureFileReader) SetKeepLineEndings(keep bool) {
+	sfr.keepLineEndings = keep
+}
+
 // ReadFile securely reads a file with access control
 func (sfr *SecureFileReader) ReadFile(filePath string) ([]byte, error) {
 	validPath, err := sfr.accessControl.ValidatePath(filePath)
@@ -278,6 +498,10 @@ func (sfr *SecureFileReader) ReadFile(filePath string) ([]byte, error) {
 		return nil, err
 	}
 
+	if sfr.contentCache != nil {
+		return sfr.contentCache.ReadFile(validPath)
+	}
+
 	content, err := os.ReadFile(validPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
@@ -288,17 +512,68 @@ func (sfr *SecureFileReader) ReadFile(filePath string) ([]byte, error) {
 
 // ReadFileLines securely reads file lines with access control
 func (sfr *SecureFileReader) ReadFileLines(filePath string, startLine, endLine int) ([]string, error) {
+	lineRange, err := sfr.ReadLineRange(filePath, startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	return lineRange.Lines, nil
+}
+
+// ReadLineRange securely reads a range of file lines with access control and
+// reports the range actually returned after clamping. A UTF-8 byte order mark
+// is not part of the first line, and CRLF and lone CR line endings end lines
+// like LF unless SetKeepLineEndings is set.
+func (sfr *SecureFileReader) ReadLineRange(filePath string, startLine, endLine int) (*LineRange, error) {
 	content, err := sfr.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	content, err = DecodeContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	if !sfr.keepLineEndings {
+		content = NormalizeLineEndings(content)
+	}
+
+	return SliceLines(content, startLine, endLine)
+}
 
-	lines := strings.Split(string(content), "\n")
+// LineRange represents a range of lines read from a document
+type LineRange struct {
+	StartLine  int      `json:"start_line"`
+	EndLine    int      `json:"end_line"`
+	TotalLines int      `json:"total_lines"`
+	Lines      []string `json:"lines"`
+}
+
+// Header returns a Markdown comment describing the line range, suitable for
+// prefixing the returned lines
+func (lr *LineRange) Header() string {
+	return fmt.Sprintf("<!-- lines %d-%d of %d -->", lr.StartLine, lr.EndLine, lr.TotalLines)
+}
+
+// SliceLines returns lines [startLine, endLine] (1-based, inclusive) of content.
+// A startLine below 1 starts at the first line, and an endLine below 1 or past
+// the end of the content is clamped to the last line. Lines are counted like
+// DocumentStructure.TotalLines, so empty content has no lines and returns an
+// empty range starting and ending at line 0.
+func SliceLines(content []byte, startLine, endLine int) (*LineRange, error) {
+	// The split leaves an empty string after a trailing newline, which is
+	// not a line
+	lines := strings.Split(string(content), "\n")[:countLines(content)]
+	if len(lines) == 0 {
+		return &LineRange{Lines: []string{}}, nil
+	}
 
 	// Validate line ranges
 	if startLine < 1 {
 		startLine = 1
 	}
+	if startLine > len(lines) {
+		return nil, fmt.Errorf("start line %d is beyond the end of the file (%d lines)", startLine, len(lines))
+	}
 	if endLine < 1 || endLine > len(lines) {
 		endLine = len(lines)
 	}
@@ -306,5 +581,10 @@ func (sfr *SecureFileReader) ReadFileLines(filePath string, startLine, endLine i
 		return nil, fmt.Errorf("invalid line range: start=%d, end=%d", startLine, endLine)
 	}
 
-	return lines[startLine-1 : endLine], nil
+	return &LineRange{
+		StartLine:  startLine,
+		EndLine:    endLine,
+		TotalLines: len(lines),
+		Lines:      lines[startLine-1 : endLine],
+	}, nil
 }
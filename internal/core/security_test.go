@@ -0,0 +1,438 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSliceLines(t *testing.T) {
+	content := "one\ntwo\nthree\nfour"
+
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		wantStart int
+		wantEnd   int
+		wantLines []string
+		wantErr   string
+	}{
+		{name: "middle range", start: 2, end: 3, wantStart: 2, wantEnd: 3, wantLines: []string{"two", "three"}},
+		{name: "end clamped", start: 3, end: 99, wantStart: 3, wantEnd: 4, wantLines: []string{"three", "four"}},
+		{name: "open end", start: 1, end: 0, wantStart: 1, wantEnd: 4, wantLines: []string{"one", "two", "three", "four"}},
+		{name: "start beyond file", start: 5, end: 6, wantErr: "beyond the end of the file"},
+		{name: "start after end", start: 3, end: 2, wantErr: "invalid line range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SliceLines([]byte(content), tt.start, tt.end)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SliceLines failed: %v", err)
+			}
+			if result.StartLine != tt.wantStart || result.EndLine != tt.wantEnd {
+				t.Errorf("Expected range %d-%d, got %d-%d", tt.wantStart, tt.wantEnd, result.StartLine, result.EndLine)
+			}
+			if result.TotalLines != 4 {
+				t.Errorf("Expected 4 total lines, got %d", result.TotalLines)
+			}
+			if strings.Join(result.Lines, "\n") != strings.Join(tt.wantLines, "\n") {
+				t.Errorf("Expected lines %v, got %v", tt.wantLines, result.Lines)
+			}
+		})
+	}
+}
+
+func TestUpdateConfigValidatesExtensions(t *testing.T) {
+	ac, err := NewAccessControl(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+
+	config := ac.GetConfig()
+	config.AllowedExts = []string{".MDX", ".mkd"}
+	if err := ac.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if got := ac.GetConfig().AllowedExts; strings.Join(got, ",") != ".mdx,.mkd" {
+		t.Errorf("Expected lower-cased extensions, got %v", got)
+	}
+
+	config.AllowedExts = []string{"mdx"}
+	if err := ac.UpdateConfig(config); err == nil || !strings.Contains(err.Error(), "must start with a dot") {
+		t.Errorf("Expected error for extension without a leading dot, got %v", err)
+	}
+
+	config.AllowedExts = []string{".md"}
+	config.MaxFileSize = 0
+	if err := ac.UpdateConfig(config); err == nil {
+		t.Error("Expected error for non-positive max file size")
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	baseDir := t.TempDir()
+	for name, content := range map[string]string{
+		"doc.md":    "# Doc\n",
+		"big.md":    strings.Repeat("x", 200),
+		"notes.pdf": "%PDF",
+	} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+	config := ac.GetConfig()
+	config.MaxFileSize = 100
+	if err := ac.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		wantAllowed bool
+		wantPath    string
+		wantReason  string
+	}{
+		{path: "doc.md", wantAllowed: true, wantPath: "doc.md"},
+		{path: "./sub/../doc.md", wantAllowed: true, wantPath: "doc.md"},
+		{path: "../outside.md", wantPath: "../outside.md", wantReason: PathReasonOutsideBase},
+		{path: "notes.pdf", wantPath: "notes.pdf", wantReason: PathReasonBadExtension},
+		{path: "missing.md", wantPath: "missing.md", wantReason: PathReasonNotFound},
+		{path: "big.md", wantPath: "big.md", wantReason: PathReasonTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			check := ac.CheckPath(tt.path)
+			if check.Allowed != tt.wantAllowed || check.Reason != tt.wantReason {
+				t.Errorf("Expected allowed=%v reason=%q, got allowed=%v reason=%q", tt.wantAllowed, tt.wantReason, check.Allowed, check.Reason)
+			}
+			if filepath.ToSlash(check.RelativePath) != tt.wantPath {
+				t.Errorf("Expected relative path %q, got %q", tt.wantPath, check.RelativePath)
+			}
+			if !tt.wantAllowed && check.Message == "" {
+				t.Error("Expected a message for a refused path")
+			}
+		})
+	}
+}
+
+func TestListAllowedFilesExcludes(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, file := range []string{
+		"README.md",
+		"docs/guide.md",
+		"docs/drafts/wip.md",
+		"node_modules/pkg/README.md",
+		"build/out.md",
+		"notes/keep.md",
+		"notes/scratch.md",
+	} {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte("# Test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	gitignore := "# build output\nbuild/\nnotes/*.md\n!notes/keep.md\n"
+	if err := os.WriteFile(filepath.Join(baseDir, ".gitignore"), []byte(gitignore), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		excludes     []string
+		useGitignore bool
+		want         []string
+	}{
+		{
+			name: "no excludes",
+			want: []string{"README.md", "build/out.md", "docs/drafts/wip.md", "docs/guide.md", "node_modules/pkg/README.md", "notes/keep.md", "notes/scratch.md"},
+		},
+		{
+			name:     "exclude globs",
+			excludes: []string{"node_modules", "docs/**/wip.md"},
+			want:     []string{"README.md", "build/out.md", "docs/guide.md", "notes/keep.md", "notes/scratch.md"},
+		},
+		{
+			name:         "gitignore",
+			useGitignore: true,
+			want:         []string{"README.md", "docs/drafts/wip.md", "docs/guide.md", "node_modules/pkg/README.md", "notes/keep.md"},
+		},
+		{
+			name:         "exclude wins over gitignore negation",
+			excludes:     []string{"notes/keep.md"},
+			useGitignore: true,
+			want:         []string{"README.md", "docs/drafts/wip.md", "docs/guide.md", "node_modules/pkg/README.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, err := NewAccessControl(baseDir)
+			if err != nil {
+				t.Fatalf("NewAccessControl failed: %v", err)
+			}
+
+			config := ac.GetConfig()
+			config.ExcludePatterns = tt.excludes
+			config.UseGitignore = tt.useGitignore
+			if err := ac.UpdateConfig(config); err != nil {
+				t.Fatalf("UpdateConfig failed: %v", err)
+			}
+
+			files, err := ac.ListAllowedFiles()
+			if err != nil {
+				t.Fatalf("ListAllowedFiles failed: %v", err)
+			}
+			for i := range files {
+				files[i] = filepath.ToSlash(files[i])
+			}
+			if strings.Join(files, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, files)
+			}
+		})
+	}
+}
+
+func TestListAllowedFilesIgnoreFile(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, file := range []string{
+		"README.md",
+		"docs/guide.md",
+		"docs/internal/plan.md",
+		"drafts/a.md",
+		"drafts/keep.md",
+		"notes/todo.md",
+		"vendor/lib/README.md",
+	} {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte("# Test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	writeIgnoreFile := func(content string) {
+		if err := os.WriteFile(filepath.Join(baseDir, IgnoreFileName), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", IgnoreFileName, err)
+		}
+	}
+	listFiles := func(ac *AccessControl) string {
+		files, err := ac.ListAllowedFiles()
+		if err != nil {
+			t.Fatalf("ListAllowedFiles failed: %v", err)
+		}
+		for i := range files {
+			files[i] = filepath.ToSlash(files[i])
+		}
+		return strings.Join(files, ",")
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+	if got, want := listFiles(ac), "README.md,docs/guide.md,docs/internal/plan.md,drafts/a.md,drafts/keep.md,notes/todo.md,vendor/lib/README.md"; got != want {
+		t.Errorf("Expected every file without an ignore file, got %v", got)
+	}
+
+	// A glob, a negation re-including a globbed file, anchored and
+	// unanchored directory patterns
+	writeIgnoreFile("# private docs\ndrafts/*.md\n!drafts/keep.md\n/docs/internal/\nvendor/\n")
+	if got, want := listFiles(ac), "README.md,docs/guide.md,drafts/keep.md,notes/todo.md"; got != want {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Exclude globs add to the ignore file and win over its negations
+	config := ac.GetConfig()
+	config.ExcludePatterns = []string{"notes", "drafts/keep.md"}
+	if err := ac.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if got, want := listFiles(ac), "README.md,docs/guide.md"; got != want {
+		t.Errorf("Expected the union of both exclusions %v, got %v", want, got)
+	}
+
+	// A changed ignore file is parsed again
+	config.ExcludePatterns = nil
+	if err := ac.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	writeIgnoreFile("*.md\n!/README.md\n")
+	if got, want := listFiles(ac), "README.md"; got != want {
+		t.Errorf("Expected the edited ignore file to apply, got %v", got)
+	}
+}
+
+func TestUpdateConfigRejectsBadExcludePattern(t *testing.T) {
+	ac, err := NewAccessControl(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+
+	config := ac.GetConfig()
+	config.ExcludePatterns = []string{"docs/[unclosed"}
+	if err := ac.UpdateConfig(config); err == nil {
+		t.Error("Expected error for malformed exclude pattern")
+	}
+}
+
+func TestListFileInfos(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, file := range []string{"README.md", "docs/guide.md", "docs/api/reference.md", "notes.txt"} {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte("# Test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "", want: []string{"README.md", "docs/api/reference.md", "docs/guide.md", "notes.txt"}},
+		{pattern: "*.md", want: []string{"README.md"}},
+		{pattern: "docs/**/*.md", want: []string{"docs/api/reference.md", "docs/guide.md"}},
+		{pattern: "docs/*.md", want: []string{"docs/guide.md"}},
+	}
+
+	for _, tt := range tests {
+		infos, err := ac.ListFileInfos(tt.pattern)
+		if err != nil {
+			t.Fatalf("ListFileInfos(%q) failed: %v", tt.pattern, err)
+		}
+
+		var got []string
+		for _, info := range infos {
+			got = append(got, filepath.ToSlash(info.RelativePath))
+			if info.Size != int64(len("# Test\n")) {
+				t.Errorf("%s: expected size %d, got %d", info.RelativePath, len("# Test\n"), info.Size)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ListFileInfos(%q): expected %v, got %v", tt.pattern, tt.want, got)
+		}
+	}
+
+	if _, err := ac.ListFileInfos("docs/[unclosed"); err == nil {
+		t.Error("Expected error for malformed pattern")
+	}
+}
+
+func TestListAllowedFilesIn(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, file := range []string{"README.md", "docs/guide.md", "docs/api/reference.md", "docsearch/notes.md"} {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte("# Test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{dir: "", want: []string{"README.md", "docs/api/reference.md", "docs/guide.md", "docsearch/notes.md"}},
+		{dir: ".", want: []string{"README.md", "docs/api/reference.md", "docs/guide.md", "docsearch/notes.md"}},
+		{dir: "docs", want: []string{"docs/api/reference.md", "docs/guide.md"}},
+		{dir: "docs/api/", want: []string{"docs/api/reference.md"}},
+	}
+
+	for _, tt := range tests {
+		files, err := ac.ListAllowedFilesIn(tt.dir)
+		if err != nil {
+			t.Fatalf("ListAllowedFilesIn(%q) failed: %v", tt.dir, err)
+		}
+		for i := range files {
+			files[i] = filepath.ToSlash(files[i])
+		}
+		if strings.Join(files, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ListAllowedFilesIn(%q): expected %v, got %v", tt.dir, tt.want, files)
+		}
+	}
+
+	for _, dir := range []string{"..", "missing", "README.md"} {
+		if _, err := ac.ListAllowedFilesIn(dir); err == nil {
+			t.Errorf("Expected error for directory %q", dir)
+		}
+	}
+}
+
+func TestValidatePathErrors(t *testing.T) {
+	baseDir := t.TempDir()
+	for name, size := range map[string]int{"doc.md": 10, "big.md": 200, "notes.json": 10} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ac, err := NewAccessControl(baseDir)
+	if err != nil {
+		t.Fatalf("NewAccessControl failed: %v", err)
+	}
+	config := ac.GetConfig()
+	config.MaxFileSize = 100
+	if err := ac.UpdateConfig(config); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		want   error
+		denied bool
+	}{
+		{path: "../doc.md", want: ErrOutsideBaseDir, denied: true},
+		{path: "notes.json", want: ErrExtensionNotAllowed, denied: true},
+		{path: "missing.md", want: ErrFileNotFound},
+		{path: "big.md", want: ErrFileTooLarge, denied: true},
+	}
+
+	for _, tt := range tests {
+		_, err := ac.ValidatePath(tt.path)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("ValidatePath(%q): expected %v, got %v", tt.path, tt.want, err)
+		}
+		if errors.Is(err, ErrAccessDenied) != tt.denied {
+			t.Errorf("ValidatePath(%q): expected ErrAccessDenied %v, got %v", tt.path, tt.denied, err)
+		}
+	}
+
+	if _, err := ac.ValidatePath("doc.md"); err != nil {
+		t.Errorf("ValidatePath(%q) failed: %v", "doc.md", err)
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// ElementStats holds counts of Markdown elements in a document
+type ElementStats struct {
+	CodeBlockCount int `json:"code_block_count" yaml:"code_block_count"`
+	LinkCount      int `json:"link_count" yaml:"link_count"`
+	ImageCount     int `json:"image_count" yaml:"image_count"`
+	TableCount     int `json:"table_count" yaml:"table_count"`
+	TaskCount      int `json:"task_count" yaml:"task_count"`
+	CompletedTasks int `json:"completed_tasks" yaml:"completed_tasks"`
+}
+
+// ParseStats walks the Markdown AST of content and counts its elements.
+// Fenced and indented code blocks are both counted as code blocks, and
+// reference-style links count as links once their definition resolves.
+// Bare URLs are autolinked as in GitHub Flavored Markdown and count as links,
+// and every task list checkbox counts as a task.
+func (p *Parser) ParseStats(content []byte) (*ElementStats, error) {
+	if fm := detectFrontMatter(content); fm != nil {
+		content = maskFrontMatter(content, fm)
+	}
+
+	doc := p.md.Parser().Parse(text.NewReader(content))
+
+	stats := &ElementStats{}
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node.Kind() {
+		case ast.KindFencedCodeBlock, ast.KindCodeBlock:
+			stats.CodeBlockCount++
+		case ast.KindLink, ast.KindAutoLink:
+			stats.LinkCount++
+		case ast.KindImage:
+			stats.ImageCount++
+		case extast.KindTable:
+			stats.TableCount++
+		case extast.KindTaskCheckBox:
+			stats.TaskCount++
+			if node.(*extast.TaskCheckBox).IsChecked {
+				stats.CompletedTasks++
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -1,31 +1,84 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/mosaan/mdatlas/pkg/types"
 )
 
 // StructureManager manages document structure information and provides
 // higher-level operations for document analysis
 type StructureManager struct {
-	parser *Parser
-	cache  *Cache
+	parser       *Parser
+	cache        *Cache
+	diskCache    *DiskCache
+	contentCache *ContentCache
+	ctx          context.Context // Set by WithContext; nil means no deadline
 }
 
 // NewStructureManager creates a new StructureManager instance
 func NewStructureManager(cache *Cache) *StructureManager {
+	return NewStructureManagerWithParser(cache, NewParser())
+}
+
+// NewStructureManagerWithParser creates a new StructureManager instance that
+// uses the given parser, e.g. one configured with custom ParserOptions
+func NewStructureManagerWithParser(cache *Cache, parser *Parser) *StructureManager {
 	return &StructureManager{
-		parser: NewParser(),
+		parser: parser,
 		cache:  cache,
 	}
 }
 
+// SetDiskCache makes the manager consult and populate diskCache after the
+// in-memory cache, so structures survive between processes
+func (sm *StructureManager) SetDiskCache(diskCache *DiskCache) {
+	sm.diskCache = diskCache
+}
+
+// SetContentCache makes the manager read files through contentCache, so
+// repeated section lookups in the same file are served from memory
+func (sm *StructureManager) SetContentCache(contentCache *ContentCache) {
+	sm.contentCache = contentCache
+}
+
+// Parser returns the parser the manager parses documents with
+func (sm *StructureManager) Parser() *Parser {
+	return sm.parser
+}
+
+// WithContext returns a shallow copy of the manager, sharing its parser and
+// caches, whose operations are abandoned with ctx's error once ctx is done
+func (sm *StructureManager) WithContext(ctx context.Context) *StructureManager {
+	manager := *sm
+	manager.ctx = ctx
+	return &manager
+}
+
+// context returns the context set by WithContext, or the background context
+func (sm *StructureManager) context() context.Context {
+	if sm.ctx == nil {
+		return context.Background()
+	}
+	return sm.ctx
+}
+
 // GetDocumentStructure retrieves the structure of a document with caching
 func (sm *StructureManager) GetDocumentStructure(filePath string) (*types.DocumentStructure, error) {
+	if err := sm.context().Err(); err != nil {
+		return nil, err
+	}
+
 	// Check cache first
 	if sm.cache != nil {
 		if structure, exists := sm.cache.GetStructure(filePath); exists {
@@ -33,20 +86,31 @@ func (sm *StructureManager) GetDocumentStructure(filePath string) (*types.Docume
 		}
 	}
 
-	// Read file and parse structure
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	// Then the disk cache, which validates the file's mtime and hash
+	if sm.diskCache != nil {
+		if entry, exists := sm.diskCache.GetStructure(filePath, sm.parser.Options()); exists {
+			if sm.cache != nil {
+				sm.cache.SetEntry(filePath, entry)
+			}
+			return entry.Structure, nil
+		}
 	}
 
-	structure, err := sm.parser.ParseStructure(content)
+	// Read file and parse structure, building on the structure cached for
+	// an earlier version of the file, if any
+	var previous *types.DocumentStructure
+	if sm.cache != nil {
+		previous, _ = sm.cache.PreviousStructure(filePath)
+	}
+	structure, contentHash, err := sm.parseFile(filePath, previous)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse structure for %s: %w", filePath, err)
+		return nil, err
 	}
 
 	// Set file path and get file modification time
 	structure.FilePath = filePath
-	if stat, err := os.Stat(filePath); err == nil {
+	stat, statErr := os.Stat(filePath)
+	if statErr == nil {
 		structure.LastModified = stat.ModTime()
 	}
 
@@ -54,62 +118,557 @@ func (sm *StructureManager) GetDocumentStructure(filePath string) (*types.Docume
 	if sm.cache != nil {
 		sm.cache.SetStructure(filePath, structure)
 	}
+	if sm.diskCache != nil && statErr == nil {
+		// Record the hash of the content that was parsed, so a concurrent
+		// edit invalidates the entry. A failed write only costs a reparse in
+		// the next process.
+		sm.diskCache.SetStructure(filePath, sm.parser.Options(), &CacheEntry{
+			Structure:    structure,
+			LastAccessed: time.Now(),
+			FileModTime:  stat.ModTime(),
+			FileSize:     stat.Size(),
+			FileHash:     contentHash,
+		})
+	}
 
 	return structure, nil
 }
 
-// GetSectionContent retrieves content for a specific section
-func (sm *StructureManager) GetSectionContent(filePath, sectionID string, includeChildren bool) (*types.SectionContent, error) {
+// GetDocumentStructures retrieves the structures of several documents like
+// GetDocumentStructure, parsing up to concurrency of them at once
+// (DefaultConcurrency if concurrency is 0 or less). The structures are
+// returned in the order of filePaths; on failure, the error is that of the
+// first failing path.
+func (sm *StructureManager) GetDocumentStructures(filePaths []string, concurrency int) ([]*types.DocumentStructure, error) {
+	structures := make([]*types.DocumentStructure, len(filePaths))
+	err := forEachIndex(len(filePaths), concurrency, func(i int) error {
+		structure, err := sm.GetDocumentStructure(filePaths[i])
+		if err != nil {
+			return fmt.Errorf("failed to get structure of %s: %w", filePaths[i], err)
+		}
+		structures[i] = structure
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return structures, nil
+}
+
+// GetDocumentStructureWithIgnoreLevels retrieves the structure of a document
+// like GetDocumentStructure, with the headings at ignoreLevels left in the
+// body of the preceding section (see ParserOptions.IgnoreLevels). Unless the
+// levels are the parser's own, the structure is parsed with a parser
+// configured for them and is not kept in the in-memory cache.
+func (sm *StructureManager) GetDocumentStructureWithIgnoreLevels(filePath string, ignoreLevels []int) (*types.DocumentStructure, error) {
+	options := sm.parser.options
+	options.IgnoreLevels = ignoreLevels
+	return sm.GetDocumentStructureWithOptions(filePath, options)
+}
+
+// GetDocumentStructureWithOptions retrieves the structure of a document like
+// GetDocumentStructure, parsed with options instead of the parser's own.
+// Unless options equal the parser's, the structure is not kept in the
+// in-memory cache.
+func (sm *StructureManager) GetDocumentStructureWithOptions(filePath string, options ParserOptions) (*types.DocumentStructure, error) {
+	parser := NewParserWithOptions(options)
+	if reflect.DeepEqual(parser.options, sm.parser.options) {
+		return sm.GetDocumentStructure(filePath)
+	}
+
+	// The disk cache keys entries by parser options, so it can be shared
+	manager := *sm
+	manager.parser = parser
+	manager.cache = nil
+	return manager.GetDocumentStructure(filePath)
+}
+
+// parseFile parses the structure of filePath and returns it with the hash of
+// the parsed content. previous, if not nil, is the structure of an earlier
+// version of the file to reparse incrementally (see ReparseStructure). With
+// ParserOptions.FastStructure, files of at least FastStructureMinSize bytes
+// are streamed through ScanStructure rather than read into memory.
+func (sm *StructureManager) parseFile(filePath string, previous *types.DocumentStructure) (*types.DocumentStructure, string, error) {
+	if sm.parser.options.FastStructure {
+		if stat, err := os.Stat(filePath); err == nil && stat.Size() >= FastStructureMinSize {
+			return sm.scanFile(filePath)
+		}
+	}
+
+	// Hash the file as stored, which is what cache validation rehashes
+	content, err := sm.readRawFile(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	structure, err := sm.parser.ReparseStructureContext(sm.context(), previous, content)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse structure for %s: %w", filePath, err)
+	}
+
+	return structure, hashContent(content), nil
+}
+
+// readFile reads filePath like readRawFile, removes a UTF-8 byte order mark
+// and normalizes line endings as the parser does, so the content lines up
+// with the parsed structure
+func (sm *StructureManager) readFile(filePath string) ([]byte, error) {
+	content, err := sm.readRawFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err = sm.parser.decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	return content, nil
+}
+
+// readRawFile reads filePath through the content cache if one is set
+func (sm *StructureManager) readRawFile(filePath string) ([]byte, error) {
+	if sm.contentCache != nil {
+		return sm.contentCache.ReadFile(filePath)
+	}
+
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
+	return content, nil
+}
 
-	return sm.parser.GetSectionContent(content, sectionID, includeChildren)
+// scanFile scans the structure of filePath through a buffered reader,
+// hashing the content as it is read
+func (sm *StructureManager) scanFile(filePath string) (*types.DocumentStructure, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	digest := xxhash.New()
+	structure, err := sm.parser.ScanStructureContext(sm.context(), io.TeeReader(file, digest))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scan structure for %s: %w", filePath, err)
+	}
+
+	return structure, formatHash(digest.Sum64()), nil
 }
 
-// SearchSections searches for sections matching a query
-func (sm *StructureManager) SearchSections(filePath, query string, caseSensitive bool) ([]types.Section, error) {
+// GetSectionContent retrieves content for a specific section
+func (sm *StructureManager) GetSectionContent(filePath, sectionID string, includeChildren bool) (*types.SectionContent, error) {
+	return sm.GetSectionContentWithContext(filePath, sectionID, includeChildren, 0)
+}
+
+// GetSectionContentWithContext is GetSectionContent with contextLines lines
+// of the surrounding document added before and after the section
+func (sm *StructureManager) GetSectionContentWithContext(filePath, sectionID string, includeChildren bool, contextLines int) (*types.SectionContent, error) {
+	return sm.GetSectionContentWithPreamble(filePath, sectionID, includeChildren, contextLines, false)
+}
+
+// GetSectionContentWithPreamble is GetSectionContentWithContext that, with
+// includePreamble, starts the content of the document's first section at its
+// preamble (see Parser.PrependPreamble). The context lines are added around
+// the widened content.
+func (sm *StructureManager) GetSectionContentWithPreamble(filePath, sectionID string, includeChildren bool, contextLines int, includePreamble bool) (*types.SectionContent, error) {
+	// Locate the section through the (cached) structure instead of reparsing
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	sectionContent, err := sm.parser.ExtractSectionContent(content, structure, sectionID, includeChildren)
+	if err != nil {
+		return nil, err
+	}
+
+	if includePreamble {
+		sm.parser.PrependPreamble(content, structure, sectionContent)
+	}
+	sm.parser.ExpandSectionContent(content, sectionContent, contextLines)
+	return sectionContent, nil
+}
+
+// GetSectionContentByPath retrieves content for a section identified by its
+// '/'-separated heading path
+func (sm *StructureManager) GetSectionContentByPath(filePath, sectionPath string, includeChildren bool) (*types.SectionContent, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := sm.parser.FindSectionByPath(structure.Structure, sectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.parser.sliceSectionContent(content, structure.Structure, section, includeChildren), nil
+}
+
+// GetSectionRange retrieves the content of the document from the start of
+// the section fromID up to the start of the section toID (see
+// Parser.ExtractSectionRange)
+func (sm *StructureManager) GetSectionRange(filePath, fromID, toID string) (*SectionRange, error) {
 	structure, err := sm.GetDocumentStructure(filePath)
 	if err != nil {
 		return nil, err
 	}
 
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.parser.ExtractSectionRange(content, structure, fromID, toID)
+}
+
+// SectionSummary is a section listed without its subsections, with the
+// number of its direct subsections instead
+type SectionSummary struct {
+	types.Section
+	ChildCount int `json:"child_count"`
+}
+
+// summarizeSections lists sections without their subsections. The list is
+// empty rather than nil when there are none.
+func summarizeSections(sections []types.Section) []SectionSummary {
+	summaries := make([]SectionSummary, 0, len(sections))
+	for _, section := range sections {
+		count := len(section.Children)
+		section.Children = []types.Section{}
+		summaries = append(summaries, SectionSummary{Section: section, ChildCount: count})
+	}
+	return summaries
+}
+
+// GetSectionChildren returns the direct subsections of a section without
+// their descendants, so a large document can be explored one level at a
+// time. A section without subsections, such as the preamble, has none.
+func (sm *StructureManager) GetSectionChildren(filePath, sectionID string) ([]SectionSummary, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	section := sm.parser.findSection(structure.Structure, sectionID)
+	if section == nil && sectionID == PreambleSectionID && structure.Preamble != nil {
+		section = structure.Preamble
+	}
+	if section == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, sectionID)
+	}
+
+	return summarizeSections(section.Children), nil
+}
+
+// SectionContentResult is the outcome of looking up one of several sections
+type SectionContentResult struct {
+	RequestedID string `json:"requested_id"`
+	*types.SectionContent
+	Error string `json:"error,omitempty"`
+}
+
+// GetSectionContents retrieves content for several sections in request order.
+// The file is read and its structure resolved once for all lookups, and a
+// missing section is reported in its own entry rather than failing the call.
+func (sm *StructureManager) GetSectionContents(filePath string, sectionIDs []string, includeChildren bool) ([]SectionContentResult, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SectionContentResult, 0, len(sectionIDs))
+	for _, sectionID := range sectionIDs {
+		result := SectionContentResult{RequestedID: sectionID}
+
+		sectionContent, err := sm.parser.ExtractSectionContent(content, structure, sectionID, includeChildren)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.SectionContent = sectionContent
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Search modes accepted by SearchSectionsWithMode and SearchSectionBodiesWithMode
+const (
+	// SearchModeSubstring matches text containing the query as written
+	SearchModeSubstring = "substring"
+	// SearchModeRegex matches text against the query as a regular expression
+	// in RE2 syntax, e.g. `^Chapter \d+`
+	SearchModeRegex = "regex"
+)
+
+// ErrInvalidSearchQuery is returned for a regex query that does not compile
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// IsValidSearchMode reports whether mode is a supported search mode
+func IsValidSearchMode(mode string) bool {
+	return mode == SearchModeSubstring || mode == SearchModeRegex
+}
+
+// textMatcher returns the start and end byte offsets of every
+// non-overlapping match of a query in text, or nil if there is none
+type textMatcher func(text string) [][]int
+
+// newTextMatcher prepares query for matching in the given mode, compiling a
+// regular expression only once for all sections searched. Substrings are
+// matched as quoted patterns, so case-insensitive matches keep the offsets
+// of the original text.
+func newTextMatcher(query, mode string, caseSensitive bool) (textMatcher, error) {
+	var pattern string
+	switch mode {
+	case SearchModeSubstring:
+		pattern = regexp.QuoteMeta(query)
+	case SearchModeRegex:
+		pattern = query
+	default:
+		return nil, fmt.Errorf("unsupported search mode: %s", mode)
+	}
+
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)
+	}
+
+	return func(text string) [][]int {
+		return re.FindAllStringIndex(text, -1)
+	}, nil
+}
+
+// DefaultSearchHighlight is the delimiter the MCP server places around
+// matches in body search snippets, which renders them in bold as Markdown
+const DefaultSearchHighlight = "**"
+
+// SearchMatch represents a section that matched a search query. Title
+// matches are reported at the heading line; body matches at the first
+// matching line, with a snippet of it.
+type SearchMatch struct {
+	types.Section
+	MatchCount int    `json:"match_count"`
+	MatchLine  int    `json:"match_line"`
+	Snippet    string `json:"snippet,omitempty"`
+}
+
+// SearchSections searches for sections whose titles contain a query
+func (sm *StructureManager) SearchSections(filePath, query string, caseSensitive bool) ([]types.Section, error) {
+	matches, err := sm.SearchSectionsWithMode(filePath, query, SearchModeSubstring, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
 	var results []types.Section
-	sm.searchSectionsRecursive(structure.Structure, query, caseSensitive, &results)
+	for _, match := range matches {
+		results = append(results, match.Section)
+	}
+	return results, nil
+}
+
+// SearchSectionsWithMode searches for sections whose titles match a query in
+// the given search mode, counting the matches in each title
+func (sm *StructureManager) SearchSectionsWithMode(filePath, query, mode string, caseSensitive bool) ([]SearchMatch, error) {
+	match, err := newTextMatcher(query, mode, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchMatch
+	sm.searchSectionsRecursive(structure.Structure, match, &results)
 	return results, nil
 }
 
 // searchSectionsRecursive recursively searches through sections
-func (sm *StructureManager) searchSectionsRecursive(sections []types.Section, query string, caseSensitive bool, results *[]types.Section) {
+func (sm *StructureManager) searchSectionsRecursive(sections []types.Section, match textMatcher, results *[]SearchMatch) {
 	for _, section := range sections {
-		title := section.Title
-		searchQuery := query
+		if matches := match(section.Title); len(matches) > 0 {
+			*results = append(*results, SearchMatch{
+				Section:    section,
+				MatchCount: len(matches),
+				MatchLine:  section.StartLine,
+			})
+		}
+
+		// Search in children
+		sm.searchSectionsRecursive(section.Children, match, results)
+	}
+}
+
+// maxSnippetLength is the maximum number of runes kept around a body match
+const maxSnippetLength = 80
 
-		if !caseSensitive {
-			title = strings.ToLower(title)
-			searchQuery = strings.ToLower(searchQuery)
+// SearchSectionBodies searches the body text of each section (excluding its
+// children) and returns the first matching line of every matching section
+func (sm *StructureManager) SearchSectionBodies(filePath, query string, caseSensitive bool) ([]SearchMatch, error) {
+	return sm.SearchSectionBodiesWithMode(filePath, query, SearchModeSubstring, caseSensitive)
+}
+
+// SearchSectionBodiesWithMode is SearchSectionBodies with the query matched
+// in the given search mode. Regular expressions are matched line by line.
+func (sm *StructureManager) SearchSectionBodiesWithMode(filePath, query, mode string, caseSensitive bool) ([]SearchMatch, error) {
+	return sm.SearchSectionBodiesWithHighlight(filePath, query, mode, caseSensitive, "")
+}
+
+// SearchSectionBodiesWithHighlight is SearchSectionBodiesWithMode with every
+// match in the snippets surrounded by highlight, e.g. "**" for
+// "Run the **installer**". An empty highlight leaves snippets unmarked.
+func (sm *StructureManager) SearchSectionBodiesWithHighlight(filePath, query, mode string, caseSensitive bool, highlight string) ([]SearchMatch, error) {
+	match, err := newTextMatcher(query, mode, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	var results []SearchMatch
+	for _, section := range sm.parser.flattenSections(structure.Structure) {
+		endLine := sm.parser.findSectionEnd(&section)
+		if endLine > len(lines) {
+			endLine = len(lines)
 		}
 
-		if strings.Contains(title, searchQuery) {
-			*results = append(*results, section)
+		// Skip the heading line itself; titles are covered by SearchSections
+		var result *SearchMatch
+		for lineNum := section.StartLine + 1; lineNum <= endLine; lineNum++ {
+			line := lines[lineNum-1]
+			matches := match(line)
+			if len(matches) == 0 {
+				continue
+			}
+			if result == nil {
+				result = &SearchMatch{
+					Section:   section,
+					MatchLine: lineNum,
+					Snippet:   makeSnippet(line, matches, highlight),
+				}
+			}
+			result.MatchCount += len(matches)
+		}
+		if result != nil {
+			results = append(results, *result)
 		}
+	}
 
-		// Search in children
-		sm.searchSectionsRecursive(section.Children, query, caseSensitive, results)
+	return results, nil
+}
+
+// makeSnippet returns a trimmed excerpt of line centered on the first of
+// matches, with each match in the excerpt surrounded by highlight
+func makeSnippet(line string, matches [][]int, highlight string) string {
+	start, end := 0, len(line)
+	if utf8.RuneCountInString(line) > maxSnippetLength {
+		start, end = snippetWindow(line, matches[0])
 	}
+
+	var snippet strings.Builder
+	pos := start
+	for _, m := range matches {
+		matchStart, matchEnd := max(m[0], start), min(m[1], end)
+		if highlight == "" || matchStart >= matchEnd {
+			continue
+		}
+		snippet.WriteString(line[pos:matchStart])
+		snippet.WriteString(highlight)
+		snippet.WriteString(line[matchStart:matchEnd])
+		snippet.WriteString(highlight)
+		pos = matchEnd
+	}
+	snippet.WriteString(line[pos:end])
+
+	excerpt := strings.TrimSpace(snippet.String())
+	if start > 0 {
+		excerpt = "..." + excerpt
+	}
+	if end < len(line) {
+		excerpt += "..."
+	}
+	return excerpt
 }
 
-// GetSectionsByLevel returns all sections at a specific level
-func (sm *StructureManager) GetSectionsByLevel(filePath string, level int) ([]types.Section, error) {
+// snippetWindow returns the byte range of the maxSnippetLength runes of line
+// centered on match
+func snippetWindow(line string, match []int) (int, int) {
+	total := utf8.RuneCountInString(line)
+	matchRunes := utf8.RuneCountInString(line[match[0]:match[1]])
+
+	start := utf8.RuneCountInString(line[:match[0]]) - (maxSnippetLength-matchRunes)/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxSnippetLength
+	if end > total {
+		end = total
+		start = end - maxSnippetLength
+	}
+
+	return runeOffset(line, start), runeOffset(line, end)
+}
+
+// runeOffset returns the byte offset of the nth rune of s, or len(s) if s
+// has fewer runes
+func runeOffset(s string, n int) int {
+	for offset := range s {
+		if n == 0 {
+			return offset
+		}
+		n--
+	}
+	return len(s)
+}
+
+// GetSectionsByLevel returns all sections at a specific level in document
+// order, without their subsections
+func (sm *StructureManager) GetSectionsByLevel(filePath string, level int) ([]SectionSummary, error) {
 	structure, err := sm.GetDocumentStructure(filePath)
 	if err != nil {
 		return nil, err
 	}
 
+	return sm.BuildSectionsByLevel(structure, level), nil
+}
+
+// BuildSectionsByLevel lists the sections at a specific level of an already
+// parsed structure, like GetSectionsByLevel
+func (sm *StructureManager) BuildSectionsByLevel(structure *types.DocumentStructure, level int) []SectionSummary {
 	var results []types.Section
 	sm.collectSectionsByLevel(structure.Structure, level, &results)
-	return results, nil
+	return summarizeSections(results)
 }
 
 // collectSectionsByLevel recursively collects sections at a specific level
@@ -126,40 +685,139 @@ func (sm *StructureManager) collectSectionsByLevel(sections []types.Section, tar
 
 // GetDocumentStats returns statistics about the document
 func (sm *StructureManager) GetDocumentStats(filePath string) (*DocumentStats, error) {
+	return sm.GetDocumentStatsWithMode(filePath, sm.parser.options.WordCountMode)
+}
+
+// GetDocumentStatsWithMode returns statistics about the document, counting
+// words with the given word count mode
+func (sm *StructureManager) GetDocumentStatsWithMode(filePath, wordCountMode string) (*DocumentStats, error) {
+	return sm.GetDocumentStatsWithDepth(filePath, wordCountMode, 0)
+}
+
+// GetDocumentStatsWithDepth returns statistics about the document like
+// GetDocumentStatsWithMode, counting only sections up to maxDepth in the
+// section count and level histogram (0 for all levels)
+func (sm *StructureManager) GetDocumentStatsWithDepth(filePath, wordCountMode string, maxDepth int) (*DocumentStats, error) {
+	if !IsValidWordCountMode(wordCountMode) {
+		return nil, fmt.Errorf("unsupported word count mode: %s", wordCountMode)
+	}
+
+	if wordCountMode != sm.parser.options.WordCountMode {
+		// Word counts of cached structures use the parser's own mode, so
+		// parse again with a parser configured for the requested mode. As in
+		// GetDocumentStructureWithOptions, the context, content cache and
+		// disk cache, which keys entries by parser options, are kept.
+		options := sm.parser.options
+		options.WordCountMode = wordCountMode
+		manager := *sm
+		manager.parser = NewParserWithOptions(options)
+		manager.cache = nil
+		return manager.GetDocumentStatsWithDepth(filePath, wordCountMode, maxDepth)
+	}
+
 	structure, err := sm.GetDocumentStructure(filePath)
 	if err != nil {
 		return nil, err
 	}
 
+	content, err := sm.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.BuildDocumentStatsWithDepth(filePath, structure, content, maxDepth)
+}
+
+// BuildDocumentStats builds document statistics from an already parsed
+// structure and the content it was parsed from
+func (sm *StructureManager) BuildDocumentStats(filePath string, structure *types.DocumentStructure, content []byte) (*DocumentStats, error) {
+	return sm.BuildDocumentStatsWithDepth(filePath, structure, content, 0)
+}
+
+// BuildDocumentStatsWithDepth builds document statistics like
+// BuildDocumentStats, counting only sections up to maxDepth (0 for all)
+func (sm *StructureManager) BuildDocumentStatsWithDepth(filePath string, structure *types.DocumentStructure, content []byte, maxDepth int) (*DocumentStats, error) {
+	elements, err := sm.parser.ParseStats(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stats for %s: %w", filePath, err)
+	}
+
 	stats := &DocumentStats{
-		FilePath:     filePath,
-		TotalChars:   structure.TotalChars,
-		TotalLines:   structure.TotalLines,
-		SectionCount: sm.countSections(structure.Structure),
-		LevelCounts:  make(map[int]int),
+		FilePath:      filePath,
+		TotalChars:    structure.TotalChars,
+		TotalRunes:    structure.TotalRunes,
+		TotalLines:    structure.TotalLines,
+		WordCount:     structure.WordCount,
+		WordCountMode: sm.parser.options.WordCountMode,
+		SectionCount:  sm.countSections(structure.Structure, maxDepth),
+		LevelCounts:   make(map[int]int),
+		ElementStats:  *elements,
+		LastModified:  structure.LastModified,
 	}
 
 	// Count sections by level
-	sm.countSectionsByLevel(structure.Structure, stats.LevelCounts)
+	sm.countSectionsByLevel(structure.Structure, maxDepth, stats.LevelCounts)
 
 	return stats, nil
 }
 
-// countSections recursively counts all sections
-func (sm *StructureManager) countSections(sections []types.Section) int {
-	count := len(sections)
+// countSections recursively counts all sections up to maxDepth (0 for all)
+func (sm *StructureManager) countSections(sections []types.Section, maxDepth int) int {
+	count := 0
 	for _, section := range sections {
-		count += sm.countSections(section.Children)
+		// Children are deeper than their parent, so stop descending here
+		if maxDepth > 0 && section.Level > maxDepth {
+			continue
+		}
+		count += 1 + sm.countSections(section.Children, maxDepth)
 	}
 	return count
 }
 
-// countSectionsByLevel counts sections by their heading level
-func (sm *StructureManager) countSectionsByLevel(sections []types.Section, counts map[int]int) {
+// countSectionsByLevel counts sections up to maxDepth (0 for all) by their
+// heading level
+func (sm *StructureManager) countSectionsByLevel(sections []types.Section, maxDepth int, counts map[int]int) {
 	for _, section := range sections {
+		if maxDepth > 0 && section.Level > maxDepth {
+			continue
+		}
 		counts[section.Level]++
-		sm.countSectionsByLevel(section.Children, counts)
+		sm.countSectionsByLevel(section.Children, maxDepth, counts)
+	}
+}
+
+// OutlineDepth reports how deep the headings of a document go, without its
+// structure. MaxLevel is 0 for a document without headings.
+type OutlineDepth struct {
+	FilePath    string      `json:"file_path" yaml:"file_path"`
+	MaxLevel    int         `json:"max_level" yaml:"max_level"`
+	LevelCounts map[int]int `json:"level_counts" yaml:"level_counts"`
+}
+
+// GetOutlineDepth returns the deepest heading level of the document and its
+// number of sections per level
+func (sm *StructureManager) GetOutlineDepth(filePath string) (*OutlineDepth, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.BuildOutlineDepth(filePath, structure), nil
+}
+
+// BuildOutlineDepth builds the outline depth of an already parsed structure
+func (sm *StructureManager) BuildOutlineDepth(filePath string, structure *types.DocumentStructure) *OutlineDepth {
+	depth := &OutlineDepth{
+		FilePath:    filePath,
+		LevelCounts: make(map[int]int),
+	}
+
+	sm.countSectionsByLevel(structure.Structure, 0, depth.LevelCounts)
+	for level := range depth.LevelCounts {
+		depth.MaxLevel = max(depth.MaxLevel, level)
 	}
+
+	return depth
 }
 
 // ValidateStructure validates the integrity of a document structure
@@ -212,12 +870,16 @@ func (sm *StructureManager) validateHierarchy(sections []types.Section, parentLe
 
 // DocumentStats represents statistics about a document
 type DocumentStats struct {
-	FilePath     string      `json:"file_path"`
-	TotalChars   int         `json:"total_chars"`
-	TotalLines   int         `json:"total_lines"`
-	SectionCount int         `json:"section_count"`
-	LevelCounts  map[int]int `json:"level_counts"`
-	LastModified time.Time   `json:"last_modified"`
+	FilePath      string      `json:"file_path" yaml:"file_path"`
+	TotalChars    int         `json:"total_chars" yaml:"total_chars"`
+	TotalRunes    int         `json:"total_runes" yaml:"total_runes"`
+	TotalLines    int         `json:"total_lines" yaml:"total_lines"`
+	WordCount     int         `json:"word_count" yaml:"word_count"`
+	WordCountMode string      `json:"word_count_mode" yaml:"word_count_mode"`
+	SectionCount  int         `json:"section_count" yaml:"section_count"`
+	LevelCounts   map[int]int `json:"level_counts" yaml:"level_counts"`
+	ElementStats  `yaml:",inline"`
+	LastModified  time.Time `json:"last_modified" yaml:"last_modified"`
 }
 
 // GetTableOfContents generates a table of contents for the document
@@ -227,38 +889,219 @@ func (sm *StructureManager) GetTableOfContents(filePath string, maxDepth int) ([
 		return nil, err
 	}
 
+	return sm.BuildTableOfContents(structure, maxDepth), nil
+}
+
+// BuildTableOfContents builds a table of contents from an already parsed structure
+func (sm *StructureManager) BuildTableOfContents(structure *types.DocumentStructure, maxDepth int) []TocEntry {
 	var toc []TocEntry
-	sm.buildTocRecursive(structure.Structure, maxDepth, &toc)
-	return toc, nil
+	sm.buildTocRecursive(structure.Structure, maxDepth, map[string]int{}, &toc)
+	return toc
 }
 
-// buildTocRecursive recursively builds table of contents
-func (sm *StructureManager) buildTocRecursive(sections []types.Section, maxDepth int, toc *[]TocEntry) {
+// buildTocRecursive recursively builds table of contents. Anchors are
+// assigned to every heading in document order, including those deeper than
+// maxDepth, so duplicate titles are numbered as a renderer would number them.
+func (sm *StructureManager) buildTocRecursive(sections []types.Section, maxDepth int, usedAnchors map[string]int, toc *[]TocEntry) {
+	for _, section := range sections {
+		anchor := uniqueSlug(Slugify(section.Title), usedAnchors)
+
+		if maxDepth == 0 || section.Level <= maxDepth {
+			*toc = append(*toc, TocEntry{
+				ID:     section.ID,
+				Anchor: anchor,
+				Level:  section.Level,
+				Title:  section.Title,
+				Line:   section.StartLine,
+			})
+		}
+
+		// Add children
+		sm.buildTocRecursive(section.Children, maxDepth, usedAnchors, toc)
+	}
+}
+
+// TocEntry represents a table of contents entry. Anchor is the GitHub-style
+// slug of the heading, so a TOC can link to "#" + Anchor in HTML rendered
+// elsewhere.
+type TocEntry struct {
+	ID     string `json:"id"`
+	Anchor string `json:"anchor"`
+	Level  int    `json:"level"`
+	Title  string `json:"title"`
+	Line   int    `json:"line"`
+}
+
+// OutlineNode is a section reduced to what a clickable outline needs
+type OutlineNode struct {
+	ID       string        `json:"id" yaml:"id"`
+	Title    string        `json:"title" yaml:"title"`
+	Level    int           `json:"level" yaml:"level"`
+	Children []OutlineNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// GetOutline returns the section tree of the document with only the ID,
+// title and level of each section (see BuildOutline)
+func (sm *StructureManager) GetOutline(filePath string, maxDepth int) ([]OutlineNode, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildOutline(structure.Structure, maxDepth), nil
+}
+
+// BuildOutline maps a section hierarchy to outline nodes, leaving out
+// sections deeper than maxDepth (0 for all levels). Leaf nodes have no
+// children rather than empty ones, and the result is empty rather than nil
+// when there are no sections.
+func BuildOutline(sections []types.Section, maxDepth int) []OutlineNode {
+	outline := []OutlineNode{}
 	for _, section := range sections {
 		if maxDepth > 0 && section.Level > maxDepth {
 			continue
 		}
+		node := OutlineNode{ID: section.ID, Title: section.Title, Level: section.Level}
+		if children := BuildOutline(section.Children, maxDepth); len(children) > 0 {
+			node.Children = children
+		}
+		outline = append(outline, node)
+	}
+	return outline
+}
 
-		entry := TocEntry{
-			ID:    section.ID,
-			Level: section.Level,
-			Title: section.Title,
-			Line:  section.StartLine,
+// FilterByMinLevel leaves out the sections above minLevel, such as the H1
+// title of a document when minLevel is 2, putting their subsections in their
+// place, so those become roots of the result (0 or 1 keeps every section).
+// The filter applies to the tree built by buildHierarchy: headings are nested
+// by level first, and sections kept keep their IDs, line ranges and counts,
+// whereas text between a dropped heading and its first subsection is left
+// out with the dropped section. Unlike ignored levels (see
+// ParserOptions.IgnoreLevels), a dropped heading still ends the section
+// before it. The sections of the result are copies, and the input is left
+// unchanged.
+func FilterByMinLevel(sections []types.Section, minLevel int) []types.Section {
+	if minLevel <= 1 {
+		return sections
+	}
+
+	var filtered []types.Section
+	for _, section := range sections {
+		if section.Level >= minLevel {
+			// Subsections are deeper than their parent, so all are kept
+			filtered = append(filtered, section)
+			continue
 		}
+		filtered = append(filtered, FilterByMinLevel(section.Children, minLevel)...)
+	}
+	return filtered
+}
 
-		*toc = append(*toc, entry)
+// SectionAtLine locates a line of a document in its section hierarchy.
+// SectionID is the innermost section whose line range holds the line, and
+// Breadcrumb the titles of that section's ancestors from the root down. A
+// line before the first heading is InPreamble, with the preamble's ID when
+// the document has one and no section otherwise.
+type SectionAtLine struct {
+	FilePath   string   `json:"file_path" yaml:"file_path"`
+	Line       int      `json:"line" yaml:"line"`
+	InPreamble bool     `json:"in_preamble" yaml:"in_preamble"`
+	SectionID  string   `json:"section_id,omitempty" yaml:"section_id,omitempty"`
+	Title      string   `json:"title,omitempty" yaml:"title,omitempty"`
+	Level      int      `json:"level,omitempty" yaml:"level,omitempty"`
+	StartLine  int      `json:"start_line,omitempty" yaml:"start_line,omitempty"`
+	EndLine    int      `json:"end_line,omitempty" yaml:"end_line,omitempty"`
+	Breadcrumb []string `json:"breadcrumb" yaml:"breadcrumb"`
+}
 
-		// Add children
-		if maxDepth == 0 || section.Level < maxDepth {
-			sm.buildTocRecursive(section.Children, maxDepth, toc)
+// GetSectionAtLine returns the innermost section of the document holding
+// line (see BuildSectionAtLine)
+func (sm *StructureManager) GetSectionAtLine(filePath string, line int) (*SectionAtLine, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.BuildSectionAtLine(filePath, structure, line)
+}
+
+// BuildSectionAtLine locates line, 1-based, in an already parsed structure.
+// It fails for a line outside the document.
+func (sm *StructureManager) BuildSectionAtLine(filePath string, structure *types.DocumentStructure, line int) (*SectionAtLine, error) {
+	if line < 1 || line > structure.TotalLines {
+		return nil, fmt.Errorf("line %d is outside the document (%d lines)", line, structure.TotalLines)
+	}
+
+	result := &SectionAtLine{FilePath: filePath, Line: line, Breadcrumb: []string{}}
+
+	// Subsections follow their parent in document order and lie within its
+	// range, so the last section holding the line is the innermost one
+	var innermost *types.Section
+	sections := sm.parser.flattenSections(structure.Structure)
+	for i := range sections {
+		if sections[i].StartLine > line {
+			break
+		}
+		if line <= sections[i].EndLine {
+			innermost = &sections[i]
+		}
+	}
+
+	if innermost == nil {
+		result.InPreamble = true
+		if preamble := structure.Preamble; preamble != nil && line >= preamble.StartLine && line <= preamble.EndLine {
+			innermost = preamble
+		} else {
+			return result, nil
 		}
 	}
+
+	result.SectionID = innermost.ID
+	result.Title = innermost.Title
+	result.Level = innermost.Level
+	result.StartLine = innermost.StartLine
+	result.EndLine = innermost.EndLine
+	ancestors, _ := sm.parser.findAncestors(structure.Structure, innermost.ID)
+	for _, ancestor := range ancestors {
+		result.Breadcrumb = append(result.Breadcrumb, ancestor.Title)
+	}
+
+	return result, nil
 }
 
-// TocEntry represents a table of contents entry
-type TocEntry struct {
-	ID    string `json:"id"`
-	Level int    `json:"level"`
-	Title string `json:"title"`
-	Line  int    `json:"line"`
+// GetSkeleton returns the headings of the document as Markdown, without body
+// text (see BuildSkeleton)
+func (sm *StructureManager) GetSkeleton(filePath string, maxDepth int) (string, error) {
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return BuildSkeleton(structure.Structure, maxDepth), nil
+}
+
+// BuildSkeleton renders a section hierarchy as ATX headings at each
+// section's own level, one per line in document order, leaving out headings
+// deeper than maxDepth (0 for all levels). The result is valid Markdown that
+// can seed the outline of a new document.
+func BuildSkeleton(sections []types.Section, maxDepth int) string {
+	var skeleton strings.Builder
+
+	var walk func(sections []types.Section)
+	walk = func(sections []types.Section) {
+		for _, section := range sections {
+			if maxDepth > 0 && section.Level > maxDepth {
+				continue
+			}
+			skeleton.WriteString(strings.Repeat("#", section.Level))
+			if section.Title != "" {
+				skeleton.WriteString(" " + section.Title)
+			}
+			skeleton.WriteByte('\n')
+			walk(section.Children)
+		}
+	}
+	walk(sections)
+
+	return skeleton.String()
 }
@@ -0,0 +1,718 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLargeDocument writes a generated document with numSections H2 sections,
+// each followed by a few H3 subsections, and returns its path
+func writeLargeDocument(tb testing.TB, numSections int) string {
+	tb.Helper()
+
+	var builder strings.Builder
+	builder.WriteString("# Large Document\n\n")
+	for i := 0; i < numSections; i++ {
+		fmt.Fprintf(&builder, "## Section %d\n\n", i)
+		builder.WriteString(strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n", 20))
+		for j := 0; j < 3; j++ {
+			fmt.Fprintf(&builder, "\n### Subsection %d.%d\n\n", i, j)
+			builder.WriteString(strings.Repeat("Sed do eiusmod tempor incididunt ut labore.\n", 10))
+		}
+		builder.WriteString("\n")
+	}
+
+	filePath := filepath.Join(tb.TempDir(), "large.md")
+	if err := os.WriteFile(filePath, []byte(builder.String()), 0644); err != nil {
+		tb.Fatalf("Failed to write large document: %v", err)
+	}
+
+	return filePath
+}
+
+func TestStructureManagerGetSectionContent(t *testing.T) {
+	filePath := writeLargeDocument(t, 10)
+	sm := NewStructureManager(NewCache(10, time.Minute))
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	section := structure.Structure[0].Children[3]
+	sectionContent, err := sm.GetSectionContent(filePath, section.ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+
+	if !strings.HasPrefix(sectionContent.Content, "## Section 3\n") {
+		t.Errorf("Expected content to start with the section heading, got %q", sectionContent.Content[:20])
+	}
+
+	if strings.Contains(sectionContent.Content, "### Subsection 3.0") {
+		t.Error("Expected child sections to be excluded")
+	}
+
+	withChildren, err := sm.GetSectionContent(filePath, section.ID, true)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+
+	if !strings.Contains(withChildren.Content, "### Subsection 3.2") || strings.Contains(withChildren.Content, "## Section 4") {
+		t.Error("Expected content to include all children and stop before the next sibling")
+	}
+}
+
+func TestStructureManagerByteOrderMark(t *testing.T) {
+	filePath := filepath.Join("..", "..", "tests", "fixtures", "bom.md")
+	sm := NewStructureManager(NewCache(10, time.Minute))
+	sm.SetContentCache(NewContentCache(0))
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	// Section lines are sliced from the content without the mark
+	sectionContent, err := sm.GetSectionContent(filePath, structure.Structure[0].ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+	if !strings.HasPrefix(sectionContent.Content, "# BOM Document\n") {
+		t.Errorf("Expected the section content to start with its heading, got %q", sectionContent.Content)
+	}
+
+	utf16Path := filepath.Join(t.TempDir(), "utf16.md")
+	if err := os.WriteFile(utf16Path, []byte("\xff\xfe#\x00 \x00T\x00"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := sm.GetDocumentStructure(utf16Path); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("Expected ErrUnsupportedEncoding for a UTF-16 file, got %v", err)
+	}
+}
+
+// BenchmarkSectionContentReparse measures extracting a section by reparsing the
+// whole document on every call
+func BenchmarkSectionContentReparse(b *testing.B) {
+	filePath := writeLargeDocument(b, 2000)
+	parser := NewParser()
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		b.Fatalf("Failed to read document: %v", err)
+	}
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		b.Fatalf("ParseStructure failed: %v", err)
+	}
+	sectionID := structure.Structure[0].Children[1000].ID
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			b.Fatalf("Failed to read document: %v", err)
+		}
+		if _, err := parser.GetSectionContent(content, sectionID, false); err != nil {
+			b.Fatalf("GetSectionContent failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkSectionContentCached measures extracting a section through the
+// StructureManager, which reuses the cached structure
+func BenchmarkSectionContentCached(b *testing.B) {
+	filePath := writeLargeDocument(b, 2000)
+	sm := NewStructureManager(NewCache(10, time.Minute))
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		b.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	sectionID := structure.Structure[0].Children[1000].ID
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sm.GetSectionContent(filePath, sectionID, false); err != nil {
+			b.Fatalf("GetSectionContent failed: %v", err)
+		}
+	}
+}
+
+func TestSearchSectionBodies(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "search.md")
+	content := "# Guide\n\nIntro text.\n\n## Install\n\nRun the Installer binary.\n\n### Linux\n\nUse the installer package.\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	matches, err := sm.SearchSectionBodies(filePath, "installer", false)
+	if err != nil {
+		t.Fatalf("SearchSectionBodies failed: %v", err)
+	}
+
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+
+	if matches[0].Title != "Install" || matches[0].MatchLine != 7 || matches[0].Snippet != "Run the Installer binary." {
+		t.Errorf("Unexpected first match: %+v", matches[0])
+	}
+
+	if matches[1].Title != "Linux" || matches[1].MatchLine != 11 {
+		t.Errorf("Unexpected second match: %+v", matches[1])
+	}
+
+	matches, err = sm.SearchSectionBodies(filePath, "Installer", true)
+	if err != nil {
+		t.Fatalf("SearchSectionBodies failed: %v", err)
+	}
+
+	if len(matches) != 1 {
+		t.Errorf("Expected 1 case-sensitive match, got %d", len(matches))
+	}
+
+	// Headings are not part of the body
+	matches, err = sm.SearchSectionBodies(filePath, "Linux", false)
+	if err != nil {
+		t.Fatalf("SearchSectionBodies failed: %v", err)
+	}
+
+	if len(matches) != 0 {
+		t.Errorf("Expected no body matches for a heading-only term, got %d", len(matches))
+	}
+}
+
+func TestSearchSectionsRegexMode(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "search.md")
+	content := "# Guide\n\n## Chapter 1\n\nRun the installer.\n\n## Chapter 2\n\nRead the chapter notes.\n\n## Appendix: Chapter list\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	sections, err := sm.SearchSectionsWithMode(filePath, `^Chapter \d+$`, SearchModeRegex, false)
+	if err != nil {
+		t.Fatalf("SearchSectionsWithMode failed: %v", err)
+	}
+	if len(sections) != 2 || sections[0].Title != "Chapter 1" || sections[1].Title != "Chapter 2" {
+		t.Errorf("Expected the anchored pattern to match the two chapters only, got %+v", sections)
+	}
+
+	// Regex matching honours case sensitivity like substring matching
+	sections, err = sm.SearchSectionsWithMode(filePath, `^chapter`, SearchModeRegex, true)
+	if err != nil {
+		t.Fatalf("SearchSectionsWithMode failed: %v", err)
+	}
+	if len(sections) != 0 {
+		t.Errorf("Expected no case-sensitive matches, got %d", len(sections))
+	}
+
+	// Substring mode does not interpret the query
+	sections, err = sm.SearchSectionsWithMode(filePath, `^Chapter`, SearchModeSubstring, false)
+	if err != nil {
+		t.Fatalf("SearchSectionsWithMode failed: %v", err)
+	}
+	if len(sections) != 0 {
+		t.Errorf("Expected no literal matches for a pattern, got %d", len(sections))
+	}
+
+	matches, err := sm.SearchSectionBodiesWithMode(filePath, `^Read the \w+`, SearchModeRegex, false)
+	if err != nil {
+		t.Fatalf("SearchSectionBodiesWithMode failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Title != "Chapter 2" || matches[0].MatchLine != 9 {
+		t.Errorf("Unexpected body matches: %+v", matches)
+	}
+
+	if _, err := sm.SearchSectionsWithMode(filePath, "(", SearchModeRegex, false); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Errorf("Expected ErrInvalidSearchQuery for a broken title pattern, got %v", err)
+	}
+	if _, err := sm.SearchSectionBodiesWithMode(filePath, "(", SearchModeRegex, false); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Errorf("Expected ErrInvalidSearchQuery for a broken body pattern, got %v", err)
+	}
+
+	if _, err := sm.SearchSectionsWithMode(filePath, "Chapter", "glob", false); err == nil {
+		t.Error("Expected an error for an unsupported mode")
+	}
+}
+
+func TestMakeSnippet(t *testing.T) {
+	line := strings.Repeat("a", 100) + "needle" + strings.Repeat("b", 100)
+
+	snippet := makeSnippet(line, [][]int{{100, 106}}, "")
+	if !strings.Contains(snippet, "needle") {
+		t.Errorf("Expected snippet to contain the match, got %q", snippet)
+	}
+
+	if !strings.HasPrefix(snippet, "...") || !strings.HasSuffix(snippet, "...") {
+		t.Errorf("Expected truncated snippet to be marked with ellipses, got %q", snippet)
+	}
+
+	if short := makeSnippet("  short line  ", [][]int{{2, 7}}, ""); short != "short line" {
+		t.Errorf("Expected short line to be returned trimmed, got %q", short)
+	}
+
+	if marked := makeSnippet("  short line  ", [][]int{{2, 7}, {8, 12}}, "**"); marked != "**short** **line**" {
+		t.Errorf("Expected every match to be highlighted, got %q", marked)
+	}
+
+	// Matches past the edge of a truncated snippet are cut off with it
+	line = "ééé needle " + strings.Repeat("x", 90) + " needle"
+	if marked := makeSnippet(line, [][]int{{7, 13}, {len(line) - 6, len(line)}}, "[]"); marked != "ééé []needle[] "+strings.Repeat("x", 69)+"..." {
+		t.Errorf("Unexpected highlighted snippet %q", marked)
+	}
+}
+
+func TestSearchMatchCountsAndHighlight(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "search.md")
+	content := "# Install the installer\n\nRun the Installer binary.\n\nThe installer asks once; the installer then exits.\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	matches, err := sm.SearchSectionsWithMode(filePath, "install", SearchModeSubstring, false)
+	if err != nil {
+		t.Fatalf("SearchSectionsWithMode failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].MatchCount != 2 || matches[0].MatchLine != 1 || matches[0].Snippet != "" {
+		t.Errorf("Expected one title match counting both occurrences, got %+v", matches)
+	}
+
+	matches, err = sm.SearchSectionBodiesWithHighlight(filePath, "installer", SearchModeSubstring, false, "**")
+	if err != nil {
+		t.Fatalf("SearchSectionBodiesWithHighlight failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matches))
+	}
+	if matches[0].MatchCount != 3 || matches[0].MatchLine != 3 {
+		t.Errorf("Expected 3 matches from line 3 on, got %+v", matches[0])
+	}
+	if matches[0].Snippet != "Run the **Installer** binary." {
+		t.Errorf("Expected the match to keep its case and be highlighted, got %q", matches[0].Snippet)
+	}
+}
+
+func TestTableOfContentsAnchors(t *testing.T) {
+	content := "# Guide\n\n## Setup\n\n### Notes\n\n## Usage\n\n### Notes\n\n## Setup\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	var anchors []string
+	for _, entry := range sm.BuildTableOfContents(structure, 0) {
+		anchors = append(anchors, entry.Anchor)
+	}
+	expected := []string{"guide", "setup", "notes", "usage", "notes-1", "setup-1"}
+	if strings.Join(anchors, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected anchors %v, got %v", expected, anchors)
+	}
+
+	// Headings left out by max depth still take their slugs
+	toc := sm.BuildTableOfContents(structure, 2)
+	if len(toc) != 4 || toc[3].Title != "Setup" || toc[3].Anchor != "setup-1" {
+		t.Errorf("Expected the second Setup to keep anchor setup-1, got %+v", toc)
+	}
+}
+
+func TestBuildSkeleton(t *testing.T) {
+	content := "# Guide\n\nIntro text.\n\n## Install\n\nRun the **installer**.\n\n### Linux\n\n```sh\n# not a heading\n```\n\n## Usage\n\nRun it.\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	expected := "# Guide\n## Install\n### Linux\n## Usage\n"
+	if skeleton := BuildSkeleton(structure.Structure, 0); skeleton != expected {
+		t.Errorf("Expected skeleton %q, got %q", expected, skeleton)
+	}
+
+	expected = "# Guide\n## Install\n## Usage\n"
+	if skeleton := BuildSkeleton(structure.Structure, 2); skeleton != expected {
+		t.Errorf("Expected skeleton up to H2 %q, got %q", expected, skeleton)
+	}
+}
+
+func TestBuildOutline(t *testing.T) {
+	structure, err := NewParser().ParseStructure([]byte("# Guide\n\n## Install\n\n### Linux\n\n## Usage\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	guide := structure.Structure[0]
+	install := guide.Children[0]
+
+	expected := []OutlineNode{{
+		ID:    guide.ID,
+		Title: "Guide",
+		Level: 1,
+		Children: []OutlineNode{
+			{ID: install.ID, Title: "Install", Level: 2, Children: []OutlineNode{{ID: install.Children[0].ID, Title: "Linux", Level: 3}}},
+			{ID: guide.Children[1].ID, Title: "Usage", Level: 2},
+		},
+	}}
+	if outline := BuildOutline(structure.Structure, 0); !reflect.DeepEqual(outline, expected) {
+		t.Errorf("Expected outline %+v, got %+v", expected, outline)
+	}
+
+	// Install loses its only child, so it has none rather than an empty list
+	if outline := BuildOutline(structure.Structure, 2); outline[0].Children[0].Children != nil {
+		t.Errorf("Expected Install to have no children up to H2, got %+v", outline[0].Children[0])
+	}
+
+	if outline := BuildOutline(nil, 0); outline == nil || len(outline) != 0 {
+		t.Errorf("Expected an empty outline without sections, got %v", outline)
+	}
+}
+
+func TestGetSectionChildren(t *testing.T) {
+	filePath := writeLargeDocument(t, 3)
+	sm := NewStructureManager(nil)
+
+	structure, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	root := structure.Structure[0]
+
+	children, err := sm.GetSectionChildren(filePath, root.ID)
+	if err != nil {
+		t.Fatalf("GetSectionChildren failed: %v", err)
+	}
+	if len(children) != len(root.Children) {
+		t.Fatalf("Expected %d children, got %d", len(root.Children), len(children))
+	}
+	for i, child := range children {
+		if child.ID != root.Children[i].ID || child.ChildCount != len(root.Children[i].Children) || len(child.Children) != 0 {
+			t.Errorf("Expected child %d to be %s with %d counted subsections and none listed, got %+v", i, root.Children[i].ID, len(root.Children[i].Children), child)
+		}
+	}
+
+	// A leaf section has no children rather than an error
+	leaf := root.Children[0].Children[0]
+	children, err = sm.GetSectionChildren(filePath, leaf.ID)
+	if err != nil {
+		t.Fatalf("GetSectionChildren failed: %v", err)
+	}
+	if children == nil || len(children) != 0 {
+		t.Errorf("Expected an empty list for a leaf section, got %v", children)
+	}
+
+	if _, err := sm.GetSectionChildren(filePath, "missing"); err == nil {
+		t.Error("Expected an error for a missing section")
+	}
+}
+
+func TestBuildSectionsByLevel(t *testing.T) {
+	content := "# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n\n### Options\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	sections := sm.BuildSectionsByLevel(structure, 3)
+	var titles []string
+	for _, section := range sections {
+		titles = append(titles, section.Title)
+	}
+	if strings.Join(titles, ", ") != "Linux, macOS, Options" {
+		t.Errorf("Expected the H3 sections in document order, got %v", titles)
+	}
+
+	sections = sm.BuildSectionsByLevel(structure, 2)
+	if len(sections) != 2 || sections[0].ChildCount != 2 || len(sections[0].Children) != 0 {
+		t.Errorf("Expected two H2 sections listed without their subsections, got %+v", sections)
+	}
+
+	if sections := sm.BuildSectionsByLevel(structure, 5); sections == nil || len(sections) != 0 {
+		t.Errorf("Expected an empty list for an unused level, got %v", sections)
+	}
+}
+
+func TestBuildOutlineDepth(t *testing.T) {
+	sm := NewStructureManager(nil)
+
+	structure, err := NewParser().ParseStructure([]byte("# Guide\n\n## Install\n\n#### Linux\n\n## Usage\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	depth := sm.BuildOutlineDepth("guide.md", structure)
+	if depth.MaxLevel != 4 || !reflect.DeepEqual(depth.LevelCounts, map[int]int{1: 1, 2: 2, 4: 1}) {
+		t.Errorf("Expected max level 4 and counts per level, got %+v", depth)
+	}
+
+	structure, err = NewParser().ParseStructure([]byte("Just a paragraph.\n"))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	depth = sm.BuildOutlineDepth("notes.md", structure)
+	if depth.MaxLevel != 0 || depth.LevelCounts == nil || len(depth.LevelCounts) != 0 {
+		t.Errorf("Expected max level 0 and no counts without headings, got %+v", depth)
+	}
+}
+
+func TestBuildSectionAtLine(t *testing.T) {
+	sm := NewStructureManager(nil)
+	content := "Intro text.\n\n# Guide\n\n## Install\n\n### Linux\n\napt install\n\n## Usage\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// Line 9 is in the body of Linux, the deepest of three nested sections
+	located, err := sm.BuildSectionAtLine("guide.md", structure, 9)
+	if err != nil {
+		t.Fatalf("BuildSectionAtLine failed: %v", err)
+	}
+	if located.Title != "Linux" || located.Level != 3 || located.InPreamble {
+		t.Errorf("Expected the Linux section, got %+v", located)
+	}
+	if !reflect.DeepEqual(located.Breadcrumb, []string{"Guide", "Install"}) {
+		t.Errorf("Expected breadcrumb [Guide Install], got %v", located.Breadcrumb)
+	}
+
+	// A heading line belongs to its own section
+	if located, err = sm.BuildSectionAtLine("guide.md", structure, 11); err != nil || located.Title != "Usage" {
+		t.Errorf("Expected the Usage section at its heading, got %+v, %v", located, err)
+	}
+
+	// Line 1 comes before the first heading
+	located, err = sm.BuildSectionAtLine("guide.md", structure, 1)
+	if err != nil {
+		t.Fatalf("BuildSectionAtLine failed: %v", err)
+	}
+	if !located.InPreamble || located.SectionID != PreambleSectionID || len(located.Breadcrumb) != 0 {
+		t.Errorf("Expected the preamble, got %+v", located)
+	}
+
+	if _, err := sm.BuildSectionAtLine("guide.md", structure, 12); err == nil {
+		t.Error("Expected an error for a line past the end of the document")
+	}
+}
+
+func TestFilterByMinLevel(t *testing.T) {
+	content := "# Guide\n\nIntro.\n\n### Note\n\n## Install\n\n### Linux\n\n## Usage\n\n# Appendix\n\n## FAQ\n"
+	structure, err := NewParser().ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	// The H1 sections are dropped and their subsections become roots, an
+	// H3 directly under an H1 included
+	roots := FilterByMinLevel(structure.Structure, 2)
+	var titles []string
+	for _, root := range roots {
+		titles = append(titles, fmt.Sprintf("%d %s", root.Level, root.Title))
+	}
+	expected := []string{"3 Note", "2 Install", "2 Usage", "2 FAQ"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Expected roots %v, got %v", expected, titles)
+	}
+
+	install := roots[1]
+	if len(install.Children) != 1 || install.Children[0].Title != "Linux" {
+		t.Errorf("Expected Install to keep its Linux subsection, got %+v", install.Children)
+	}
+	if original := structure.Structure[0].Children[1]; install.ID != original.ID || install.StartLine != original.StartLine || install.EndLine != original.EndLine {
+		t.Errorf("Expected Install unchanged by the filter, got %+v", install)
+	}
+
+	if len(structure.Structure) != 2 || structure.Structure[0].Title != "Guide" {
+		t.Errorf("Expected the input left unchanged, got %+v", structure.Structure)
+	}
+
+	for _, minLevel := range []int{0, 1} {
+		if filtered := FilterByMinLevel(structure.Structure, minLevel); len(filtered) != 2 {
+			t.Errorf("Expected min level %d to keep both H1 roots, got %d", minLevel, len(filtered))
+		}
+	}
+}
+
+func TestStructureManagerDiskCache(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# Original Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	diskCache, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+
+	getTitle := func(parser *Parser) string {
+		t.Helper()
+		sm := NewStructureManagerWithParser(nil, parser)
+		sm.SetDiskCache(diskCache)
+		structure, err := sm.GetDocumentStructure(filePath)
+		if err != nil {
+			t.Fatalf("GetDocumentStructure failed: %v", err)
+		}
+		return structure.Structure[0].Title
+	}
+
+	if title := getTitle(NewParser()); title != "Original Title" {
+		t.Fatalf("Expected Original Title, got %q", title)
+	}
+
+	entry, ok := diskCache.GetStructure(filePath, NewParser().Options())
+	if !ok {
+		t.Fatal("Expected structure to be stored in the disk cache")
+	}
+
+	// A structure that only exists in the cache proves the file is not reparsed
+	entry.Structure.Structure[0].Title = "Cached Title"
+	if err := diskCache.SetStructure(filePath, NewParser().Options(), entry); err != nil {
+		t.Fatalf("SetStructure failed: %v", err)
+	}
+	if title := getTitle(NewParser()); title != "Cached Title" {
+		t.Errorf("Expected structure from disk cache, got %q", title)
+	}
+
+	// Entries are keyed by parser options
+	slugParser := NewParserWithOptions(ParserOptions{IDStyle: IDStyleSlug})
+	if title := getTitle(slugParser); title != "Original Title" {
+		t.Errorf("Expected reparse for different parser options, got %q", title)
+	}
+
+	// Changing the file invalidates the entry
+	if err := os.WriteFile(filePath, []byte("# Updated Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if title := getTitle(NewParser()); title != "Updated Title" {
+		t.Errorf("Expected reparse after file change, got %q", title)
+	}
+}
+
+func TestStructureManagerWithoutCache(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(filePath, []byte("# Original Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	sm := NewStructureManager(nil)
+
+	first, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	second, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+
+	// A cache would hand out the same structure; without one each call parses
+	if first == second {
+		t.Error("Expected a freshly parsed structure on every call without a cache")
+	}
+
+	// Changing the file while keeping its modification time is still seen
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Failed to stat document: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("# Updated Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := os.Chtimes(filePath, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("Failed to restore modification time: %v", err)
+	}
+
+	updated, err := sm.GetDocumentStructure(filePath)
+	if err != nil {
+		t.Fatalf("GetDocumentStructure failed: %v", err)
+	}
+	if title := updated.Structure[0].Title; title != "Updated Title" {
+		t.Errorf("Expected Updated Title, got %q", title)
+	}
+}
+
+func TestStructureManagerWithContext(t *testing.T) {
+	filePath := writeLargeDocument(t, 10)
+	cache := NewCache(10, time.Minute)
+	sm := NewStructureManager(cache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := sm.WithContext(ctx).GetDocumentStructure(filePath); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := sm.WithContext(ctx).GetSectionContent(filePath, "section_1", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetSectionContent, got %v", err)
+	}
+	// Stats counted in another word count mode parse again, still under ctx
+	if _, err := sm.WithContext(ctx).GetDocumentStatsWithMode(filePath, WordCountCJK); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetDocumentStatsWithMode, got %v", err)
+	}
+	if _, exists := cache.GetStructure(filePath); exists {
+		t.Error("Expected an abandoned parse not to be cached")
+	}
+
+	// The original manager is unaffected
+	if _, err := sm.GetDocumentStructure(filePath); err != nil {
+		t.Errorf("GetDocumentStructure failed: %v", err)
+	}
+}
+
+func TestStructureManagerGetDocumentStructuresConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	var filePaths []string
+	for i := 0; i < 12; i++ {
+		filePath := filepath.Join(dir, fmt.Sprintf("doc%02d.md", i))
+		content := fmt.Sprintf("# Document %d\n\n%s", i, strings.Repeat("## Part\n\nText.\n\n", i+1))
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write document: %v", err)
+		}
+		filePaths = append(filePaths, filePath)
+	}
+
+	serial, err := NewStructureManager(nil).GetDocumentStructures(filePaths, 1)
+	if err != nil {
+		t.Fatalf("GetDocumentStructures failed: %v", err)
+	}
+	parallel, err := NewStructureManager(NewCache(100, time.Minute)).GetDocumentStructures(filePaths, 4)
+	if err != nil {
+		t.Fatalf("GetDocumentStructures failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Error("Expected the same structures in the same order with a concurrency of 4 as with 1")
+	}
+	for i, structure := range parallel {
+		if structure.FilePath != filePaths[i] {
+			t.Errorf("Expected structure %d to be of %s, got %s", i, filePaths[i], structure.FilePath)
+		}
+	}
+
+	// The first failing path is reported, as in a serial loop
+	missing := append(append([]string{}, filePaths[:5]...), filepath.Join(dir, "missing1.md"), filepath.Join(dir, "missing2.md"))
+	if _, err := NewStructureManager(nil).GetDocumentStructures(missing, 4); err == nil || !strings.Contains(err.Error(), "missing1.md") {
+		t.Errorf("Expected the error of missing1.md, got %v", err)
+	}
+
+	// Warming up the cache in parallel caches every file
+	cache := NewCache(100, time.Minute)
+	if err := cache.WarmUpCacheWithConcurrency(filePaths, NewParser(), 4); err != nil {
+		t.Fatalf("WarmUpCacheWithConcurrency failed: %v", err)
+	}
+	if cached := len(cache.GetCachedFiles()); cached != len(filePaths) {
+		t.Errorf("Expected %d cached files, got %d", len(filePaths), cached)
+	}
+}
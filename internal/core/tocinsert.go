@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// Markers delimiting a generated table of contents inside a document. A TOC
+// is inserted after TocStartMarker and closed with TocEndMarker, so that the
+// block can be found and replaced when the TOC is regenerated.
+const (
+	TocStartMarker = "<!-- toc -->"
+	TocEndMarker   = "<!-- tocstop -->"
+)
+
+// ErrNoTocMarker is returned by InsertToc for a document without a
+// TocStartMarker line outside fenced code blocks
+var ErrNoTocMarker = errors.New("no " + TocStartMarker + " marker found")
+
+// FormatTocMarkdown renders TOC entries as a nested Markdown list linking to
+// each heading's anchor, indenting two spaces per level relative to the
+// shallowest heading in the TOC
+func FormatTocMarkdown(toc []TocEntry) string {
+	minLevel := 0
+	for _, entry := range toc {
+		if minLevel == 0 || entry.Level < minLevel {
+			minLevel = entry.Level
+		}
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+
+	var builder strings.Builder
+	for _, entry := range toc {
+		builder.WriteString(strings.Repeat("  ", entry.Level-minLevel))
+		builder.WriteString("- [")
+		builder.WriteString(escaper.Replace(entry.Title))
+		builder.WriteString("](#")
+		builder.WriteString(entry.Anchor)
+		builder.WriteString(")\n")
+	}
+
+	return builder.String()
+}
+
+// InsertToc returns content with the TOC rendered by FormatTocMarkdown placed
+// after the first TocStartMarker line and followed by TocEndMarker. If the
+// marker already opens a block closed by TocEndMarker, the block is replaced,
+// so inserting the TOC of the result again leaves it unchanged. Marker lines
+// inside fenced code blocks are ignored.
+func InsertToc(content []byte, toc []TocEntry) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+
+	start, end := -1, -1
+	var fenceChar byte
+	var fenceLength int
+	for i, line := range lines {
+		if fenceLength > 0 {
+			if isClosingFence(line, fenceChar, fenceLength) {
+				fenceLength = 0
+			}
+			continue
+		}
+		if char, length := openingFence(line); length > 0 {
+			fenceChar, fenceLength = char, length
+			continue
+		}
+
+		marker := strings.TrimSpace(line)
+		if start < 0 && marker == TocStartMarker {
+			start = i
+		} else if start >= 0 && marker == TocEndMarker {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, ErrNoTocMarker
+	}
+
+	// Without a closing marker nothing after the start marker is replaced
+	rest := lines[start+1:]
+	if end >= 0 {
+		rest = lines[end+1:]
+	}
+
+	block := strings.Split(strings.TrimSuffix(FormatTocMarkdown(toc), "\n"), "\n")
+	if len(toc) == 0 {
+		block = nil
+	}
+
+	result := make([]string, 0, len(lines)+len(block)+1)
+	result = append(result, lines[:start+1]...)
+	result = append(result, block...)
+	result = append(result, TocEndMarker)
+	result = append(result, rest...)
+
+	return []byte(strings.Join(result, "\n")), nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// insertToc parses content and inserts its TOC up to maxDepth
+func insertToc(t *testing.T, content string, maxDepth int) string {
+	t.Helper()
+
+	parser := NewParser()
+	structure, err := parser.ParseStructure([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+
+	toc := NewStructureManagerWithParser(nil, parser).BuildTableOfContents(structure, maxDepth)
+	result, err := InsertToc([]byte(content), toc)
+	if err != nil {
+		t.Fatalf("InsertToc failed: %v", err)
+	}
+
+	return string(result)
+}
+
+func TestInsertToc(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxDepth int
+		expected string
+	}{
+		{
+			name:    "Marker only",
+			content: "# Title\n\n<!-- toc -->\n\n## Setup\n\n### Linux\n\n## Usage [beta]\n",
+			expected: "# Title\n\n<!-- toc -->\n" +
+				"- [Title](#title)\n" +
+				"  - [Setup](#setup)\n" +
+				"    - [Linux](#linux)\n" +
+				"  - [Usage \\[beta\\]](#usage-beta)\n" +
+				"<!-- tocstop -->\n\n## Setup\n\n### Linux\n\n## Usage [beta]\n",
+		},
+		{
+			name:     "Stale block replaced",
+			content:  "# Title\n<!-- toc -->\n- [Old](#old)\n<!-- tocstop -->\n## New\n",
+			expected: "# Title\n<!-- toc -->\n- [Title](#title)\n  - [New](#new)\n<!-- tocstop -->\n## New\n",
+		},
+		{
+			name:     "Max depth",
+			content:  "<!-- toc -->\n# Title\n## Setup\n### Linux\n",
+			maxDepth: 2,
+			expected: "<!-- toc -->\n- [Title](#title)\n  - [Setup](#setup)\n<!-- tocstop -->\n# Title\n## Setup\n### Linux\n",
+		},
+		{
+			name:     "Marker in code block ignored",
+			content:  "```\n<!-- toc -->\n```\n<!-- toc -->\n# Title\n",
+			expected: "```\n<!-- toc -->\n```\n<!-- toc -->\n- [Title](#title)\n<!-- tocstop -->\n# Title\n",
+		},
+		{
+			name:     "No headings",
+			content:  "<!-- toc -->\ntext\n",
+			expected: "<!-- toc -->\n<!-- tocstop -->\ntext\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := insertToc(t, tt.content, tt.maxDepth)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, result)
+			}
+
+			// A second run must find and replace the block it inserted
+			again := insertToc(t, result, tt.maxDepth)
+			if again != result {
+				t.Errorf("Expected second run to be idempotent, got:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestInsertTocNoMarker(t *testing.T) {
+	for _, content := range []string{"# Title\n", "```\n<!-- toc -->\n```\n# Title\n"} {
+		_, err := InsertToc([]byte(content), nil)
+		if !errors.Is(err, ErrNoTocMarker) {
+			t.Errorf("Expected ErrNoTocMarker for %q, got %v", content, err)
+		}
+	}
+}
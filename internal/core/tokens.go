@@ -0,0 +1,57 @@
+package core
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizers supported by EstimateTokens. Both are approximations of the
+// token counts of language model tokenizers, good enough to size a prompt
+// but not to fill a context window to the last token.
+const (
+	// TokenizerChars counts one token per four bytes of text
+	TokenizerChars = "chars"
+	// TokenizerWords counts each run of letters and digits as one token per
+	// six characters, rounded up, and each punctuation or symbol character
+	// and each CJK character as a token of its own, which follows code and
+	// punctuation-heavy text more closely
+	TokenizerWords = "words"
+)
+
+// IsValidTokenizer reports whether tokenizer is a supported tokenizer
+func IsValidTokenizer(tokenizer string) bool {
+	return tokenizer == TokenizerChars || tokenizer == TokenizerWords
+}
+
+// EstimateTokens returns the approximate number of tokens in text. An
+// unknown tokenizer falls back to TokenizerChars.
+func EstimateTokens(text, tokenizer string) int {
+	if tokenizer != TokenizerWords {
+		return (len(text) + 3) / 4
+	}
+
+	var tokens, wordRunes int
+	endWord := func() {
+		tokens += (wordRunes + 5) / 6
+		wordRunes = 0
+	}
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		switch {
+		case isCJK(r):
+			endWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			wordRunes++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+			tokens++
+		}
+	}
+	endWord()
+
+	return tokens
+}
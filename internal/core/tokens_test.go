@@ -0,0 +1,54 @@
+package core
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	prose := "The quick brown fox jumps over the lazy dog."
+	code := "if (x[i] != y) { return -1; }"
+
+	tests := []struct {
+		name      string
+		text      string
+		tokenizer string
+		want      int
+	}{
+		{name: "empty", text: "", tokenizer: TokenizerWords, want: 0},
+		{name: "english chars", text: prose, tokenizer: TokenizerChars, want: 11},
+		{name: "english words", text: prose, tokenizer: TokenizerWords, want: 10},
+		{name: "code chars", text: code, tokenizer: TokenizerChars, want: 8},
+		// Every bracket and operator character is a token of its own
+		{name: "code words", text: code, tokenizer: TokenizerWords, want: 16},
+		{name: "long word", text: "internationalization", tokenizer: TokenizerWords, want: 4},
+		{name: "cjk words", text: "日本語のテキスト", tokenizer: TokenizerWords, want: 8},
+		{name: "unknown tokenizer", text: prose, tokenizer: "bpe", want: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateTokens(tt.text, tt.tokenizer); got != tt.want {
+				t.Errorf("Expected %d tokens, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatSectionContentTokenEstimate(t *testing.T) {
+	parser := NewParser()
+	content := []byte("# Title\n\nSome text about the title.\n")
+
+	structure, err := parser.ParseStructure(content)
+	if err != nil {
+		t.Fatalf("ParseStructure failed: %v", err)
+	}
+	sectionContent, err := parser.GetSectionContent(content, structure.Structure[0].ID, false)
+	if err != nil {
+		t.Fatalf("GetSectionContent failed: %v", err)
+	}
+	if err := parser.FormatSectionContent(sectionContent, "markdown"); err != nil {
+		t.Fatalf("FormatSectionContent failed: %v", err)
+	}
+
+	if want := EstimateTokens(sectionContent.Content, TokenizerChars); sectionContent.TokenEstimate != want {
+		t.Errorf("Expected token estimate %d, got %d", want, sectionContent.TokenEstimate)
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Word count modes supported by the parser
+const (
+	// WordCountWhitespace counts whitespace-separated tokens
+	WordCountWhitespace = "whitespace"
+	// WordCountCJK additionally counts every CJK character as a word, for
+	// languages that are written without spaces between words
+	WordCountCJK = "cjk"
+)
+
+// IsValidWordCountMode reports whether mode is a supported word count mode
+func IsValidWordCountMode(mode string) bool {
+	return mode == WordCountWhitespace || mode == WordCountCJK
+}
+
+// countWordsInLines counts the words in lines [startLine, endLine] (1-based).
+// Heading lines and lines inside fenced or indented code blocks, as recorded
+// in nonProse, are excluded so that only prose contributes to the count.
+func (p *Parser) countWordsInLines(lines []string, startLine, endLine int, nonProse map[int]bool) int {
+	var count int
+	for lineNum := startLine; lineNum <= endLine && lineNum <= len(lines); lineNum++ {
+		if lineNum < 1 || nonProse[lineNum] {
+			continue
+		}
+		count += countWords(lines[lineNum-1], p.options.WordCountMode)
+	}
+	return count
+}
+
+// countWords counts the words in a single line of text
+func countWords(line, mode string) int {
+	if mode != WordCountCJK {
+		return len(strings.Fields(line))
+	}
+
+	var count int
+	for _, field := range strings.Fields(line) {
+		inWord := false
+		for _, r := range field {
+			if isCJK(r) {
+				// Each CJK character is counted as a word of its own
+				count++
+				inWord = false
+			} else if !inWord && !unicode.IsPunct(r) {
+				// Punctuation such as '。' does not start a word on its own
+				count++
+				inWord = true
+			}
+		}
+	}
+	return count
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
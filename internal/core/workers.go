@@ -0,0 +1,54 @@
+package core
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultConcurrency is the number of files parsed at once by the operations
+// on several files, such as WarmUpCache and GetDocumentStructures, when they
+// are given a concurrency of 0
+var DefaultConcurrency = runtime.GOMAXPROCS(0)
+
+// forEachIndex calls fn for each index from 0 to n-1 on up to concurrency
+// goroutines (DefaultConcurrency if concurrency is 0 or less). Indexes are
+// handed out in order and no more are handed out once fn has failed, so the
+// error returned, that of the lowest failing index, is the one a serial loop
+// would stop at.
+func forEachIndex(n, concurrency int, fn func(i int) error) error {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	concurrency = min(concurrency, n)
+
+	errs := make([]error, n)
+	var failed atomic.Bool
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if errs[i] = fn(i); errs[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n && !failed.Load(); i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
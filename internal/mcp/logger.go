@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogLevel is the severity of a log record
+type LogLevel int
+
+// Log levels in increasing order of severity
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// String returns the level name used by the --log-level flag
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// mcpName returns the level name defined by the MCP logging specification
+func (l LogLevel) mcpName() string {
+	if l == LogLevelWarn {
+		return "warning"
+	}
+	return l.String()
+}
+
+// ParseLogLevel parses a --log-level value (debug, info, warn, error)
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch level {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	default:
+		return 0, fmt.Errorf("unsupported log level: %s (use debug, info, warn or error)", level)
+	}
+}
+
+// parseMCPLogLevel maps the eight MCP syslog-style levels onto LogLevel
+func parseMCPLogLevel(level string) (LogLevel, error) {
+	switch level {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info", "notice":
+		return LogLevelInfo, nil
+	case "warning":
+		return LogLevelWarn, nil
+	case "error", "critical", "alert", "emergency":
+		return LogLevelError, nil
+	default:
+		return 0, fmt.Errorf("unsupported log level: %s", level)
+	}
+}
+
+// LogMessageParams are the parameters of a notifications/message log record
+type LogMessageParams struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   string `json:"data"`
+}
+
+// Logger writes leveled log records to an io.Writer, normally stderr since
+// stdout carries the JSON-RPC stream. Once the client sets a level with
+// logging/setLevel, records at or above that level are also forwarded to it.
+type Logger struct {
+	mu            sync.Mutex
+	out           io.Writer
+	level         LogLevel
+	clientEnabled bool
+	clientLevel   LogLevel
+	notify        func(params LogMessageParams)
+}
+
+// NewLogger creates a logger writing records at or above level to out
+func NewLogger(out io.Writer, level LogLevel) *Logger {
+	return &Logger{
+		out:   out,
+		level: level,
+	}
+}
+
+// SetClientLevel forwards records at or above level to the client via notify
+func (l *Logger) SetClientLevel(level LogLevel, notify func(params LogMessageParams)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.clientEnabled = true
+	l.clientLevel = level
+	l.notify = notify
+}
+
+// Debugf logs a debug record
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(LogLevelDebug, format, args...)
+}
+
+// Infof logs an informational record
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LogLevelInfo, format, args...)
+}
+
+// Warnf logs a warning record
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(LogLevelWarn, format, args...)
+}
+
+// Errorf logs an error record
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(LogLevelError, format, args...)
+}
+
+// log writes a record to the output and, if enabled, to the client
+func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
+	l.mu.Lock()
+	writeLocal := level >= l.level
+	notify := l.notify
+	if !l.clientEnabled || level < l.clientLevel {
+		notify = nil
+	}
+	l.mu.Unlock()
+
+	if !writeLocal && notify == nil {
+		return
+	}
+
+	message := fmt.Sprintf(format, args...)
+	if writeLocal {
+		l.mu.Lock()
+		fmt.Fprintf(l.out, "%s [%s] %s\n", time.Now().Format(time.RFC3339), level, message)
+		l.mu.Unlock()
+	}
+	if notify != nil {
+		notify(LogMessageParams{Level: level.mcpName(), Logger: "mdatlas", Data: message})
+	}
+}
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mosaan/mdatlas/internal/core"
+)
+
+// ErrInvalidPrompt is returned when a prompt does not exist or is missing
+// required arguments
+var ErrInvalidPrompt = errors.New("invalid prompt")
+
+// PromptHandler handles MCP prompt operations
+type PromptHandler struct {
+	structureManager *core.StructureManager
+	accessControl    *core.AccessControl
+}
+
+// NewPromptHandler creates a new prompt handler
+func NewPromptHandler(structureManager *core.StructureManager, accessControl *core.AccessControl) *PromptHandler {
+	return &PromptHandler{
+		structureManager: structureManager,
+		accessControl:    accessControl,
+	}
+}
+
+// GetAvailablePrompts returns the list of available prompts
+func (ph *PromptHandler) GetAvailablePrompts() []Prompt {
+	return []Prompt{
+		{
+			Name:        "summarize_section",
+			Description: "Summarize a section of a Markdown document",
+			Arguments: []PromptArgument{
+				{
+					Name:        "file_path",
+					Description: "Path to the Markdown file (relative to base directory)",
+					Required:    true,
+				},
+				{
+					Name:        "section_id",
+					Description: "ID of the section to summarize",
+					Required:    true,
+				},
+				{
+					Name:        "include_children",
+					Description: "Set to \"false\" to summarize only the section's own text, without its subsections",
+				},
+			},
+		},
+		{
+			Name:        "toc_overview",
+			Description: "Give an overview of a Markdown document from its table of contents",
+			Arguments: []PromptArgument{
+				{
+					Name:        "file_path",
+					Description: "Path to the Markdown file (relative to base directory)",
+					Required:    true,
+				},
+			},
+		},
+	}
+}
+
+// GetPrompt renders the named prompt with the given arguments
+func (ph *PromptHandler) GetPrompt(name string, args map[string]string) (PromptGetResult, error) {
+	switch name {
+	case "summarize_section":
+		return ph.getSummarizeSectionPrompt(args)
+	case "toc_overview":
+		return ph.getTocOverviewPrompt(args)
+	default:
+		return PromptGetResult{}, fmt.Errorf("%w: unknown prompt: %s", ErrInvalidPrompt, name)
+	}
+}
+
+// getSummarizeSectionPrompt renders the summarize_section prompt
+func (ph *PromptHandler) getSummarizeSectionPrompt(args map[string]string) (PromptGetResult, error) {
+	validPath, err := ph.validateFileArgument(args)
+	if err != nil {
+		return PromptGetResult{}, err
+	}
+
+	sectionID := args["section_id"]
+	if sectionID == "" {
+		return PromptGetResult{}, fmt.Errorf("%w: missing section_id argument", ErrInvalidPrompt)
+	}
+
+	includeChildren := args["include_children"] != "false"
+
+	section, err := ph.structureManager.GetSectionContent(validPath, sectionID, includeChildren)
+	if err != nil {
+		return PromptGetResult{}, fmt.Errorf("failed to get section content: %w", err)
+	}
+
+	text := fmt.Sprintf("Summarize the section %q from %s. Keep the key points, decisions and any caveats, and keep the summary shorter than the original.\n\n%s",
+		section.Title, args["file_path"], section.Content)
+
+	return PromptGetResult{
+		Description: fmt.Sprintf("Summary of section %q", section.Title),
+		Messages: []PromptMessage{
+			{Role: "user", Content: CreateTextContent(text)},
+		},
+	}, nil
+}
+
+// getTocOverviewPrompt renders the toc_overview prompt
+func (ph *PromptHandler) getTocOverviewPrompt(args map[string]string) (PromptGetResult, error) {
+	validPath, err := ph.validateFileArgument(args)
+	if err != nil {
+		return PromptGetResult{}, err
+	}
+
+	toc, err := ph.structureManager.GetTableOfContents(validPath, 0)
+	if err != nil {
+		return PromptGetResult{}, fmt.Errorf("failed to get table of contents: %w", err)
+	}
+
+	var outline strings.Builder
+	for _, entry := range toc {
+		fmt.Fprintf(&outline, "%s- %s\n", strings.Repeat("  ", entry.Level-1), entry.Title)
+	}
+
+	text := fmt.Sprintf("Here is the table of contents of %s. Describe what the document covers and how it is organized, in a few sentences, without inventing details that the headings do not suggest.\n\n%s",
+		args["file_path"], outline.String())
+
+	return PromptGetResult{
+		Description: fmt.Sprintf("Overview of %s", args["file_path"]),
+		Messages: []PromptMessage{
+			{Role: "user", Content: CreateTextContent(text)},
+		},
+	}, nil
+}
+
+// validateFileArgument validates the file_path argument against access control
+func (ph *PromptHandler) validateFileArgument(args map[string]string) (string, error) {
+	filePath := args["file_path"]
+	if filePath == "" {
+		return "", fmt.Errorf("%w: missing file_path argument", ErrInvalidPrompt)
+	}
+
+	validPath, err := ph.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return "", fmt.Errorf("access denied: %w", err)
+	}
+
+	return validPath, nil
+}
@@ -2,7 +2,10 @@ package mcp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+
+	"github.com/mosaan/mdatlas/internal/core"
 )
 
 // MCPRequest represents an MCP request message
@@ -43,7 +46,8 @@ type ToolCallParams struct {
 
 // Resource list parameters
 type ResourceListParams struct {
-	Cursor string `json:"cursor,omitempty"`
+	Cursor   string `json:"cursor,omitempty"`
+	PageSize int    `json:"pageSize,omitempty"`
 }
 
 // Resource read parameters
@@ -51,6 +55,48 @@ type ResourceReadParams struct {
 	URI string `json:"uri"`
 }
 
+// Resource subscribe/unsubscribe parameters
+type ResourceSubscribeParams struct {
+	URI string `json:"uri"`
+}
+
+// Resource updated notification parameters
+type ResourceUpdatedParams struct {
+	URI string `json:"uri"`
+}
+
+// Prompt definition
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// Prompt argument definition
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt get parameters
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// Prompt get result
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// Prompt message
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
 // Tool definition
 type Tool struct {
 	Name        string      `json:"name"`
@@ -69,8 +115,27 @@ type Resource struct {
 
 // Tool call result
 type ToolResult struct {
-	Content []Content `json:"content"`
-	IsError bool      `json:"isError,omitempty"`
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// ToolErrorContent is the structured content of a tool result refused by
+// access control, carrying the same code a protocol-level error would
+type ToolErrorContent struct {
+	Error MCPError `json:"error"`
+}
+
+// LineRange is the structured content of a tool result holding text sliced
+// from a file, giving the 1-based, inclusive range of lines returned and,
+// when the text was cut to a size limit, its length before the cut. Tools
+// returning section content also give its approximate token count.
+type LineRange struct {
+	StartLine         int  `json:"start_line"`
+	EndLine           int  `json:"end_line"`
+	Truncated         bool `json:"truncated,omitempty"`
+	OriginalCharCount int  `json:"original_char_count,omitempty"`
+	TokenEstimate     int  `json:"token_estimate,omitempty"`
 }
 
 // Content block
@@ -96,6 +161,38 @@ type ResourceReadResult struct {
 type ServerCapabilities struct {
 	Tools     *ToolsCapability     `json:"tools,omitempty"`
 	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
+	Logging   *LoggingCapability   `json:"logging,omitempty"`
+}
+
+// Logging capability
+type LoggingCapability struct{}
+
+// Logging set level parameters
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+// Ping parameters
+type PingParams struct {
+	// Verbose adds server diagnostics to the pong
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+// PingDiagnostics is the result of a verbose ping, describing what the
+// server can see so clients can show its status
+type PingDiagnostics struct {
+	Status          string                  `json:"status"`
+	Version         string                  `json:"version"`
+	BuildDate       string                  `json:"build_date"`
+	BaseDir         string                  `json:"base_dir"`
+	BaseDirReadable bool                    `json:"base_dir_readable"`
+	Error           string                  `json:"error,omitempty"` // Why listing the base directory failed
+	AllowedExts     []string                `json:"allowed_exts"`
+	MaxFileSize     int64                   `json:"max_file_size"`
+	FileCount       int                     `json:"file_count"`
+	Cache           *core.CacheStats        `json:"cache,omitempty"`
+	ContentCache    *core.ContentCacheStats `json:"content_cache,omitempty"`
 }
 
 // Tools capability
@@ -103,6 +200,11 @@ type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+// Prompts capability
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
 // Resources capability
 type ResourcesCapability struct {
 	Subscribe   bool `json:"subscribe,omitempty"`
@@ -141,6 +243,13 @@ type InitializeResult struct {
 	ProtocolVersion string             `json:"protocolVersion"`
 	Capabilities    ServerCapabilities `json:"capabilities"`
 	ServerInfo      ServerInfo         `json:"serverInfo"`
+	Meta            *ServerMeta        `json:"_meta,omitempty"`
+}
+
+// ServerMeta is build metadata about the server binary, sent in the _meta
+// field of the initialize result
+type ServerMeta struct {
+	BuildDate string `json:"buildDate"`
 }
 
 // Server info
@@ -158,6 +267,59 @@ const (
 	InternalError  = -32603
 )
 
+// Server error codes for paths refused by access control, taken from the
+// range JSON-RPC reserves for implementation-defined server errors
+const (
+	AccessDenied        = -32001 // Path outside the base directory
+	FileNotFound        = -32002
+	FileTooLarge        = -32003
+	ExtensionNotAllowed = -32004
+)
+
+// RequestTimeout is the server error code for a request that did not finish
+// within ServerOptions.RequestTimeout
+const RequestTimeout = -32005
+
+// Server error codes for lookups that failed after the file was read
+const (
+	SectionNotFound   = -32006
+	UnsupportedFormat = -32007
+)
+
+// accessErrorCode returns the server error code for an access control
+// failure, or false if err is not one
+func accessErrorCode(err error) (int, bool) {
+	switch {
+	case errors.Is(err, core.ErrOutsideBaseDir):
+		return AccessDenied, true
+	case errors.Is(err, core.ErrFileNotFound):
+		return FileNotFound, true
+	case errors.Is(err, core.ErrFileTooLarge):
+		return FileTooLarge, true
+	case errors.Is(err, core.ErrExtensionNotAllowed):
+		return ExtensionNotAllowed, true
+	default:
+		return 0, false
+	}
+}
+
+// errorCode returns the server error code for an error of the core package,
+// including access control failures, or false if err has none
+func errorCode(err error) (int, bool) {
+	if code, ok := accessErrorCode(err); ok {
+		return code, true
+	}
+
+	switch {
+	case errors.Is(err, core.ErrSectionNotFound):
+		return SectionNotFound, true
+	case errors.Is(err, core.ErrUnsupportedFormat):
+		return UnsupportedFormat, true
+	default:
+		return 0, false
+	}
+}
+
 // CreateErrorResponse creates an error response
 func CreateErrorResponse(id interface{}, code int, message string, data interface{}) MCPResponse {
 	return MCPResponse{
@@ -249,6 +411,46 @@ func ParseResourceReadParams(params json.RawMessage) (*ResourceReadParams, error
 	return &readParams, nil
 }
 
+// ParseResourceSubscribeParams parses resource subscribe and unsubscribe parameters
+func ParseResourceSubscribeParams(params json.RawMessage) (*ResourceSubscribeParams, error) {
+	var subscribeParams ResourceSubscribeParams
+	if err := json.Unmarshal(params, &subscribeParams); err != nil {
+		return nil, fmt.Errorf("failed to parse resource subscribe params: %w", err)
+	}
+
+	if subscribeParams.URI == "" {
+		return nil, fmt.Errorf("missing resource URI")
+	}
+
+	return &subscribeParams, nil
+}
+
+// ParsePingParams parses ping parameters, which are optional
+func ParsePingParams(params json.RawMessage) (*PingParams, error) {
+	var pingParams PingParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &pingParams); err != nil {
+			return nil, fmt.Errorf("failed to parse ping params: %w", err)
+		}
+	}
+
+	return &pingParams, nil
+}
+
+// ParsePromptGetParams parses prompt get parameters
+func ParsePromptGetParams(params json.RawMessage) (*PromptGetParams, error) {
+	var promptParams PromptGetParams
+	if err := json.Unmarshal(params, &promptParams); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt get params: %w", err)
+	}
+
+	if promptParams.Name == "" {
+		return nil, fmt.Errorf("missing prompt name")
+	}
+
+	return &promptParams, nil
+}
+
 // CreateTextContent creates a text content block
 func CreateTextContent(text string) Content {
 	return Content{
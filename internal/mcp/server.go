@@ -2,14 +2,18 @@ package mcp
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/mosaan/mdatlas/pkg/types"
 )
 
 // Server represents the MCP server
@@ -19,98 +23,411 @@ type Server struct {
 	structureManager *core.StructureManager
 	toolHandler      *ToolHandler
 	resourceHandler  *ResourceHandler
+	promptHandler    *PromptHandler
+	subscriptions    *SubscriptionManager
 	cache            *core.Cache
+	contentCache     *core.ContentCache
+	options          ServerOptions
+	logger           *Logger
+
+	// writeMu serializes writes to writer, since notifications are sent
+	// from the file watcher goroutine
+	writeMu sync.Mutex
+	writer  messageWriter
 }
 
-// NewServer creates a new MCP server instance
+// ServerOptions configures how the server talks to the client
+type ServerOptions struct {
+	// Framing is the message framing on STDIO (FramingStream by default)
+	Framing string
+	// MaxMessageSize limits the size of a single message in the ndjson and
+	// header framings (DefaultMaxMessageSize by default)
+	MaxMessageSize int
+	// LogLevel is the minimum level logged to stderr ("info" by default)
+	LogLevel string
+	// CacheDir, if set, persists parsed structures on disk between runs
+	CacheDir string
+	// NoCache disables the in-memory structure and content caches and the
+	// disk cache
+	NoCache bool
+	// CacheSize and CacheTTL bound the in-memory structure cache
+	// (core.DefaultCacheSize entries and core.DefaultCacheTTL by default)
+	CacheSize int
+	CacheTTL  time.Duration
+	// FastStructure scans large files line by line instead of parsing them
+	// into a Markdown AST (see core.ParserOptions.FastStructure)
+	FastStructure bool
+	// IgnoreLevels lists heading levels that do not start sections (see
+	// core.ParserOptions.IgnoreLevels); get_markdown_structure can override
+	// it per call
+	IgnoreLevels []int
+	// IDPrefix starts hash section IDs (core.DefaultIDPrefix by default)
+	IDPrefix string
+	// KeepLineEndings leaves CRLF and lone CR line endings as written
+	// instead of normalizing them to LF (see
+	// core.ParserOptions.KeepLineEndings)
+	KeepLineEndings bool
+	// Concurrency is how many files tools covering several files parse at
+	// once (core.DefaultConcurrency by default)
+	Concurrency int
+	// RequestTimeout, if positive, limits how long a single request may run
+	// before it is answered with a RequestTimeout error
+	RequestTimeout time.Duration
+	// Version and BuildDate describe the server binary to clients ("dev"
+	// and "unknown" by default), normally as set by ldflags at build time
+	Version   string
+	BuildDate string
+}
+
+// NewServer creates a new MCP server instance with default options
 func NewServer(baseDir string) (*Server, error) {
+	return NewServerWithOptions(baseDir, ServerOptions{})
+}
+
+// NewServerWithOptions creates a new MCP server instance with the given options
+func NewServerWithOptions(baseDir string, options ServerOptions) (*Server, error) {
+	if options.Framing == "" {
+		options.Framing = FramingStream
+	}
+	if !IsValidFraming(options.Framing) {
+		return nil, fmt.Errorf("unsupported framing: %s", options.Framing)
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if options.LogLevel == "" {
+		options.LogLevel = LogLevelInfo.String()
+	}
+	if options.Version == "" {
+		options.Version = "dev"
+	}
+	if options.BuildDate == "" {
+		options.BuildDate = "unknown"
+	}
+	if options.CacheSize <= 0 {
+		options.CacheSize = core.DefaultCacheSize
+	}
+	if options.CacheTTL <= 0 {
+		options.CacheTTL = core.DefaultCacheTTL
+	}
+	logLevel, err := ParseLogLevel(options.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create access control
 	accessControl, err := core.NewAccessControl(baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create access control: %w", err)
 	}
 
-	// Create cache
-	cache := core.NewCache(100, 30*time.Minute)
+	// Create caches; with NoCache the structure manager gets nil caches and
+	// reads and parses the document on every request
+	var cache *core.Cache
+	var contentCache *core.ContentCache
+	if !options.NoCache {
+		cache = core.NewCache(options.CacheSize, options.CacheTTL)
+		contentCache = core.NewContentCache(core.DefaultContentCacheBytes)
+	}
 
 	// Create structure manager
-	structureManager := core.NewStructureManager(cache)
+	parser := core.NewParserWithOptions(core.ParserOptions{
+		FastStructure:   options.FastStructure,
+		IgnoreLevels:    options.IgnoreLevels,
+		IDPrefix:        options.IDPrefix,
+		KeepLineEndings: options.KeepLineEndings,
+	})
+	structureManager := core.NewStructureManagerWithParser(cache, parser)
+	if contentCache != nil {
+		structureManager.SetContentCache(contentCache)
+	}
+	if options.CacheDir != "" && !options.NoCache {
+		diskCache, err := core.NewDiskCache(options.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		structureManager.SetDiskCache(diskCache)
+	}
 
 	// Create handlers
-	toolHandler := NewToolHandler(structureManager, accessControl)
-	resourceHandler := NewResourceHandler(accessControl)
+	toolHandler := NewToolHandler(structureManager, accessControl, cache, contentCache)
+	toolHandler.SetConcurrency(options.Concurrency)
+	resourceHandler := NewResourceHandler(structureManager, accessControl, contentCache)
+	promptHandler := NewPromptHandler(structureManager, accessControl)
 
-	return &Server{
+	server := &Server{
 		baseDir:          baseDir,
 		accessControl:    accessControl,
 		structureManager: structureManager,
 		toolHandler:      toolHandler,
 		resourceHandler:  resourceHandler,
+		promptHandler:    promptHandler,
 		cache:            cache,
-	}, nil
+		contentCache:     contentCache,
+		options:          options,
+		logger:           NewLogger(os.Stderr, logLevel),
+	}
+	server.subscriptions = NewSubscriptionManager(server.logger, server.notifyResourceUpdated)
+
+	return server, nil
+}
+
+// UpdateAccessConfig replaces the server's file access restrictions. It must
+// be called before Run.
+func (s *Server) UpdateAccessConfig(config types.AccessConfig) error {
+	return s.accessControl.UpdateConfig(config)
 }
 
-// Run starts the MCP server
+// AccessConfig returns a copy of the server's file access restrictions
+func (s *Server) AccessConfig() types.AccessConfig {
+	return s.accessControl.GetConfig()
+}
+
+// readResult is a message read from the client, or the error that ended
+// reading
+type readResult struct {
+	message json.RawMessage
+	err     error
+}
+
+// Run serves requests from STDIO until the client closes stdin or ctx is
+// cancelled, which is a clean shutdown and returns nil. A request being
+// handled when ctx is cancelled is finished and answered first, and no
+// message is written once Run has returned.
 func (s *Server) Run(ctx context.Context) error {
-	// Create JSON decoder and encoder for STDIO
-	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	// Create message reader and writer for STDIO
+	reader, err := newMessageReader(s.options.Framing, os.Stdin, s.options.MaxMessageSize)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	s.writer = newMessageWriter(s.options.Framing, os.Stdout)
+	s.writeMu.Unlock()
+	defer s.stop()
 
 	// Send server info to stderr for debugging
-	fmt.Fprintf(os.Stderr, "MCP Server started with base directory: %s\n", s.baseDir)
+	s.logger.Infof("MCP Server started with base directory: %s (%s framing)", s.baseDir, s.options.Framing)
+
+	// Read in the background, since a blocked read of stdin cannot be
+	// interrupted by ctx
+	messages := make(chan readResult)
+	go func() {
+		for {
+			message, err := reader.ReadMessage()
+			select {
+			case messages <- readResult{message: message, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil && !errors.Is(err, errMalformedMessage) {
+				return
+			}
+		}
+	}()
+
+	// Requests run to completion during shutdown, so they do not inherit
+	// ctx's cancellation
+	requestCtx := context.WithoutCancel(ctx)
 
 	for {
+		var result readResult
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			// Read request
-			var request MCPRequest
-			if err := decoder.Decode(&request); err != nil {
-				if err == io.EOF {
-					return nil // Clean shutdown
-				}
-
-				// Send error response if we can parse the ID
-				response := CreateErrorResponse(nil, ParseError, "Failed to parse request", err.Error())
-				if encodeErr := encoder.Encode(response); encodeErr != nil {
-					fmt.Fprintf(os.Stderr, "Failed to encode error response: %v\n", encodeErr)
-				}
+			s.logger.Infof("MCP Server shutting down")
+			return nil
+		case result = <-messages:
+		}
+
+		if result.err != nil {
+			if result.err == io.EOF {
+				return nil // Clean shutdown
+			}
+
+			s.sendParseError(result.err)
+			if errors.Is(result.err, errMalformedMessage) {
 				continue
 			}
+			return fmt.Errorf("failed to read request: %w", result.err)
+		}
+
+		// Handle request or batch
+		response, ok := s.handleMessage(requestCtx, result.message)
+		if !ok {
+			continue
+		}
 
-			// Handle request
-			response := s.handleRequest(request)
+		// Send response
+		if err := s.send(response); err != nil {
+			s.logger.Errorf("Failed to encode response: %v", err)
+		}
+	}
+}
 
-			// Send response
-			if err := encoder.Encode(response); err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to encode response: %v\n", err)
-			}
+// stop closes the file watcher and the structure cache and detaches the
+// writer, so notifications still in flight are dropped instead of written
+// after Run returns
+func (s *Server) stop() {
+	if err := s.subscriptions.Close(); err != nil {
+		s.logger.Warnf("Failed to close file watcher: %v", err)
+	}
+	if s.cache != nil {
+		s.cache.Close()
+	}
+
+	s.writeMu.Lock()
+	s.writer = nil
+	s.writeMu.Unlock()
+}
+
+// handleMessage handles a single request or a JSON-RPC batch and returns the
+// reply to send. It returns false when there is nothing to send, which is the
+// case for notifications and batches made up only of notifications.
+func (s *Server) handleMessage(ctx context.Context, message json.RawMessage) (interface{}, bool) {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		var request MCPRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			return CreateErrorResponse(nil, ParseError, "Failed to parse request", err.Error()), true
 		}
+
+		// Notifications are handled for their side effects but never answered
+		response := s.handleRequest(ctx, request)
+		if IsNotification(request) {
+			return nil, false
+		}
+		return response, true
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(message, &batch); err != nil {
+		return CreateErrorResponse(nil, ParseError, "Failed to parse batch", err.Error()), true
+	}
+	if len(batch) == 0 {
+		return CreateErrorResponse(nil, InvalidRequest, "Empty batch", nil), true
+	}
+
+	responses := make([]MCPResponse, 0, len(batch))
+	for _, item := range batch {
+		var request MCPRequest
+		if err := json.Unmarshal(item, &request); err != nil {
+			responses = append(responses, CreateErrorResponse(nil, InvalidRequest, "Invalid request in batch", err.Error()))
+			continue
+		}
+
+		response := s.handleRequest(ctx, request)
+		if IsNotification(request) {
+			continue
+		}
+		responses = append(responses, response)
+	}
+
+	if len(responses) == 0 {
+		return nil, false
+	}
+	return responses, true
+}
+
+// sendParseError reports a request that could not be read or parsed
+func (s *Server) sendParseError(err error) {
+	s.logger.Warnf("Failed to parse request: %v", err)
+	response := CreateErrorResponse(nil, ParseError, "Failed to parse request", err.Error())
+	if encodeErr := s.send(response); encodeErr != nil {
+		s.logger.Errorf("Failed to encode error response: %v", encodeErr)
 	}
 }
 
-// handleRequest handles an MCP request
-func (s *Server) handleRequest(req MCPRequest) MCPResponse {
+// send writes a message to the client
+func (s *Server) send(message interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.writer == nil {
+		return fmt.Errorf("server is not running")
+	}
+
+	return s.writer.WriteMessage(message)
+}
+
+// notifyResourceUpdated sends a notifications/resources/updated message for uri
+func (s *Server) notifyResourceUpdated(uri string) {
+	notification := CreateNotification("notifications/resources/updated", ResourceUpdatedParams{URI: uri})
+	if err := s.send(notification); err != nil {
+		s.logger.Errorf("Failed to encode notification: %v", err)
+	}
+}
+
+// sendLogMessage forwards a log record to the client. Failures are not
+// logged, since that would recurse.
+func (s *Server) sendLogMessage(params LogMessageParams) {
+	s.send(CreateNotification("notifications/message", params))
+}
+
+// handleRequest handles an MCP request, within RequestTimeout if one is set
+func (s *Server) handleRequest(ctx context.Context, req MCPRequest) MCPResponse {
+	s.logger.Debugf("Handling %s (id %v)", req.Method, req.ID)
+
 	// Validate request
 	if err := ValidateRequest(req); err != nil {
 		return CreateErrorResponse(GetRequestID(req), InvalidRequest, err.Error(), nil)
 	}
 
-	// Handle different methods
+	if s.options.RequestTimeout <= 0 {
+		return s.dispatchRequest(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.options.RequestTimeout)
+	defer cancel()
+
+	// Answer at the deadline even if the handler is stuck in work that
+	// cannot be interrupted; it stops at its next context check and its
+	// result is discarded
+	done := make(chan MCPResponse, 1)
+	go func() {
+		done <- s.dispatchRequest(ctx, req)
+	}()
+
+	select {
+	case response := <-done:
+		if ctx.Err() == nil {
+			return response
+		}
+	case <-ctx.Done():
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.logger.Warnf("%s (id %v) timed out after %s", req.Method, req.ID, s.options.RequestTimeout)
+		return CreateErrorResponse(GetRequestID(req), RequestTimeout, fmt.Sprintf("Request timed out after %s", s.options.RequestTimeout), nil)
+	}
+	return CreateErrorResponse(GetRequestID(req), InternalError, "Request cancelled", ctx.Err().Error())
+}
+
+// dispatchRequest calls the handler of a validated request's method
+func (s *Server) dispatchRequest(ctx context.Context, req MCPRequest) MCPResponse {
 	switch req.Method {
 	case "initialize":
 		return s.handleInitialize(req)
 	case "tools/list":
 		return s.handleToolsList(req)
 	case "tools/call":
-		return s.handleToolsCall(req)
+		return s.handleToolsCall(ctx, req)
 	case "resources/list":
 		return s.handleResourcesList(req)
 	case "resources/read":
-		return s.handleResourcesRead(req)
+		return s.handleResourcesRead(ctx, req)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(req)
+	case "resources/unsubscribe":
+		return s.handleResourcesUnsubscribe(req)
+	case "prompts/list":
+		return s.handlePromptsList(req)
+	case "prompts/get":
+		return s.handlePromptsGet(req)
+	case "logging/setLevel":
+		return s.handleLoggingSetLevel(req)
 	case "ping":
 		return s.handlePing(req)
+	case "notifications/initialized":
+		return CreateSuccessResponse(GetRequestID(req), nil)
 	default:
 		return CreateErrorResponse(GetRequestID(req), MethodNotFound, fmt.Sprintf("Method not found: %s", req.Method), nil)
 	}
@@ -132,13 +449,20 @@ func (s *Server) handleInitialize(req MCPRequest) MCPResponse {
 				ListChanged: false,
 			},
 			Resources: &ResourcesCapability{
-				Subscribe:   false,
+				Subscribe:   true,
+				ListChanged: false,
+			},
+			Prompts: &PromptsCapability{
 				ListChanged: false,
 			},
+			Logging: &LoggingCapability{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "mdatlas",
-			Version: "1.0.0",
+			Version: s.options.Version,
+		},
+		Meta: &ServerMeta{
+			BuildDate: s.options.BuildDate,
 		},
 	}
 
@@ -157,50 +481,193 @@ func (s *Server) handleToolsList(req MCPRequest) MCPResponse {
 }
 
 // handleToolsCall handles the tools/call request
-func (s *Server) handleToolsCall(req MCPRequest) MCPResponse {
+func (s *Server) handleToolsCall(ctx context.Context, req MCPRequest) MCPResponse {
 	toolParams, err := ParseToolCallParams(req.Params)
 	if err != nil {
 		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
 	}
 
 	// Execute tool
-	result := s.toolHandler.HandleToolCall(toolParams.Name, toolParams.Arguments)
+	result := s.toolHandler.HandleToolCall(ctx, toolParams.Name, toolParams.Arguments)
 
 	return CreateSuccessResponse(GetRequestID(req), result)
 }
 
 // handleResourcesList handles the resources/list request
 func (s *Server) handleResourcesList(req MCPRequest) MCPResponse {
-	resources, err := s.resourceHandler.GetAvailableResources()
+	listParams, err := ParseResourceListParams(req.Params)
 	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	resources, nextCursor, err := s.resourceHandler.GetResourcesPage(listParams.Cursor, listParams.PageSize)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+		}
 		return CreateErrorResponse(GetRequestID(req), InternalError, "Failed to list resources", err.Error())
 	}
 
 	result := ResourceListResult{
-		Resources: resources,
+		Resources:  resources,
+		NextCursor: nextCursor,
 	}
 
 	return CreateSuccessResponse(GetRequestID(req), result)
 }
 
 // handleResourcesRead handles the resources/read request
-func (s *Server) handleResourcesRead(req MCPRequest) MCPResponse {
+func (s *Server) handleResourcesRead(ctx context.Context, req MCPRequest) MCPResponse {
 	readParams, err := ParseResourceReadParams(req.Params)
 	if err != nil {
 		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
 	}
 
-	result, err := s.resourceHandler.ReadResource(readParams.URI)
+	result, err := s.resourceHandler.ReadResource(ctx, readParams.URI)
 	if err != nil {
+		if code, ok := errorCode(err); ok {
+			return CreateErrorResponse(GetRequestID(req), code, "Failed to read resource", err.Error())
+		}
 		return CreateErrorResponse(GetRequestID(req), InternalError, "Failed to read resource", err.Error())
 	}
 
 	return CreateSuccessResponse(GetRequestID(req), result)
 }
 
+// handleResourcesSubscribe handles the resources/subscribe request
+func (s *Server) handleResourcesSubscribe(req MCPRequest) MCPResponse {
+	subscribeParams, err := ParseResourceSubscribeParams(req.Params)
+	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	filePath, _, err := s.resourceHandler.ResolveResourceURI(subscribeParams.URI)
+	if err != nil {
+		if code, ok := accessErrorCode(err); ok {
+			return CreateErrorResponse(GetRequestID(req), code, err.Error(), nil)
+		}
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	if err := s.subscriptions.Subscribe(subscribeParams.URI, filePath); err != nil {
+		return CreateErrorResponse(GetRequestID(req), InternalError, "Failed to subscribe to resource", err.Error())
+	}
+
+	return CreateSuccessResponse(GetRequestID(req), map[string]interface{}{})
+}
+
+// handleResourcesUnsubscribe handles the resources/unsubscribe request
+func (s *Server) handleResourcesUnsubscribe(req MCPRequest) MCPResponse {
+	subscribeParams, err := ParseResourceSubscribeParams(req.Params)
+	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	filePath, _, err := s.resourceHandler.ResolveResourceURI(subscribeParams.URI)
+	if err != nil {
+		if code, ok := accessErrorCode(err); ok {
+			return CreateErrorResponse(GetRequestID(req), code, err.Error(), nil)
+		}
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	s.subscriptions.Unsubscribe(subscribeParams.URI, filePath)
+
+	return CreateSuccessResponse(GetRequestID(req), map[string]interface{}{})
+}
+
+// handlePromptsList handles the prompts/list request
+func (s *Server) handlePromptsList(req MCPRequest) MCPResponse {
+	result := map[string]interface{}{
+		"prompts": s.promptHandler.GetAvailablePrompts(),
+	}
+
+	return CreateSuccessResponse(GetRequestID(req), result)
+}
+
+// handlePromptsGet handles the prompts/get request
+func (s *Server) handlePromptsGet(req MCPRequest) MCPResponse {
+	promptParams, err := ParsePromptGetParams(req.Params)
+	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	result, err := s.promptHandler.GetPrompt(promptParams.Name, promptParams.Arguments)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPrompt) {
+			return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+		}
+		if code, ok := errorCode(err); ok {
+			return CreateErrorResponse(GetRequestID(req), code, err.Error(), nil)
+		}
+		return CreateErrorResponse(GetRequestID(req), InternalError, "Failed to get prompt", err.Error())
+	}
+
+	return CreateSuccessResponse(GetRequestID(req), result)
+}
+
+// handleLoggingSetLevel handles the logging/setLevel request
+func (s *Server) handleLoggingSetLevel(req MCPRequest) MCPResponse {
+	var params SetLevelParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, "Invalid logging/setLevel parameters", err.Error())
+	}
+
+	level, err := parseMCPLogLevel(params.Level)
+	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	s.logger.SetClientLevel(level, s.sendLogMessage)
+
+	return CreateSuccessResponse(GetRequestID(req), map[string]interface{}{})
+}
+
 // handlePing handles the ping request
 func (s *Server) handlePing(req MCPRequest) MCPResponse {
-	return CreateSuccessResponse(GetRequestID(req), map[string]string{"status": "pong"})
+	pingParams, err := ParsePingParams(req.Params)
+	if err != nil {
+		return CreateErrorResponse(GetRequestID(req), InvalidParams, err.Error(), nil)
+	}
+
+	if !pingParams.Verbose {
+		return CreateSuccessResponse(GetRequestID(req), map[string]string{"status": "pong"})
+	}
+
+	return CreateSuccessResponse(GetRequestID(req), s.diagnostics())
+}
+
+// diagnostics reports the server's configuration, how many files it can
+// serve and the state of its caches
+func (s *Server) diagnostics() PingDiagnostics {
+	config := s.accessControl.GetConfig()
+	diagnostics := PingDiagnostics{
+		Status:      "pong",
+		Version:     s.options.Version,
+		BuildDate:   s.options.BuildDate,
+		BaseDir:     config.BaseDir,
+		AllowedExts: config.AllowedExts,
+		MaxFileSize: config.MaxFileSize,
+	}
+
+	files, err := s.accessControl.ListAllowedFiles()
+	if err != nil {
+		diagnostics.Error = err.Error()
+	} else {
+		diagnostics.BaseDirReadable = true
+		diagnostics.FileCount = len(files)
+	}
+
+	if s.cache != nil {
+		stats := s.cache.Stats()
+		diagnostics.Cache = &stats
+	}
+	if s.contentCache != nil {
+		stats := s.contentCache.Stats()
+		diagnostics.ContentCache = &stats
+	}
+
+	return diagnostics
 }
 
 // RunInteractive runs the server in interactive mode for testing
@@ -245,7 +712,11 @@ func (s *Server) handleInteractiveCommand(command string) {
 
 	case "status":
 		fmt.Printf("Base directory: %s\n", s.baseDir)
-		fmt.Printf("Cache size: %d entries\n", s.cache.Size())
+		if s.cache == nil {
+			fmt.Println("Cache: disabled")
+		} else {
+			fmt.Printf("Cache size: %d/%d entries, TTL %v\n", s.cache.Size(), s.options.CacheSize, s.options.CacheTTL)
+		}
 
 	case "tools":
 		tools := s.toolHandler.GetAvailableTools()
@@ -266,16 +737,25 @@ func (s *Server) handleInteractiveCommand(command string) {
 		}
 
 	case "cache":
+		if s.cache == nil {
+			fmt.Println("Cache: disabled")
+			return
+		}
 		stats := s.cache.Stats()
 		fmt.Printf("Cache statistics:\n")
 		fmt.Printf("  Size: %d/%d entries\n", stats.Size, stats.MaxSize)
 		fmt.Printf("  TTL: %v\n", stats.TTL)
+		fmt.Printf("  Hits: %d, misses: %d (hit ratio %.1f%%)\n", stats.Hits, stats.Misses, stats.HitRatio*100)
 		if !stats.OldestEntry.IsZero() {
 			fmt.Printf("  Oldest entry: %v\n", stats.OldestEntry)
 		}
 		if !stats.NewestEntry.IsZero() {
 			fmt.Printf("  Newest entry: %v\n", stats.NewestEntry)
 		}
+		contentStats := s.contentCache.Stats()
+		fmt.Printf("Content cache statistics:\n")
+		fmt.Printf("  Size: %d files, %d/%d bytes\n", contentStats.Entries, contentStats.Bytes, contentStats.MaxBytes)
+		fmt.Printf("  Hits: %d, misses: %d (hit ratio %.1f%%)\n", contentStats.Hits, contentStats.Misses, contentStats.HitRatio*100)
 
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
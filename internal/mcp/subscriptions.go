@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// SubscriptionManager tracks resource subscriptions and watches the
+// subscribed files for changes
+type SubscriptionManager struct {
+	mu            sync.Mutex
+	watcher       *fsnotify.Watcher
+	subscriptions map[string]map[string]bool // file path -> subscribed URIs
+	hashes        map[string]string          // file path -> last seen content hash
+	watchedDirs   map[string]int             // directory -> number of subscribed files
+	logger        *Logger
+	notify        func(uri string)
+}
+
+// NewSubscriptionManager creates a new subscription manager. notify is called
+// for every subscribed URI whose file changed. The structure cache notices
+// the change on its own and keeps the old structure until the file is
+// reparsed, so edits that leave the headings alone are reparsed
+// incrementally.
+func NewSubscriptionManager(logger *Logger, notify func(uri string)) *SubscriptionManager {
+	return &SubscriptionManager{
+		subscriptions: make(map[string]map[string]bool),
+		hashes:        make(map[string]string),
+		watchedDirs:   make(map[string]int),
+		logger:        logger,
+		notify:        notify,
+	}
+}
+
+// Subscribe starts watching filePath and reports changes for uri
+func (sm *SubscriptionManager) Subscribe(uri, filePath string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.subscriptions[filePath][uri] {
+		return nil
+	}
+
+	if sm.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create file watcher: %w", err)
+		}
+		sm.watcher = watcher
+		go sm.watch(watcher)
+	}
+
+	if _, exists := sm.subscriptions[filePath]; !exists {
+		// Watch the parent directory rather than the file itself so that
+		// editors which save by renaming a temporary file are still seen
+		dir := filepath.Dir(filePath)
+		if sm.watchedDirs[dir] == 0 {
+			if err := sm.watcher.Add(dir); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", dir, err)
+			}
+		}
+		sm.watchedDirs[dir]++
+
+		sm.subscriptions[filePath] = make(map[string]bool)
+		sm.hashes[filePath], _ = fileHash(filePath)
+	}
+
+	sm.subscriptions[filePath][uri] = true
+	return nil
+}
+
+// Unsubscribe stops reporting changes for uri
+func (sm *SubscriptionManager) Unsubscribe(uri, filePath string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	uris, exists := sm.subscriptions[filePath]
+	if !exists || !uris[uri] {
+		return
+	}
+
+	delete(uris, uri)
+	if len(uris) > 0 {
+		return
+	}
+
+	delete(sm.subscriptions, filePath)
+	delete(sm.hashes, filePath)
+
+	dir := filepath.Dir(filePath)
+	sm.watchedDirs[dir]--
+	if sm.watchedDirs[dir] == 0 {
+		delete(sm.watchedDirs, dir)
+		sm.watcher.Remove(dir)
+	}
+}
+
+// Close stops watching all subscribed files
+func (sm *SubscriptionManager) Close() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.watcher == nil {
+		return nil
+	}
+
+	err := sm.watcher.Close()
+	sm.watcher = nil
+	sm.subscriptions = make(map[string]map[string]bool)
+	sm.hashes = make(map[string]string)
+	sm.watchedDirs = make(map[string]int)
+	return err
+}
+
+// watch processes file system events until the watcher is closed
+func (sm *SubscriptionManager) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			sm.handleChange(filepath.Clean(event.Name))
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			sm.logger.Errorf("File watcher error: %v", err)
+		}
+	}
+}
+
+// handleChange notifies subscribers of filePath if its content changed
+func (sm *SubscriptionManager) handleChange(filePath string) {
+	sm.mu.Lock()
+	uris, exists := sm.subscriptions[filePath]
+	if !exists {
+		sm.mu.Unlock()
+		return
+	}
+
+	// Editors often emit several events per save; only report real changes.
+	// A removed file hashes to "", which differs from any previous content.
+	hash, _ := fileHash(filePath)
+	if hash == sm.hashes[filePath] {
+		sm.mu.Unlock()
+		return
+	}
+	sm.hashes[filePath] = hash
+	sm.logger.Debugf("Resource changed: %s", filePath)
+
+	changed := make([]string, 0, len(uris))
+	for uri := range uris {
+		changed = append(changed, uri)
+	}
+	sm.mu.Unlock()
+
+	for _, uri := range changed {
+		sm.notify(uri)
+	}
+}
+
+// fileHash calculates the MD5 hash of a file's content
+func fileHash(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", md5.Sum(content)), nil
+}
@@ -1,28 +1,71 @@
 package mcp
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mosaan/mdatlas/internal/core"
 	"github.com/mosaan/mdatlas/pkg/types"
 )
 
+// maxDirectoryFiles is the largest number of files get_directory_structure
+// parses in one call
+const maxDirectoryFiles = 100
+
 // ToolHandler handles MCP tool calls
 type ToolHandler struct {
 	structureManager *core.StructureManager
 	accessControl    *core.AccessControl
+	cache            *core.Cache
+	contentCache     *core.ContentCache
+	concurrency      int
 }
 
-// NewToolHandler creates a new tool handler
-func NewToolHandler(structureManager *core.StructureManager, accessControl *core.AccessControl) *ToolHandler {
+// NewToolHandler creates a new tool handler. cache and contentCache are the
+// server's structure and file content caches, reported and cleared by the
+// cache tools; they are nil when caching is disabled.
+func NewToolHandler(structureManager *core.StructureManager, accessControl *core.AccessControl, cache *core.Cache, contentCache *core.ContentCache) *ToolHandler {
 	return &ToolHandler{
 		structureManager: structureManager,
 		accessControl:    accessControl,
+		cache:            cache,
+		contentCache:     contentCache,
 	}
 }
 
+// SetConcurrency sets how many files get_directory_structure parses at once
+// (core.DefaultConcurrency if concurrency is 0 or less)
+func (th *ToolHandler) SetConcurrency(concurrency int) {
+	th.concurrency = concurrency
+}
+
+// CacheStatsResult is the response of the get_cache_stats tool
+type CacheStatsResult struct {
+	core.CacheStats
+	CachedFiles []string                `json:"cached_files"`
+	Content     *core.ContentCacheStats `json:"content,omitempty"`
+}
+
+// MarkdownFileEntry is a file listed by the list_markdown_files tool
+type MarkdownFileEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ClearCacheResult is the response of the clear_cache tool
+type ClearCacheResult struct {
+	Cleared int `json:"cleared"`
+}
+
 // GetAvailableTools returns the list of available tools
 func (th *ToolHandler) GetAvailableTools() []Tool {
 	return []Tool{
@@ -42,6 +85,31 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 						"minimum":     1,
 						"maximum":     6,
 					},
+					"min_level": map[string]interface{}{
+						"type":        "integer",
+						"description": "Minimum heading level to include, e.g. 2 to leave out a document's H1 title; sections above it are dropped and their subsections become roots. With max_depth it selects a window of levels (optional)",
+						"minimum":     1,
+						"maximum":     6,
+					},
+					"no_counts": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Leave the character, line and word counts of the sections at zero to extract the heading tree faster",
+						"default":     false,
+					},
+					"flat": map[string]interface{}{
+						"type":        "boolean",
+						"description": "List the sections as a flat array in document order, each with the parent_id of its parent section, instead of nesting them in children",
+						"default":     false,
+					},
+					"ignore_levels": map[string]interface{}{
+						"type":        "array",
+						"description": "Heading levels that do not start sections, e.g. [5, 6]; their lines stay in the preceding section (optional, defaults to the server's --ignore-levels)",
+						"items": map[string]interface{}{
+							"type":    "integer",
+							"minimum": 1,
+							"maximum": 6,
+						},
+					},
 				},
 				"required": []string{"file_path"},
 			},
@@ -58,7 +126,91 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 					},
 					"section_id": map[string]interface{}{
 						"type":        "string",
-						"description": "Unique identifier of the section to retrieve",
+						"description": "Unique identifier of the section to retrieve (section_preamble for the text before the first heading)",
+					},
+					"include_children": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether to include child sections in the content",
+						"default":     false,
+					},
+					"context_lines": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of lines of the surrounding document to add before and after the section, clamped to the file. The returned range is reported as start_line and end_line.",
+						"minimum":     0,
+						"default":     0,
+					},
+					"include_preamble": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Start the content of the document's first section at the text before it (the preamble), if there is any; ignored for other sections",
+						"default":     false,
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"description": "Output format for the content: markdown as written, plain text with the Markdown syntax stripped, or an html fragment with raw HTML omitted",
+						"enum":        []string{"markdown", "plain", "html"},
+						"default":     "markdown",
+					},
+					"max_chars": map[string]interface{}{
+						"type":        "integer",
+						"description": "Largest content to return, in bytes of Markdown counted like char_count before any plain or html rendering; longer content is cut at a character boundary and reported with truncated and original_char_count (optional, no limit by default)",
+						"minimum":     1,
+					},
+					"truncate_at_paragraph": map[string]interface{}{
+						"type":        "boolean",
+						"description": "With max_chars, cut at the end of the last whole paragraph that fits instead of mid-paragraph",
+						"default":     false,
+					},
+					"tokenizer": tokenizerSchema(),
+				},
+				"required": []string{"file_path", "section_id"},
+			},
+		},
+		{
+			Name:        "get_markdown_section_by_path",
+			Description: "Retrieve content from a section of a Markdown file identified by its heading path",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"section_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Slash-separated heading titles, e.g. 'Introduction/Getting Started/Installation' (case-insensitive)",
+					},
+					"include_children": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether to include child sections in the content",
+						"default":     false,
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"description": "Output format for the content: markdown as written, plain text with the Markdown syntax stripped, or an html fragment with raw HTML omitted",
+						"enum":        []string{"markdown", "plain", "html"},
+						"default":     "markdown",
+					},
+				},
+				"required": []string{"file_path", "section_path"},
+			},
+		},
+		{
+			Name:        "get_markdown_sections",
+			Description: "Retrieve content from several sections of a Markdown file in one call",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"section_ids": map[string]interface{}{
+						"type":        "array",
+						"description": "Identifiers of the sections to retrieve, returned in the same order",
+						"items": map[string]interface{}{
+							"type": "string",
+						},
+						"minItems": 1,
 					},
 					"include_children": map[string]interface{}{
 						"type":        "boolean",
@@ -67,17 +219,102 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 					},
 					"format": map[string]interface{}{
 						"type":        "string",
-						"description": "Output format for the content",
-						"enum":        []string{"markdown", "plain"},
+						"description": "Output format for the content: markdown as written, plain text with the Markdown syntax stripped, or an html fragment with raw HTML omitted",
+						"enum":        []string{"markdown", "plain", "html"},
 						"default":     "markdown",
 					},
 				},
+				"required": []string{"file_path", "section_ids"},
+			},
+		},
+		{
+			Name:        "get_section_children",
+			Description: "List the direct subsections of a section without their content, for exploring a large document one level at a time. Returns {file_path, section_id, children, count}, each child a section with an empty children array and a child_count of its own subsections",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"section_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Identifier of the section whose subsections to list",
+					},
+				},
 				"required": []string{"file_path", "section_id"},
 			},
 		},
+		{
+			Name:        "get_sections_by_level",
+			Description: "List every section at a heading level of a Markdown file in document order, e.g. level 2 for per-chapter indexes. Returns {file_path, level, sections, count}, each section without its subsections but with their child_count; the list is empty when no heading has the level",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"level": map[string]interface{}{
+						"type":        "integer",
+						"description": "Heading level of the sections to list",
+						"minimum":     1,
+						"maximum":     6,
+					},
+				},
+				"required": []string{"file_path", "level"},
+			},
+		},
+		{
+			Name:        "get_markdown_lines",
+			Description: "Retrieve a range of lines from a Markdown file, independent of its sections",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"start_line": map[string]interface{}{
+						"type":        "integer",
+						"description": "First line to return (1-based)",
+						"minimum":     1,
+						"default":     1,
+					},
+					"end_line": map[string]interface{}{
+						"type":        "integer",
+						"description": "Last line to return, inclusive (defaults to the end of the file)",
+						"minimum":     1,
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "get_markdown_range",
+			Description: "Retrieve the content of a Markdown file from the heading of one section up to, but not including, the heading of a later section in document order, e.g. to export a range of chapters. Returns {from_section_id, to_section_id, content, start_line, end_line}",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"from_section_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Identifier of the section the range starts with",
+					},
+					"to_section_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Identifier of the section the range stops before; it must come after from_section_id",
+					},
+				},
+				"required": []string{"file_path", "from_section_id", "to_section_id"},
+			},
+		},
 		{
 			Name:        "search_markdown_content",
-			Description: "Search for sections containing specific text in a Markdown file",
+			Description: "Search for sections containing specific text in a Markdown file. Returns {file_path, query, results, count}, each result a section with match_count and match_line, plus a snippet with the matches highlighted for body searches",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -87,17 +324,47 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 					},
 					"query": map[string]interface{}{
 						"type":        "string",
-						"description": "Search query to find in section titles",
+						"description": "Search query to find in section titles (or bodies with search_body)",
 					},
 					"case_sensitive": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Whether the search should be case sensitive",
 						"default":     false,
 					},
+					"search_body": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Search section body text instead of titles, returning the matched line and a snippet",
+						"default":     false,
+					},
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"description": "How the query is matched: substring for plain text, regex for a regular expression in RE2 syntax such as ^Chapter \\d+",
+						"enum":        []string{core.SearchModeSubstring, core.SearchModeRegex},
+						"default":     core.SearchModeSubstring,
+					},
+					"highlight": map[string]interface{}{
+						"type":        "string",
+						"description": "Delimiter placed before and after each match in body search snippets; an empty string leaves snippets unmarked",
+						"default":     core.DefaultSearchHighlight,
+					},
 				},
 				"required": []string{"file_path", "query"},
 			},
 		},
+		{
+			Name:        "get_markdown_outline_depth",
+			Description: "Get the deepest heading level of a Markdown file and its number of sections per level, a cheap sizing check before fetching the structure. Returns {file_path, max_level, level_counts}; max_level is 0 when the file has no headings",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
 		{
 			Name:        "get_markdown_stats",
 			Description: "Get statistics about a Markdown document",
@@ -108,13 +375,25 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 						"type":        "string",
 						"description": "Path to the Markdown file (relative to base directory)",
 					},
+					"word_count_mode": map[string]interface{}{
+						"type":        "string",
+						"description": "How words are counted: whitespace-separated tokens, or cjk to also count each CJK character as a word. Heading lines and code blocks are excluded.",
+						"enum":        []string{core.WordCountWhitespace, core.WordCountCJK},
+						"default":     core.WordCountWhitespace,
+					},
+					"max_depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum heading depth counted in section_count and level_counts (optional, all levels by default)",
+						"minimum":     1,
+						"maximum":     6,
+					},
 				},
 				"required": []string{"file_path"},
 			},
 		},
 		{
 			Name:        "get_markdown_toc",
-			Description: "Generate a table of contents for a Markdown document",
+			Description: "Generate a table of contents for a Markdown document. Each entry has {id, anchor, level, title, line}, where anchor is the GitHub-style heading slug for linking to rendered HTML",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -132,32 +411,1067 @@ func (th *ToolHandler) GetAvailableTools() []Tool {
 				"required": []string{"file_path"},
 			},
 		},
+		{
+			Name:        "get_markdown_outline",
+			Description: "Get the section tree of a Markdown file with only the id, title and level of each section, e.g. to render a clickable outline; much smaller than get_markdown_structure for large documents. Returns {file_path, outline}; sections without subsections have no children field",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"max_depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum heading depth to include (optional)",
+						"minimum":     1,
+						"maximum":     6,
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "section_at_line",
+			Description: "Find the section containing a line of a Markdown file, e.g. the section under an editor's cursor. Returns {file_path, line, in_preamble, section_id, title, level, start_line, end_line, breadcrumb} for the innermost section whose line range holds the line; a line before the first heading has in_preamble set and, if the preamble has text, its section_id",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"line": map[string]interface{}{
+						"type":        "integer",
+						"description": "Line number to locate (1-based)",
+						"minimum":     1,
+					},
+				},
+				"required": []string{"file_path", "line"},
+			},
+		},
+		{
+			Name:        "get_markdown_skeleton",
+			Description: "Return the headings of a Markdown document as Markdown, one ATX heading per line with its '#' markers and no body text, ready to paste as the outline of a new document",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"max_depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum heading depth to include (optional)",
+						"minimum":     1,
+						"maximum":     6,
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "diff_markdown_structure",
+			Description: "Compare the heading structure of two Markdown files. Returns {old_file, new_file, added, removed, changed}, keyed by heading path; changed lists sections that moved, changed level or whose own text length changed significantly",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"old_file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the old version of the Markdown file (relative to base directory)",
+					},
+					"new_file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the new version of the Markdown file (relative to base directory)",
+					},
+				},
+				"required": []string{"old_file_path", "new_file_path"},
+			},
+		},
+		{
+			Name:        "changed_sections",
+			Description: "Find the sections of a Markdown file whose text changed since a client recorded their content_hash values, e.g. to re-embed only edited sections in an index. A section's hash covers its own text up to the next heading, so editing a subsection does not change its parent. Returns {file_path, changed, added, removed, unchanged}: changed and added list sections with their current content_hash, and removed the recorded IDs no longer in the file",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"section_hashes": map[string]interface{}{
+						"type":        "object",
+						"description": "The content_hash recorded for each section ID, as returned by get_markdown_structure",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{"file_path", "section_hashes"},
+			},
+		},
+		{
+			Name:        "lint_markdown",
+			Description: "Report all problems in the heading structure of a Markdown document: skipped heading levels, empty headings and duplicate sibling titles. Returns {file_path, issues, count}, each issue with {section_id, kind, message, line}",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "list_markdown_files",
+			Description: "List the Markdown files that can be accessed under the base directory, honoring exclude patterns. Returns {files, count}, each file with {path (relative to base directory), size, mod_time}",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pattern": map[string]interface{}{
+						"type":        "string",
+						"description": "Glob matched against the relative path, e.g. \"docs/**/*.md\"; \"**\" matches any number of directories. Lists all files if omitted",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get_directory_structure",
+			Description: fmt.Sprintf("Get the structure of every Markdown file under a directory in one call. Returns {directory, files, order, count}, where files maps each path (relative to base directory) to its document structure, or to its table of contents when toc_only is set, and order lists the paths in filename order, or by the front matter key sort_by. Fails if the directory holds more than %d files", maxDirectoryFiles),
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"directory": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory relative to base directory; the base directory itself if omitted",
+					},
+					"max_depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum heading depth to include",
+						"minimum":     1,
+						"maximum":     6,
+					},
+					"toc_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return a table of contents per file instead of the full structure",
+						"default":     false,
+					},
+					"sort_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Front matter key, such as weight or order, to sort the paths of order by, as a static site generator sequences pages; files without the key follow in filename order (optional)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "estimate_tokens",
+			Description: "Estimate the number of tokens in a Markdown file, or in one of its sections, without returning the content, e.g. to plan prompt assembly. The estimate is approximate, from a heuristic rather than a model's tokenizer. Returns {file_path, section_id, tokenizer, char_count, token_estimate}",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the Markdown file (relative to base directory)",
+					},
+					"section_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Section to estimate; the whole file if omitted",
+					},
+					"include_children": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether to include child sections in the estimate",
+						"default":     false,
+					},
+					"tokenizer": tokenizerSchema(),
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "validate_path",
+			Description: "Check whether a file can be accessed, without reading it. Returns {allowed, relative_path, reason, message}, where relative_path is the path resolved against the base directory and, for a refused path, reason is one of outside_base, bad_extension, not_found or too_large",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to check (relative to base directory)",
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			Name:        "get_cache_stats",
+			Description: "Get statistics about the server's document structure cache. Returns {size, max_size, ttl (nanoseconds), oldest_entry, newest_entry, hits, misses, hit_ratio, cached_files (relative to base directory), content}, where content reports the file content cache as {entries, bytes, max_bytes, hits, misses, hit_ratio}",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "clear_cache",
+			Description: "Clear the server's document structure and file content caches so every document is read and re-parsed on next access, and reset the hit and miss counters. Returns {cleared} with the number of removed structure entries",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+// HandleToolCall handles a specific tool call. Parsing is abandoned once ctx
+// is done.
+func (th *ToolHandler) HandleToolCall(ctx context.Context, toolName string, arguments map[string]interface{}) ToolResult {
+	handler := *th
+	handler.structureManager = th.structureManager.WithContext(ctx)
+	return handler.handleToolCall(toolName, arguments)
+}
+
+// handleToolCall dispatches a tool call to its handler
+func (th *ToolHandler) handleToolCall(toolName string, arguments map[string]interface{}) ToolResult {
+	switch toolName {
+	case "get_markdown_structure":
+		return th.handleGetMarkdownStructure(arguments)
+	case "get_markdown_section":
+		return th.handleGetMarkdownSection(arguments)
+	case "get_markdown_section_by_path":
+		return th.handleGetMarkdownSectionByPath(arguments)
+	case "get_markdown_sections":
+		return th.handleGetMarkdownSections(arguments)
+	case "get_section_children":
+		return th.handleGetSectionChildren(arguments)
+	case "get_sections_by_level":
+		return th.handleGetSectionsByLevel(arguments)
+	case "get_markdown_lines":
+		return th.handleGetMarkdownLines(arguments)
+	case "get_markdown_range":
+		return th.handleGetMarkdownRange(arguments)
+	case "search_markdown_content":
+		return th.handleSearchMarkdownContent(arguments)
+	case "get_markdown_outline_depth":
+		return th.handleGetMarkdownOutlineDepth(arguments)
+	case "get_markdown_stats":
+		return th.handleGetMarkdownStats(arguments)
+	case "get_markdown_toc":
+		return th.handleGetMarkdownTOC(arguments)
+	case "get_markdown_outline":
+		return th.handleGetMarkdownOutline(arguments)
+	case "section_at_line":
+		return th.handleSectionAtLine(arguments)
+	case "get_markdown_skeleton":
+		return th.handleGetMarkdownSkeleton(arguments)
+	case "diff_markdown_structure":
+		return th.handleDiffMarkdownStructure(arguments)
+	case "changed_sections":
+		return th.handleChangedSections(arguments)
+	case "lint_markdown":
+		return th.handleLintMarkdown(arguments)
+	case "list_markdown_files":
+		return th.handleListMarkdownFiles(arguments)
+	case "get_directory_structure":
+		return th.handleGetDirectoryStructure(arguments)
+	case "estimate_tokens":
+		return th.handleEstimateTokens(arguments)
+	case "validate_path":
+		return th.handleValidatePath(arguments)
+	case "get_cache_stats":
+		return th.handleGetCacheStats()
+	case "clear_cache":
+		return th.handleClearCache()
+	default:
+		return ToolResult{
+			Content: []Content{CreateTextContent(fmt.Sprintf("Unknown tool: %s", toolName))},
+			IsError: true,
+		}
+	}
+}
+
+// handleGetMarkdownStructure handles the get_markdown_structure tool
+func (th *ToolHandler) handleGetMarkdownStructure(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	maxDepth, err := parseMaxDepth(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	minLevel, err := parseMinLevel(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+	if maxDepth > 0 && minLevel > maxDepth {
+		return th.createInvalidParamsResult(fmt.Sprintf("invalid min_level: %d is deeper than max_depth %d", minLevel, maxDepth))
+	}
+
+	ignoreLevels, err := parseIgnoreLevels(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	// Get document structure, with the parser options overridden by the
+	// arguments
+	options := th.structureManager.Parser().Options()
+	if ignoreLevels != nil {
+		options.IgnoreLevels = ignoreLevels
+	}
+	if n, exists := args["no_counts"]; exists {
+		if b, ok := n.(bool); ok {
+			options.NoCounts = b
+		}
+	}
+	structure, err := th.structureManager.GetDocumentStructureWithOptions(validPath, options)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get structure: %v", err), err)
+	}
+
+	flat := false
+	if f, exists := args["flat"]; exists {
+		if b, ok := f.(bool); ok {
+			flat = b
+		}
+	}
+
+	// Apply max depth and min level filters if specified, on a copy so the
+	// cached structure keeps all sections
+	result := *structure
+	result.Structure = th.filterByDepth(structure.Structure, maxDepth)
+	result.Structure = core.FilterByMinLevel(result.Structure, minLevel)
+	if flat {
+		result.Structure = th.structureManager.Parser().FlattenStructure(result.Structure)
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(result)},
+	}
+}
+
+// handleGetMarkdownSection handles the get_markdown_section tool
+func (th *ToolHandler) handleGetMarkdownSection(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	sectionID, ok := args["section_id"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid section_id parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	// Get optional parameters
+	includeChildren := false
+	if include, exists := args["include_children"]; exists {
+		if b, ok := include.(bool); ok {
+			includeChildren = b
+		}
+	}
+
+	format := "markdown"
+	if f, exists := args["format"]; exists {
+		if s, ok := f.(string); ok {
+			format = s
+		}
+	}
+
+	contextLines, err := parseContextLines(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	includePreamble := false
+	if include, exists := args["include_preamble"]; exists {
+		if b, ok := include.(bool); ok {
+			includePreamble = b
+		}
+	}
+
+	maxChars, err := parseMaxChars(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	atParagraph := false
+	if truncate, exists := args["truncate_at_paragraph"]; exists {
+		if b, ok := truncate.(bool); ok {
+			atParagraph = b
+		}
+	}
+
+	tokenizer, err := parseTokenizer(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	// Get section content
+	sectionContent, err := th.structureManager.GetSectionContentWithPreamble(validPath, sectionID, includeChildren, contextLines, includePreamble)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get section: %v", err), err)
+	}
+
+	// Truncate the Markdown before rendering, so html stays well formed
+	th.structureManager.Parser().TruncateSectionContent(sectionContent, maxChars, atParagraph)
+
+	// Set format
+	if err := th.structureManager.Parser().FormatSectionContent(sectionContent, format); err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get section: %v", err), err)
+	}
+	sectionContent.TokenEstimate = core.EstimateTokens(sectionContent.Content, tokenizer)
+
+	// Return based on format
+	switch format {
+	case "json":
+		return ToolResult{
+			Content: []Content{CreateJSONContent(sectionContent)},
+		}
+	default:
+		textContent := CreateTextContent(sectionContent.Content)
+		textContent.MimeType = sectionContent.MimeType
+		return ToolResult{
+			Content: []Content{textContent},
+			StructuredContent: LineRange{
+				StartLine:         sectionContent.StartLine,
+				EndLine:           sectionContent.EndLine,
+				Truncated:         sectionContent.Truncated,
+				OriginalCharCount: sectionContent.OriginalCharCount,
+				TokenEstimate:     sectionContent.TokenEstimate,
+			},
+		}
+	}
+}
+
+// handleGetMarkdownSectionByPath handles the get_markdown_section_by_path tool
+func (th *ToolHandler) handleGetMarkdownSectionByPath(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	sectionPath, ok := args["section_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid section_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	// Get optional parameters
+	includeChildren := false
+	if include, exists := args["include_children"]; exists {
+		if b, ok := include.(bool); ok {
+			includeChildren = b
+		}
+	}
+
+	format := "markdown"
+	if f, exists := args["format"]; exists {
+		if s, ok := f.(string); ok {
+			format = s
+		}
+	}
+
+	// Get section content
+	sectionContent, err := th.structureManager.GetSectionContentByPath(validPath, sectionPath, includeChildren)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get section: %v", err), err)
+	}
+
+	// Set format
+	if err := th.structureManager.Parser().FormatSectionContent(sectionContent, format); err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get section: %v", err), err)
+	}
+
+	// Return based on format
+	switch format {
+	case "json":
+		return ToolResult{
+			Content: []Content{CreateJSONContent(sectionContent)},
+		}
+	default:
+		textContent := CreateTextContent(sectionContent.Content)
+		textContent.MimeType = sectionContent.MimeType
+		return ToolResult{
+			Content: []Content{textContent},
+		}
+	}
+}
+
+// handleGetMarkdownSections handles the get_markdown_sections tool
+func (th *ToolHandler) handleGetMarkdownSections(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	rawIDs, ok := args["section_ids"].([]interface{})
+	if !ok || len(rawIDs) == 0 {
+		return th.createErrorResult("Missing or invalid section_ids parameter")
+	}
+
+	sectionIDs := make([]string, 0, len(rawIDs))
+	for _, rawID := range rawIDs {
+		sectionID, ok := rawID.(string)
+		if !ok {
+			return th.createErrorResult("Missing or invalid section_ids parameter")
+		}
+		sectionIDs = append(sectionIDs, sectionID)
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	// Get optional parameters
+	includeChildren := false
+	if include, exists := args["include_children"]; exists {
+		if b, ok := include.(bool); ok {
+			includeChildren = b
+		}
+	}
+
+	format := "markdown"
+	if f, exists := args["format"]; exists {
+		if s, ok := f.(string); ok {
+			format = s
+		}
+	}
+
+	// Get section contents
+	results, err := th.structureManager.GetSectionContents(validPath, sectionIDs, includeChildren)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get sections: %v", err), err)
+	}
+
+	for _, result := range results {
+		if result.SectionContent != nil {
+			if err := th.structureManager.Parser().FormatSectionContent(result.SectionContent, format); err != nil {
+				return th.createCodedErrorResult(fmt.Sprintf("Failed to get sections: %v", err), err)
+			}
+		}
+	}
+
+	sectionsResult := map[string]interface{}{
+		"file_path": filePath,
+		"sections":  results,
+		"count":     len(results),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(sectionsResult)},
+	}
+}
+
+// handleGetSectionChildren handles the get_section_children tool
+func (th *ToolHandler) handleGetSectionChildren(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	sectionID, ok := args["section_id"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid section_id parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	children, err := th.structureManager.GetSectionChildren(validPath, sectionID)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get section children: %v", err), err)
+	}
+
+	childrenResult := map[string]interface{}{
+		"file_path":  filePath,
+		"section_id": sectionID,
+		"children":   children,
+		"count":      len(children),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(childrenResult)},
+	}
+}
+
+// handleGetSectionsByLevel handles the get_sections_by_level tool
+func (th *ToolHandler) handleGetSectionsByLevel(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	level, ok := args["level"].(float64)
+	if !ok || level != math.Trunc(level) || level < 1 || level > 6 {
+		return th.createInvalidParamsResult(fmt.Sprintf("invalid level: expected an integer from 1 to 6, got %v", args["level"]))
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	sections, err := th.structureManager.GetSectionsByLevel(validPath, int(level))
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get sections: %v", err), err)
+	}
+
+	sectionsResult := map[string]interface{}{
+		"file_path": filePath,
+		"level":     int(level),
+		"sections":  sections,
+		"count":     len(sections),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(sectionsResult)},
+	}
+}
+
+// handleGetMarkdownLines handles the get_markdown_lines tool
+func (th *ToolHandler) handleGetMarkdownLines(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Get optional line range
+	startLine, err := parseLineNumber(args, "start_line", 1)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	endLine, err := parseLineNumber(args, "end_line", 0)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	if endLine > 0 && startLine > endLine {
+		return th.createErrorResult(fmt.Sprintf("Invalid line range: start_line %d is after end_line %d", startLine, endLine))
+	}
+
+	// Read lines with access control
+	reader := core.NewSecureFileReader(th.accessControl)
+	if th.contentCache != nil {
+		reader.SetContentCache(th.contentCache)
+	}
+	reader.SetKeepLineEndings(th.structureManager.Parser().Options().KeepLineEndings)
+	lineRange, err := reader.ReadLineRange(filePath, startLine, endLine)
+	if err != nil {
+		if _, ok := accessErrorCode(err); ok {
+			return th.createAccessErrorResult(err)
+		}
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to read lines: %v", err), err)
+	}
+
+	text := lineRange.Header() + "\n" + strings.Join(lineRange.Lines, "\n")
+
+	return ToolResult{
+		Content: []Content{CreateTextContent(text)},
+	}
+}
+
+// handleGetMarkdownRange handles the get_markdown_range tool
+func (th *ToolHandler) handleGetMarkdownRange(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	fromID, ok := args["from_section_id"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid from_section_id parameter")
+	}
+
+	toID, ok := args["to_section_id"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid to_section_id parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	sectionRange, err := th.structureManager.GetSectionRange(validPath, fromID, toID)
+	if errors.Is(err, core.ErrInvalidSectionRange) {
+		return th.createInvalidParamsResult(fmt.Sprintf("Failed to get range: %v", err))
+	}
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get range: %v", err), err)
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(sectionRange)},
+	}
+}
+
+// handleSearchMarkdownContent handles the search_markdown_content tool
+func (th *ToolHandler) handleSearchMarkdownContent(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid query parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	// Get case sensitivity setting
+	caseSensitive := false
+	if cs, exists := args["case_sensitive"]; exists {
+		if b, ok := cs.(bool); ok {
+			caseSensitive = b
+		}
+	}
+
+	searchBody := false
+	if sb, exists := args["search_body"]; exists {
+		if b, ok := sb.(bool); ok {
+			searchBody = b
+		}
+	}
+
+	mode := core.SearchModeSubstring
+	if m, exists := args["mode"]; exists {
+		if s, ok := m.(string); ok && core.IsValidSearchMode(s) {
+			mode = s
+		} else {
+			return th.createInvalidParamsResult(fmt.Sprintf("Invalid mode parameter: expected %s or %s, got %v", core.SearchModeSubstring, core.SearchModeRegex, m))
+		}
+	}
+
+	highlight := core.DefaultSearchHighlight
+	if h, exists := args["highlight"]; exists {
+		if s, ok := h.(string); ok {
+			highlight = s
+		} else {
+			return th.createInvalidParamsResult(fmt.Sprintf("Invalid highlight parameter: expected a string, got %v", h))
+		}
+	}
+
+	// Search sections
+	var matches []core.SearchMatch
+	if searchBody {
+		matches, err = th.structureManager.SearchSectionBodiesWithHighlight(validPath, query, mode, caseSensitive, highlight)
+	} else {
+		matches, err = th.structureManager.SearchSectionsWithMode(validPath, query, mode, caseSensitive)
+	}
+	if errors.Is(err, core.ErrInvalidSearchQuery) {
+		return th.createInvalidParamsResult(fmt.Sprintf("Search failed: %v", err))
+	}
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Search failed: %v", err), err)
+	}
+
+	searchResult := map[string]interface{}{
+		"file_path": filePath,
+		"query":     query,
+		"results":   matches,
+		"count":     len(matches),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(searchResult)},
+	}
+}
+
+// handleGetMarkdownOutlineDepth handles the get_markdown_outline_depth tool
+func (th *ToolHandler) handleGetMarkdownOutlineDepth(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	depth, err := th.structureManager.GetOutlineDepth(validPath)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get outline depth: %v", err), err)
+	}
+	depth.FilePath = filePath
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(depth)},
+	}
+}
+
+// handleGetMarkdownStats handles the get_markdown_stats tool
+func (th *ToolHandler) handleGetMarkdownStats(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	wordCountMode := core.WordCountWhitespace
+	if m, exists := args["word_count_mode"]; exists {
+		if s, ok := m.(string); ok {
+			wordCountMode = s
+		}
+	}
+
+	maxDepth, err := parseMaxDepth(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	// Get document statistics
+	stats, err := th.structureManager.GetDocumentStatsWithDepth(validPath, wordCountMode, maxDepth)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get stats: %v", err), err)
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(stats)},
+	}
+}
+
+// handleGetMarkdownTOC handles the get_markdown_toc tool
+func (th *ToolHandler) handleGetMarkdownTOC(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	maxDepth, err := parseMaxDepth(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	// Generate table of contents
+	toc, err := th.structureManager.GetTableOfContents(validPath, maxDepth)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to generate TOC: %v", err), err)
+	}
+
+	tocResult := map[string]interface{}{
+		"file_path": filePath,
+		"toc":       toc,
+		"count":     len(toc),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(tocResult)},
+	}
+}
+
+// handleGetMarkdownOutline handles the get_markdown_outline tool
+func (th *ToolHandler) handleGetMarkdownOutline(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	maxDepth, err := parseMaxDepth(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	outline, err := th.structureManager.GetOutline(validPath, maxDepth)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get outline: %v", err), err)
+	}
+
+	outlineResult := map[string]interface{}{
+		"file_path": filePath,
+		"outline":   outline,
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(outlineResult)},
+	}
+}
+
+// handleSectionAtLine handles the section_at_line tool
+func (th *ToolHandler) handleSectionAtLine(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	line, ok := args["line"].(float64)
+	if !ok || line < 1 || line != float64(int(line)) {
+		return th.createInvalidParamsResult(fmt.Sprintf("invalid line: expected a positive integer, got %v", args["line"]))
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	located, err := th.structureManager.GetSectionAtLine(validPath, int(line))
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to locate line: %v", err), err)
+	}
+	located.FilePath = filePath
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(located)},
+	}
+}
+
+// handleGetMarkdownSkeleton handles the get_markdown_skeleton tool
+func (th *ToolHandler) handleGetMarkdownSkeleton(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	maxDepth, err := parseMaxDepth(args)
+	if err != nil {
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	skeleton, err := th.structureManager.GetSkeleton(validPath, maxDepth)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to build skeleton: %v", err), err)
+	}
+
+	textContent := CreateTextContent(skeleton)
+	textContent.MimeType = "text/markdown"
+	return ToolResult{
+		Content: []Content{textContent},
+	}
+}
+
+// handleDiffMarkdownStructure handles the diff_markdown_structure tool
+func (th *ToolHandler) handleDiffMarkdownStructure(args map[string]interface{}) ToolResult {
+	oldPath, ok := args["old_file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid old_file_path parameter")
+	}
+
+	newPath, ok := args["new_file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid new_file_path parameter")
+	}
+
+	// Validate access to both files
+	validOldPath, err := th.accessControl.ValidatePath(oldPath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	validNewPath, err := th.accessControl.ValidatePath(newPath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	diff, err := th.structureManager.DiffDocuments(validOldPath, validNewPath)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to diff structures: %v", err), err)
+	}
+
+	// Report the paths as given, relative to the base directory
+	diff.OldFile = oldPath
+	diff.NewFile = newPath
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(diff)},
+	}
+}
+
+// handleChangedSections handles the changed_sections tool
+func (th *ToolHandler) handleChangedSections(args map[string]interface{}) ToolResult {
+	filePath, ok := args["file_path"].(string)
+	if !ok {
+		return th.createErrorResult("Missing or invalid file_path parameter")
+	}
+
+	rawHashes, ok := args["section_hashes"].(map[string]interface{})
+	if !ok {
+		return th.createInvalidParamsResult("invalid section_hashes: expected an object mapping section IDs to content hashes")
+	}
+	priorHashes := make(map[string]string, len(rawHashes))
+	for id, raw := range rawHashes {
+		hash, ok := raw.(string)
+		if !ok {
+			return th.createInvalidParamsResult(fmt.Sprintf("invalid section_hashes: expected a string hash for %s, got %v", id, raw))
+		}
+		priorHashes[id] = hash
+	}
+
+	// Validate file access
+	validPath, err := th.accessControl.ValidatePath(filePath)
+	if err != nil {
+		return th.createAccessErrorResult(err)
+	}
+
+	structure, err := th.structureManager.GetDocumentStructure(validPath)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get structure: %v", err), err)
+	}
+
+	changes := core.CompareSectionHashes(structure, priorHashes)
+	changes.FilePath = filePath
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(changes)},
 	}
 }
 
-// HandleToolCall handles a specific tool call
-func (th *ToolHandler) HandleToolCall(toolName string, arguments map[string]interface{}) ToolResult {
-	switch toolName {
-	case "get_markdown_structure":
-		return th.handleGetMarkdownStructure(arguments)
-	case "get_markdown_section":
-		return th.handleGetMarkdownSection(arguments)
-	case "search_markdown_content":
-		return th.handleSearchMarkdownContent(arguments)
-	case "get_markdown_stats":
-		return th.handleGetMarkdownStats(arguments)
-	case "get_markdown_toc":
-		return th.handleGetMarkdownTOC(arguments)
-	default:
-		return ToolResult{
-			Content: []Content{CreateTextContent(fmt.Sprintf("Unknown tool: %s", toolName))},
-			IsError: true,
-		}
-	}
-}
-
-// handleGetMarkdownStructure handles the get_markdown_structure tool
-func (th *ToolHandler) handleGetMarkdownStructure(args map[string]interface{}) ToolResult {
+// handleLintMarkdown handles the lint_markdown tool
+func (th *ToolHandler) handleLintMarkdown(args map[string]interface{}) ToolResult {
 	filePath, ok := args["file_path"].(string)
 	if !ok {
 		return th.createErrorResult("Missing or invalid file_path parameter")
@@ -166,128 +1480,301 @@ func (th *ToolHandler) handleGetMarkdownStructure(args map[string]interface{}) T
 	// Validate file access
 	validPath, err := th.accessControl.ValidatePath(filePath)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Access denied: %v", err))
+		return th.createAccessErrorResult(err)
 	}
 
-	// Get document structure
-	structure, err := th.structureManager.GetDocumentStructure(validPath)
+	issues, err := th.structureManager.LintStructure(validPath)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Failed to get structure: %v", err))
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to lint structure: %v", err), err)
 	}
 
-	// Apply max depth filter if specified
-	if maxDepthRaw, exists := args["max_depth"]; exists {
-		if maxDepth, ok := maxDepthRaw.(float64); ok {
-			structure.Structure = th.filterByDepth(structure.Structure, int(maxDepth))
-		}
+	lintResult := map[string]interface{}{
+		"file_path": filePath,
+		"issues":    issues,
+		"count":     len(issues),
 	}
 
 	return ToolResult{
-		Content: []Content{CreateJSONContent(structure)},
+		Content: []Content{CreateJSONContent(lintResult)},
 	}
 }
 
-// handleGetMarkdownSection handles the get_markdown_section tool
-func (th *ToolHandler) handleGetMarkdownSection(args map[string]interface{}) ToolResult {
-	filePath, ok := args["file_path"].(string)
-	if !ok {
-		return th.createErrorResult("Missing or invalid file_path parameter")
+// handleListMarkdownFiles handles the list_markdown_files tool
+func (th *ToolHandler) handleListMarkdownFiles(args map[string]interface{}) ToolResult {
+	pattern := ""
+	if p, exists := args["pattern"]; exists {
+		if s, ok := p.(string); ok {
+			pattern = s
+		}
 	}
 
-	sectionID, ok := args["section_id"].(string)
-	if !ok {
-		return th.createErrorResult("Missing or invalid section_id parameter")
+	infos, err := th.accessControl.ListFileInfos(pattern)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to list files: %v", err), err)
 	}
 
-	// Validate file access
-	validPath, err := th.accessControl.ValidatePath(filePath)
+	files := make([]MarkdownFileEntry, 0, len(infos))
+	for _, info := range infos {
+		files = append(files, MarkdownFileEntry{
+			Path:    filepath.ToSlash(info.RelativePath),
+			Size:    info.Size,
+			ModTime: info.ModTime,
+		})
+	}
+
+	listResult := map[string]interface{}{
+		"files": files,
+		"count": len(files),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(listResult)},
+	}
+}
+
+// handleGetDirectoryStructure handles the get_directory_structure tool
+func (th *ToolHandler) handleGetDirectoryStructure(args map[string]interface{}) ToolResult {
+	directory := ""
+	if d, exists := args["directory"]; exists {
+		if s, ok := d.(string); ok {
+			directory = s
+		}
+	}
+
+	maxDepth, err := parseMaxDepth(args)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Access denied: %v", err))
+		return th.createInvalidParamsResult(err.Error())
 	}
 
-	// Get optional parameters
-	includeChildren := false
-	if include, exists := args["include_children"]; exists {
-		if b, ok := include.(bool); ok {
-			includeChildren = b
+	tocOnly := false
+	if t, exists := args["toc_only"]; exists {
+		if b, ok := t.(bool); ok {
+			tocOnly = b
 		}
 	}
 
-	format := "markdown"
-	if f, exists := args["format"]; exists {
-		if s, ok := f.(string); ok {
-			format = s
+	sortBy := ""
+	if k, exists := args["sort_by"]; exists {
+		if s, ok := k.(string); ok {
+			sortBy = s
 		}
 	}
 
-	// Get section content
-	sectionContent, err := th.structureManager.GetSectionContent(validPath, sectionID, includeChildren)
+	files, err := th.accessControl.ListAllowedFilesIn(directory)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Failed to get section: %v", err))
+		return th.createAccessErrorResult(err)
 	}
 
-	// Set format
-	sectionContent.Format = format
+	if len(files) > maxDirectoryFiles {
+		return th.createErrorResult(fmt.Sprintf("Directory contains %d files, more than the limit of %d; request a subdirectory instead", len(files), maxDirectoryFiles))
+	}
 
-	// Return based on format
-	switch format {
-	case "json":
-		return ToolResult{
-			Content: []Content{CreateJSONContent(sectionContent)},
+	validPaths := make([]string, len(files))
+	for i, file := range files {
+		validPath, err := th.accessControl.ValidatePath(file)
+		if err != nil {
+			return th.createAccessErrorResult(err)
 		}
-	default:
-		return ToolResult{
-			Content: []Content{CreateTextContent(sectionContent.Content)},
+		validPaths[i] = validPath
+	}
+
+	// Structures come from the structure manager, so files parsed before
+	// are served from the cache
+	structures, err := th.structureManager.GetDocumentStructures(validPaths, th.concurrency)
+	if err != nil {
+		return th.createCodedErrorResult(fmt.Sprintf("Failed to get structures: %v", err), err)
+	}
+
+	results := make(map[string]interface{}, len(files))
+	for i, file := range files {
+		structure := structures[i]
+		if tocOnly {
+			results[filepath.ToSlash(file)] = th.structureManager.BuildTableOfContents(structure, maxDepth)
+			continue
+		}
+
+		result := *structure
+		result.Structure = th.filterByDepth(structure.Structure, maxDepth)
+		results[filepath.ToSlash(file)] = result
+	}
+
+	// files is a JSON object, whose keys have no order, so the order of the
+	// paths is given separately
+	order := make([]string, 0, len(files))
+	if sortBy != "" {
+		for _, i := range core.FrontMatterOrder(files, structures, sortBy) {
+			order = append(order, filepath.ToSlash(files[i]))
+		}
+	} else {
+		for _, file := range files {
+			order = append(order, filepath.ToSlash(file))
 		}
 	}
+
+	directoryResult := map[string]interface{}{
+		"directory": directory,
+		"files":     results,
+		"order":     order,
+		"count":     len(results),
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(directoryResult)},
+	}
 }
 
-// handleSearchMarkdownContent handles the search_markdown_content tool
-func (th *ToolHandler) handleSearchMarkdownContent(args map[string]interface{}) ToolResult {
-	filePath, ok := args["file_path"].(string)
-	if !ok {
-		return th.createErrorResult("Missing or invalid file_path parameter")
+// parseMaxDepth returns the optional max_depth argument, or 0 when it is
+// absent. It must be a whole number within the 1-6 range declared in the
+// tool schemas.
+func parseMaxDepth(args map[string]interface{}) (int, error) {
+	raw, exists := args["max_depth"]
+	if !exists || raw == nil {
+		return 0, nil
 	}
 
-	query, ok := args["query"].(string)
-	if !ok {
-		return th.createErrorResult("Missing or invalid query parameter")
+	depth, ok := raw.(float64)
+	if !ok || depth != math.Trunc(depth) || depth < 1 || depth > 6 {
+		return 0, fmt.Errorf("invalid max_depth: expected an integer from 1 to 6, got %v", raw)
 	}
 
-	// Validate file access
-	validPath, err := th.accessControl.ValidatePath(filePath)
-	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Access denied: %v", err))
+	return int(depth), nil
+}
+
+// parseMinLevel returns the optional min_level argument, or 0 when it is
+// absent. Like max_depth, it must be a whole number from 1 to 6.
+func parseMinLevel(args map[string]interface{}) (int, error) {
+	raw, exists := args["min_level"]
+	if !exists || raw == nil {
+		return 0, nil
 	}
 
-	// Get case sensitivity setting
-	caseSensitive := false
-	if cs, exists := args["case_sensitive"]; exists {
-		if b, ok := cs.(bool); ok {
-			caseSensitive = b
+	level, ok := raw.(float64)
+	if !ok || level != math.Trunc(level) || level < 1 || level > 6 {
+		return 0, fmt.Errorf("invalid min_level: expected an integer from 1 to 6, got %v", raw)
+	}
+
+	return int(level), nil
+}
+
+// parseIgnoreLevels returns the optional ignore_levels argument, or nil when
+// it is absent so the server's own levels apply. Every element must be a
+// whole number from 1 to 6; an empty array ignores no levels.
+func parseIgnoreLevels(args map[string]interface{}) ([]int, error) {
+	raw, exists := args["ignore_levels"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid ignore_levels: expected an array of integers from 1 to 6, got %v", raw)
+	}
+
+	levels := []int{}
+	for _, value := range values {
+		level, ok := value.(float64)
+		if !ok || level != math.Trunc(level) || level < 1 || level > 6 {
+			return nil, fmt.Errorf("invalid ignore_levels: expected integers from 1 to 6, got %v", value)
 		}
+		levels = append(levels, int(level))
 	}
 
-	// Search sections
-	sections, err := th.structureManager.SearchSections(validPath, query, caseSensitive)
-	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Search failed: %v", err))
+	return levels, nil
+}
+
+// parseContextLines reads the optional context_lines argument, which must be
+// a non-negative whole number. An absent or null argument adds no context.
+func parseContextLines(args map[string]interface{}) (int, error) {
+	raw, exists := args["context_lines"]
+	if !exists || raw == nil {
+		return 0, nil
 	}
 
-	searchResult := map[string]interface{}{
-		"file_path": filePath,
-		"query":     query,
-		"results":   sections,
-		"count":     len(sections),
+	lines, ok := raw.(float64)
+	if !ok || lines != math.Trunc(lines) || lines < 0 {
+		return 0, fmt.Errorf("invalid context_lines: expected a non-negative integer, got %v", raw)
 	}
 
-	return ToolResult{
-		Content: []Content{CreateJSONContent(searchResult)},
+	return int(lines), nil
+}
+
+// parseLineNumber reads the optional 1-based line number argument name,
+// returning fallback when it is absent or null
+func parseLineNumber(args map[string]interface{}, name string, fallback int) (int, error) {
+	raw, exists := args[name]
+	if !exists || raw == nil {
+		return fallback, nil
 	}
+
+	line, ok := raw.(float64)
+	if !ok || line != math.Trunc(line) || line < 1 {
+		return 0, fmt.Errorf("invalid %s: expected a positive integer, got %v", name, raw)
+	}
+
+	return int(line), nil
 }
 
-// handleGetMarkdownStats handles the get_markdown_stats tool
-func (th *ToolHandler) handleGetMarkdownStats(args map[string]interface{}) ToolResult {
+// tokenizerSchema returns the input schema of the tokenizer argument shared
+// by the tools reporting a token estimate
+func tokenizerSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": "Heuristic for the approximate token estimate: chars counts a token per four bytes, words counts words, punctuation and symbols, which follows code more closely",
+		"enum":        []string{core.TokenizerChars, core.TokenizerWords},
+		"default":     core.TokenizerChars,
+	}
+}
+
+// parseTokenizer reads the optional tokenizer argument, returning
+// core.TokenizerChars when it is absent
+func parseTokenizer(args map[string]interface{}) (string, error) {
+	raw, exists := args["tokenizer"]
+	if !exists {
+		return core.TokenizerChars, nil
+	}
+
+	tokenizer, ok := raw.(string)
+	if !ok || !core.IsValidTokenizer(tokenizer) {
+		return "", fmt.Errorf("invalid tokenizer: expected %s or %s, got %v", core.TokenizerChars, core.TokenizerWords, raw)
+	}
+	return tokenizer, nil
+}
+
+// parseMaxChars reads the optional max_chars argument, returning 0 when it
+// is absent
+func parseMaxChars(args map[string]interface{}) (int, error) {
+	raw, exists := args["max_chars"]
+	if !exists || raw == nil {
+		return 0, nil
+	}
+
+	maxChars, ok := raw.(float64)
+	if !ok || maxChars != math.Trunc(maxChars) || maxChars < 1 {
+		return 0, fmt.Errorf("invalid max_chars: expected a positive integer, got %v", raw)
+	}
+
+	return int(maxChars), nil
+}
+
+// filterByDepth filters sections by maximum depth
+func (th *ToolHandler) filterByDepth(sections []types.Section, maxDepth int) []types.Section {
+	if maxDepth <= 0 {
+		return sections
+	}
+
+	var filtered []types.Section
+	for _, section := range sections {
+		if section.Level <= maxDepth {
+			filteredSection := section
+			filteredSection.Children = th.filterByDepth(section.Children, maxDepth)
+			filtered = append(filtered, filteredSection)
+		}
+	}
+	return filtered
+}
+
+// handleEstimateTokens handles the estimate_tokens tool
+func (th *ToolHandler) handleEstimateTokens(args map[string]interface{}) ToolResult {
 	filePath, ok := args["file_path"].(string)
 	if !ok {
 		return th.createErrorResult("Missing or invalid file_path parameter")
@@ -296,73 +1783,120 @@ func (th *ToolHandler) handleGetMarkdownStats(args map[string]interface{}) ToolR
 	// Validate file access
 	validPath, err := th.accessControl.ValidatePath(filePath)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Access denied: %v", err))
+		return th.createAccessErrorResult(err)
 	}
 
-	// Get document statistics
-	stats, err := th.structureManager.GetDocumentStats(validPath)
+	tokenizer, err := parseTokenizer(args)
 	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Failed to get stats: %v", err))
+		return th.createInvalidParamsResult(err.Error())
+	}
+
+	includeChildren := false
+	if include, exists := args["include_children"]; exists {
+		if b, ok := include.(bool); ok {
+			includeChildren = b
+		}
+	}
+
+	var text string
+	sectionID, _ := args["section_id"].(string)
+	if sectionID != "" {
+		sectionContent, err := th.structureManager.GetSectionContent(validPath, sectionID, includeChildren)
+		if err != nil {
+			return th.createCodedErrorResult(fmt.Sprintf("Failed to get section: %v", err), err)
+		}
+		text = sectionContent.Content
+	} else {
+		reader := core.NewSecureFileReader(th.accessControl)
+		if th.contentCache != nil {
+			reader.SetContentCache(th.contentCache)
+		}
+		content, err := reader.ReadFile(filePath)
+		if err != nil {
+			return th.createCodedErrorResult(fmt.Sprintf("Failed to read file: %v", err), err)
+		}
+		if content, err = core.DecodeContent(content); err != nil {
+			return th.createCodedErrorResult(fmt.Sprintf("Failed to read file: %v", err), err)
+		}
+		if !th.structureManager.Parser().Options().KeepLineEndings {
+			content = core.NormalizeLineEndings(content)
+		}
+		text = string(content)
+	}
+
+	result := map[string]interface{}{
+		"file_path":      filePath,
+		"tokenizer":      tokenizer,
+		"char_count":     len(text),
+		"token_estimate": core.EstimateTokens(text, tokenizer),
+	}
+	if sectionID != "" {
+		result["section_id"] = sectionID
 	}
 
 	return ToolResult{
-		Content: []Content{CreateJSONContent(stats)},
+		Content: []Content{CreateJSONContent(result)},
 	}
 }
 
-// handleGetMarkdownTOC handles the get_markdown_toc tool
-func (th *ToolHandler) handleGetMarkdownTOC(args map[string]interface{}) ToolResult {
+// handleValidatePath handles the validate_path tool. A refused path is a
+// successful result reporting why it was refused.
+func (th *ToolHandler) handleValidatePath(args map[string]interface{}) ToolResult {
 	filePath, ok := args["file_path"].(string)
 	if !ok {
 		return th.createErrorResult("Missing or invalid file_path parameter")
 	}
 
-	// Validate file access
-	validPath, err := th.accessControl.ValidatePath(filePath)
-	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Access denied: %v", err))
+	return ToolResult{
+		Content: []Content{CreateJSONContent(th.accessControl.CheckPath(filePath))},
 	}
+}
 
-	// Get max depth
-	maxDepth := 0
-	if maxDepthRaw, exists := args["max_depth"]; exists {
-		if md, ok := maxDepthRaw.(float64); ok {
-			maxDepth = int(md)
-		}
+// handleGetCacheStats handles the get_cache_stats tool
+func (th *ToolHandler) handleGetCacheStats() ToolResult {
+	if th.cache == nil {
+		return th.createErrorResult("Caching is disabled")
 	}
 
-	// Generate table of contents
-	toc, err := th.structureManager.GetTableOfContents(validPath, maxDepth)
-	if err != nil {
-		return th.createErrorResult(fmt.Sprintf("Failed to generate TOC: %v", err))
+	baseDir := th.accessControl.GetConfig().BaseDir
+	cachedFiles := make([]string, 0)
+	for _, file := range th.cache.GetCachedFiles() {
+		if relPath, err := filepath.Rel(baseDir, file); err == nil {
+			file = relPath
+		}
+		cachedFiles = append(cachedFiles, file)
 	}
+	sort.Strings(cachedFiles)
 
-	tocResult := map[string]interface{}{
-		"file_path": filePath,
-		"toc":       toc,
-		"count":     len(toc),
+	result := CacheStatsResult{
+		CacheStats:  th.cache.Stats(),
+		CachedFiles: cachedFiles,
+	}
+	if th.contentCache != nil {
+		contentStats := th.contentCache.Stats()
+		result.Content = &contentStats
 	}
 
 	return ToolResult{
-		Content: []Content{CreateJSONContent(tocResult)},
+		Content: []Content{CreateJSONContent(result)},
 	}
 }
 
-// filterByDepth filters sections by maximum depth
-func (th *ToolHandler) filterByDepth(sections []types.Section, maxDepth int) []types.Section {
-	if maxDepth <= 0 {
-		return sections
+// handleClearCache handles the clear_cache tool
+func (th *ToolHandler) handleClearCache() ToolResult {
+	if th.cache == nil {
+		return th.createErrorResult("Caching is disabled")
 	}
 
-	var filtered []types.Section
-	for _, section := range sections {
-		if section.Level <= maxDepth {
-			filteredSection := section
-			filteredSection.Children = th.filterByDepth(section.Children, maxDepth)
-			filtered = append(filtered, filteredSection)
-		}
+	result := ClearCacheResult{Cleared: th.cache.Size()}
+	th.cache.Clear()
+	if th.contentCache != nil {
+		th.contentCache.Clear()
+	}
+
+	return ToolResult{
+		Content: []Content{CreateJSONContent(result)},
 	}
-	return filtered
 }
 
 // createErrorResult creates an error tool result
@@ -373,16 +1907,127 @@ func (th *ToolHandler) createErrorResult(message string) ToolResult {
 	}
 }
 
+// createCodedErrorResult creates an error result for a failure caused by
+// err, with the error code of err in its structured content if it has one
+func (th *ToolHandler) createCodedErrorResult(message string, err error) ToolResult {
+	result := th.createErrorResult(message)
+	if code, ok := errorCode(err); ok {
+		result.StructuredContent = ToolErrorContent{Error: MCPError{Code: code, Message: message}}
+	}
+	return result
+}
+
+// createInvalidParamsResult creates an error result for invalid arguments,
+// with the InvalidParams code in its structured content
+func (th *ToolHandler) createInvalidParamsResult(message string) ToolResult {
+	result := th.createErrorResult(message)
+	result.StructuredContent = ToolErrorContent{Error: MCPError{Code: InvalidParams, Message: message}}
+	return result
+}
+
+// createAccessErrorResult creates an error result for a path refused by
+// access control, with the error code in its structured content
+func (th *ToolHandler) createAccessErrorResult(err error) ToolResult {
+	message := fmt.Sprintf("Access denied: %v", err)
+	result := th.createErrorResult(message)
+	if code, ok := accessErrorCode(err); ok {
+		result.StructuredContent = ToolErrorContent{Error: MCPError{Code: code, Message: message}}
+	}
+	return result
+}
+
+const (
+	// DefaultResourcePageSize is the number of resources returned per
+	// resources/list page when the client does not request a page size
+	DefaultResourcePageSize = 100
+	// MaxResourcePageSize caps the page size a client may request
+	MaxResourcePageSize = 1000
+)
+
+// ErrInvalidCursor is returned when a resources/list cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 // ResourceHandler handles MCP resource operations
 type ResourceHandler struct {
-	accessControl *core.AccessControl
+	structureManager *core.StructureManager
+	accessControl    *core.AccessControl
+	contentCache     *core.ContentCache
 }
 
-// NewResourceHandler creates a new resource handler
-func NewResourceHandler(accessControl *core.AccessControl) *ResourceHandler {
+// NewResourceHandler creates a new resource handler. Structure resources are
+// parsed by structureManager, the one the tools use, so their section IDs
+// match; content resources are read through contentCache, which may be nil.
+func NewResourceHandler(structureManager *core.StructureManager, accessControl *core.AccessControl, contentCache *core.ContentCache) *ResourceHandler {
 	return &ResourceHandler{
-		accessControl: accessControl,
+		structureManager: structureManager,
+		accessControl:    accessControl,
+		contentCache:     contentCache,
+	}
+}
+
+// GetResourcesPage returns at most pageSize resources starting at the position
+// encoded in cursor, along with the cursor for the next page. The next cursor
+// is empty once the last page has been returned. Resources are listed in the
+// order of the directory walk, so a cursor stays valid as long as the
+// directory listing is unchanged.
+func (rh *ResourceHandler) GetResourcesPage(cursor string, pageSize int) ([]Resource, string, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultResourcePageSize
+	}
+	if pageSize > MaxResourcePageSize {
+		pageSize = MaxResourcePageSize
+	}
+
+	offset, err := decodeResourceCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resources, err := rh.GetAvailableResources()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if offset > len(resources) {
+		return nil, "", fmt.Errorf("%w: offset %d is past the end of the resource list", ErrInvalidCursor, offset)
+	}
+
+	end := offset + pageSize
+	if end >= len(resources) {
+		return resources[offset:], "", nil
+	}
+
+	return resources[offset:end], encodeResourceCursor(end), nil
+}
+
+// encodeResourceCursor encodes a resource list offset as an opaque cursor
+func encodeResourceCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeResourceCursor decodes a cursor produced by encodeResourceCursor.
+// An empty cursor refers to the first page.
+func decodeResourceCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	value, found := strings.CutPrefix(string(decoded), "offset:")
+	if !found {
+		return 0, ErrInvalidCursor
 	}
+
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return offset, nil
 }
 
 // GetAvailableResources returns the list of available resources
@@ -416,12 +2061,29 @@ func (rh *ResourceHandler) GetAvailableResources() ([]Resource, error) {
 	return resources, nil
 }
 
-// ReadResource reads a specific resource
-func (rh *ResourceHandler) ReadResource(uri string) (ResourceReadResult, error) {
+// ReadResource reads a specific resource. Parsing is abandoned once ctx is
+// done.
+func (rh *ResourceHandler) ReadResource(ctx context.Context, uri string) (ResourceReadResult, error) {
+	validPath, resourceType, err := rh.ResolveResourceURI(uri)
+	if err != nil {
+		return ResourceReadResult{}, err
+	}
+
+	switch resourceType {
+	case "structure":
+		return rh.readStructureResource(ctx, validPath)
+	default:
+		return rh.readContentResource(validPath)
+	}
+}
+
+// ResolveResourceURI parses a resource URI and returns the validated file path
+// and resource type it refers to
+func (rh *ResourceHandler) ResolveResourceURI(uri string) (string, string, error) {
 	// Parse URI
 	parts := strings.Split(uri, "/")
 	if len(parts) < 4 || parts[0] != "markdown:" || parts[1] != "" || parts[2] != "file" {
-		return ResourceReadResult{}, fmt.Errorf("invalid resource URI: %s", uri)
+		return "", "", fmt.Errorf("invalid resource URI: %s", uri)
 	}
 
 	// Extract file path and resource type
@@ -431,26 +2093,19 @@ func (rh *ResourceHandler) ReadResource(uri string) (ResourceReadResult, error)
 	// Validate file access
 	validPath, err := rh.accessControl.ValidatePath(filePath)
 	if err != nil {
-		return ResourceReadResult{}, fmt.Errorf("access denied: %w", err)
+		return "", "", fmt.Errorf("access denied: %w", err)
 	}
 
-	switch resourceType {
-	case "structure":
-		return rh.readStructureResource(validPath)
-	case "content":
-		return rh.readContentResource(validPath)
-	default:
-		return ResourceReadResult{}, fmt.Errorf("unknown resource type: %s", resourceType)
+	if resourceType != "structure" && resourceType != "content" {
+		return "", "", fmt.Errorf("unknown resource type: %s", resourceType)
 	}
+
+	return validPath, resourceType, nil
 }
 
 // readStructureResource reads a structure resource
-func (rh *ResourceHandler) readStructureResource(filePath string) (ResourceReadResult, error) {
-	// Create structure manager
-	cache := core.NewCache(100, 0) // No TTL for resources
-	structureManager := core.NewStructureManager(cache)
-
-	structure, err := structureManager.GetDocumentStructure(filePath)
+func (rh *ResourceHandler) readStructureResource(ctx context.Context, filePath string) (ResourceReadResult, error) {
+	structure, err := rh.structureManager.WithContext(ctx).GetDocumentStructure(filePath)
 	if err != nil {
 		return ResourceReadResult{}, fmt.Errorf("failed to get structure: %w", err)
 	}
@@ -463,6 +2118,9 @@ func (rh *ResourceHandler) readStructureResource(filePath string) (ResourceReadR
 // readContentResource reads a content resource
 func (rh *ResourceHandler) readContentResource(filePath string) (ResourceReadResult, error) {
 	reader := core.NewSecureFileReader(rh.accessControl)
+	if rh.contentCache != nil {
+		reader.SetContentCache(rh.contentCache)
+	}
 	content, err := reader.ReadFile(filePath)
 	if err != nil {
 		return ResourceReadResult{}, fmt.Errorf("failed to read file: %w", err)
@@ -0,0 +1,228 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Message framings supported by Server.Run
+const (
+	// FramingStream decodes a stream of JSON values, tolerating any whitespace
+	// between them, including pretty-printed multi-line requests
+	FramingStream = "stream"
+	// FramingNDJSON reads exactly one JSON object per line
+	FramingNDJSON = "ndjson"
+	// FramingHeader prefixes each message with LSP-style headers, e.g.
+	// "Content-Length: N\r\n\r\n" followed by N bytes of JSON
+	FramingHeader = "header"
+)
+
+// DefaultMaxMessageSize is the largest message accepted in line and header framings
+const DefaultMaxMessageSize = 1024 * 1024 // 1MB
+
+// errMalformedMessage marks a read error that affects only the current
+// message; the reader can continue with the next one
+var errMalformedMessage = errors.New("malformed message")
+
+// IsValidFraming reports whether framing is a supported message framing
+func IsValidFraming(framing string) bool {
+	switch framing {
+	case FramingStream, FramingNDJSON, FramingHeader:
+		return true
+	default:
+		return false
+	}
+}
+
+// messageReader reads raw JSON-RPC messages from the client
+type messageReader interface {
+	// ReadMessage returns the next message, or io.EOF when the input is
+	// exhausted. Errors wrapping errMalformedMessage are recoverable.
+	ReadMessage() (json.RawMessage, error)
+}
+
+// messageWriter writes JSON-RPC messages to the client
+type messageWriter interface {
+	WriteMessage(message interface{}) error
+}
+
+// newMessageReader creates a reader for the given framing
+func newMessageReader(framing string, r io.Reader, maxMessageSize int) (messageReader, error) {
+	switch framing {
+	case FramingStream, "":
+		return &streamReader{decoder: json.NewDecoder(r)}, nil
+	case FramingNDJSON:
+		if maxMessageSize <= 0 {
+			maxMessageSize = DefaultMaxMessageSize
+		}
+		// The scanner grows its buffer up to the larger of its initial
+		// capacity and the maximum, so start no larger than the maximum
+		initialSize := 64 * 1024
+		if initialSize > maxMessageSize {
+			initialSize = maxMessageSize
+		}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, initialSize), maxMessageSize)
+		return &lineReader{scanner: scanner}, nil
+	case FramingHeader:
+		if maxMessageSize <= 0 {
+			maxMessageSize = DefaultMaxMessageSize
+		}
+		return &headerReader{reader: bufio.NewReader(r), maxMessageSize: maxMessageSize}, nil
+	default:
+		return nil, fmt.Errorf("unsupported framing: %s", framing)
+	}
+}
+
+// newMessageWriter creates a writer for the given framing
+func newMessageWriter(framing string, w io.Writer) messageWriter {
+	if framing == FramingHeader {
+		return &headerWriter{writer: w}
+	}
+
+	// Stream and line framings write compact JSON followed by a newline
+	return &lineWriter{encoder: json.NewEncoder(w)}
+}
+
+// streamReader reads whitespace-separated JSON values
+type streamReader struct {
+	decoder *json.Decoder
+}
+
+// ReadMessage reads the next JSON value. A syntax error leaves the decoder
+// unable to continue, so it is not recoverable.
+func (sr *streamReader) ReadMessage() (json.RawMessage, error) {
+	var message json.RawMessage
+	if err := sr.decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// lineReader reads one JSON object per line, skipping blank lines
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+// ReadMessage reads the next non-blank line
+func (lr *lineReader) ReadMessage() (json.RawMessage, error) {
+	for lr.scanner.Scan() {
+		line := lr.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("%w: line is not a single JSON value", errMalformedMessage)
+		}
+		// The scanner reuses its buffer, so copy the line
+		return append(json.RawMessage(nil), line...), nil
+	}
+
+	if err := lr.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("message exceeds the maximum size: %w", err)
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// lineWriter writes each message as compact JSON on its own line
+type lineWriter struct {
+	encoder *json.Encoder
+}
+
+// WriteMessage writes a message followed by a newline
+func (lw *lineWriter) WriteMessage(message interface{}) error {
+	return lw.encoder.Encode(message)
+}
+
+// headerReader reads messages framed by LSP-style headers
+type headerReader struct {
+	reader         *bufio.Reader
+	maxMessageSize int
+}
+
+// ReadMessage reads the headers of the next message and then exactly
+// Content-Length bytes of body. Only a malformed body is recoverable; bad
+// headers leave the reader unable to find the next message.
+func (hr *headerReader) ReadMessage() (json.RawMessage, error) {
+	contentLength := -1
+	sawHeader := false
+
+	for {
+		line, err := hr.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && !sawHeader && line == "" {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read message header: %w", io.ErrUnexpectedEOF)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if !sawHeader {
+				continue // Tolerate blank lines between messages
+			}
+			break
+		}
+		sawHeader = true
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid message header: %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+			contentLength = length
+		}
+	}
+
+	if contentLength < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	if contentLength > hr.maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum size of %d bytes", contentLength, hr.maxMessageSize)
+	}
+
+	// io.ReadFull keeps reading until the whole body has arrived
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(hr.reader, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("%w: body is not a single JSON value", errMalformedMessage)
+	}
+
+	return body, nil
+}
+
+// headerWriter writes messages framed by a Content-Length header
+type headerWriter struct {
+	writer io.Writer
+}
+
+// WriteMessage writes the header and compact JSON body in a single write
+func (hw *headerWriter) WriteMessage(message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, 0, len(body)+32)
+	frame = append(frame, fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))...)
+	frame = append(frame, body...)
+
+	_, err = hw.writer.Write(frame)
+	return err
+}
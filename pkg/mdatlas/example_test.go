@@ -0,0 +1,41 @@
+package mdatlas_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mosaan/mdatlas/pkg/mdatlas"
+)
+
+func Example() {
+	content := []byte("# Guide\n\nA short guide.\n\n## Install\n\nRun `go install`.\n\n## Usage\n\nSee [the docs](https://example.com).\n")
+
+	structure, err := mdatlas.Analyze(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	guide := structure.Structure[0]
+	for _, section := range guide.Children {
+		fmt.Printf("%s (lines %d-%d)\n", section.Title, section.StartLine, section.EndLine)
+	}
+
+	install, err := mdatlas.Section(content, guide.Children[0].ID, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(install.Content)
+
+	stats, err := mdatlas.Stats(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d sections, %d links\n", stats.SectionCount, stats.LinkCount)
+
+	// Output:
+	// Install (lines 5-8)
+	// Usage (lines 9-11)
+	// ## Install
+	//
+	// Run `go install`.
+	// 3 sections, 1 links
+}
@@ -0,0 +1,59 @@
+// Package mdatlas extracts the structure, sections and statistics of
+// Markdown documents for programs embedding mdatlas as a library. It parses
+// content held in memory with the default parser options, as the CLI does
+// without flags; reading files, caching and access control are left to the
+// caller.
+package mdatlas
+
+import (
+	"github.com/mosaan/mdatlas/internal/core"
+	"github.com/mosaan/mdatlas/pkg/types"
+)
+
+// DocumentStructure is the heading structure of a document. Its sections are
+// types.Section values; in this package the name Section is taken by the
+// function returning a section's content.
+type DocumentStructure = types.DocumentStructure
+
+// SectionContent is the content of one section of a document
+type SectionContent = types.SectionContent
+
+// StructureIssue is a problem found in a document's heading structure
+type StructureIssue = types.StructureIssue
+
+// DocumentStats holds the counts of a document, its sections by level and
+// its Markdown elements
+type DocumentStats = core.DocumentStats
+
+// ElementStats holds counts of Markdown elements in a document
+type ElementStats = core.ElementStats
+
+// Analyze parses content and returns its heading structure. The structure
+// has no file path; its LastModified is the time of the call.
+func Analyze(content []byte) (*DocumentStructure, error) {
+	return core.NewParser().ParseStructure(content)
+}
+
+// Section returns the content of the section of content with the given ID,
+// as listed by Analyze, and with includeChildren that of its subsections
+func Section(content []byte, id string, includeChildren bool) (*SectionContent, error) {
+	return core.NewParser().GetSectionContent(content, id, includeChildren)
+}
+
+// Stats returns the statistics of content, as the CLI's stats command
+// reports them for a file. The statistics have no file path.
+func Stats(content []byte) (*DocumentStats, error) {
+	content, err := core.DecodeContent(content)
+	if err != nil {
+		return nil, err
+	}
+	content = core.NormalizeLineEndings(content)
+
+	sm := core.NewStructureManager(nil)
+	structure, err := sm.Parser().ParseStructure(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.BuildDocumentStats("", structure, content)
+}
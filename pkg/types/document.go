@@ -2,39 +2,92 @@ package types
 
 import "time"
 
-// DocumentStructure represents the structure information of a document
+// DocumentStructure represents the structure information of a document.
+// TotalLines counts every line ended by a newline, plus a final line without
+// one, so a trailing newline adds no line and an empty document has none;
+// section line ranges count the same way. DuplicateTitles lists the heading
+// titles used more than once anywhere in the document, compared
+// case-insensitively.
 type DocumentStructure struct {
-	FilePath     string    `json:"file_path"`
-	TotalChars   int       `json:"total_chars"`
-	TotalLines   int       `json:"total_lines"`
-	Structure    []Section `json:"structure"`
-	LastModified time.Time `json:"last_modified"`
+	FilePath        string                 `json:"file_path" yaml:"file_path"`
+	TotalChars      int                    `json:"total_chars" yaml:"total_chars"`
+	TotalRunes      int                    `json:"total_runes" yaml:"total_runes"`
+	TotalLines      int                    `json:"total_lines" yaml:"total_lines"`
+	WordCount       int                    `json:"word_count" yaml:"word_count"`
+	FrontMatter     map[string]interface{} `json:"front_matter,omitempty" yaml:"front_matter,omitempty"`
+	Preamble        *Section               `json:"preamble,omitempty" yaml:"preamble,omitempty"`
+	Structure       []Section              `json:"structure" yaml:"structure"`
+	Warnings        []StructureIssue       `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	DuplicateTitles []string               `json:"duplicate_titles,omitempty" yaml:"duplicate_titles,omitempty"`
+	LastModified    time.Time              `json:"last_modified" yaml:"last_modified"`
 }
 
-// Section represents section information in the document
+// Section represents section information in the document. Title is the
+// cleaned heading text and RawTitle the heading line as written, with its
+// '#' markers and inline markup; for Setext headings it is the text without
+// the underline. ParentID is only set in flat listings of a structure, where
+// it replaces the nesting in Children. SourceFile is set on sections whose
+// heading comes from a file inlined by an include directive, relative to the
+// base directory; their line ranges still refer to the expanded document.
+// ContentHash is a hash of the section's own text, from its heading line up
+// to the next heading at any level, so it changes only when that text does,
+// not when a subsection or sibling is edited.
 type Section struct {
-	ID        string    `json:"id"`
-	Level     int       `json:"level"`
-	Title     string    `json:"title"`
-	CharCount int       `json:"char_count"`
-	LineCount int       `json:"line_count"`
-	StartLine int       `json:"start_line"`
-	EndLine   int       `json:"end_line"`
-	Children  []Section `json:"children"`
+	ID          string    `json:"id" yaml:"id"`
+	Level       int       `json:"level" yaml:"level"`
+	Title       string    `json:"title" yaml:"title"`
+	RawTitle    string    `json:"raw_title,omitempty" yaml:"raw_title,omitempty"`
+	CharCount   int       `json:"char_count" yaml:"char_count"`
+	RuneCount   int       `json:"rune_count" yaml:"rune_count"`
+	LineCount   int       `json:"line_count" yaml:"line_count"`
+	WordCount   int       `json:"word_count" yaml:"word_count"`
+	StartLine   int       `json:"start_line" yaml:"start_line"`
+	EndLine     int       `json:"end_line" yaml:"end_line"`
+	StartByte   int       `json:"start_byte" yaml:"start_byte"`
+	EndByte     int       `json:"end_byte" yaml:"end_byte"`
+	PrevID      string    `json:"prev_id,omitempty" yaml:"prev_id,omitempty"`
+	NextID      string    `json:"next_id,omitempty" yaml:"next_id,omitempty"`
+	ParentID    string    `json:"parent_id,omitempty" yaml:"parent_id,omitempty"`
+	ContentHash string    `json:"content_hash,omitempty" yaml:"content_hash,omitempty"`
+	SourceFile  string    `json:"source_file,omitempty" yaml:"source_file,omitempty"`
+	Children    []Section `json:"children" yaml:"children"`
 }
 
-// SectionContent represents the content of a section
+// SectionContent represents the content of a section. StartLine and EndLine
+// are the range of lines Content was sliced from. Truncated is set when
+// Content was cut short to a size limit, and OriginalCharCount is then its
+// length in bytes before the cut. TokenEstimate is an approximate token count
+// of Content, from a heuristic rather than a model's tokenizer.
 type SectionContent struct {
-	ID              string `json:"id"`
-	Title           string `json:"title"`
-	Content         string `json:"content"`
-	Format          string `json:"format"`
-	IncludeChildren bool   `json:"include_children"`
+	ID                string   `json:"id" yaml:"id"`
+	Title             string   `json:"title" yaml:"title"`
+	Level             int      `json:"level" yaml:"level"`
+	Content           string   `json:"content" yaml:"content"`
+	Format            string   `json:"format" yaml:"format"`
+	MimeType          string   `json:"mime_type,omitempty" yaml:"mime_type,omitempty"`
+	StartLine         int      `json:"start_line" yaml:"start_line"`
+	EndLine           int      `json:"end_line" yaml:"end_line"`
+	IncludeChildren   bool     `json:"include_children" yaml:"include_children"`
+	Breadcrumb        []string `json:"breadcrumb" yaml:"breadcrumb"`
+	ParentID          string   `json:"parent_id,omitempty" yaml:"parent_id,omitempty"`
+	Truncated         bool     `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+	OriginalCharCount int      `json:"original_char_count,omitempty" yaml:"original_char_count,omitempty"`
+	TokenEstimate     int      `json:"token_estimate" yaml:"token_estimate"`
+}
+
+// StructureIssue is a problem found in a document's heading structure
+type StructureIssue struct {
+	SectionID string `json:"section_id" yaml:"section_id"`
+	Kind      string `json:"kind" yaml:"kind"`
+	Message   string `json:"message" yaml:"message"`
+	Line      int    `json:"line" yaml:"line"`
 }
 
 // AccessConfig represents file access control settings
 type AccessConfig struct {
-	BaseDir     string   `json:"base_dir"`
-	AllowedExts []string `json:"allowed_extensions"`
-	MaxFileSize int64    `json:"max_file_size"`
+	BaseDir         string   `json:"base_dir"`
+	AllowedExts     []string `json:"allowed_extensions"`
+	MaxFileSize     int64    `json:"max_file_size"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+	UseGitignore    bool     `json:"use_gitignore,omitempty"`
 }
@@ -2,6 +2,8 @@ package integration
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -91,7 +93,7 @@ func TestCLIStructureCommandComprehensive(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			output, err := cmd.Output()
 			tt.validate(t, output, err)
 		})
@@ -103,7 +105,7 @@ func TestCLISectionCommandComprehensive(t *testing.T) {
 	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
 
 	// First get structure to obtain section IDs
-	cmd := exec.Command(binaryPath, "structure", testFile)
+	cmd := cliCommand(binaryPath, "structure", testFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Failed to get structure: %v", err)
@@ -204,7 +206,7 @@ func TestCLISectionCommandComprehensive(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			output, err := cmd.Output()
 			tt.validate(t, output, err)
 		})
@@ -349,6 +351,245 @@ func TestCLIGlobalFlags(t *testing.T) {
 	}
 }
 
+func TestCLIBaseDirSandbox(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	tests := []struct {
+		name            string
+		args            []string
+		expectedInError string
+	}{
+		{
+			name: "absolute path within base-dir",
+			args: []string{"--base-dir", fixturesDir, "structure", filepath.Join(fixturesDir, "sample.md")},
+		},
+		{
+			name:            "relative path escaping base-dir",
+			args:            []string{"--base-dir", fixturesDir, "structure", "../../README.md"},
+			expectedInError: "path outside base directory",
+		},
+		{
+			name:            "absolute path outside base-dir",
+			args:            []string{"--base-dir", fixturesDir, "structure", filepath.Join(projectRoot, "README.md")},
+			expectedInError: "path outside base directory",
+		},
+		{
+			name:            "section outside base-dir",
+			args:            []string{"--base-dir", filepath.Join(projectRoot, "internal"), "section", filepath.Join(fixturesDir, "sample.md"), "--section-path", "Sample Document"},
+			expectedInError: "path outside base directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binaryPath, tt.args...)
+			cmd.Dir = projectRoot
+			output, err := cmd.CombinedOutput()
+			if tt.expectedInError == "" {
+				if err != nil {
+					t.Fatalf("Command failed: %v\n%s", err, output)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected the command to fail, got output: %s", output)
+			}
+			if !strings.Contains(string(output), tt.expectedInError) {
+				t.Errorf("Expected error containing %q, got: %s", tt.expectedInError, output)
+			}
+		})
+	}
+}
+
+func TestCLISandbox(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	sampleFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "notes.rst"), []byte("# Notes\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	large := "# Large\n\n" + strings.Repeat("Some text for the large document.\n", 100)
+	if err := os.WriteFile(filepath.Join(tempDir, "large.md"), []byte(large), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		args            []string
+		expectedInError string
+	}{
+		{
+			name:            "file outside the working directory",
+			args:            []string{"structure", sampleFile},
+			expectedInError: "path outside base directory",
+		},
+		{
+			name: "file outside the working directory without the sandbox",
+			args: []string{"--no-sandbox", "structure", sampleFile},
+		},
+		{
+			name:            "denied extension",
+			args:            []string{"structure", "notes.rst"},
+			expectedInError: "file extension not allowed: .rst",
+		},
+		{
+			name: "extension allowed with --allowed-exts",
+			args: []string{"--allowed-exts", ".rst", "structure", "notes.rst"},
+		},
+		{
+			name: "denied extension without the sandbox",
+			args: []string{"--no-sandbox", "toc", "notes.rst"},
+		},
+		{
+			name:            "oversize file",
+			args:            []string{"--max-file-size", "1KB", "section", "large.md", "--section-path", "Large"},
+			expectedInError: "file too large",
+		},
+		{
+			name:            "oversize file in search",
+			args:            []string{"--max-file-size", "1KB", "search", "large.md", "--query", "Large"},
+			expectedInError: "file too large",
+		},
+		{
+			name: "oversize file without the sandbox",
+			args: []string{"--no-sandbox", "--max-file-size", "1KB", "stats", "large.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binaryPath, tt.args...)
+			cmd.Dir = tempDir
+			output, err := cmd.CombinedOutput()
+			if tt.expectedInError == "" {
+				if err != nil {
+					t.Fatalf("Command failed: %v\n%s", err, output)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected the command to fail, got output: %s", output)
+			}
+			if !strings.Contains(string(output), tt.expectedInError) {
+				t.Errorf("Expected error containing %q, got: %s", tt.expectedInError, output)
+			}
+		})
+	}
+}
+
+func TestCLIDepthCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	sampleFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	tests := []struct {
+		name          string
+		args          []string
+		stdin         string
+		maxLevel      int
+		sectionCounts map[string]int
+	}{
+		{
+			name:          "sample document",
+			args:          []string{"depth", sampleFile},
+			maxLevel:      4,
+			sectionCounts: map[string]int{"1": 1, "2": 3, "3": 6, "4": 2},
+		},
+		{
+			name:          "no headings",
+			args:          []string{"depth", "-"},
+			stdin:         "Just a paragraph.\n",
+			maxLevel:      0,
+			sectionCounts: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := cliCommand(binaryPath, tt.args...)
+			cmd.Stdin = strings.NewReader(tt.stdin)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+
+			var depth struct {
+				MaxLevel    int            `json:"max_level"`
+				LevelCounts map[string]int `json:"level_counts"`
+			}
+			if err := json.Unmarshal(output, &depth); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			if depth.MaxLevel != tt.maxLevel {
+				t.Errorf("Expected max_level %d, got %d", tt.maxLevel, depth.MaxLevel)
+			}
+			if depth.LevelCounts == nil || len(depth.LevelCounts) != len(tt.sectionCounts) {
+				t.Errorf("Expected level_counts %v, got %v", tt.sectionCounts, depth.LevelCounts)
+			}
+			for level, count := range tt.sectionCounts {
+				if depth.LevelCounts[level] != count {
+					t.Errorf("Expected %d sections at level %s, got %d", count, level, depth.LevelCounts[level])
+				}
+			}
+		})
+	}
+}
+
+func TestCLISchema(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	sampleFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+	document := "---\ntitle: Guide\ntags: [a, b]\n---\nSome intro.\n\n# Guide\n\n## Notes\n\n- [x] Done\n\n## Notes\n\nSee [the docs](https://example.com).\n"
+
+	tests := []struct {
+		name   string
+		schema string
+		args   []string
+		stdin  string
+	}{
+		{name: "structure", schema: "structure", args: []string{"structure", sampleFile}},
+		{name: "structure with front matter and preamble", schema: "structure", args: []string{"structure", "-"}, stdin: document},
+		{name: "flat structure", schema: "structure", args: []string{"structure", sampleFile, "--flat"}},
+		{name: "section", schema: "section", args: []string{"section", sampleFile, "--section-path", "Sample Document/Introduction", "--format", "json"}},
+		{name: "stats", schema: "stats", args: []string{"stats", "-"}, stdin: document},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schemaOutput, err := exec.Command(binaryPath, "schema", tt.schema).Output()
+			if err != nil {
+				t.Fatalf("Schema command failed: %v", err)
+			}
+			var schema map[string]interface{}
+			if err := json.Unmarshal(schemaOutput, &schema); err != nil {
+				t.Fatalf("Failed to parse schema: %v", err)
+			}
+
+			cmd := cliCommand(binaryPath, tt.args...)
+			cmd.Dir = projectRoot
+			cmd.Stdin = strings.NewReader(tt.stdin)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal(output, &value); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+
+			for _, problem := range validateJSONSchema(schema, schema, value, "$") {
+				t.Error(problem)
+			}
+		})
+	}
+
+	t.Run("unknown schema", func(t *testing.T) {
+		if err := exec.Command(binaryPath, "schema", "toc").Run(); err == nil {
+			t.Error("Expected an unknown schema to be an error")
+		}
+	})
+}
+
 func TestCLIErrorHandling(t *testing.T) {
 	projectRoot, binaryPath := setupTest(t)
 
@@ -357,12 +598,13 @@ func TestCLIErrorHandling(t *testing.T) {
 		args            []string
 		expectError     bool
 		expectedInError string
+		exitCode        int
 	}{
 		{
-			name:            "nonexistent file",
-			args:            []string{"structure", "nonexistent.md"},
-			expectError:     true,
-			expectedInError: "does not exist",
+			name:        "nonexistent file",
+			args:        []string{"structure", "nonexistent.md"},
+			expectError: true,
+			exitCode:    4,
 		},
 		{
 			name:            "missing section ID",
@@ -371,16 +613,16 @@ func TestCLIErrorHandling(t *testing.T) {
 			expectedInError: "required",
 		},
 		{
-			name:            "invalid section ID",
-			args:            []string{"section", filepath.Join(projectRoot, "tests", "fixtures", "sample.md"), "--section-id", "invalid"},
-			expectError:     true,
-			expectedInError: "section not found",
+			name:        "invalid section ID",
+			args:        []string{"section", filepath.Join(projectRoot, "tests", "fixtures", "sample.md"), "--section-id", "invalid"},
+			expectError: true,
+			exitCode:    4,
 		},
 		{
-			name:            "invalid format",
-			args:            []string{"section", filepath.Join(projectRoot, "tests", "fixtures", "sample.md"), "--section-id", "test", "--format", "invalid"},
-			expectError:     true,
-			expectedInError: "section not found",
+			name:        "invalid format",
+			args:        []string{"structure", filepath.Join(projectRoot, "tests", "fixtures", "sample.md"), "--format", "invalid"},
+			expectError: true,
+			exitCode:    5,
 		},
 		{
 			name:            "invalid max-depth",
@@ -404,7 +646,7 @@ func TestCLIErrorHandling(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			output, err := cmd.CombinedOutput()
 
 			if tt.expectError {
@@ -414,6 +656,9 @@ func TestCLIErrorHandling(t *testing.T) {
 				if tt.expectedInError != "" && !strings.Contains(string(output), tt.expectedInError) {
 					t.Errorf("Expected error message to contain '%s', got: %s", tt.expectedInError, string(output))
 				}
+				if exitErr, ok := err.(*exec.ExitError); ok && tt.exitCode != 0 && exitErr.ExitCode() != tt.exitCode {
+					t.Errorf("Expected exit status %d, got %d. Output: %s", tt.exitCode, exitErr.ExitCode(), string(output))
+				}
 			} else {
 				if err != nil {
 					t.Errorf("Expected success but got error: %v. Output: %s", err, string(output))
@@ -428,7 +673,7 @@ func TestCLIOutputFormats(t *testing.T) {
 	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
 
 	// Get a valid section ID first
-	cmd := exec.Command(binaryPath, "structure", testFile)
+	cmd := cliCommand(binaryPath, "structure", testFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Failed to get structure: %v", err)
@@ -490,7 +735,7 @@ func TestCLIOutputFormats(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := exec.Command(binaryPath, "section", testFile, "--section-id", sectionID, "--format", tt.format)
+			cmd := cliCommand(binaryPath, "section", testFile, "--section-id", sectionID, "--format", tt.format)
 			output, err := cmd.Output()
 			if err != nil {
 				t.Fatalf("Command failed: %v", err)
@@ -534,7 +779,7 @@ func TestCLIPerformance(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			start := time.Now()
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			_, err := cmd.Output()
 			elapsed := time.Since(start)
 
@@ -564,3 +809,81 @@ func checkMaxDepth(t *testing.T, sections []interface{}, maxDepth int) {
 		}
 	}
 }
+
+// validateJSONSchema checks value against the subset of JSON Schema the
+// schema command emits, returning a problem per mismatch
+func validateJSONSchema(root, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		defs := root["$defs"].(map[string]interface{})
+		return validateJSONSchema(root, defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{}), value, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, option := range anyOf {
+			if len(validateJSONSchema(root, option.(map[string]interface{}), value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{path + ": matches none of anyOf"}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "null":
+		if value != nil {
+			problems = append(problems, path+": expected null")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, path+": expected a boolean")
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			problems = append(problems, path+": expected an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, path+": expected a number")
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			problems = append(problems, path+": expected a string")
+		} else if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				problems = append(problems, path+": expected a date-time")
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(problems, path+": expected an array")
+		}
+		for i, item := range items {
+			problems = append(problems, validateJSONSchema(root, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(problems, path+": expected an object")
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := object[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing required property %s", path, name))
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range object {
+			if propertySchema, ok := properties[name]; ok {
+				problems = append(problems, validateJSONSchema(root, propertySchema.(map[string]interface{}), property, path+"."+name)...)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				problems = append(problems, validateJSONSchema(root, additional, property, path+"."+name)...)
+			} else if schema["additionalProperties"] == false {
+				problems = append(problems, fmt.Sprintf("%s: unexpected property %s", path, name))
+			}
+		}
+	}
+
+	return problems
+}
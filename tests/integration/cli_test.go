@@ -8,6 +8,9 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Helper function to get project root and build binary
@@ -36,6 +39,12 @@ func setupTest(t *testing.T) (string, string) {
 	return projectRoot, binaryPath
 }
 
+// cliCommand runs the binary as a standalone CLI with --no-sandbox, since
+// tests read fixtures and temporary files outside the working directory
+func cliCommand(binaryPath string, args ...string) *exec.Cmd {
+	return exec.Command(binaryPath, append([]string{"--no-sandbox"}, args...)...)
+}
+
 func TestCLIStructureCommand(t *testing.T) {
 	projectRoot, binaryPath := setupTest(t)
 
@@ -46,7 +55,7 @@ func TestCLIStructureCommand(t *testing.T) {
 	}
 
 	// Run structure command
-	cmd := exec.Command(binaryPath, "structure", testFile, "--pretty")
+	cmd := cliCommand(binaryPath, "structure", testFile, "--pretty")
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -82,7 +91,7 @@ func TestCLISectionCommand(t *testing.T) {
 	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
 
 	// First get the structure to find a section ID
-	structureOutput, err := exec.Command(binaryPath, "structure", testFile).Output()
+	structureOutput, err := cliCommand(binaryPath, "structure", testFile).Output()
 	if err != nil {
 		t.Fatalf("Failed to get structure: %v", err)
 	}
@@ -109,7 +118,7 @@ func TestCLISectionCommand(t *testing.T) {
 	}
 
 	// Run section command
-	sectionOutput, err := exec.Command(binaryPath, "section", testFile, "--section-id", sectionID).Output()
+	sectionOutput, err := cliCommand(binaryPath, "section", testFile, "--section-id", sectionID).Output()
 	if err != nil {
 		t.Fatalf("Section command failed: %v", err)
 	}
@@ -126,6 +135,73 @@ func TestCLISectionCommand(t *testing.T) {
 	}
 }
 
+func TestCLISectionWithMeta(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+	sectionPath := "Sample Document/Introduction/Background"
+
+	output, err := cliCommand(binaryPath, "section", testFile, "--section-path", sectionPath, "--with-meta").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	expected := "<!--\nid: section_d34c2b1aa51dcbe1\ntitle: Background\nlevel: 3\nlines: 9-12\nbreadcrumb: Sample Document > Introduction\n-->\n### Background\n"
+	if !strings.HasPrefix(string(output), expected) {
+		t.Errorf("Expected a metadata comment before the content, got %q", output)
+	}
+
+	output, err = cliCommand(binaryPath, "section", testFile, "--section-path", sectionPath, "--with-meta", "--format", "plain").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "# id: section_d34c2b1aa51dcbe1\n") || !strings.Contains(string(output), "# breadcrumb: Sample Document > Introduction\n\nBackground\n") {
+		t.Errorf("Expected a commented metadata header before the plain text, got %q", output)
+	}
+
+	// JSON carries the metadata with or without the flag
+	output, err = cliCommand(binaryPath, "section", testFile, "--section-path", sectionPath, "--format", "json").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	var sectionContent struct {
+		Level      int      `json:"level"`
+		StartLine  int      `json:"start_line"`
+		EndLine    int      `json:"end_line"`
+		Breadcrumb []string `json:"breadcrumb"`
+	}
+	if err := json.Unmarshal(output, &sectionContent); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	if sectionContent.Level != 3 || sectionContent.StartLine != 9 || sectionContent.EndLine != 12 || len(sectionContent.Breadcrumb) != 2 {
+		t.Errorf("Unexpected section metadata: %+v", sectionContent)
+	}
+}
+
+func TestCLISectionIncludePreamble(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	testFile := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(testFile, []byte("Some intro.\n\n# Guide\n\nText.\n\n## Install\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	output, err := cliCommand(binaryPath, "section", testFile, "--section-path", "Guide", "--include-preamble").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	if string(output) != "Some intro.\n\n# Guide\n\nText.\n" {
+		t.Errorf("Expected the preamble before the first section, got %q", output)
+	}
+
+	// Other sections have nothing to prepend
+	output, err = cliCommand(binaryPath, "section", testFile, "--section-path", "Guide/Install", "--include-preamble").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	if string(output) != "## Install\n" {
+		t.Errorf("Expected Install alone, got %q", output)
+	}
+}
+
 func TestCLIVersionCommand(t *testing.T) {
 	_, binaryPath := setupTest(t)
 
@@ -150,7 +226,7 @@ func TestCLIHelpCommand(t *testing.T) {
 	_, binaryPath := setupTest(t)
 
 	// Run help command
-	output, err := exec.Command(binaryPath, "--help").Output()
+	output, err := cliCommand(binaryPath, "--help").Output()
 	if err != nil {
 		t.Fatalf("Help command failed: %v", err)
 	}
@@ -176,7 +252,7 @@ func TestCLIInvalidFile(t *testing.T) {
 
 	// Run structure command with invalid file
 	binaryPath := filepath.Join("..", "..", "bin", "mdatlas")
-	cmd := exec.Command(binaryPath, "structure", "nonexistent.md")
+	cmd := cliCommand(binaryPath, "structure", "nonexistent.md")
 	cmd.Dir = filepath.Join("..", "..")
 
 	_, err := cmd.Output()
@@ -191,3 +267,684 @@ func TestCLIInvalidFile(t *testing.T) {
 		}
 	}
 }
+
+func TestCLITocCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	// Run toc command with default text output
+	output, err := cliCommand(binaryPath, "toc", testFile, "--max-depth", "2").Output()
+	if err != nil {
+		t.Fatalf("TOC command failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) == 0 || lines[0] != "Sample Document" {
+		t.Fatalf("Expected TOC to start with 'Sample Document', got %q", string(output))
+	}
+
+	if lines[1] != "  Introduction" {
+		t.Errorf("Expected indented 'Introduction', got %q", lines[1])
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "    ") {
+			t.Errorf("Expected no entries deeper than level 2, got %q", line)
+		}
+	}
+
+	// Run toc command with JSON output
+	output, err = cliCommand(binaryPath, "toc", testFile, "--format", "json").Output()
+	if err != nil {
+		t.Fatalf("TOC command failed: %v", err)
+	}
+
+	var toc []map[string]interface{}
+	if err := json.Unmarshal(output, &toc); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if len(toc) == 0 {
+		t.Error("Expected TOC entries in JSON output")
+	}
+}
+
+func TestCLISectionsCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	output, err := cliCommand(binaryPath, "sections", testFile, "--level", "2").Output()
+	if err != nil {
+		t.Fatalf("Sections command failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "2 Introduction (#section_") || !strings.HasPrefix(lines[2], "2 Conclusion (#section_") {
+		t.Errorf("Unexpected sections output: %q", output)
+	}
+
+	// No heading at the level is an empty list, not an error
+	output, err = cliCommand(binaryPath, "sections", testFile, "--level", "6", "--format", "json").Output()
+	if err != nil {
+		t.Fatalf("Sections command failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "[]" {
+		t.Errorf("Expected an empty JSON list, got %q", output)
+	}
+
+	if err := cliCommand(binaryPath, "sections", testFile, "--level", "0").Run(); err == nil {
+		t.Error("Expected an error for level 0")
+	}
+}
+
+func TestCLISearchCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	// Run search command with default plain output
+	output, err := cliCommand(binaryPath, "search", testFile, "--query", "details").Output()
+	if err != nil {
+		t.Fatalf("Search command failed: %v", err)
+	}
+
+	content := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(content, "3 Technical Details (#section_") || !strings.HasSuffix(content, " line 24)") {
+		t.Errorf("Unexpected plain search output: %q", content)
+	}
+
+	// Case-sensitive search should not match
+	output, err = cliCommand(binaryPath, "search", testFile, "--query", "details", "--case-sensitive", "--format", "json").Output()
+	if err != nil {
+		t.Fatalf("Search command failed: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if count, ok := result["count"].(float64); !ok || count != 0 {
+		t.Errorf("Expected no case-sensitive matches, got %v", result["count"])
+	}
+}
+
+func TestCLIStdinInput(t *testing.T) {
+	_, binaryPath := setupTest(t)
+	content := "# Piped\n\nBody\n\n## Child\n\nMore\n"
+
+	// Structure from stdin using "-"
+	cmd := cliCommand(binaryPath, "structure", "-")
+	cmd.Stdin = strings.NewReader(content)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Structure command failed: %v", err)
+	}
+
+	var structure map[string]interface{}
+	if err := json.Unmarshal(output, &structure); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if structure["file_path"] != "<stdin>" {
+		t.Errorf("Expected file_path '<stdin>', got %v", structure["file_path"])
+	}
+
+	if structure["total_chars"] != float64(len(content)) {
+		t.Errorf("Expected total_chars %d, got %v", len(content), structure["total_chars"])
+	}
+
+	if structure["total_lines"] != float64(7) {
+		t.Errorf("Expected total_lines 7, got %v", structure["total_lines"])
+	}
+
+	// TOC from piped stdin without a file argument
+	cmd = cliCommand(binaryPath, "toc")
+	cmd.Stdin = strings.NewReader(content)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("TOC command failed: %v", err)
+	}
+
+	if string(output) != "Piped\n  Child\n" {
+		t.Errorf("Unexpected TOC output: %q", string(output))
+	}
+
+	// Section from stdin
+	cmd = cliCommand(binaryPath, "--id-style", "slug", "section", "-", "--section-id", "child")
+	cmd.Stdin = strings.NewReader(content)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+
+	if string(output) != "## Child\n\nMore\n" {
+		t.Errorf("Unexpected section output: %q", string(output))
+	}
+}
+
+func TestCLIStatsCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "complex.md")
+
+	output, err := cliCommand(binaryPath, "stats", testFile).Output()
+	if err != nil {
+		t.Fatalf("Stats command failed: %v", err)
+	}
+
+	var stats map[string]interface{}
+	if err := json.Unmarshal(output, &stats); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	for _, field := range []string{"section_count", "word_count", "code_block_count", "link_count", "image_count", "table_count"} {
+		if _, ok := stats[field]; !ok {
+			t.Errorf("Expected %s in stats output", field)
+		}
+	}
+
+	if stats["table_count"] != float64(1) {
+		t.Errorf("Expected 1 table in complex.md, got %v", stats["table_count"])
+	}
+}
+
+func TestCLILinesCommand(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	output, err := cliCommand(binaryPath, "lines", testFile, "--start", "1", "--end", "1").Output()
+	if err != nil {
+		t.Fatalf("Lines command failed: %v", err)
+	}
+
+	expected := "<!-- lines 1-1 of 52 -->\n# Sample Document\n"
+	if string(output) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(output))
+	}
+
+	// A range past the end of the file is clamped and reported in the header
+	output, err = cliCommand(binaryPath, "lines", testFile, "--start", "52", "--end", "100").Output()
+	if err != nil {
+		t.Fatalf("Lines command failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "<!-- lines 52-52 of 52 -->") {
+		t.Errorf("Expected clamped header, got %q", string(output))
+	}
+
+	if err := cliCommand(binaryPath, "lines", testFile, "--start", "5", "--end", "2").Run(); err == nil {
+		t.Error("Expected error for start after end")
+	}
+
+	// Line numbers start at 1; --end 0 alone means the end of the file
+	for _, args := range [][]string{{"--start", "0"}, {"--start", "-1"}, {"--end", "-1"}} {
+		if err := cliCommand(binaryPath, append([]string{"lines", testFile}, args...)...).Run(); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestCLIStructureDiskCache(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	tempDir := t.TempDir()
+	cacheDir := filepath.Join(tempDir, "cache")
+	testFile := filepath.Join(tempDir, "doc.md")
+	if err := os.WriteFile(testFile, []byte("# Original Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	structureTitle := func() string {
+		t.Helper()
+		output, err := cliCommand(binaryPath, "structure", testFile, "--cache-dir", cacheDir).Output()
+		if err != nil {
+			t.Fatalf("Structure command failed: %v", err)
+		}
+		var structure map[string]interface{}
+		if err := json.Unmarshal(output, &structure); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v", err)
+		}
+		sections := structure["structure"].([]interface{})
+		return sections[0].(map[string]interface{})["title"].(string)
+	}
+
+	if title := structureTitle(); title != "Original Title" {
+		t.Fatalf("Expected Original Title, got %q", title)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one cache entry, got %v (%v)", entries, err)
+	}
+
+	// Edit the cached entry; a second process must return it without reparsing
+	data, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatalf("Failed to read cache entry: %v", err)
+	}
+	data = []byte(strings.Replace(string(data), "Original Title", "Cached Title", 1))
+	if err := os.WriteFile(entries[0], data, 0644); err != nil {
+		t.Fatalf("Failed to write cache entry: %v", err)
+	}
+	if title := structureTitle(); title != "Cached Title" {
+		t.Errorf("Expected structure from the disk cache, got %q", title)
+	}
+
+	// Changing the file invalidates the entry
+	if err := os.WriteFile(testFile, []byte("# Updated Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if title := structureTitle(); title != "Updated Title" {
+		t.Errorf("Expected reparse after file change, got %q", title)
+	}
+}
+
+func TestCLIStructureWatch(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	testFile := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(testFile, []byte("# First Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	cmd := cliCommand(binaryPath, "structure", testFile, "--watch")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to get stdout: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	titles := make(chan string)
+	go func() {
+		decoder := json.NewDecoder(stdout)
+		for {
+			var structure struct {
+				Structure []struct {
+					Title string `json:"title"`
+				} `json:"structure"`
+			}
+			if err := decoder.Decode(&structure); err != nil {
+				close(titles)
+				return
+			}
+			if len(structure.Structure) > 0 {
+				titles <- structure.Structure[0].Title
+			}
+		}
+	}()
+
+	expectTitle := func(expected string) {
+		t.Helper()
+		select {
+		case title, ok := <-titles:
+			if !ok {
+				t.Fatalf("Watch exited before printing %q", expected)
+			}
+			if title != expected {
+				t.Fatalf("Expected %q, got %q", expected, title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %q", expected)
+		}
+	}
+
+	expectTitle("First Title")
+
+	if err := os.WriteFile(testFile, []byte("# Second Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	expectTitle("Second Title")
+
+	// A deleted and recreated file is picked up again
+	if err := os.Remove(testFile); err != nil {
+		t.Fatalf("Failed to remove document: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if err := os.WriteFile(testFile, []byte("# Third Title\n\nBody\n"), 0644); err != nil {
+		t.Fatalf("Failed to recreate document: %v", err)
+	}
+	expectTitle("Third Title")
+}
+
+func TestCLIStructureOutlineFormat(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	testFile := filepath.Join(t.TempDir(), "doc.md")
+	content := "# Guide\n\n## Install\n\n### Linux\n\n#### Packages\n\n## Usage\n"
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	output, err := cliCommand(binaryPath, "structure", testFile, "--format", "outline").Output()
+	if err != nil {
+		t.Fatalf("Structure command failed: %v", err)
+	}
+
+	// Each bullet is indented two spaces per heading level below the first
+	levels := map[string]int{"Guide": 1, "Install": 2, "Linux": 3, "Packages": 4, "Usage": 2}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) != len(levels) {
+		t.Fatalf("Expected %d outline lines, got %q", len(levels), output)
+	}
+	for _, line := range lines {
+		title := strings.TrimPrefix(strings.TrimLeft(line, " "), "- ")
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		level, ok := levels[title]
+		if !ok {
+			t.Fatalf("Unexpected outline line %q", line)
+		}
+		if indent != 2*(level-1) {
+			t.Errorf("Expected %q to be indented %d spaces, got %d", title, 2*(level-1), indent)
+		}
+	}
+
+	// --max-depth applies to the outline too
+	output, err = cliCommand(binaryPath, "structure", testFile, "--format", "headings", "--max-depth", "2").Output()
+	if err != nil {
+		t.Fatalf("Structure command failed: %v", err)
+	}
+	expected := "# Guide\n## Install\n## Usage\n"
+	if string(output) != expected {
+		t.Errorf("Expected headings %q, got %q", expected, output)
+	}
+
+	if err := cliCommand(binaryPath, "structure", testFile, "--format", "xml").Run(); err == nil {
+		t.Error("Expected unsupported format to fail")
+	}
+}
+
+func TestCLIIgnoreLevels(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	testFile := filepath.Join(t.TempDir(), "doc.md")
+	content := "# Guide\n\n### Linux\n\n##### Note\n\nText.\n\n###### Label\n\n## Usage\n"
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	output, err := cliCommand(binaryPath, "structure", testFile, "--format", "headings", "--ignore-levels", "5,6").Output()
+	if err != nil {
+		t.Fatalf("Structure command failed: %v", err)
+	}
+	expected := "# Guide\n### Linux\n## Usage\n"
+	if string(output) != expected {
+		t.Errorf("Expected headings %q, got %q", expected, output)
+	}
+
+	// The ignored headings are part of the Linux section's content
+	output, err = cliCommand(binaryPath, "section", testFile, "--section-path", "Guide/Linux", "--ignore-levels", "5,6").Output()
+	if err != nil {
+		t.Fatalf("Section command failed: %v", err)
+	}
+	if !strings.Contains(string(output), "##### Note") || !strings.Contains(string(output), "###### Label") {
+		t.Errorf("Expected the ignored headings in the section content, got %q", output)
+	}
+
+	if err := cliCommand(binaryPath, "structure", testFile, "--ignore-levels", "7").Run(); err == nil {
+		t.Error("Expected an error for ignore level 7")
+	}
+}
+
+func TestCLIYAMLFormat(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	tests := []struct {
+		name string
+		args []string
+		keys []string
+	}{
+		{
+			name: "structure",
+			args: []string{"structure", testFile, "--format", "yaml"},
+			keys: []string{"file_path", "total_chars", "total_lines", "structure", "last_modified"},
+		},
+		{
+			name: "stats",
+			args: []string{"stats", testFile, "--format", "yaml"},
+			keys: []string{"file_path", "section_count", "level_counts", "code_block_count", "last_modified"},
+		},
+		{
+			name: "section",
+			args: []string{"section", testFile, "--section-path", "Sample Document/Introduction", "--format", "yaml"},
+			keys: []string{"id", "title", "content", "format", "include_children"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := cliCommand(binaryPath, tt.args...).Output()
+			if err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+
+			var result map[string]interface{}
+			if err := yaml.Unmarshal(output, &result); err != nil {
+				t.Fatalf("Failed to parse YAML output: %v\n%s", err, output)
+			}
+
+			// YAML keys match the JSON field names
+			for _, key := range tt.keys {
+				if _, exists := result[key]; !exists {
+					t.Errorf("Expected key %q in YAML output, got %v", key, result)
+				}
+			}
+		})
+	}
+}
+
+func TestCLIDiffCommand(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	tempDir := t.TempDir()
+	oldFile := filepath.Join(tempDir, "old.md")
+	newFile := filepath.Join(tempDir, "new.md")
+	if err := os.WriteFile(oldFile, []byte("# Guide\n\n## Install\n\n## Legacy\n"), 0644); err != nil {
+		t.Fatalf("Failed to write old document: %v", err)
+	}
+	if err := os.WriteFile(newFile, []byte("# Guide\n\n## Install\n\n## Usage\n"), 0644); err != nil {
+		t.Fatalf("Failed to write new document: %v", err)
+	}
+
+	output, err := cliCommand(binaryPath, "diff", oldFile, newFile).Output()
+	if err != nil {
+		t.Fatalf("Diff command failed: %v", err)
+	}
+
+	var diff struct {
+		Added   []struct{ Path string } `json:"added"`
+		Removed []struct{ Path string } `json:"removed"`
+		Changed []interface{}           `json:"changed"`
+	}
+	if err := json.Unmarshal(output, &diff); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Path != "Guide/Usage" {
+		t.Errorf("Expected Guide/Usage to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "Guide/Legacy" {
+		t.Errorf("Expected Guide/Legacy to be removed, got %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 0 {
+		t.Errorf("Expected no changed sections, got %v", diff.Changed)
+	}
+
+	if err := cliCommand(binaryPath, "diff", oldFile).Run(); err == nil {
+		t.Error("Expected diff with one file to fail")
+	}
+}
+
+func TestCLILintCommand(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	tempDir := t.TempDir()
+	cleanFile := filepath.Join(tempDir, "clean.md")
+	badFile := filepath.Join(tempDir, "bad.md")
+	if err := os.WriteFile(cleanFile, []byte("# Title\n\n## Section\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+	if err := os.WriteFile(badFile, []byte("# Title\n\n### Deep\n\n## Notes\n\n## Notes\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	if output, err := cliCommand(binaryPath, "lint", cleanFile).CombinedOutput(); err != nil {
+		t.Errorf("Expected clean document to pass, got %v: %s", err, output)
+	}
+
+	cmd := cliCommand(binaryPath, "lint", badFile)
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("Expected lint to exit with status 2, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 issues, got %q", output)
+	}
+	if !strings.HasPrefix(lines[0], badFile+":3: skipped_level:") {
+		t.Errorf("Expected skipped level on line 3, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], badFile+":7: duplicate_title:") {
+		t.Errorf("Expected duplicate title on line 7, got %q", lines[1])
+	}
+}
+
+func TestCLIOutputFlag(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+	outFile := filepath.Join(t.TempDir(), "out.json")
+
+	output, err := cliCommand(binaryPath, "structure", testFile, "-o", outFile).Output()
+	if err != nil {
+		t.Fatalf("Structure command failed: %v", err)
+	}
+	if len(output) != 0 {
+		t.Errorf("Expected nothing on stdout with --output, got %q", output)
+	}
+
+	written, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	var structure map[string]interface{}
+	if err := json.Unmarshal(written, &structure); err != nil {
+		t.Fatalf("Failed to parse output file: %v", err)
+	}
+
+	// A failing command leaves the previous output in place
+	if err := cliCommand(binaryPath, "section", testFile, "--section-id", "missing", "--output", outFile).Run(); err == nil {
+		t.Fatal("Expected section command to fail for a missing section")
+	}
+	if after, err := os.ReadFile(outFile); err != nil || string(after) != string(written) {
+		t.Errorf("Expected output file to be unchanged after a failure, got %q (%v)", after, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(outFile))
+	if err != nil {
+		t.Fatalf("Failed to list output directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the output file, found %d entries", len(entries))
+	}
+
+	// "-" writes to stdout
+	output, err = cliCommand(binaryPath, "toc", testFile, "-o", "-").Output()
+	if err != nil {
+		t.Fatalf("TOC command failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "Sample Document") {
+		t.Errorf("Expected TOC on stdout, got %q", output)
+	}
+}
+
+func TestCLIQuietAndExitCodes(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	exitCode := func(err error) int {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		if err != nil {
+			t.Fatalf("Failed to run command: %v", err)
+		}
+		return 0
+	}
+
+	output, err := cliCommand(binaryPath, "structure", testFile, "--quiet").Output()
+	if code := exitCode(err); code != 0 {
+		t.Errorf("Expected status 0, got %d", code)
+	}
+	if len(output) != 0 {
+		t.Errorf("Expected no output with --quiet, got %q", output)
+	}
+
+	badFile := filepath.Join(t.TempDir(), "bad.md")
+	if err := os.WriteFile(badFile, []byte("# Title\n\n### Deep\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+	output, err = cliCommand(binaryPath, "lint", badFile, "-q").Output()
+	if code := exitCode(err); code != 2 {
+		t.Errorf("Expected status 2 for lint issues, got %d", code)
+	}
+	if len(output) != 0 {
+		t.Errorf("Expected no output with -q, got %q", output)
+	}
+
+	_, err = cliCommand(binaryPath, "structure", "nonexistent.md").Output()
+	if code := exitCode(err); code != 4 {
+		t.Errorf("Expected status 4 for a missing file, got %d", code)
+	}
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("Skipping unreadable file check: permissions are not enforced")
+	}
+	lockedFile := filepath.Join(t.TempDir(), "locked.md")
+	if err := os.WriteFile(lockedFile, []byte("# Locked\n"), 0000); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+	_, err = cliCommand(binaryPath, "structure", lockedFile).Output()
+	if code := exitCode(err); code != 3 {
+		t.Errorf("Expected status 3 for an unreadable file, got %d", code)
+	}
+}
+
+func TestCLIProfileFlags(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	testFile := filepath.Join(projectRoot, "tests", "fixtures", "sample.md")
+
+	expected, err := cliCommand(binaryPath, "toc", testFile).Output()
+	if err != nil {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+
+	profileDir := t.TempDir()
+	cpuProfile := filepath.Join(profileDir, "cpu.prof")
+	memProfile := filepath.Join(profileDir, "mem.prof")
+	output, err := cliCommand(binaryPath, "--cpuprofile", cpuProfile, "--memprofile", memProfile, "toc", testFile).Output()
+	if err != nil {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+
+	// Profiling leaves the output as it is
+	if string(output) != string(expected) {
+		t.Errorf("Expected the same output with profiling, got %s", output)
+	}
+	for _, profile := range []string{cpuProfile, memProfile} {
+		if stat, err := os.Stat(profile); err != nil || stat.Size() == 0 {
+			t.Errorf("Expected a profile written to %s, got %v", profile, err)
+		}
+	}
+
+	help, err := exec.Command(binaryPath, "--help").Output()
+	if err != nil {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if strings.Contains(string(help), "profile") {
+		t.Error("Expected the profiling flags to be hidden from the help")
+	}
+}
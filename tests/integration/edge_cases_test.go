@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -22,7 +21,7 @@ func TestEdgeCasesEmptyFile(t *testing.T) {
 	defer os.Remove(emptyFile)
 
 	// Test structure command with empty file
-	cmd := exec.Command(binaryPath, "structure", emptyFile)
+	cmd := cliCommand(binaryPath, "structure", emptyFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -46,8 +45,9 @@ func TestEdgeCasesEmptyFile(t *testing.T) {
 		t.Errorf("Expected 0 total_chars, got %v", structure["total_chars"])
 	}
 
-	if structure["total_lines"].(float64) != 1 {
-		t.Errorf("Expected 1 total_lines, got %v", structure["total_lines"])
+	// An empty file has no lines
+	if structure["total_lines"].(float64) != 0 {
+		t.Errorf("Expected 0 total_lines, got %v", structure["total_lines"])
 	}
 }
 
@@ -82,7 +82,7 @@ But no headings at all.`
 	defer os.Remove(noHeadingsFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", noHeadingsFile)
+	cmd := cliCommand(binaryPath, "structure", noHeadingsFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -142,7 +142,7 @@ Mixed language content.`
 	defer os.Remove(unicodeFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", unicodeFile)
+	cmd := cliCommand(binaryPath, "structure", unicodeFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -190,7 +190,7 @@ func TestEdgeCasesVeryLongLines(t *testing.T) {
 	defer os.Remove(longFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", longFile)
+	cmd := cliCommand(binaryPath, "structure", longFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -239,7 +239,7 @@ Content at level 6.
 	defer os.Remove(deepFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", deepFile)
+	cmd := cliCommand(binaryPath, "structure", deepFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -289,7 +289,7 @@ Content with special characters.`
 	defer os.Remove(specialFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", specialFile)
+	cmd := cliCommand(binaryPath, "structure", specialFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -331,7 +331,7 @@ Content with various formatting.`
 	defer os.Remove(formattedFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", formattedFile)
+	cmd := cliCommand(binaryPath, "structure", formattedFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -382,7 +382,7 @@ func TestEdgeCasesFilePermissions(t *testing.T) {
 	}()
 
 	// Test structure command with unreadable file
-	cmd := exec.Command(binaryPath, "structure", restrictedFile)
+	cmd := cliCommand(binaryPath, "structure", restrictedFile)
 	_, err := cmd.Output()
 	if err == nil {
 		t.Error("Expected error for unreadable file")
@@ -413,7 +413,7 @@ func TestEdgeCasesLargeNumberOfSections(t *testing.T) {
 	defer os.Remove(manyFile)
 
 	// Test structure command
-	cmd := exec.Command(binaryPath, "structure", manyFile)
+	cmd := cliCommand(binaryPath, "structure", manyFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Command failed: %v", err)
@@ -454,7 +454,7 @@ func TestEdgeCasesInvalidSectionID(t *testing.T) {
 
 	for _, invalidID := range invalidIDs {
 		t.Run(fmt.Sprintf("invalid_id_%s", invalidID), func(t *testing.T) {
-			cmd := exec.Command(binaryPath, "section", testFile, "--section-id", invalidID)
+			cmd := cliCommand(binaryPath, "section", testFile, "--section-id", invalidID)
 			_, err := cmd.Output()
 			if err == nil {
 				t.Errorf("Expected error for invalid section ID: %s", invalidID)
@@ -480,3 +480,43 @@ func checkForLevel(section map[string]interface{}, targetLevel int) bool {
 
 	return false
 }
+
+func TestEdgeCasesLineEndings(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	structureOf := func(file string, args ...string) map[string]interface{} {
+		cmd := cliCommand(binaryPath, append([]string{"structure", filepath.Join(projectRoot, "tests", "fixtures", file)}, args...)...)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("structure %s failed: %v", file, err)
+		}
+		var structure map[string]interface{}
+		if err := json.Unmarshal(output, &structure); err != nil {
+			t.Fatalf("Failed to parse JSON: %v", err)
+		}
+		return structure
+	}
+
+	for _, file := range []string{"eol_lf.md", "eol_crlf.md", "eol_cr.md"} {
+		t.Run(file, func(t *testing.T) {
+			structure := structureOf(file)
+			if structure["total_lines"] != float64(11) {
+				t.Errorf("Expected 11 lines, got %v", structure["total_lines"])
+			}
+
+			cmd := cliCommand(binaryPath, "section", filepath.Join(projectRoot, "tests", "fixtures", file), "--section-path", "Line Endings/Second")
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("section failed: %v", err)
+			}
+			if string(output) != "## Second\n\nSecond body.\n" {
+				t.Errorf("Expected the section with LF line endings, got %q", output)
+			}
+		})
+	}
+
+	// Without normalization a CR-only file has a single line
+	if structure := structureOf("eol_cr.md", "--normalize-eol=false"); structure["total_lines"] != float64(1) {
+		t.Errorf("Expected 1 line without --normalize-eol, got %v", structure["total_lines"])
+	}
+}
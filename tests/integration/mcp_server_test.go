@@ -3,9 +3,15 @@ package integration
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -94,6 +100,18 @@ func TestMCPServerInitialization(t *testing.T) {
 		t.Errorf("Expected protocol version 2024-11-05, got %v", result["protocolVersion"])
 	}
 
+	// The server reports the same build as the version command
+	versionOutput, err := exec.Command(binaryPath, "version").Output()
+	if err != nil {
+		t.Fatalf("Version command failed: %v", err)
+	}
+	serverInfo := result["serverInfo"].(map[string]interface{})
+	meta, _ := result["_meta"].(map[string]interface{})
+	expected := fmt.Sprintf("mdatlas version %v\nBuild date: %v\n", serverInfo["version"], meta["buildDate"])
+	if string(versionOutput) != expected {
+		t.Errorf("Expected server info to match %q, got %q", versionOutput, expected)
+	}
+
 	// Clean up
 	stdin.Close()
 	cmd.Process.Kill()
@@ -212,63 +230,159 @@ func TestMCPServerToolsCall(t *testing.T) {
 			},
 		},
 		{
-			name:     "search_markdown_content",
-			toolName: "search_markdown_content",
+			name:     "get_markdown_structure with min_level",
+			toolName: "get_markdown_structure",
 			args: map[string]interface{}{
 				"file_path": "sample.md",
-				"query":     "Introduction",
+				"min_level": 2,
+				"max_depth": 2,
 			},
 			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
 				toolResult := result.(map[string]interface{})
 				content := toolResult["content"].([]interface{})
-				if len(content) == 0 {
-					t.Error("Expected content in tool result")
+				var structure struct {
+					Structure []struct {
+						Level    int           `json:"level"`
+						Title    string        `json:"title"`
+						Children []interface{} `json:"children"`
+					} `json:"structure"`
 				}
-
-				// Parse the JSON content
-				firstContent := content[0].(map[string]interface{})
-				var searchResult map[string]interface{}
-				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &searchResult); err != nil {
-					t.Fatalf("Failed to parse search result JSON: %v", err)
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &structure); err != nil {
+					t.Fatalf("Failed to parse structure JSON: %v", err)
+				}
+				// The H1 title is dropped and its H2 sections become roots
+				if len(structure.Structure) != 3 {
+					t.Fatalf("Expected 3 H2 roots, got %+v", structure.Structure)
+				}
+				for _, root := range structure.Structure {
+					if root.Level != 2 || len(root.Children) != 0 {
+						t.Errorf("Expected an H2 root without children, got %+v", root)
+					}
+				}
+			},
+		},
+		{
+			name:     "get_markdown_structure min_level deeper than max_depth",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"min_level": 3,
+				"max_depth": 2,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_structure with ignore_levels",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path":     "sample.md",
+				"ignore_levels": []int{3},
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var structure struct {
+					Structure []struct {
+						Children []struct {
+							Title    string        `json:"title"`
+							EndLine  int           `json:"end_line"`
+							Children []interface{} `json:"children"`
+						} `json:"children"`
+					} `json:"structure"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &structure); err != nil {
+					t.Fatalf("Failed to parse structure JSON: %v", err)
 				}
 
-				if searchResult["query"] != "Introduction" {
-					t.Error("Expected query to be 'Introduction'")
+				// The H3 headings stay in the body of their H2 sections
+				introduction := structure.Structure[0].Children[0]
+				if introduction.Title != "Introduction" || len(introduction.Children) != 0 || introduction.EndLine != 19 {
+					t.Errorf("Expected Introduction to span its former subsections, got %+v", introduction)
 				}
 			},
 		},
 		{
-			name:     "get_markdown_stats",
-			toolName: "get_markdown_stats",
+			name:     "get_markdown_structure no_counts",
+			toolName: "get_markdown_structure",
 			args: map[string]interface{}{
 				"file_path": "sample.md",
+				"no_counts": true,
 			},
 			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
 				toolResult := result.(map[string]interface{})
 				content := toolResult["content"].([]interface{})
-				if len(content) == 0 {
-					t.Error("Expected content in tool result")
+				var structure struct {
+					Structure []struct {
+						CharCount int `json:"char_count"`
+						LineCount int `json:"line_count"`
+						StartLine int `json:"start_line"`
+						EndLine   int `json:"end_line"`
+					} `json:"structure"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &structure); err != nil {
+					t.Fatalf("Failed to parse structure JSON: %v", err)
 				}
 
-				// Parse the JSON content
-				firstContent := content[0].(map[string]interface{})
-				var stats map[string]interface{}
-				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &stats); err != nil {
-					t.Fatalf("Failed to parse stats JSON: %v", err)
+				section := structure.Structure[0]
+				if section.CharCount != 0 || section.LineCount != 0 {
+					t.Errorf("Expected zero counts, got %+v", section)
+				}
+				if section.StartLine != 1 || section.EndLine != 52 {
+					t.Errorf("Expected the line range to be kept, got %+v", section)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_structure flat",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"flat":      true,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var structure struct {
+					Structure []struct {
+						ID       string        `json:"id"`
+						Title    string        `json:"title"`
+						ParentID string        `json:"parent_id"`
+						Children []interface{} `json:"children"`
+					} `json:"structure"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &structure); err != nil {
+					t.Fatalf("Failed to parse structure JSON: %v", err)
 				}
 
-				if stats["total_chars"] == nil {
-					t.Error("Expected total_chars in stats")
+				if len(structure.Structure) != 12 {
+					t.Fatalf("Expected all 12 sections in the flat list, got %d", len(structure.Structure))
+				}
+				if first := structure.Structure[0]; first.Title != "Sample Document" || first.ParentID != "" {
+					t.Errorf("Expected the top-level section first without a parent, got %+v", first)
+				}
+				for _, section := range structure.Structure {
+					if len(section.Children) != 0 {
+						t.Errorf("Expected %q to have no children in the flat list", section.Title)
+					}
+					if section.Title == "Background" && section.ParentID != "section_0be89c366744b2c8" {
+						t.Errorf("Expected Background to link to Introduction, got parent %q", section.ParentID)
+					}
 				}
 			},
 		},
 		{
-			name:     "get_markdown_toc",
-			toolName: "get_markdown_toc",
+			name:     "search_markdown_content",
+			toolName: "search_markdown_content",
 			args: map[string]interface{}{
 				"file_path": "sample.md",
+				"query":     "Introduction",
 			},
 			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
@@ -280,224 +394,2122 @@ func TestMCPServerToolsCall(t *testing.T) {
 
 				// Parse the JSON content
 				firstContent := content[0].(map[string]interface{})
-				var toc map[string]interface{}
-				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &toc); err != nil {
-					t.Fatalf("Failed to parse TOC JSON: %v", err)
+				var searchResult map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &searchResult); err != nil {
+					t.Fatalf("Failed to parse search result JSON: %v", err)
 				}
 
-				if toc["toc"] == nil {
-					t.Error("Expected toc in result")
+				if searchResult["query"] != "Introduction" {
+					t.Error("Expected query to be 'Introduction'")
 				}
 			},
 		},
 		{
-			name:     "invalid tool",
-			toolName: "invalid_tool",
+			name:     "get_markdown_section with context lines",
+			toolName: "get_markdown_section",
 			args: map[string]interface{}{
-				"file_path": "sample.md",
+				"file_path":     "sample.md",
+				"section_id":    "section_d34c2b1aa51dcbe1",
+				"context_lines": 1,
 			},
-			expectError: true,
+			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
 				toolResult := result.(map[string]interface{})
-				if toolResult["isError"] != true {
-					t.Error("Expected isError to be true for invalid tool")
+				content := toolResult["content"].([]interface{})
+				text := content[0].(map[string]interface{})["text"].(string)
+				if text != "\n### Background\n\nHere we explain the background context.\n\n### Objectives" {
+					t.Errorf("Expected a line of context on each side, got %q", text)
+				}
+
+				lineRange := toolResult["structuredContent"].(map[string]interface{})
+				if lineRange["start_line"] != float64(8) || lineRange["end_line"] != float64(13) {
+					t.Errorf("Expected lines 8-13 to be reported, got %v", lineRange)
 				}
 			},
 		},
 		{
-			name:        "missing file_path",
-			toolName:    "get_markdown_structure",
-			args:        map[string]interface{}{},
-			expectError: true,
+			name:     "get_markdown_section include_preamble without preamble",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":        "sample.md",
+				"section_id":       "section_6f4ebc1d5b6b68df",
+				"include_preamble": true,
+			},
+			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
 				toolResult := result.(map[string]interface{})
-				if toolResult["isError"] != true {
-					t.Error("Expected isError to be true for missing file_path")
+				lineRange := toolResult["structuredContent"].(map[string]interface{})
+				if lineRange["start_line"] != float64(1) || lineRange["end_line"] != float64(4) {
+					t.Errorf("Expected the first section's own lines 1-4, got %v", lineRange)
 				}
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			params := map[string]interface{}{
-				"name":      tt.toolName,
-				"arguments": tt.args,
-			}
-
-			paramsJSON, _ := json.Marshal(params)
-			response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
-				JSONRPC: "2.0",
-				ID:      1,
-				Method:  "tools/call",
-				Params:  paramsJSON,
-			})
-
-			if tt.expectError {
-				// For tool errors, the MCP response should be successful but the tool result should indicate error
-				if response.Error != nil {
-					t.Errorf("Expected no MCP error, got %v", response.Error)
-				}
-			} else {
-				if response.Error != nil {
-					t.Errorf("Expected no error, got %v", response.Error)
-				}
-			}
-
-			if tt.validate != nil {
-				tt.validate(t, response.Result)
-			}
-		})
-	}
-}
-
-func TestMCPServerResourcesList(t *testing.T) {
-	projectRoot, binaryPath := setupTest(t)
-
-	response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
-		JSONRPC: "2.0",
-		ID:      1,
-		Method:  "resources/list",
-	})
-
-	if response.Error != nil {
-		t.Fatalf("Expected no error, got %v", response.Error)
-	}
-
-	result, ok := response.Result.(map[string]interface{})
-	if !ok {
-		t.Fatalf("Expected result to be an object")
-	}
-
-	resources, ok := result["resources"].([]interface{})
-	if !ok {
-		t.Fatalf("Expected resources to be an array")
-	}
-
-	// Should have resources for our test files
-	if len(resources) == 0 {
-		t.Error("Expected at least one resource")
-	}
-
-	// Check that resources have proper structure
-	for _, resource := range resources {
-		resourceMap := resource.(map[string]interface{})
-		if resourceMap["uri"] == "" {
-			t.Error("Expected resource to have URI")
-		}
-		if resourceMap["name"] == "" {
-			t.Error("Expected resource to have name")
-		}
-	}
-}
-
-func TestMCPServerResourcesRead(t *testing.T) {
-	projectRoot, binaryPath := setupTest(t)
-
-	tests := []struct {
-		name        string
-		uri         string
-		expectError bool
-		validate    func(t *testing.T, result interface{})
-	}{
 		{
-			name:        "structure resource",
-			uri:         "markdown://file/sample.md/structure",
+			name:     "get_markdown_section max_chars",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"section_id": "section_d34c2b1aa51dcbe1",
+				"max_chars":  20,
+			},
 			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
-				resourceResult := result.(map[string]interface{})
-				contents := resourceResult["contents"].([]interface{})
-				if len(contents) == 0 {
-					t.Error("Expected contents in resource result")
-				}
-
-				firstContent := contents[0].(map[string]interface{})
-				if firstContent["type"] != "text" {
-					t.Error("Expected content type to be 'text'")
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if text := content[0].(map[string]interface{})["text"].(string); text != "### Background\n\nHere" {
+					t.Errorf("Expected the first 20 bytes of the section, got %q", text)
 				}
 
-				// Parse the JSON content
-				var structure map[string]interface{}
-				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &structure); err != nil {
-					t.Fatalf("Failed to parse structure JSON: %v", err)
+				structured := toolResult["structuredContent"].(map[string]interface{})
+				if structured["truncated"] != true || structured["original_char_count"] != float64(len("### Background\n\nHere we explain the background context.\n")) {
+					t.Errorf("Expected the content to be reported as truncated, got %v", structured)
 				}
-
-				if structure["file_path"] == "" {
-					t.Error("Expected file_path in structure")
+				if structured["start_line"] != float64(9) || structured["end_line"] != float64(11) {
+					t.Errorf("Expected lines 9-11 to be reported, got %v", structured)
 				}
 			},
 		},
 		{
-			name:        "content resource",
-			uri:         "markdown://file/sample.md/content",
+			name:     "get_markdown_section max_chars at paragraph",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":             "sample.md",
+				"section_id":            "section_d34c2b1aa51dcbe1",
+				"max_chars":             30,
+				"truncate_at_paragraph": true,
+				"format":                "json",
+			},
 			expectError: false,
 			validate: func(t *testing.T, result interface{}) {
-				resourceResult := result.(map[string]interface{})
-				contents := resourceResult["contents"].([]interface{})
-				if len(contents) == 0 {
-					t.Error("Expected contents in resource result")
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var sectionContent struct {
+					Content   string `json:"content"`
+					Truncated bool   `json:"truncated"`
 				}
-
-				firstContent := contents[0].(map[string]interface{})
-				if firstContent["type"] != "text" {
-					t.Error("Expected content type to be 'text'")
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &sectionContent); err != nil {
+					t.Fatalf("Failed to parse section JSON: %v", err)
 				}
-
-				text := firstContent["text"].(string)
-				if !strings.Contains(text, "#") {
-					t.Error("Expected markdown content to contain headers")
+				if sectionContent.Content != "### Background\n" || !sectionContent.Truncated {
+					t.Errorf("Expected the content cut after the heading paragraph, got %+v", sectionContent)
 				}
 			},
 		},
 		{
-			name:        "invalid resource",
-			uri:         "markdown://file/nonexistent.md/structure",
+			name:     "get_markdown_section invalid max_chars",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"section_id": "section_d34c2b1aa51dcbe1",
+				"max_chars":  0,
+			},
 			expectError: true,
 			validate: func(t *testing.T, result interface{}) {
-				// Should be handled as an error
+				expectInvalidParams(t, result)
 			},
 		},
 		{
-			name:        "invalid URI format",
-			uri:         "invalid://uri",
+			name:     "get_markdown_section negative context lines",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":     "sample.md",
+				"section_id":    "section_d34c2b1aa51dcbe1",
+				"context_lines": -1,
+			},
 			expectError: true,
 			validate: func(t *testing.T, result interface{}) {
-				// Should be handled as an error
+				expectInvalidParams(t, result)
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			params := map[string]interface{}{
-				"uri": tt.uri,
-			}
-
-			paramsJSON, _ := json.Marshal(params)
+		{
+			name:     "get_markdown_section unknown section",
+			toolName: "get_markdown_section",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"section_id": "section_0000000000000000",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectToolErrorCode(t, result, -32006)
+			},
+		},
+		{
+			name:     "get_markdown_structure missing file",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path": "nonexistent.md",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectToolErrorCode(t, result, -32002)
+			},
+		},
+		{
+			name:     "get_section_children",
+			toolName: "get_section_children",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"section_id": "section_0be89c366744b2c8",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var childrenResult struct {
+					Children []struct {
+						Title      string        `json:"title"`
+						ChildCount int           `json:"child_count"`
+						Children   []interface{} `json:"children"`
+					} `json:"children"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &childrenResult); err != nil {
+					t.Fatalf("Failed to parse children JSON: %v", err)
+				}
+
+				if len(childrenResult.Children) != 2 || childrenResult.Children[0].Title != "Background" || childrenResult.Children[1].Title != "Objectives" {
+					t.Errorf("Expected Background and Objectives, got %+v", childrenResult.Children)
+				}
+			},
+		},
+		{
+			name:     "get_section_children of a leaf section",
+			toolName: "get_section_children",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"section_id": "section_d34c2b1aa51dcbe1",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				if toolResult["isError"] == true {
+					t.Fatalf("Expected a leaf section to have no children rather than an error, got %v", toolResult)
+				}
+				content := toolResult["content"].([]interface{})
+				if text := content[0].(map[string]interface{})["text"].(string); !strings.Contains(text, `"children": []`) {
+					t.Errorf("Expected an empty children array, got %s", text)
+				}
+			},
+		},
+		{
+			name:     "get_sections_by_level",
+			toolName: "get_sections_by_level",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"level":     2,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var sectionsResult struct {
+					Sections []struct {
+						Title string `json:"title"`
+					} `json:"sections"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &sectionsResult); err != nil {
+					t.Fatalf("Failed to parse sections JSON: %v", err)
+				}
+
+				var titles []string
+				for _, section := range sectionsResult.Sections {
+					titles = append(titles, section.Title)
+				}
+				if strings.Join(titles, ", ") != "Introduction, Main Content, Conclusion" {
+					t.Errorf("Expected the H2 sections in document order, got %v", titles)
+				}
+			},
+		},
+		{
+			name:     "get_sections_by_level unused level",
+			toolName: "get_sections_by_level",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"level":     6,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				if toolResult["isError"] == true {
+					t.Fatalf("Expected an empty list rather than an error, got %v", toolResult)
+				}
+				content := toolResult["content"].([]interface{})
+				if text := content[0].(map[string]interface{})["text"].(string); !strings.Contains(text, `"sections": []`) {
+					t.Errorf("Expected an empty sections array, got %s", text)
+				}
+			},
+		},
+		{
+			name:     "get_sections_by_level out of range",
+			toolName: "get_sections_by_level",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"level":     7,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_range",
+			toolName: "get_markdown_range",
+			args: map[string]interface{}{
+				"file_path":       "sample.md",
+				"from_section_id": "section_0be89c366744b2c8",
+				"to_section_id":   "section_d534b335d8ce7848",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var sectionRange struct {
+					Content   string `json:"content"`
+					StartLine int    `json:"start_line"`
+					EndLine   int    `json:"end_line"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &sectionRange); err != nil {
+					t.Fatalf("Failed to parse range JSON: %v", err)
+				}
+
+				if sectionRange.StartLine != 5 || sectionRange.EndLine != 39 {
+					t.Errorf("Expected lines 5-39 from Introduction up to Conclusion, got %d-%d", sectionRange.StartLine, sectionRange.EndLine)
+				}
+				if !strings.HasPrefix(sectionRange.Content, "## Introduction") || !strings.Contains(sectionRange.Content, "## Main Content") || strings.Contains(sectionRange.Content, "## Conclusion") {
+					t.Errorf("Expected Introduction and Main Content without Conclusion, got %q", sectionRange.Content)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_range reversed",
+			toolName: "get_markdown_range",
+			args: map[string]interface{}{
+				"file_path":       "sample.md",
+				"from_section_id": "section_d534b335d8ce7848",
+				"to_section_id":   "section_0be89c366744b2c8",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_outline_depth",
+			toolName: "get_markdown_outline_depth",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var depth struct {
+					FilePath    string         `json:"file_path"`
+					MaxLevel    int            `json:"max_level"`
+					LevelCounts map[string]int `json:"level_counts"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &depth); err != nil {
+					t.Fatalf("Failed to parse outline depth JSON: %v", err)
+				}
+
+				if depth.FilePath != "sample.md" || depth.MaxLevel != 4 {
+					t.Errorf("Expected max level 4 for sample.md, got %+v", depth)
+				}
+				if !reflect.DeepEqual(depth.LevelCounts, map[string]int{"1": 1, "2": 3, "3": 6, "4": 2}) {
+					t.Errorf("Expected the sections per level of sample.md, got %v", depth.LevelCounts)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_section_by_path plain format",
+			toolName: "get_markdown_section_by_path",
+			args: map[string]interface{}{
+				"file_path":    "sample.md",
+				"section_path": "Sample Document/Introduction/Objectives",
+				"format":       "plain",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				text := content[0].(map[string]interface{})["text"].(string)
+
+				expected := "Objectives\n\nThe main objectives are:\n\nTest document structure parsing\nValidate section extraction\nEnsure proper hierarchy handling\n"
+				if text != expected {
+					t.Errorf("Expected heading and list markers to be stripped, got %q", text)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_section_by_path html format",
+			toolName: "get_markdown_section_by_path",
+			args: map[string]interface{}{
+				"file_path":    "sample.md",
+				"section_path": "Sample Document/Introduction/Background",
+				"format":       "html",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+
+				expected := "<h3>Background</h3>\n<p>Here we explain the background context.</p>\n"
+				if text := firstContent["text"].(string); text != expected {
+					t.Errorf("Expected %q, got %q", expected, text)
+				}
+				if mimeType := firstContent["mimeType"]; mimeType != "text/html" {
+					t.Errorf("Expected MIME type text/html, got %v", mimeType)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_sections",
+			toolName: "get_markdown_sections",
+			args: map[string]interface{}{
+				"file_path":   "sample.md",
+				"section_ids": []string{"section_d34c2b1aa51dcbe1", "missing", "section_0be89c366744b2c8"},
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				// Parse the JSON content
+				firstContent := content[0].(map[string]interface{})
+				var sectionsResult map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &sectionsResult); err != nil {
+					t.Fatalf("Failed to parse sections JSON: %v", err)
+				}
+
+				sections := sectionsResult["sections"].([]interface{})
+				if len(sections) != 3 {
+					t.Fatalf("Expected 3 section results, got %d", len(sections))
+				}
+
+				first := sections[0].(map[string]interface{})
+				if first["title"] != "Background" || !strings.HasPrefix(first["content"].(string), "### Background") {
+					t.Errorf("Expected Background section first, got %v", first)
+				}
+
+				missing := sections[1].(map[string]interface{})
+				if missing["requested_id"] != "missing" || missing["error"] == nil {
+					t.Errorf("Expected per-entry error for missing section, got %v", missing)
+				}
+
+				third := sections[2].(map[string]interface{})
+				if third["title"] != "Introduction" {
+					t.Errorf("Expected Introduction section last, got %v", third["title"])
+				}
+			},
+		},
+		{
+			name:     "get_markdown_stats",
+			toolName: "get_markdown_stats",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Error("Expected content in tool result")
+				}
+
+				// Parse the JSON content
+				firstContent := content[0].(map[string]interface{})
+				var stats map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &stats); err != nil {
+					t.Fatalf("Failed to parse stats JSON: %v", err)
+				}
+
+				if stats["total_chars"] == nil {
+					t.Error("Expected total_chars in stats")
+				}
+			},
+		},
+		{
+			name:     "get_markdown_stats with max_depth",
+			toolName: "get_markdown_stats",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"max_depth": 2,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				var stats struct {
+					SectionCount int            `json:"section_count"`
+					LevelCounts  map[string]int `json:"level_counts"`
+				}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &stats); err != nil {
+					t.Fatalf("Failed to parse stats JSON: %v", err)
+				}
+
+				// sample.md has one H1 and three H2 headings above its deeper ones
+				if stats.SectionCount != 4 {
+					t.Errorf("Expected 4 sections up to depth 2, got %d", stats.SectionCount)
+				}
+				if len(stats.LevelCounts) != 2 || stats.LevelCounts["1"] != 1 || stats.LevelCounts["2"] != 3 {
+					t.Errorf("Expected level counts for levels 1 and 2 only, got %v", stats.LevelCounts)
+				}
+			},
+		},
+		{
+			name:     "changed_sections",
+			toolName: "changed_sections",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"section_hashes": map[string]interface{}{
+					"section_d34c2b1aa51dcbe1": "0000000000000000",
+					"section_removed":          "0000000000000000",
+				},
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				var changes struct {
+					Changed []struct {
+						ID          string `json:"id"`
+						ContentHash string `json:"content_hash"`
+						PriorHash   string `json:"prior_hash"`
+					} `json:"changed"`
+					Added   []interface{} `json:"added"`
+					Removed []string      `json:"removed"`
+				}
+				if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &changes); err != nil {
+					t.Fatalf("Failed to parse changes JSON: %v", err)
+				}
+
+				// Background's recorded hash is stale, and the other 11
+				// sections of sample.md were not recorded at all
+				if len(changes.Changed) != 1 || changes.Changed[0].ID != "section_d34c2b1aa51dcbe1" || changes.Changed[0].PriorHash != "0000000000000000" || changes.Changed[0].ContentHash == "" {
+					t.Errorf("Expected Background changed, got %+v", changes.Changed)
+				}
+				if len(changes.Added) != 11 {
+					t.Errorf("Expected 11 added sections, got %d", len(changes.Added))
+				}
+				if len(changes.Removed) != 1 || changes.Removed[0] != "section_removed" {
+					t.Errorf("Expected section_removed removed, got %v", changes.Removed)
+				}
+			},
+		},
+		{
+			name:     "changed_sections invalid hash",
+			toolName: "changed_sections",
+			args: map[string]interface{}{
+				"file_path":      "sample.md",
+				"section_hashes": map[string]interface{}{"section_d34c2b1aa51dcbe1": 1},
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "diff_markdown_structure",
+			toolName: "diff_markdown_structure",
+			args: map[string]interface{}{
+				"old_file_path": "sample.md",
+				"new_file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				var diff map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &diff); err != nil {
+					t.Fatalf("Failed to parse diff JSON: %v", err)
+				}
+
+				// A file compared with itself has no differences
+				for _, key := range []string{"added", "removed", "changed"} {
+					entries, ok := diff[key].([]interface{})
+					if !ok || len(entries) != 0 {
+						t.Errorf("Expected empty %s, got %v", key, diff[key])
+					}
+				}
+				if diff["old_file"] != "sample.md" {
+					t.Errorf("Expected old_file sample.md, got %v", diff["old_file"])
+				}
+			},
+		},
+		{
+			name:     "lint_markdown",
+			toolName: "lint_markdown",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				var lint map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &lint); err != nil {
+					t.Fatalf("Failed to parse lint JSON: %v", err)
+				}
+
+				if _, ok := lint["issues"].([]interface{}); !ok {
+					t.Errorf("Expected issues array, got %v", lint["issues"])
+				}
+			},
+		},
+		{
+			name:     "list_markdown_files with pattern",
+			toolName: "list_markdown_files",
+			args: map[string]interface{}{
+				"pattern": "s*.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				var list map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &list); err != nil {
+					t.Fatalf("Failed to parse file list JSON: %v", err)
+				}
+
+				files, ok := list["files"].([]interface{})
+				if !ok {
+					t.Fatalf("Expected files array, got %v", list["files"])
+				}
+
+				var paths []string
+				for _, file := range files {
+					entry := file.(map[string]interface{})
+					paths = append(paths, entry["path"].(string))
+					if entry["size"].(float64) <= 0 {
+						t.Errorf("Expected positive size for %v", entry["path"])
+					}
+					if _, ok := entry["mod_time"].(string); !ok {
+						t.Errorf("Expected mod_time for %v", entry["path"])
+					}
+				}
+				if strings.Join(paths, ",") != "sample.md,setext.md" {
+					t.Errorf("Expected sample.md and setext.md, got %v", paths)
+				}
+			},
+		},
+		{
+			name:     "get_directory_structure toc_only",
+			toolName: "get_directory_structure",
+			args: map[string]interface{}{
+				"toc_only":  true,
+				"max_depth": 1,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				var directory map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &directory); err != nil {
+					t.Fatalf("Failed to parse directory structure JSON: %v", err)
+				}
+
+				files, ok := directory["files"].(map[string]interface{})
+				if !ok {
+					t.Fatalf("Expected files object, got %v", directory["files"])
+				}
+
+				// sample.md has a single H1
+				toc, ok := files["sample.md"].([]interface{})
+				if !ok || len(toc) != 1 {
+					t.Errorf("Expected one TOC entry for sample.md, got %v", files["sample.md"])
+				}
+			},
+		},
+		{
+			name:        "get_cache_stats",
+			toolName:    "get_cache_stats",
+			args:        map[string]interface{}{},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				// Parse the JSON content
+				firstContent := content[0].(map[string]interface{})
+				var stats map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &stats); err != nil {
+					t.Fatalf("Failed to parse cache stats JSON: %v", err)
+				}
+
+				if stats["max_size"] != float64(100) {
+					t.Errorf("Expected max_size 100, got %v", stats["max_size"])
+				}
+				if _, ok := stats["cached_files"].([]interface{}); !ok {
+					t.Errorf("Expected cached_files array, got %v", stats["cached_files"])
+				}
+
+				contentStats, ok := stats["content"].(map[string]interface{})
+				if !ok {
+					t.Fatalf("Expected content cache stats, got %v", stats["content"])
+				}
+				if contentStats["max_bytes"].(float64) <= 0 {
+					t.Errorf("Expected a positive content cache limit, got %v", contentStats["max_bytes"])
+				}
+			},
+		},
+		{
+			name:        "clear_cache",
+			toolName:    "clear_cache",
+			args:        map[string]interface{}{},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Fatal("Expected content in tool result")
+				}
+
+				firstContent := content[0].(map[string]interface{})
+				if !strings.Contains(firstContent["text"].(string), `"cleared": 0`) {
+					t.Errorf("Expected cleared count in result, got %v", firstContent["text"])
+				}
+			},
+		},
+		{
+			name:     "estimate_tokens",
+			toolName: "estimate_tokens",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"tokenizer": "words",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+
+				var estimate map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &estimate); err != nil {
+					t.Fatalf("Failed to parse estimate_tokens JSON: %v", err)
+				}
+				if estimate["tokenizer"] != "words" {
+					t.Errorf("Expected the words tokenizer, got %v", estimate["tokenizer"])
+				}
+				tokens, _ := estimate["token_estimate"].(float64)
+				chars, _ := estimate["char_count"].(float64)
+				if tokens <= 0 || tokens >= chars {
+					t.Errorf("Expected a token estimate between 0 and the %v chars, got %v", chars, tokens)
+				}
+			},
+		},
+		{
+			name:     "section_at_line",
+			toolName: "section_at_line",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"line":      20,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+
+				var located map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &located); err != nil {
+					t.Fatalf("Failed to parse section_at_line JSON: %v", err)
+				}
+				if located["title"] != "Main Content" || located["in_preamble"] != false {
+					t.Errorf("Expected line 20 in Main Content, got %v", located)
+				}
+			},
+		},
+		{
+			name:     "validate_path",
+			toolName: "validate_path",
+			args: map[string]interface{}{
+				"file_path": "../outside.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+
+				var check map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &check); err != nil {
+					t.Fatalf("Failed to parse validate_path JSON: %v", err)
+				}
+				if check["allowed"] != false || check["reason"] != "outside_base" {
+					t.Errorf("Expected a path refused as outside_base, got %v", check)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_skeleton",
+			toolName: "get_markdown_skeleton",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+				if firstContent["mimeType"] != "text/markdown" {
+					t.Errorf("Expected mimeType text/markdown, got %v", firstContent["mimeType"])
+				}
+
+				// Every line is a heading, and every heading is there
+				text := firstContent["text"].(string)
+				lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+				for _, line := range lines {
+					if !strings.HasPrefix(line, "#") {
+						t.Errorf("Expected only headings, got line %q", line)
+					}
+				}
+				for _, heading := range []string{"# Sample Document\n", "## Introduction\n", "### Background\n", "## Conclusion\n", "### References\n"} {
+					if !strings.Contains(text, heading) {
+						t.Errorf("Expected heading %q in skeleton %q", heading, text)
+					}
+				}
+				if len(lines) != 12 {
+					t.Errorf("Expected 12 headings, got %d", len(lines))
+				}
+			},
+		},
+		{
+			name:     "get_markdown_skeleton with max_depth",
+			toolName: "get_markdown_skeleton",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"max_depth": 2,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				text := content[0].(map[string]interface{})["text"].(string)
+				if text != "# Sample Document\n## Introduction\n## Main Content\n## Conclusion\n" {
+					t.Errorf("Unexpected skeleton up to H2: %q", text)
+				}
+			},
+		},
+		{
+			name:     "get_markdown_toc",
+			toolName: "get_markdown_toc",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				if len(content) == 0 {
+					t.Error("Expected content in tool result")
+				}
+
+				// Parse the JSON content
+				firstContent := content[0].(map[string]interface{})
+				var toc map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &toc); err != nil {
+					t.Fatalf("Failed to parse TOC JSON: %v", err)
+				}
+
+				if toc["toc"] == nil {
+					t.Error("Expected toc in result")
+				}
+			},
+		},
+		{
+			name:     "invalid tool",
+			toolName: "invalid_tool",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				if toolResult["isError"] != true {
+					t.Error("Expected isError to be true for invalid tool")
+				}
+			},
+		},
+		{
+			name:     "get_markdown_structure max_depth 0",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"max_depth": 0,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_structure ignore_levels out of range",
+			toolName: "get_markdown_structure",
+			args: map[string]interface{}{
+				"file_path":     "sample.md",
+				"ignore_levels": []int{5, 7},
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_lines string start_line",
+			toolName: "get_markdown_lines",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"start_line": "5",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_lines fractional start_line",
+			toolName: "get_markdown_lines",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"start_line": 2.7,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_lines start_line 0",
+			toolName: "get_markdown_lines",
+			args: map[string]interface{}{
+				"file_path":  "sample.md",
+				"start_line": 0,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_lines negative end_line",
+			toolName: "get_markdown_lines",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"end_line":  -1,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_toc max_depth 7",
+			toolName: "get_markdown_toc",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"max_depth": 7,
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_markdown_stats non-numeric max_depth",
+			toolName: "get_markdown_stats",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"max_depth": "two",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "search_markdown_content regex mode",
+			toolName: "search_markdown_content",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"query":     "^(Background|Objectives)$",
+				"mode":      "regex",
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+				var searchResult map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &searchResult); err != nil {
+					t.Fatalf("Failed to parse search result JSON: %v", err)
+				}
+
+				if count := searchResult["count"].(float64); count != 2 {
+					t.Errorf("Expected the anchored pattern to match 2 sections, got %v", count)
+				}
+			},
+		},
+		{
+			name:     "search_markdown_content body search with highlight",
+			toolName: "search_markdown_content",
+			args: map[string]interface{}{
+				"file_path":   "sample.md",
+				"query":       "background",
+				"search_body": true,
+			},
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				content := toolResult["content"].([]interface{})
+				firstContent := content[0].(map[string]interface{})
+				var searchResult struct {
+					Results []struct {
+						ID         string `json:"id"`
+						MatchCount int    `json:"match_count"`
+						Snippet    string `json:"snippet"`
+					} `json:"results"`
+				}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &searchResult); err != nil {
+					t.Fatalf("Failed to parse search result JSON: %v", err)
+				}
+
+				if len(searchResult.Results) != 1 {
+					t.Fatalf("Expected 1 body match, got %d", len(searchResult.Results))
+				}
+				match := searchResult.Results[0]
+				if match.ID == "" || match.MatchCount != 1 {
+					t.Errorf("Expected a section ID and one match, got %+v", match)
+				}
+				if match.Snippet != "Here we explain the **background** context." {
+					t.Errorf("Expected the match to be highlighted by default, got %q", match.Snippet)
+				}
+			},
+		},
+		{
+			name:     "search_markdown_content invalid regex",
+			toolName: "search_markdown_content",
+			args: map[string]interface{}{
+				"file_path": "sample.md",
+				"query":     "(Background",
+				"mode":      "regex",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				expectInvalidParams(t, result)
+			},
+		},
+		{
+			name:     "get_directory_structure outside base directory",
+			toolName: "get_directory_structure",
+			args: map[string]interface{}{
+				"directory": "..",
+			},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				if toolResult["isError"] != true {
+					t.Error("Expected isError to be true for a directory outside the base directory")
+				}
+
+				structured, ok := toolResult["structuredContent"].(map[string]interface{})
+				if !ok {
+					t.Fatal("Expected structured content with the error code")
+				}
+				toolError := structured["error"].(map[string]interface{})
+				if code := toolError["code"].(float64); code != -32001 {
+					t.Errorf("Expected access denied code -32001, got %v", code)
+				}
+			},
+		},
+		{
+			name:        "missing file_path",
+			toolName:    "get_markdown_structure",
+			args:        map[string]interface{}{},
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				toolResult := result.(map[string]interface{})
+				if toolResult["isError"] != true {
+					t.Error("Expected isError to be true for missing file_path")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"name":      tt.toolName,
+				"arguments": tt.args,
+			}
+
+			paramsJSON, _ := json.Marshal(params)
+			response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+				JSONRPC: "2.0",
+				ID:      1,
+				Method:  "tools/call",
+				Params:  paramsJSON,
+			})
+
+			if tt.expectError {
+				// For tool errors, the MCP response should be successful but the tool result should indicate error
+				if response.Error != nil {
+					t.Errorf("Expected no MCP error, got %v", response.Error)
+				}
+			} else {
+				if response.Error != nil {
+					t.Errorf("Expected no error, got %v", response.Error)
+				}
+			}
+
+			if tt.validate != nil {
+				tt.validate(t, response.Result)
+			}
+		})
+	}
+}
+
+func TestMCPServerOutlinePayloadSize(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	callTool := func(name string) string {
+		paramsJSON, _ := json.Marshal(map[string]interface{}{
+			"name":      name,
+			"arguments": map[string]interface{}{"file_path": "complex.md"},
+		})
+		response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+			JSONRPC: "2.0",
+			ID:      1,
+			Method:  "tools/call",
+			Params:  paramsJSON,
+		})
+		if response.Error != nil {
+			t.Fatalf("%s failed: %v", name, response.Error)
+		}
+		content := response.Result.(map[string]interface{})["content"].([]interface{})
+		return content[0].(map[string]interface{})["text"].(string)
+	}
+
+	structure, outline := callTool("get_markdown_structure"), callTool("get_markdown_outline")
+
+	var parsed struct {
+		Outline []struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"outline"`
+	}
+	if err := json.Unmarshal([]byte(outline), &parsed); err != nil {
+		t.Fatalf("Failed to parse outline JSON: %v", err)
+	}
+	if len(parsed.Outline) == 0 || parsed.Outline[0].Title != "Complex Document Structure" {
+		t.Errorf("Expected the outline of complex.md, got %+v", parsed.Outline)
+	}
+
+	if len(outline)*3 > len(structure) {
+		t.Errorf("Expected the outline to be under a third of the structure's size, got %d bytes against %d", len(outline), len(structure))
+	}
+}
+
+func TestMCPServerDirectorySortBy(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	// Weights put the files in the reverse of their alphabetical order
+	baseDir := t.TempDir()
+	for name, content := range map[string]string{
+		"a-intro.md":   "---\nweight: 30\n---\n# Intro\n",
+		"b-install.md": "---\nweight: 20\n---\n# Install\n",
+		"c-usage.md":   "---\nweight: 10\n---\n# Usage\n",
+	} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "get_directory_structure", "arguments": {"toc_only": true, "sort_by": "weight"}}}
+{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "get_directory_structure", "arguments": {"toc_only": true}}}
+`
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", baseDir)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+	var orders [][]string
+	for decoder.More() {
+		var response struct {
+			Result struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		var directory struct {
+			Order []string `json:"order"`
+		}
+		if len(response.Result.Content) == 0 {
+			t.Fatalf("Expected content in tool result: %s", output)
+		}
+		if err := json.Unmarshal([]byte(response.Result.Content[0].Text), &directory); err != nil {
+			t.Fatalf("Failed to parse directory structure JSON: %v", err)
+		}
+		orders = append(orders, directory.Order)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("Expected 2 responses, got %d: %s", len(orders), output)
+	}
+
+	expected := "c-usage.md,b-install.md,a-intro.md"
+	if got := strings.Join(orders[0], ","); got != expected {
+		t.Errorf("Expected weight order %s, got %s", expected, got)
+	}
+	expected = "a-intro.md,b-install.md,c-usage.md"
+	if got := strings.Join(orders[1], ","); got != expected {
+		t.Errorf("Expected filename order %s without sort_by, got %s", expected, got)
+	}
+}
+
+func TestMCPServerResourcesList(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "resources/list",
+	})
+
+	if response.Error != nil {
+		t.Fatalf("Expected no error, got %v", response.Error)
+	}
+
+	result, ok := response.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected result to be an object")
+	}
+
+	resources, ok := result["resources"].([]interface{})
+	if !ok {
+		t.Fatalf("Expected resources to be an array")
+	}
+
+	// Should have resources for our test files
+	if len(resources) == 0 {
+		t.Error("Expected at least one resource")
+	}
+
+	// Check that resources have proper structure
+	for _, resource := range resources {
+		resourceMap := resource.(map[string]interface{})
+		if resourceMap["uri"] == "" {
+			t.Error("Expected resource to have URI")
+		}
+		if resourceMap["name"] == "" {
+			t.Error("Expected resource to have name")
+		}
+	}
+}
+
+func TestMCPServerResourcesListPagination(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	listURIs := func(params string) ([]string, string, *MCPError) {
+		request := MCPRequest{
+			JSONRPC: "2.0",
+			ID:      1,
+			Method:  "resources/list",
+		}
+		if params != "" {
+			request.Params = json.RawMessage(params)
+		}
+
+		response := sendMCPRequest(t, projectRoot, binaryPath, request)
+		if response.Error != nil {
+			return nil, "", response.Error
+		}
+
+		result := response.Result.(map[string]interface{})
+		var uris []string
+		for _, resource := range result["resources"].([]interface{}) {
+			uris = append(uris, resource.(map[string]interface{})["uri"].(string))
+		}
+		nextCursor, _ := result["nextCursor"].(string)
+		return uris, nextCursor, nil
+	}
+
+	all, nextCursor, mcpErr := listURIs("")
+	if mcpErr != nil {
+		t.Fatalf("Expected no error, got %v", mcpErr)
+	}
+	if nextCursor != "" {
+		t.Fatalf("Expected no next cursor for the default page size, got %q", nextCursor)
+	}
+
+	// Walk the listing three resources at a time
+	var paged []string
+	cursor := ""
+	for page := 0; page < len(all); page++ {
+		params := `{"pageSize": 3}`
+		if cursor != "" {
+			params = fmt.Sprintf(`{"pageSize": 3, "cursor": %q}`, cursor)
+		}
+
+		uris, next, mcpErr := listURIs(params)
+		if mcpErr != nil {
+			t.Fatalf("Expected no error, got %v", mcpErr)
+		}
+		if len(uris) > 3 {
+			t.Fatalf("Expected at most 3 resources per page, got %d", len(uris))
+		}
+
+		paged = append(paged, uris...)
+		cursor = next
+		if cursor == "" {
+			break
+		}
+	}
+
+	if strings.Join(paged, ",") != strings.Join(all, ",") {
+		t.Errorf("Expected paged resources to match full listing\nfull:  %v\npaged: %v", all, paged)
+	}
+
+	if _, _, mcpErr := listURIs(`{"cursor": "not-a-cursor"}`); mcpErr == nil || mcpErr.Code != -32602 {
+		t.Errorf("Expected invalid params error for bad cursor, got %v", mcpErr)
+	}
+}
+
+func TestMCPServerAccessFlags(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	for _, args := range [][]string{
+		{"--allowed-exts", "md"},
+		{"--max-file-size", "0"},
+		{"--max-file-size", "lots"},
+		{"--follow-includes"},
+	} {
+		cmd := exec.Command(binaryPath, append([]string{"--mcp-server", "--base-dir", fixturesDir}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("Expected %v to fail, got output %s", args, output)
+		}
+	}
+
+	// Only .markdown files are listed; the fixtures are all .md
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--allowed-exts", ".markdown", "--max-file-size", "100MB")
+	cmd.Stdin = strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "resources/list"}` + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	var response MCPResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	resources, _ := response.Result.(map[string]interface{})["resources"].([]interface{})
+	if len(resources) != 0 {
+		t.Errorf("Expected no resources for .markdown only, got %d", len(resources))
+	}
+}
+
+func TestMCPServerStructureResourceParserOptions(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "get_markdown_structure", "arguments": {"file_path": "sample.md"}}}
+{"jsonrpc": "2.0", "id": 2, "method": "resources/read", "params": {"uri": "markdown://file/sample.md/structure"}}
+`
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--id-prefix", "doc_")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+	var ids []string
+	for decoder.More() {
+		var response struct {
+			Result struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+				Contents []struct {
+					Text string `json:"text"`
+				} `json:"contents"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		texts := append(response.Result.Content, response.Result.Contents...)
+		if len(texts) == 0 {
+			t.Fatalf("Expected content in response: %s", output)
+		}
+		var structure struct {
+			Structure []struct {
+				ID string `json:"id"`
+			} `json:"structure"`
+		}
+		if err := json.Unmarshal([]byte(texts[0].Text), &structure); err != nil {
+			t.Fatalf("Failed to parse structure JSON: %v", err)
+		}
+		if len(structure.Structure) == 0 {
+			t.Fatalf("Expected sections in structure: %s", texts[0].Text)
+		}
+		ids = append(ids, structure.Structure[0].ID)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 responses, got %d: %s", len(ids), output)
+	}
+
+	// The resource is parsed with the server's --id-prefix, as the tool is
+	if !strings.HasPrefix(ids[0], "doc_") || ids[1] != ids[0] {
+		t.Errorf("Expected the resource and tool to share the doc_ section ID, got %v", ids)
+	}
+}
+
+func TestMCPServerNoCache(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "get_markdown_structure", "arguments": {"file_path": "sample.md"}}}
+{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "get_cache_stats", "arguments": {}}}
+`
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--no-cache")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+	var responses []MCPResponse
+	for decoder.More() {
+		var response MCPResponse
+		if err := decoder.Decode(&response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d: %s", len(responses), output)
+	}
+
+	structureResult := responses[0].Result.(map[string]interface{})
+	if isError, _ := structureResult["isError"].(bool); isError {
+		t.Fatalf("Expected structure without cache, got %v", structureResult)
+	}
+
+	// The cache tools report that caching is disabled
+	statsResult := responses[1].Result.(map[string]interface{})
+	if isError, _ := statsResult["isError"].(bool); !isError {
+		t.Errorf("Expected get_cache_stats to fail with --no-cache, got %v", statsResult)
+	}
+}
+
+func TestMCPServerCacheFlags(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "get_cache_stats", "arguments": {}}}
+`
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--cache-size", "7", "--cache-ttl", "90s")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	var response MCPResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	content := response.Result.(map[string]interface{})["content"].([]interface{})
+	var stats struct {
+		MaxSize int           `json:"max_size"`
+		TTL     time.Duration `json:"ttl"`
+	}
+	if err := json.Unmarshal([]byte(content[0].(map[string]interface{})["text"].(string)), &stats); err != nil {
+		t.Fatalf("Failed to parse cache stats: %v", err)
+	}
+	if stats.MaxSize != 7 || stats.TTL != 90*time.Second {
+		t.Errorf("Expected a 7 entry cache with a 90s TTL, got %+v", stats)
+	}
+
+	// A cache that can hold nothing is refused
+	cmd = exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--cache-size", "0")
+	cmd.Stdin = strings.NewReader("")
+	if output, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(output), "cache size must be positive") {
+		t.Errorf("Expected --cache-size 0 to be rejected, got %v: %s", err, output)
+	}
+}
+
+func TestMCPServerRequestTimeout(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	// A document large enough that parsing it takes far longer than the timeout
+	baseDir := t.TempDir()
+	var builder strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&builder, "## Section %d\n\n%s\n", i, strings.Repeat("Some *emphasized* text with a [link](https://example.com). ", 3))
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "slow.md"), []byte(builder.String()), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "get_markdown_structure", "arguments": {"file_path": "slow.md"}}}` + "\n"
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", baseDir, "--request-timeout", "1ms")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	var response MCPResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Error == nil || response.Error.Code != -32005 {
+		t.Fatalf("Expected a -32005 timeout error, got %s", output)
+	}
+}
+
+func TestMCPServerResourcesRead(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	tests := []struct {
+		name        string
+		uri         string
+		expectError bool
+		errorCode   int
+		validate    func(t *testing.T, result interface{})
+	}{
+		{
+			name:        "structure resource",
+			uri:         "markdown://file/sample.md/structure",
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				resourceResult := result.(map[string]interface{})
+				contents := resourceResult["contents"].([]interface{})
+				if len(contents) == 0 {
+					t.Error("Expected contents in resource result")
+				}
+
+				firstContent := contents[0].(map[string]interface{})
+				if firstContent["type"] != "text" {
+					t.Error("Expected content type to be 'text'")
+				}
+
+				// Parse the JSON content
+				var structure map[string]interface{}
+				if err := json.Unmarshal([]byte(firstContent["text"].(string)), &structure); err != nil {
+					t.Fatalf("Failed to parse structure JSON: %v", err)
+				}
+
+				if structure["file_path"] == "" {
+					t.Error("Expected file_path in structure")
+				}
+			},
+		},
+		{
+			name:        "content resource",
+			uri:         "markdown://file/sample.md/content",
+			expectError: false,
+			validate: func(t *testing.T, result interface{}) {
+				resourceResult := result.(map[string]interface{})
+				contents := resourceResult["contents"].([]interface{})
+				if len(contents) == 0 {
+					t.Error("Expected contents in resource result")
+				}
+
+				firstContent := contents[0].(map[string]interface{})
+				if firstContent["type"] != "text" {
+					t.Error("Expected content type to be 'text'")
+				}
+
+				text := firstContent["text"].(string)
+				if !strings.Contains(text, "#") {
+					t.Error("Expected markdown content to contain headers")
+				}
+			},
+		},
+		{
+			name:        "invalid resource",
+			uri:         "markdown://file/nonexistent.md/structure",
+			expectError: true,
+			errorCode:   -32002,
+			validate: func(t *testing.T, result interface{}) {
+				// Should be handled as an error
+			},
+		},
+		{
+			name:        "invalid URI format",
+			uri:         "invalid://uri",
+			expectError: true,
+			validate: func(t *testing.T, result interface{}) {
+				// Should be handled as an error
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{
+				"uri": tt.uri,
+			}
+
+			paramsJSON, _ := json.Marshal(params)
+			response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+				JSONRPC: "2.0",
+				ID:      1,
+				Method:  "resources/read",
+				Params:  paramsJSON,
+			})
+
+			if tt.expectError {
+				if response.Error == nil {
+					t.Fatal("Expected error for invalid resource")
+				}
+				if tt.errorCode != 0 && response.Error.Code != tt.errorCode {
+					t.Errorf("Expected error code %d, got %d", tt.errorCode, response.Error.Code)
+				}
+			} else {
+				if response.Error != nil {
+					t.Errorf("Expected no error, got %v", response.Error)
+				}
+
+				if tt.validate != nil {
+					tt.validate(t, response.Result)
+				}
+			}
+		})
+	}
+}
+
+func TestMCPServerResourceSubscription(t *testing.T) {
+	_, binaryPath := setupTest(t)
+
+	baseDir := t.TempDir()
+	watchedFile := filepath.Join(baseDir, "watched.md")
+	if err := os.WriteFile(watchedFile, []byte("# Original\n"), 0644); err != nil {
+		t.Fatalf("Failed to create watched file: %v", err)
+	}
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", baseDir)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdin pipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdout pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	messages := make(chan map[string]interface{}, 10)
+	go func() {
+		decoder := json.NewDecoder(stdout)
+		for {
+			var message map[string]interface{}
+			if err := decoder.Decode(&message); err != nil {
+				close(messages)
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	nextMessage := func() map[string]interface{} {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				t.Fatal("MCP server closed stdout")
+			}
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for MCP server message")
+		}
+		return nil
+	}
+
+	uri := "markdown://file/watched.md/structure"
+	encoder := json.NewEncoder(stdin)
+	if err := encoder.Encode(MCPRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "resources/subscribe",
+		Params:  json.RawMessage(fmt.Sprintf(`{"uri": %q}`, uri)),
+	}); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	if response := nextMessage(); response["error"] != nil {
+		t.Fatalf("Expected no error subscribing, got %v", response["error"])
+	}
+
+	if err := os.WriteFile(watchedFile, []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify watched file: %v", err)
+	}
+
+	notification := nextMessage()
+	if notification["method"] != "notifications/resources/updated" {
+		t.Fatalf("Expected resource updated notification, got %v", notification)
+	}
+	params := notification["params"].(map[string]interface{})
+	if params["uri"] != uri {
+		t.Errorf("Expected notification for %s, got %v", uri, params["uri"])
+	}
+
+	// The structure must be re-read rather than served from the cache
+	if err := encoder.Encode(MCPRequest{
+		JSONRPC: "2.0",
+		ID:      2,
+		Method:  "resources/read",
+		Params:  json.RawMessage(fmt.Sprintf(`{"uri": %q}`, uri)),
+	}); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	// Writing the file may fire more than one notification; skip to the response
+	response := nextMessage()
+	for response["id"] == nil {
+		response = nextMessage()
+	}
+	data, _ := json.Marshal(response["result"])
+	if !strings.Contains(string(data), "Changed") {
+		t.Errorf("Expected updated structure after change, got %s", data)
+	}
+}
+
+func TestMCPServerNDJSONFraming(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--mcp-framing", "ndjson", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
+	cmd.Stdin = strings.NewReader(
+		`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n" +
+			`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}` + "\n")
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 response lines, got %d: %q", len(lines), output)
+	}
+
+	for i, line := range lines {
+		var response MCPResponse
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			t.Fatalf("Response line %d is not a single JSON object: %v", i+1, err)
+		}
+		if response.Error != nil {
+			t.Errorf("Expected no error on line %d, got %v", i+1, response.Error)
+		}
+		if response.ID != float64(i+1) {
+			t.Errorf("Expected response ID %d on line %d, got %v", i+1, i+1, response.ID)
+		}
+	}
+}
+
+func TestMCPServerHeaderFraming(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--mcp-framing", "header", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdin pipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdout pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	// Two queued messages in one write, then a third split across writes
+	first := frame(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}`) + frame(`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`)
+	third := frame(`{"jsonrpc": "2.0", "id": 3, "method": "ping"}`)
+	go func() {
+		stdin.Write([]byte(first))
+		stdin.Write([]byte(third[:10]))
+		time.Sleep(50 * time.Millisecond)
+		stdin.Write([]byte(third[10:30]))
+		time.Sleep(50 * time.Millisecond)
+		stdin.Write([]byte(third[30:]))
+		stdin.Close()
+	}()
+
+	reader := bufio.NewReader(stdout)
+	for id := 1; id <= 3; id++ {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read header of response %d: %v", id, err)
+		}
+		var length int
+		if _, err := fmt.Sscanf(header, "Content-Length: %d\r\n", &length); err != nil {
+			t.Fatalf("Expected Content-Length header, got %q", header)
+		}
+		if blank, _ := reader.ReadString('\n'); blank != "\r\n" {
+			t.Fatalf("Expected blank line after header, got %q", blank)
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatalf("Failed to read body of response %d: %v", id, err)
+		}
+
+		var response MCPResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatalf("Failed to parse response %d: %v", id, err)
+		}
+		if response.Error != nil || response.ID != float64(id) {
+			t.Errorf("Expected successful response with ID %d, got %+v", id, response)
+		}
+	}
+}
+
+func TestMCPServerBatchRequest(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
+	cmd.Stdin = strings.NewReader(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "ping"},
+		{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+	]
+	[]
+	`)
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+
+	var responses []MCPResponse
+	if err := decoder.Decode(&responses); err != nil {
+		t.Fatalf("Expected an array of responses: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(responses))
+	}
+	for i, response := range responses {
+		if response.Error != nil {
+			t.Errorf("Expected no error for request %d, got %v", i+1, response.Error)
+		}
+		if response.ID != float64(i+1) {
+			t.Errorf("Expected response ID %d, got %v", i+1, response.ID)
+		}
+	}
+	if _, ok := responses[1].Result.(map[string]interface{})["tools"]; !ok {
+		t.Error("Expected tools in tools/list response")
+	}
+
+	// An empty batch is answered with a single InvalidRequest error
+	var emptyBatch MCPResponse
+	if err := decoder.Decode(&emptyBatch); err != nil {
+		t.Fatalf("Expected a response to the empty batch: %v", err)
+	}
+	if emptyBatch.Error == nil || emptyBatch.Error.Code != -32600 {
+		t.Errorf("Expected InvalidRequest error for empty batch, got %+v", emptyBatch)
+	}
+}
+
+func TestMCPServerNotificationsGetNoResponse(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
+	cmd.Stdin = strings.NewReader(
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}` + "\n" +
+			`{"jsonrpc": "2.0", "method": "unknown_notification"}` + "\n" +
+			`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n")
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	// Only the ping is answered, so it must be the first and only response
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single response, got %d: %q", len(lines), output)
+	}
+
+	var response MCPResponse
+	if err := json.Unmarshal([]byte(lines[0]), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.ID != float64(1) || response.Error != nil {
+		t.Errorf("Expected ping response with ID 1, got %+v", response)
+	}
+}
+
+func TestMCPServerPrompts(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	listResponse := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "prompts/list",
+	})
+	if listResponse.Error != nil {
+		t.Fatalf("Expected no error, got %v", listResponse.Error)
+	}
+
+	prompts := listResponse.Result.(map[string]interface{})["prompts"].([]interface{})
+	names := make(map[string]bool)
+	for _, prompt := range prompts {
+		names[prompt.(map[string]interface{})["name"].(string)] = true
+	}
+	for _, name := range []string{"summarize_section", "toc_overview"} {
+		if !names[name] {
+			t.Errorf("Expected prompt %s in prompts/list", name)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		params       string
+		expectError  bool
+		expectedText string
+	}{
+		{
+			name:         "summarize section",
+			params:       `{"name": "summarize_section", "arguments": {"file_path": "sample.md", "section_id": "section_d34c2b1aa51dcbe1"}}`,
+			expectedText: "Here we explain the background context.",
+		},
+		{
+			name:         "toc overview",
+			params:       `{"name": "toc_overview", "arguments": {"file_path": "sample.md"}}`,
+			expectedText: "    - Technical Details",
+		},
+		{
+			name:        "missing section id",
+			params:      `{"name": "summarize_section", "arguments": {"file_path": "sample.md"}}`,
+			expectError: true,
+		},
+		{
+			name:        "unknown prompt",
+			params:      `{"name": "unknown_prompt"}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
 			response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
 				JSONRPC: "2.0",
-				ID:      1,
-				Method:  "resources/read",
-				Params:  paramsJSON,
+				ID:      2,
+				Method:  "prompts/get",
+				Params:  json.RawMessage(tt.params),
 			})
 
 			if tt.expectError {
-				if response.Error == nil {
-					t.Error("Expected error for invalid resource")
-				}
-			} else {
-				if response.Error != nil {
-					t.Errorf("Expected no error, got %v", response.Error)
+				if response.Error == nil || response.Error.Code != -32602 {
+					t.Errorf("Expected invalid params error, got %+v", response)
 				}
+				return
+			}
+			if response.Error != nil {
+				t.Fatalf("Expected no error, got %v", response.Error)
+			}
 
-				if tt.validate != nil {
-					tt.validate(t, response.Result)
-				}
+			messages := response.Result.(map[string]interface{})["messages"].([]interface{})
+			if len(messages) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(messages))
+			}
+			content := messages[0].(map[string]interface{})["content"].(map[string]interface{})
+			if !strings.Contains(content["text"].(string), tt.expectedText) {
+				t.Errorf("Expected prompt text to contain %q, got %q", tt.expectedText, content["text"])
 			}
 		})
 	}
 }
 
+func TestMCPServerLogging(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+	fixturesDir := filepath.Join(projectRoot, "tests", "fixtures")
+
+	// Logs go to stderr only, filtered by --log-level
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--log-level", "debug")
+	cmd.Stdin = strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "[debug] Handling ping") {
+		t.Errorf("Expected debug log on stderr, got %q", stderr.String())
+	}
+	if strings.Count(string(output), "\n") != 1 {
+		t.Errorf("Expected only the ping response on stdout, got %q", output)
+	}
+
+	cmd = exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--log-level", "error")
+	cmd.Stdin = strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no logs at error level, got %q", stderr.String())
+	}
+
+	// After logging/setLevel, records are also sent to the client
+	cmd = exec.Command(binaryPath, "--mcp-server", "--base-dir", fixturesDir, "--log-level", "error")
+	cmd.Stdin = strings.NewReader(
+		`{"jsonrpc": "2.0", "id": 1, "method": "logging/setLevel", "params": {"level": "debug"}}` + "\n" +
+			`{"jsonrpc": "2.0", "id": 2, "method": "ping"}` + "\n")
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("MCP server failed: %v", err)
+	}
+
+	var sawLogMessage bool
+	decoder := json.NewDecoder(strings.NewReader(string(output)))
+	for decoder.More() {
+		var message map[string]interface{}
+		if err := decoder.Decode(&message); err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		if message["method"] == "notifications/message" {
+			params := message["params"].(map[string]interface{})
+			if params["level"] == "debug" && strings.Contains(params["data"].(string), "ping") {
+				sawLogMessage = true
+			}
+		}
+	}
+	if !sawLogMessage {
+		t.Errorf("Expected a notifications/message log record, got %s", output)
+	}
+}
+
+func TestMCPServerGracefulShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM cannot be sent on Windows")
+	}
+	projectRoot, binaryPath := setupTest(t)
+
+	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdin pipe: %v", err)
+	}
+	defer stdin.Close()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdout pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+
+	request := `{"jsonrpc": "2.0", "id": %d, "method": "tools/call", "params": {"name": "get_markdown_structure", "arguments": {"file_path": "sample.md"}}}` + "\n"
+	if _, err := fmt.Fprintf(stdin, request, 1); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	decoder := json.NewDecoder(stdout)
+	var response MCPResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	// Signal right behind a second request, which is either answered in
+	// full or not read at all; stdin stays open, so only the signal ends
+	// the server
+	if _, err := fmt.Fprintf(stdin, request, 2); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to send SIGTERM: %v", err)
+	}
+
+	// The server closes stdout on exit, so reading the rest of the output
+	// also waits for it to stop
+	output := make(chan []byte, 1)
+	go func() {
+		rest, _ := io.ReadAll(io.MultiReader(decoder.Buffered(), stdout))
+		output <- rest
+	}()
+
+	var rest []byte
+	select {
+	case rest = <-output:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("MCP server did not exit after SIGTERM")
+	}
+
+	// Everything written after the first response must be complete messages
+	remaining := json.NewDecoder(strings.NewReader(string(rest)))
+	for remaining.More() {
+		var extra MCPResponse
+		if err := remaining.Decode(&extra); err != nil {
+			t.Fatalf("Expected only complete responses after SIGTERM, got %q: %v", rest, err)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("Expected a clean exit after SIGTERM, got %v", err)
+	}
+}
+
 func TestMCPServerPing(t *testing.T) {
 	projectRoot, binaryPath := setupTest(t)
 
@@ -521,6 +2533,45 @@ func TestMCPServerPing(t *testing.T) {
 	}
 }
 
+func TestMCPServerPingVerbose(t *testing.T) {
+	projectRoot, binaryPath := setupTest(t)
+
+	response := sendMCPRequest(t, projectRoot, binaryPath, MCPRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "ping",
+		Params:  json.RawMessage(`{"verbose": true}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("Expected no error, got %v", response.Error)
+	}
+
+	result, ok := response.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected result to be an object")
+	}
+
+	if result["status"] != "pong" {
+		t.Errorf("Expected status 'pong', got %v", result["status"])
+	}
+	if result["base_dir_readable"] != true {
+		t.Errorf("Expected a readable base directory, got %v", result)
+	}
+	if count, _ := result["file_count"].(float64); count < 5 {
+		t.Errorf("Expected the fixtures to be counted, got %v", result["file_count"])
+	}
+	if version, _ := result["version"].(string); version == "" {
+		t.Error("Expected a version")
+	}
+	if exts, _ := result["allowed_exts"].([]interface{}); len(exts) == 0 {
+		t.Error("Expected allowed extensions")
+	}
+	if _, ok := result["cache"].(map[string]interface{}); !ok {
+		t.Error("Expected structure cache statistics")
+	}
+}
+
 func TestMCPServerErrorHandling(t *testing.T) {
 	projectRoot, binaryPath := setupTest(t)
 
@@ -595,6 +2646,31 @@ func TestMCPServerErrorHandling(t *testing.T) {
 }
 
 // Helper function to send MCP request and get response
+// expectInvalidParams checks that a tool result is an error carrying the
+// InvalidParams code in its structured content
+func expectInvalidParams(t *testing.T, result interface{}) {
+	t.Helper()
+	expectToolErrorCode(t, result, -32602)
+}
+
+// expectToolErrorCode checks that a tool result is an error carrying code in
+// its structured content
+func expectToolErrorCode(t *testing.T, result interface{}, code float64) {
+	t.Helper()
+
+	toolResult := result.(map[string]interface{})
+	if toolResult["isError"] != true {
+		t.Fatalf("Expected isError to be true, got %v", toolResult)
+	}
+	structured, ok := toolResult["structuredContent"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected structured content with the error code")
+	}
+	if got := structured["error"].(map[string]interface{})["code"].(float64); got != code {
+		t.Errorf("Expected error code %v, got %v", code, got)
+	}
+}
+
 func sendMCPRequest(t *testing.T, projectRoot, binaryPath string, request MCPRequest) MCPResponse {
 	// Start MCP server
 	cmd := exec.Command(binaryPath, "--mcp-server", "--base-dir", filepath.Join(projectRoot, "tests", "fixtures"))
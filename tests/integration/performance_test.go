@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -82,7 +81,7 @@ func TestPerformanceStructureAnalysis(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			start := time.Now()
 
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			output, err := cmd.Output()
 
 			duration := time.Since(start)
@@ -120,7 +119,7 @@ func TestPerformanceSectionExtraction(t *testing.T) {
 	defer os.Remove(largeFile)
 
 	// Get structure to find section IDs
-	cmd := exec.Command(binaryPath, "structure", largeFile)
+	cmd := cliCommand(binaryPath, "structure", largeFile)
 	output, err := cmd.Output()
 	if err != nil {
 		t.Fatalf("Failed to get structure: %v", err)
@@ -168,7 +167,7 @@ func TestPerformanceSectionExtraction(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			start := time.Now()
 
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			output, err := cmd.Output()
 
 			duration := time.Since(start)
@@ -229,7 +228,7 @@ func TestPerformanceCommandOverhead(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			start := time.Now()
 
-			cmd := exec.Command(binaryPath, tt.args...)
+			cmd := cliCommand(binaryPath, tt.args...)
 			_, err := cmd.Output()
 
 			duration := time.Since(start)
@@ -272,7 +271,7 @@ func TestStressTestMultipleFiles(t *testing.T) {
 	start := time.Now()
 
 	for i, file := range testFiles {
-		cmd := exec.Command(binaryPath, "structure", file)
+		cmd := cliCommand(binaryPath, "structure", file)
 		output, err := cmd.Output()
 		if err != nil {
 			t.Fatalf("Failed to process file %d: %v", i, err)
@@ -327,7 +326,7 @@ func TestStressTestLargeFile(t *testing.T) {
 	// Test structure analysis
 	start := time.Now()
 
-	cmd := exec.Command(binaryPath, "structure", largeFile)
+	cmd := cliCommand(binaryPath, "structure", largeFile)
 	output, err := cmd.Output()
 
 	duration := time.Since(start)
@@ -372,7 +371,7 @@ func TestMemoryUsage(t *testing.T) {
 
 	// Test memory usage by running the same command multiple times
 	for i := 0; i < 5; i++ {
-		cmd := exec.Command(binaryPath, "structure", largeFile)
+		cmd := cliCommand(binaryPath, "structure", largeFile)
 		output, err := cmd.Output()
 		if err != nil {
 			t.Fatalf("Command failed on iteration %d: %v", i, err)